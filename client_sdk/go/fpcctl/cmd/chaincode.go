@@ -0,0 +1,366 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/fab/ccpackager"
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/sgx"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/resmgmt"
+	"github.com/hyperledger/fabric/common/policydsl"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	chaincodeCmd = &cobra.Command{
+		Use:   "chaincode",
+		Short: "Package, install, approve and commit FPC chaincodes",
+	}
+
+	ccPackageCmd = &cobra.Command{
+		Use:   "package <output_file>",
+		Short: "Create a FPC chaincode package from the enclave artifacts",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runPackage,
+	}
+
+	ccInstallCmd = &cobra.Command{
+		Use:   "install <package_file>",
+		Short: "Install a FPC chaincode package at the peers",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runInstall,
+	}
+
+	ccApproveCmd = &cobra.Command{
+		Use:   "approve",
+		Short: "Approve a FPC chaincode definition for the organization",
+		Long: `Approve a FPC chaincode definition for the organization.
+The version of a FPC chaincode definition is the mrenclave of the chaincode enclave. If a package file is given,
+the package ID and the version are taken from the package; verify the package with 'fpcctl package verify' first.`,
+		Args: cobra.NoArgs,
+		RunE: runApprove,
+	}
+
+	ccCommitCmd = &cobra.Command{
+		Use:   "commit",
+		Short: "Commit a FPC chaincode definition on the channel",
+		Args:  cobra.NoArgs,
+		RunE:  runCommit,
+	}
+
+	ccOpts chaincodeOptions
+)
+
+type chaincodeOptions struct {
+	// package
+	lang    string
+	label   string
+	path    string
+	sgxMode string
+
+	// install, approve and commit
+	peers   []string
+	orderer string
+
+	// chaincode definition
+	name      string
+	version   string
+	sequence  int64
+	policy    string
+	packageID string
+	pkgFile   string
+}
+
+// mrenclavePattern matches the version of FPC chaincode definitions
+var mrenclavePattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+func init() {
+	ccPackageCmd.Flags().StringVarP(&ccOpts.lang, "lang", "l", ccpackager.ChaincodeType, "the package type: fpc-c, fpc-go, or external")
+	ccPackageCmd.Flags().StringVar(&ccOpts.label, "label", "", "the package label")
+	ccPackageCmd.Flags().StringVarP(&ccOpts.path, "path", "p", "", "the directory containing the enclave artifacts")
+	ccPackageCmd.Flags().StringVar(&ccOpts.sgxMode, "sgx-mode", "", "the SGX mode (SIM or HW), defaults to $SGX_MODE")
+
+	ccInstallCmd.Flags().StringSliceVar(&ccOpts.peers, "peers", nil, "the peers to install the package at (default: all peers of the organization)")
+
+	for _, cmd := range []*cobra.Command{ccApproveCmd, ccCommitCmd} {
+		cmd.Flags().StringVarP(&ccOpts.name, "name", "n", "", "the chaincode ID")
+		cmd.Flags().StringVarP(&ccOpts.version, "version", "v", "", "the chaincode version, i.e., the mrenclave")
+		cmd.Flags().Int64Var(&ccOpts.sequence, "sequence", 1, "the sequence number of the chaincode definition")
+		cmd.Flags().StringVar(&ccOpts.policy, "signature-policy", "", "the validation endorsement policy of the chaincode")
+		cmd.Flags().StringSliceVar(&ccOpts.peers, "peers", nil, "the peers to endorse the transaction")
+		cmd.Flags().StringVar(&ccOpts.orderer, "orderer", "", "the orderer to submit the transaction to")
+	}
+	ccApproveCmd.Flags().StringVar(&ccOpts.packageID, "package-id", "", "the ID of the installed package")
+	ccApproveCmd.Flags().StringVar(&ccOpts.pkgFile, "package", "", "the package file to take the package ID and version from")
+
+	chaincodeCmd.AddCommand(ccPackageCmd, ccInstallCmd, ccApproveCmd, ccCommitCmd)
+	addNetworkFlags(chaincodeCmd)
+	rootCmd.AddCommand(chaincodeCmd)
+}
+
+// packageResult is the output of the chaincode package and install commands
+type packageResult struct {
+	File      string   `json:"file"`
+	PackageID string   `json:"package_id"`
+	Label     string   `json:"label"`
+	Mrenclave string   `json:"mrenclave,omitempty"`
+	Peers     []string `json:"peers,omitempty"`
+}
+
+// txResult is the output of commands that submit a transaction
+type txResult struct {
+	TxID string `json:"tx_id"`
+}
+
+func runPackage(cmd *cobra.Command, args []string) error {
+	sgxMode := ccOpts.sgxMode
+	if sgxMode == "" {
+		sgxMode = os.Getenv(sgx.SGXModeEnvKey)
+	}
+
+	pkgBytes, err := ccpackager.NewCCPackage(&ccpackager.Descriptor{
+		Type:    ccOpts.lang,
+		Label:   ccOpts.label,
+		Path:    ccOpts.path,
+		SGXMode: sgxMode,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(args[0], pkgBytes, 0644); err != nil {
+		return err
+	}
+
+	result, err := newPackageResult(args[0], pkgBytes)
+	if err != nil {
+		return err
+	}
+	return printPackageResult(cmd, result)
+}
+
+func runInstall(cmd *cobra.Command, args []string) error {
+	pkgBytes, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	result, err := newPackageResult(args[0], pkgBytes)
+	if err != nil {
+		return err
+	}
+
+	net, err := connect()
+	if err != nil {
+		return err
+	}
+	defer net.Close()
+
+	admin, err := net.ChaincodeAdmin()
+	if err != nil {
+		return err
+	}
+
+	var opts []resmgmt.RequestOption
+	if len(ccOpts.peers) != 0 {
+		opts = append(opts, resmgmt.WithTargetEndpoints(ccOpts.peers...))
+	}
+
+	responses, err := admin.LifecycleInstallCC(resmgmt.LifecycleInstallCCRequest{
+		Label:   result.Label,
+		Package: pkgBytes,
+	}, opts...)
+	if err != nil {
+		return errors.Wrap(err, "failed to install chaincode package")
+	}
+
+	for _, response := range responses {
+		if response.PackageID != result.PackageID {
+			return errors.Errorf("peer %s returned unexpected package ID %s", response.Target, response.PackageID)
+		}
+		result.Peers = append(result.Peers, response.Target)
+	}
+
+	return printPackageResult(cmd, result)
+}
+
+func runApprove(cmd *cobra.Command, args []string) error {
+	if ccOpts.pkgFile != "" {
+		if err := definitionFromPackage(ccOpts.pkgFile); err != nil {
+			return err
+		}
+	}
+
+	if ccOpts.packageID == "" {
+		return errors.New("a package ID is required, use --package-id or --package")
+	}
+
+	policy, err := checkDefinition()
+	if err != nil {
+		return err
+	}
+
+	net, err := connect()
+	if err != nil {
+		return err
+	}
+	defer net.Close()
+
+	admin, err := net.ChaincodeAdmin()
+	if err != nil {
+		return err
+	}
+
+	txID, err := admin.LifecycleApproveCC(netOpts.channel, resmgmt.LifecycleApproveCCRequest{
+		Name:            ccOpts.name,
+		Version:         ccOpts.version,
+		PackageID:       ccOpts.packageID,
+		Sequence:        ccOpts.sequence,
+		SignaturePolicy: policy,
+	}, submitOptions()...)
+	if err != nil {
+		return errors.Wrap(err, "failed to approve chaincode definition")
+	}
+
+	return printTxResult(cmd, "approved", string(txID))
+}
+
+func runCommit(cmd *cobra.Command, args []string) error {
+	policy, err := checkDefinition()
+	if err != nil {
+		return err
+	}
+
+	net, err := connect()
+	if err != nil {
+		return err
+	}
+	defer net.Close()
+
+	admin, err := net.ChaincodeAdmin()
+	if err != nil {
+		return err
+	}
+
+	txID, err := admin.LifecycleCommitCC(netOpts.channel, resmgmt.LifecycleCommitCCRequest{
+		Name:            ccOpts.name,
+		Version:         ccOpts.version,
+		Sequence:        ccOpts.sequence,
+		SignaturePolicy: policy,
+	}, submitOptions()...)
+	if err != nil {
+		return errors.Wrap(err, "failed to commit chaincode definition")
+	}
+
+	return printTxResult(cmd, "committed", string(txID))
+}
+
+func newPackageResult(file string, pkgBytes []byte) (*packageResult, error) {
+	pkg, err := ccpackager.ReadCCPackage(pkgBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &packageResult{File: file, PackageID: pkg.ID, Label: pkg.Metadata.Label}
+	if pkg.HasMrenclave() {
+		if result.Mrenclave, err = pkg.Mrenclave(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// definitionFromPackage sets the package ID and the version of the chaincode definition from the given package
+func definitionFromPackage(file string) error {
+	pkgBytes, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	result, err := newPackageResult(file, pkgBytes)
+	if err != nil {
+		return err
+	}
+
+	if ccOpts.packageID != "" && ccOpts.packageID != result.PackageID {
+		return errors.Errorf("package ID %s does not match package %s", ccOpts.packageID, result.PackageID)
+	}
+	if ccOpts.version != "" && ccOpts.version != result.Mrenclave {
+		return errors.Errorf("version %s does not match package mrenclave %s", ccOpts.version, result.Mrenclave)
+	}
+
+	ccOpts.packageID = result.PackageID
+	ccOpts.version = result.Mrenclave
+	return nil
+}
+
+// checkDefinition checks the FPC requirements of the chaincode definition and returns the parsed signature policy
+func checkDefinition() (*common.SignaturePolicyEnvelope, error) {
+	if ccOpts.name == "" {
+		return nil, errors.New("a chaincode ID is required, use --name")
+	}
+
+	if !mrenclavePattern.MatchString(ccOpts.version) {
+		return nil, errors.Errorf("invalid version '%s': the version of a FPC chaincode must be its mrenclave", ccOpts.version)
+	}
+
+	if ccOpts.sequence < 1 {
+		return nil, errors.New("the sequence must be at least 1")
+	}
+
+	if ccOpts.policy == "" {
+		return nil, nil
+	}
+
+	policy, err := policydsl.FromString(ccOpts.policy)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid signature policy")
+	}
+	return policy, nil
+}
+
+func submitOptions() []resmgmt.RequestOption {
+	var opts []resmgmt.RequestOption
+	if len(ccOpts.peers) != 0 {
+		opts = append(opts, resmgmt.WithTargetEndpoints(ccOpts.peers...))
+	}
+	if ccOpts.orderer != "" {
+		opts = append(opts, resmgmt.WithOrdererEndpoint(ccOpts.orderer))
+	}
+	return opts
+}
+
+func printPackageResult(cmd *cobra.Command, result *packageResult) error {
+	out := cmd.OutOrStdout()
+	if jsonOutput {
+		return printJSON(out, result)
+	}
+
+	fmt.Fprintf(out, "Package:                    %s\n", result.File)
+	fmt.Fprintf(out, "Package ID:                 %s\n", result.PackageID)
+	fmt.Fprintf(out, "Label:                      %s\n", result.Label)
+	fmt.Fprintf(out, "Mrenclave:                  %s\n", result.Mrenclave)
+	for _, peer := range result.Peers {
+		fmt.Fprintf(out, "Installed at:               %s\n", peer)
+	}
+	return nil
+}
+
+func printTxResult(cmd *cobra.Command, action string, txID string) error {
+	out := cmd.OutOrStdout()
+	if jsonOutput {
+		return printJSON(out, &txResult{TxID: txID})
+	}
+
+	fmt.Fprintf(out, "Chaincode definition %s (txID: %s)\n", action, txID)
+	return nil
+}
@@ -0,0 +1,92 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/resmgmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaincodeDeploy(t *testing.T) {
+	net := useFakeNetwork(t)
+	pkgFile := filepath.Join(t.TempDir(), "mycc.tar.gz")
+
+	// package
+	out, err := runCommand(t, "", "chaincode", "package", pkgFile, "--lang", "fpc-go", "--label", "mycc", "--path", newBuildDir(t, "some binary"), "--sgx-mode", "SIM", "--json")
+	require.NoError(t, err)
+	pkg := &packageResult{}
+	require.NoError(t, json.Unmarshal([]byte(out), pkg))
+	assert.Equal(t, "mycc", pkg.Label)
+	assert.Equal(t, testMrenclave, pkg.Mrenclave)
+	assert.FileExists(t, pkgFile)
+
+	pkgBytes, err := os.ReadFile(pkgFile)
+	require.NoError(t, err)
+
+	// install
+	net.admin.installResp = []resmgmt.LifecycleInstallCCResponse{
+		{Target: "peer0.org1.example.com:7051", PackageID: pkg.PackageID},
+		{Target: "peer1.org1.example.com:7051", PackageID: pkg.PackageID},
+	}
+	out, err = runCommand(t, "", "chaincode", "install", pkgFile, "--peers", "peer0.org1.example.com:7051,peer1.org1.example.com:7051")
+	require.NoError(t, err)
+	assert.Equal(t, "mycc", net.admin.installReq.Label)
+	assert.Equal(t, pkgBytes, net.admin.installReq.Package)
+	assert.Equal(t, 1, net.admin.optionsCount)
+	assert.Contains(t, out, "Package ID:                 "+pkg.PackageID)
+	assert.Contains(t, out, "Installed at:               peer1.org1.example.com:7051")
+
+	net.admin.installResp[1].PackageID = "mycc:other"
+	_, err = runCommand(t, "", "chaincode", "install", pkgFile)
+	assert.EqualError(t, err, "peer peer1.org1.example.com:7051 returned unexpected package ID mycc:other")
+	assert.Equal(t, 0, net.admin.optionsCount)
+
+	// approve with package ID and version from the package
+	out, err = runCommand(t, "", "chaincode", "approve", "-C", "otherchannel", "-n", "mycc", "--package", pkgFile,
+		"--signature-policy", "OR('Org1MSP.peer')", "--orderer", "orderer.example.com:7050", "--json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"tx_id": "approveTxID"}`, out)
+	assert.Equal(t, "otherchannel", net.admin.channelID)
+	assert.Equal(t, "mycc", net.admin.approveReq.Name)
+	assert.Equal(t, testMrenclave, net.admin.approveReq.Version)
+	assert.Equal(t, pkg.PackageID, net.admin.approveReq.PackageID)
+	assert.Equal(t, int64(1), net.admin.approveReq.Sequence)
+	assert.NotNil(t, net.admin.approveReq.SignaturePolicy)
+	assert.Equal(t, 1, net.admin.optionsCount)
+
+	_, err = runCommand(t, "", "chaincode", "approve", "-n", "mycc", "--package", pkgFile, "--version", "some other version")
+	assert.EqualError(t, err, "version some other version does not match package mrenclave "+testMrenclave)
+
+	_, err = runCommand(t, "", "chaincode", "approve", "-n", "mycc", "--package-id", pkg.PackageID, "--version", "1.0")
+	assert.EqualError(t, err, "invalid version '1.0': the version of a FPC chaincode must be its mrenclave")
+
+	_, err = runCommand(t, "", "chaincode", "approve", "-n", "mycc", "--version", testMrenclave)
+	assert.EqualError(t, err, "a package ID is required, use --package-id or --package")
+
+	_, err = runCommand(t, "", "chaincode", "approve", "-n", "mycc", "--package", pkgFile, "--signature-policy", "OR(")
+	assert.ErrorContains(t, err, "invalid signature policy")
+
+	// commit
+	out, err = runCommand(t, "", "chaincode", "commit", "-n", "mycc", "--version", testMrenclave, "--sequence", "2", "--peers", "peer0.org1.example.com:7051,peer0.org2.example.com:7051")
+	require.NoError(t, err)
+	assert.Equal(t, "Chaincode definition committed (txID: commitTxID)\n", out)
+	assert.Equal(t, "mychannel", net.admin.channelID)
+	assert.Equal(t, testMrenclave, net.admin.commitReq.Version)
+	assert.Equal(t, int64(2), net.admin.commitReq.Sequence)
+	assert.Nil(t, net.admin.commitReq.SignaturePolicy)
+
+	_, err = runCommand(t, "", "chaincode", "commit", "--version", testMrenclave)
+	assert.EqualError(t, err, "a chaincode ID is required, use --name")
+
+	assert.Equal(t, 4, net.closed)
+}
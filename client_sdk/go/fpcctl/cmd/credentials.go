@@ -0,0 +1,315 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	ercc "github.com/hyperledger/fabric-private-chaincode/ercc/attestation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/cvm"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/simulation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	credentialsCmd = &cobra.Command{
+		Use:   "credentials",
+		Short: "Work with FPC enclave credentials",
+	}
+
+	inspectCmd = &cobra.Command{
+		Use:   "inspect [credentials_file]",
+		Short: "Decode and pretty-print enclave credentials",
+		Long: `Decode and pretty-print enclave credentials as returned by __initEnclave and passed to registerEnclave.
+The credentials are read as base64 string from the given file or from stdin if no file (or '-') is given.
+If an expected mrenclave is provided, the credentials are verified the same way as ERCC does at registration.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runInspect,
+	}
+
+	expectedMrenclave  string
+	convertAttestation bool
+)
+
+func init() {
+	inspectCmd.Flags().StringVar(&expectedMrenclave, "mrenclave", "", "verify the credentials against the expected mrenclave")
+	inspectCmd.Flags().BoolVar(&convertAttestation, "convert", false, "convert the attestation to evidence before inspection (may contact the attestation service)")
+	credentialsCmd.AddCommand(inspectCmd)
+	rootCmd.AddCommand(credentialsCmd)
+}
+
+// credentialsInfo contains the decoded content of enclave credentials
+type credentialsInfo struct {
+	EnclaveId              string                 `json:"enclave_id"`
+	ChaincodeParams        *protos.CCParameters   `json:"chaincode_params"`
+	HostParams             *protos.HostParameters `json:"host_params"`
+	ChaincodeEkFingerprint string                 `json:"chaincode_ek_fingerprint"`
+	Attestation            *attestationInfo       `json:"attestation,omitempty"`
+	Evidence               *attestationInfo       `json:"evidence,omitempty"`
+	// Verified is only set if the credentials were verified against an expected mrenclave
+	Verified *bool `json:"verified,omitempty"`
+}
+
+// attestationInfo contains the platform specific fields of an attestation or evidence
+type attestationInfo struct {
+	Type string `json:"type"`
+	// Measurement is the code identity, i.e., mrenclave or the launch measurement of a confidential VM
+	Measurement string `json:"measurement,omitempty"`
+	// StatementBound is true if the report data binds the attested data
+	StatementBound bool              `json:"statement_bound"`
+	Properties     map[string]string `json:"properties,omitempty"`
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+	input, err := readInput(cmd.InOrStdin(), args)
+	if err != nil {
+		return err
+	}
+
+	credentialsBase64 := strings.TrimSpace(string(input))
+	if convertAttestation {
+		credentialsBase64, err = attestation.NewDefaultCredentialConverter().ConvertCredentials(credentialsBase64)
+		if err != nil {
+			return err
+		}
+	}
+
+	credentials, err := utils.UnmarshalCredentials(credentialsBase64)
+	if err != nil {
+		return errors.Wrap(err, "cannot decode credentials")
+	}
+
+	info, err := inspectCredentials(credentials)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(expectedMrenclave) == 0 {
+		if jsonOutput {
+			return printJSON(out, info)
+		}
+		printCredentialsInfo(out, info)
+		return nil
+	}
+
+	err = verifyCredentials(credentials, info, expectedMrenclave)
+	if jsonOutput {
+		verified := err == nil
+		info.Verified = &verified
+		if err := printJSON(out, info); err != nil {
+			return err
+		}
+		if !verified {
+			return errors.Wrap(err, "verification failed")
+		}
+		return nil
+	}
+
+	printCredentialsInfo(out, info)
+	if err != nil {
+		fmt.Fprintf(out, "\nVerification:               FAILED (%s)\n", err)
+		return errors.Wrap(err, "verification failed")
+	}
+	fmt.Fprintf(out, "\nVerification:               OK\n")
+	return nil
+}
+
+func readInput(stdin io.Reader, args []string) ([]byte, error) {
+	if len(args) == 0 || args[0] == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(args[0])
+}
+
+func inspectCredentials(credentials *protos.Credentials) (*credentialsInfo, error) {
+	attestedData, err := utils.UnmarshalAttestedData(credentials.SerializedAttestedData)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode attested data")
+	}
+
+	ekHash := sha256.Sum256(attestedData.ChaincodeEk)
+	info := &credentialsInfo{
+		EnclaveId:              utils.GetEnclaveId(attestedData),
+		ChaincodeParams:        attestedData.CcParams,
+		HostParams:             attestedData.HostParams,
+		ChaincodeEkFingerprint: hex.EncodeToString(ekHash[:]),
+	}
+
+	statement := credentials.SerializedAttestedData.GetValue()
+
+	if len(credentials.Attestation) != 0 {
+		att := &types.Attestation{}
+		if err := json.Unmarshal(credentials.Attestation, att); err != nil {
+			return nil, errors.Wrap(err, "cannot decode attestation")
+		}
+		if info.Attestation, err = inspectAttestation(att.Type, att.Data, statement); err != nil {
+			return nil, errors.Wrap(err, "cannot inspect attestation")
+		}
+	}
+
+	if len(credentials.Evidence) != 0 {
+		evidence := &types.Evidence{}
+		if err := json.Unmarshal(credentials.Evidence, evidence); err != nil {
+			return nil, errors.Wrap(err, "cannot decode evidence")
+		}
+		if info.Evidence, err = inspectEvidence(evidence.Type, evidence.Data, statement); err != nil {
+			return nil, errors.Wrap(err, "cannot inspect evidence")
+		}
+	}
+
+	return info, nil
+}
+
+// inspectAttestation decodes the attestation as issued by the enclave
+func inspectAttestation(attestationType, data string, statement []byte) (*attestationInfo, error) {
+	switch attestationType {
+	case epid.LinkableType, epid.UnlinkableType:
+		quote, err := epid.ParseQuote(data)
+		if err != nil {
+			return nil, err
+		}
+		return quoteInfo(attestationType, quote, statement), nil
+	default:
+		// all other attestation types are passed unmodified as evidence
+		return inspectEvidence(attestationType, data, statement)
+	}
+}
+
+// inspectEvidence decodes the evidence as verified by ERCC
+func inspectEvidence(evidenceType, data string, statement []byte) (*attestationInfo, error) {
+	switch evidenceType {
+	case simulation.SimulationType:
+		return &attestationInfo{Type: evidenceType}, nil
+
+	case epid.LinkableType, epid.UnlinkableType:
+		report := &epid.IASReport{}
+		if err := json.Unmarshal([]byte(data), report); err != nil {
+			return nil, errors.Wrap(err, "cannot decode ias report")
+		}
+		body := &epid.IASResponseBody{}
+		if err := json.Unmarshal([]byte(report.Body), body); err != nil {
+			return nil, errors.Wrap(err, "cannot decode ias report body")
+		}
+		quote, err := epid.ParseQuoteBody(body.IsvEnclaveQuoteBody)
+		if err != nil {
+			return nil, err
+		}
+		info := quoteInfo(evidenceType, quote, statement)
+		info.Properties["quote_status"] = body.IsvEnclaveQuoteStatus
+		info.Properties["timestamp"] = body.Timestamp
+		if len(body.AdvisoryIDs) != 0 {
+			info.Properties["advisory_ids"] = strings.Join(body.AdvisoryIDs, ",")
+		}
+		return info, nil
+
+	case cvm.TDXType, cvm.SEVSNPType:
+		_, report, err := cvm.UnmarshalSignedReport([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		info := &attestationInfo{
+			Type:           evidenceType,
+			Measurement:    report.LaunchMeasurement,
+			StatementBound: strings.EqualFold(report.ReportData, hex.EncodeToString(cvm.ReportData(statement))),
+			Properties:     map[string]string{"platform": report.Platform},
+		}
+		for name, value := range report.RuntimeMeasurements {
+			info.Properties[name] = value
+		}
+		return info, nil
+
+	default:
+		return nil, fmt.Errorf("unknown attestation type '%s'", evidenceType)
+	}
+}
+
+func quoteInfo(attestationType string, quote *epid.QuoteBody, statement []byte) *attestationInfo {
+	expectedReportData := make([]byte, len(quote.ReportData))
+	h := sha256.Sum256(statement)
+	copy(expectedReportData, h[:])
+
+	return &attestationInfo{
+		Type:           attestationType,
+		Measurement:    quote.Mrenclave,
+		StatementBound: bytes.Equal(quote.ReportData, expectedReportData),
+		Properties: map[string]string{
+			"mrsigner":    quote.Mrsigner,
+			"isv_prod_id": fmt.Sprint(quote.IsvProdId),
+			"isv_svn":     fmt.Sprint(quote.IsvSvn),
+			"debug":       fmt.Sprint(quote.Debug),
+			"linkable":    fmt.Sprint(quote.Linkable),
+			"report_data": hex.EncodeToString(quote.ReportData),
+		},
+	}
+}
+
+// verifyCredentials performs the credential checks of ERCC that do not depend on the ledger state
+func verifyCredentials(credentials *protos.Credentials, info *credentialsInfo, mrenclave string) error {
+	if info.ChaincodeParams.GetVersion() != mrenclave {
+		return fmt.Errorf("mrenclave does not match chaincode params version")
+	}
+
+	if len(credentials.Evidence) == 0 {
+		return fmt.Errorf("credentials contain no evidence, use --convert")
+	}
+
+	return ercc.GetAvailableVerifier().VerifyCredentials(credentials, mrenclave)
+}
+
+func printCredentialsInfo(w io.Writer, info *credentialsInfo) {
+	fmt.Fprintf(w, "Enclave ID:                 %s\n", info.EnclaveId)
+	fmt.Fprintf(w, "Chaincode EK (sha256):      %s\n", info.ChaincodeEkFingerprint)
+	fmt.Fprintf(w, "Chaincode params:\n")
+	fmt.Fprintf(w, "  Chaincode ID:             %s\n", info.ChaincodeParams.GetChaincodeId())
+	fmt.Fprintf(w, "  Version (mrenclave):      %s\n", info.ChaincodeParams.GetVersion())
+	fmt.Fprintf(w, "  Sequence:                 %d\n", info.ChaincodeParams.GetSequence())
+	fmt.Fprintf(w, "  Channel ID:               %s\n", info.ChaincodeParams.GetChannelId())
+	fmt.Fprintf(w, "Host params:\n")
+	fmt.Fprintf(w, "  Peer MSP ID:              %s\n", info.HostParams.GetPeerMspId())
+	fmt.Fprintf(w, "  Peer endpoint:            %s\n", info.HostParams.GetPeerEndpoint())
+	printAttestationInfo(w, "Attestation", info.Attestation)
+	printAttestationInfo(w, "Evidence", info.Evidence)
+}
+
+func printAttestationInfo(w io.Writer, title string, info *attestationInfo) {
+	if info == nil {
+		fmt.Fprintf(w, "%s:%s<none>\n", title, strings.Repeat(" ", 27-len(title)))
+		return
+	}
+
+	fmt.Fprintf(w, "%s:\n", title)
+	fmt.Fprintf(w, "  Type:                     %s\n", info.Type)
+	if info.Type == simulation.SimulationType {
+		return
+	}
+	fmt.Fprintf(w, "  Measurement:              %s\n", info.Measurement)
+	fmt.Fprintf(w, "  Bound to attested data:   %t\n", info.StatementBound)
+
+	names := make([]string, 0, len(info.Properties))
+	for name := range info.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-24s  %s\n", name+":", info.Properties[name])
+	}
+}
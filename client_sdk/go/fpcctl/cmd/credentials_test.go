@@ -0,0 +1,159 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/fab/ccpackager"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid/iastest"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const testMrenclave = "98aed61c91f258a37c68ed4943297695647ec7bbe6008cc111b0a12650ebeb91"
+
+func newAttestedData(t *testing.T) *anypb.Any {
+	serializedAttestedData, err := anypb.New(&protos.AttestedData{
+		EnclaveVk:   []byte("some enclave vk"),
+		ChaincodeEk: []byte("some chaincode ek"),
+		CcParams: &protos.CCParameters{
+			ChaincodeId: "some chaincode",
+			Version:     testMrenclave,
+			Sequence:    1,
+			ChannelId:   "mychannel",
+		},
+		HostParams: &protos.HostParameters{
+			PeerMspId:    "Org1MSP",
+			PeerEndpoint: "peer0.org1.example.com:7051",
+		},
+	})
+	require.NoError(t, err)
+	return serializedAttestedData
+}
+
+func runCommand(t *testing.T, stdin string, args ...string) (string, error) {
+	// reset flags of previous runs
+	expectedMrenclave = ""
+	convertAttestation = false
+	verifyOpts = verifyOptions{}
+	jsonOutput = false
+	netOpts = networkOptions{user: "Admin", channel: "mychannel"}
+	ccOpts = chaincodeOptions{lang: ccpackager.ChaincodeType, sequence: 1}
+	enclaveOpts = enclaveOptions{}
+	txChaincodeID = ""
+
+	out := &bytes.Buffer{}
+	rootCmd.SetIn(strings.NewReader(stdin))
+	rootCmd.SetOut(out)
+	rootCmd.SetErr(out)
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestInspectSimulated(t *testing.T) {
+	credentials := utils.MarshallProtoBase64(&protos.Credentials{
+		SerializedAttestedData: newAttestedData(t),
+		Attestation:            []byte(`{"attestation_type":"simulated","attestation":"MA=="}`),
+		Evidence:               []byte(`{"attestation_type":"simulated","evidence":"MA=="}`),
+	})
+
+	out, err := runCommand(t, credentials, "credentials", "inspect")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Enclave ID:                 "+utils.GetEnclaveId(&protos.AttestedData{EnclaveVk: []byte("some enclave vk")}))
+	assert.Contains(t, out, "Chaincode ID:             some chaincode")
+	assert.Contains(t, out, "Peer endpoint:            peer0.org1.example.com:7051")
+	assert.Contains(t, out, "Type:                     simulated")
+
+	// read from file and verify
+	path := filepath.Join(t.TempDir(), "credentials")
+	require.NoError(t, os.WriteFile(path, []byte(credentials+"\n"), 0644))
+
+	out, err = runCommand(t, "", "credentials", "inspect", path, "--mrenclave", testMrenclave)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Verification:               OK")
+
+	out, err = runCommand(t, "", "credentials", "inspect", path, "--mrenclave", "some other mrenclave")
+	assert.Error(t, err)
+	assert.Contains(t, out, "Verification:               FAILED (mrenclave does not match chaincode params version)")
+
+	out, err = runCommand(t, "", "credentials", "inspect", path, "--mrenclave", testMrenclave, "--json")
+	require.NoError(t, err)
+	info := &credentialsInfo{}
+	require.NoError(t, json.Unmarshal([]byte(out), info))
+	assert.Equal(t, "peer0.org1.example.com:7051", info.HostParams.GetPeerEndpoint())
+	require.NotNil(t, info.Verified)
+	assert.True(t, *info.Verified)
+
+	_, err = runCommand(t, "not credentials", "credentials", "inspect")
+	assert.Contains(t, err.Error(), "cannot decode credentials")
+}
+
+func TestInspectEPID(t *testing.T) {
+	ca, err := iastest.NewCA()
+	require.NoError(t, err)
+	ias := httptest.NewServer(iastest.NewServer(ca))
+	defer ias.Close()
+
+	rootPath := filepath.Join(t.TempDir(), "root.pem")
+	require.NoError(t, os.WriteFile(rootPath, ca.RootPEM(), 0644))
+	t.Setenv(epid.ReportSigningRootEnv, rootPath)
+	t.Setenv(epid.IASUrlEnv, ias.URL+iastest.ReportPath)
+	t.Setenv("IAS_API_KEY", "some_key")
+
+	serializedAttestedData := newAttestedData(t)
+	att, err := iastest.NewAttestation(&iastest.Quote{Linkable: true, Mrenclave: testMrenclave, Statement: serializedAttestedData.Value})
+	require.NoError(t, err)
+
+	credentials := utils.MarshallProtoBase64(&protos.Credentials{
+		SerializedAttestedData: serializedAttestedData,
+		Attestation:            att,
+	})
+
+	// attestation only
+	out, err := runCommand(t, credentials, "credentials", "inspect", "--mrenclave", testMrenclave)
+	assert.Error(t, err)
+	assert.Contains(t, out, "Type:                     epid-linkable")
+	assert.Contains(t, out, "Measurement:              "+testMrenclave)
+	assert.Contains(t, out, "Bound to attested data:   true")
+	assert.Contains(t, out, "Evidence:                   <none>")
+	assert.Contains(t, out, "credentials contain no evidence")
+
+	// with conversion
+	out, err = runCommand(t, credentials, "credentials", "inspect", "--convert", "--mrenclave", testMrenclave)
+	require.NoError(t, err)
+	assert.Contains(t, out, "quote_status:             OK")
+	assert.Contains(t, out, "Verification:               OK")
+
+	// evidence of other credentials
+	converted, err := attestation.NewDefaultCredentialConverter().ConvertCredentials(credentials)
+	require.NoError(t, err)
+	tampered, err := utils.UnmarshalCredentials(converted)
+	require.NoError(t, err)
+	tampered.SerializedAttestedData, err = anypb.New(&protos.AttestedData{
+		EnclaveVk: []byte("other enclave vk"),
+		CcParams:  &protos.CCParameters{Version: testMrenclave},
+	})
+	require.NoError(t, err)
+
+	out, err = runCommand(t, utils.MarshallProtoBase64(tampered), "credentials", "inspect", "--mrenclave", testMrenclave)
+	assert.Error(t, err)
+	assert.Contains(t, out, "Bound to attested data:   false")
+	assert.Contains(t, out, "expected statement mismatch")
+}
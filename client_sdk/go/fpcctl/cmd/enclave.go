@@ -0,0 +1,404 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/lifecycle"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	enclaveCmd = &cobra.Command{
+		Use:   "enclave",
+		Short: "Initialize, register and list the enclaves of a FPC chaincode",
+	}
+
+	enclaveInitCmd = &cobra.Command{
+		Use:   "init",
+		Short: "Initialize and register an enclave at a peer",
+		Long: `Initialize and register an enclave at a peer.
+If an enclave is registered for the peer already, no new enclave is initialized, so the command can be re-run
+after a failure. ERCC accepts a single enclave per chaincode, as the chaincode keys cannot be distributed to
+the enclaves at other peers yet; thus, the command fails if an enclave is registered at another peer.
+The credentials of the new enclave are verified before its registration.`,
+		Args: cobra.NoArgs,
+		RunE: runEnclaveInit,
+	}
+
+	enclaveListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the enclaves registered at ERCC",
+		Args:  cobra.NoArgs,
+		RunE:  runEnclaveList,
+	}
+
+	enclaveEndpointsCmd = &cobra.Command{
+		Use:   "endpoints",
+		Short: "List the endpoints of the peers hosting a registered enclave",
+		Args:  cobra.NoArgs,
+		RunE:  runEnclaveEndpoints,
+	}
+
+	enclaveExportKeysCmd = &cobra.Command{
+		Use:   "export-keys",
+		Short: "Escrow the chaincode keys of the registered enclave for an upgraded chaincode version",
+		Long: `Escrow the chaincode keys of the registered enclave for an upgraded chaincode version.
+The enclave hands over its chaincode keys to the trusted ledger (TLCC) of its peer, which releases them
+to an enclave of a chaincode version allowed by the upgrade policy (see 'fpcctl enclave set-upgrade-policy').
+The keys must be exported before the new chaincode definition is committed.`,
+		Args: cobra.NoArgs,
+		RunE: runEnclaveExportKeys,
+	}
+
+	enclaveUpgradeCmd = &cobra.Command{
+		Use:     "upgrade",
+		Aliases: []string{"rotate-keys"},
+		Short:   "Replace the registered enclave and hand over the chaincode keys",
+		Long: `Replace the registered enclave and hand over the chaincode keys.
+A new enclave is initialized at the peer, replaces the registered enclave at ERCC, and imports the chaincode
+keys, which the registered enclave escrowed before the new chaincode definition was committed
+(see 'fpcctl enclave export-keys'); thus, the peer must be the peer of the registered enclave.
+The new enclave generates a new chaincode encryption key, i.e., the command rotates the chaincode encryption key.
+The version of the new enclave must be allowed by the upgrade policy (see 'fpcctl enclave set-upgrade-policy').`,
+		Args: cobra.NoArgs,
+		RunE: runEnclaveUpgrade,
+	}
+
+	enclaveApproveUpgradePolicyCmd = &cobra.Command{
+		Use:   "approve-upgrade-policy <mrenclave>...",
+		Short: "Approve an upgrade policy on behalf of the organization",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runApproveUpgradePolicy,
+	}
+
+	enclaveUpgradePolicyCmd = &cobra.Command{
+		Use:   "set-upgrade-policy <mrenclave>...",
+		Short: "Set the chaincode versions the enclave may export its chaincode keys to",
+		Long: `Set the chaincode versions the enclave may export its chaincode keys to.
+The policy must be approved by the organizations of the endorsing peers of ERCC, by default a majority
+of the channel members (see 'fpcctl enclave approve-upgrade-policy').`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runSetUpgradePolicy,
+	}
+
+	enclaveOpts enclaveOptions
+)
+
+type enclaveOptions struct {
+	chaincodeID string
+	peer        string
+}
+
+func init() {
+	enclaveCmd.PersistentFlags().StringVarP(&enclaveOpts.chaincodeID, "name", "n", "", "the chaincode ID")
+
+	enclaveInitCmd.Flags().StringVar(&enclaveOpts.peer, "peer", "", "the peer to initialize the enclave at")
+	enclaveInitCmd.Flags().StringVar(&netOpts.credentialStore, "credential-store", "", "a directory to keep credentials until their registration succeeded")
+
+	enclaveExportKeysCmd.Flags().StringVar(&enclaveOpts.peer, "peer", "", "the peer hosting the registered enclave")
+	enclaveUpgradeCmd.Flags().StringVar(&enclaveOpts.peer, "peer", "", "the peer to initialize the new enclave at")
+
+	for _, cmd := range []*cobra.Command{enclaveInitCmd, enclaveUpgradeCmd} {
+		cmd.Flags().StringVar(&netOpts.sgxCredentials, "sgx-credentials", "", "the directory containing the SGX credentials (default: $SGX_MODE and $SGX_CREDENTIALS_PATH)")
+	}
+
+	enclaveCmd.AddCommand(enclaveInitCmd, enclaveListCmd, enclaveEndpointsCmd, enclaveExportKeysCmd, enclaveUpgradeCmd, enclaveApproveUpgradePolicyCmd, enclaveUpgradePolicyCmd)
+	addNetworkFlags(enclaveCmd)
+	rootCmd.AddCommand(enclaveCmd)
+}
+
+// enclaveInitResult is the outcome of the enclave initialization at a peer
+type enclaveInitResult struct {
+	Peer              string `json:"peer"`
+	TxID              string `json:"tx_id,omitempty"`
+	AlreadyRegistered bool   `json:"already_registered"`
+}
+
+// exportKeysResult is the outcome of the key export of the enclave at a peer
+type exportKeysResult struct {
+	Peer string `json:"peer"`
+}
+
+// enclaveInfo describes an enclave registered at ERCC
+type enclaveInfo struct {
+	EnclaveId    string `json:"enclave_id"`
+	PeerEndpoint string `json:"peer_endpoint"`
+	PeerMspId    string `json:"peer_msp_id"`
+	Version      string `json:"version"`
+	Sequence     int64  `json:"sequence"`
+	EvidenceType string `json:"evidence_type,omitempty"`
+}
+
+func runEnclaveInit(cmd *cobra.Command, args []string) error {
+	if enclaveOpts.chaincodeID == "" {
+		return errors.New("a chaincode ID is required, use --name")
+	}
+
+	params, err := attestationParams()
+	if err != nil {
+		return err
+	}
+
+	net, err := connect()
+	if err != nil {
+		return err
+	}
+	defer net.Close()
+
+	client, err := newLifecycleClient(net)
+	if err != nil {
+		return err
+	}
+
+	txID, err := client.LifecycleInitEnclave(netOpts.channel, lifecycle.LifecycleInitEnclaveRequest{
+		ChaincodeID:         enclaveOpts.chaincodeID,
+		EnclavePeerEndpoint: enclaveOpts.peer,
+		AttestationParams:   params,
+	})
+	if err != nil {
+		return err
+	}
+
+	result := &enclaveInitResult{Peer: enclaveOpts.peer, TxID: txID, AlreadyRegistered: txID == ""}
+	out := cmd.OutOrStdout()
+	if jsonOutput {
+		return printJSON(out, result)
+	}
+	if result.AlreadyRegistered {
+		fmt.Fprintf(out, "Enclave at %s already registered\n", result.Peer)
+		return nil
+	}
+	fmt.Fprintf(out, "Enclave at %s registered (txID: %s)\n", result.Peer, result.TxID)
+	return nil
+}
+
+func runEnclaveList(cmd *cobra.Command, args []string) error {
+	if enclaveOpts.chaincodeID == "" {
+		return errors.New("a chaincode ID is required, use --name")
+	}
+
+	payload, err := queryERCC(lifecycle.QueryListEnclaveCredentialsCMD, enclaveOpts.chaincodeID)
+	if err != nil {
+		return err
+	}
+
+	enclaves, err := listEnclaves(payload)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if jsonOutput {
+		return printJSON(out, enclaves)
+	}
+
+	if len(enclaves) == 0 {
+		fmt.Fprintf(out, "No enclaves registered for %s\n", enclaveOpts.chaincodeID)
+		return nil
+	}
+	for i, enclave := range enclaves {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "Enclave ID:                 %s\n", enclave.EnclaveId)
+		fmt.Fprintf(out, "Peer endpoint:              %s\n", enclave.PeerEndpoint)
+		fmt.Fprintf(out, "Peer MSP ID:                %s\n", enclave.PeerMspId)
+		fmt.Fprintf(out, "Version (mrenclave):        %s\n", enclave.Version)
+		fmt.Fprintf(out, "Sequence:                   %d\n", enclave.Sequence)
+		fmt.Fprintf(out, "Evidence type:              %s\n", enclave.EvidenceType)
+	}
+	return nil
+}
+
+func runEnclaveEndpoints(cmd *cobra.Command, args []string) error {
+	if enclaveOpts.chaincodeID == "" {
+		return errors.New("a chaincode ID is required, use --name")
+	}
+
+	payload, err := queryERCC("queryChaincodeEndPoints", enclaveOpts.chaincodeID)
+	if err != nil {
+		return err
+	}
+
+	// ERCC returns the endpoints as comma separated list
+	endpoints := []string{}
+	for _, endpoint := range strings.Split(string(payload), ",") {
+		if endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	if jsonOutput {
+		return printJSON(out, endpoints)
+	}
+	for _, endpoint := range endpoints {
+		fmt.Fprintln(out, endpoint)
+	}
+	return nil
+}
+
+func runEnclaveExportKeys(cmd *cobra.Command, args []string) error {
+	net, err := connect()
+	if err != nil {
+		return err
+	}
+	defer net.Close()
+
+	client, err := newLifecycleClient(net)
+	if err != nil {
+		return err
+	}
+
+	if err := client.LifecycleExportCCKeys(netOpts.channel, enclaveOpts.chaincodeID, enclaveOpts.peer); err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if jsonOutput {
+		return printJSON(out, &exportKeysResult{Peer: enclaveOpts.peer})
+	}
+	fmt.Fprintf(out, "Chaincode keys of the enclave at %s escrowed\n", enclaveOpts.peer)
+	return nil
+}
+
+func runEnclaveUpgrade(cmd *cobra.Command, args []string) error {
+	params, err := attestationParams()
+	if err != nil {
+		return err
+	}
+
+	net, err := connect()
+	if err != nil {
+		return err
+	}
+	defer net.Close()
+
+	client, err := newLifecycleClient(net)
+	if err != nil {
+		return err
+	}
+
+	txID, err := client.LifecycleUpgradeEnclave(netOpts.channel, lifecycle.LifecycleInitEnclaveRequest{
+		ChaincodeID:         enclaveOpts.chaincodeID,
+		EnclavePeerEndpoint: enclaveOpts.peer,
+		AttestationParams:   params,
+	})
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if jsonOutput {
+		return printJSON(out, &txResult{TxID: txID})
+	}
+	fmt.Fprintf(out, "Enclave at %s upgraded (txID: %s)\n", enclaveOpts.peer, txID)
+	return nil
+}
+
+func runApproveUpgradePolicy(cmd *cobra.Command, args []string) error {
+	return runUpgradePolicy(cmd, args, "Upgrade policy approved", (*lifecycle.Client).LifecycleApproveUpgradePolicy)
+}
+
+func runSetUpgradePolicy(cmd *cobra.Command, args []string) error {
+	return runUpgradePolicy(cmd, args, "Upgrade policy set", (*lifecycle.Client).LifecycleSetUpgradePolicy)
+}
+
+func runUpgradePolicy(cmd *cobra.Command, args []string, done string, execute func(*lifecycle.Client, string, string, []string) (string, error)) error {
+	for _, version := range args {
+		if !mrenclavePattern.MatchString(version) {
+			return errors.Errorf("invalid version '%s': the version of a FPC chaincode must be its mrenclave", version)
+		}
+	}
+
+	net, err := connect()
+	if err != nil {
+		return err
+	}
+	defer net.Close()
+
+	client, err := newLifecycleClient(net)
+	if err != nil {
+		return err
+	}
+
+	txID, err := execute(client, netOpts.channel, enclaveOpts.chaincodeID, args)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if jsonOutput {
+		return printJSON(out, &txResult{TxID: txID})
+	}
+	fmt.Fprintf(out, "%s (txID: %s)\n", done, txID)
+	return nil
+}
+
+// queryERCC evaluates a query function of the enclave registry
+func queryERCC(function string, args ...string) ([]byte, error) {
+	net, err := connect()
+	if err != nil {
+		return nil, err
+	}
+	defer net.Close()
+
+	client, err := net.ChannelClient(netOpts.channel)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := client.Query(lifecycle.ERCC, function, toBytes(args))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query %s", function)
+	}
+	return payload, nil
+}
+
+// listEnclaves decodes the list of credentials returned by ERCC
+func listEnclaves(payload []byte) ([]*enclaveInfo, error) {
+	enclaves := []*enclaveInfo{}
+	if len(payload) == 0 {
+		return enclaves, nil
+	}
+
+	var allCredentials []string
+	if err := json.Unmarshal(payload, &allCredentials); err != nil {
+		return nil, errors.Wrap(err, "invalid enclave credentials list")
+	}
+
+	for _, credentialsBase64 := range allCredentials {
+		credentials, err := utils.UnmarshalCredentials(credentialsBase64)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot decode credentials")
+		}
+
+		info, err := inspectCredentials(credentials)
+		if err != nil {
+			return nil, err
+		}
+
+		enclave := &enclaveInfo{
+			EnclaveId:    info.EnclaveId,
+			PeerEndpoint: info.HostParams.GetPeerEndpoint(),
+			PeerMspId:    info.HostParams.GetPeerMspId(),
+			Version:      info.ChaincodeParams.GetVersion(),
+			Sequence:     info.ChaincodeParams.GetSequence(),
+		}
+		if info.Evidence != nil {
+			enclave.EvidenceType = info.Evidence.Type
+		}
+		enclaves = append(enclaves, enclave)
+	}
+	return enclaves, nil
+}
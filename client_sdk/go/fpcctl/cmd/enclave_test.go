@@ -0,0 +1,248 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/lifecycle"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// simulatedCredentials returns the credentials of a simulated enclave at the given peer
+func simulatedCredentials(t *testing.T, peerEndpoint string) string {
+	serializedAttestedData, err := anypb.New(&protos.AttestedData{
+		EnclaveVk:  []byte("enclave vk of " + peerEndpoint),
+		CcParams:   &protos.CCParameters{ChaincodeId: "mycc", Version: testMrenclave, Sequence: 1, ChannelId: "mychannel"},
+		HostParams: &protos.HostParameters{PeerMspId: "Org1MSP", PeerEndpoint: peerEndpoint},
+	})
+	require.NoError(t, err)
+	return utils.MarshallProtoBase64(&protos.Credentials{
+		SerializedAttestedData: serializedAttestedData,
+		Attestation:            []byte(`{"attestation_type":"simulated","attestation":"MA=="}`),
+		Evidence:               []byte(`{"attestation_type":"simulated","evidence":"MA=="}`),
+	})
+}
+
+// simulatedERCC lets the channel client fake answer ERCC queries and __initEnclave
+func simulatedERCC(t *testing.T, net *fakeNetwork, registered ...string) {
+	net.client.QueryCalls(func(chaincodeID string, fcn string, args [][]byte, targets ...string) ([]byte, error) {
+		switch fcn {
+		case lifecycle.QueryListEnclaveCredentialsCMD:
+			var allCredentials []string
+			for _, endpoint := range registered {
+				allCredentials = append(allCredentials, simulatedCredentials(t, endpoint))
+			}
+			return json.Marshal(allCredentials)
+
+		case lifecycle.InitEnclaveCMD:
+			initMsgBytes, err := base64.StdEncoding.DecodeString(string(args[0]))
+			require.NoError(t, err)
+			initMsg := &protos.InitEnclaveMessage{}
+			require.NoError(t, proto.Unmarshal(initMsgBytes, initMsg))
+			if initMsg.PeerEndpoint == "peer2.org1.example.com:7051" {
+				return nil, fmt.Errorf("peer not available")
+			}
+			return []byte(simulatedCredentials(t, initMsg.PeerEndpoint)), nil
+
+		default:
+			return nil, fmt.Errorf("unexpected query %s", fcn)
+		}
+	})
+	net.client.ExecuteReturns("registerTxID", nil)
+}
+
+func TestEnclaveInit(t *testing.T) {
+	t.Setenv("SGX_MODE", "SIM")
+	net := useFakeNetwork(t)
+	simulatedERCC(t, net, "peer0.org1.example.com:7051")
+
+	out, err := runCommand(t, "", "enclave", "init", "-n", "mycc", "--peer", "peer0.org1.example.com:7051")
+	require.NoError(t, err)
+	assert.Equal(t, "Enclave at peer0.org1.example.com:7051 already registered\n", out)
+
+	// ERCC accepts a single enclave per chaincode
+	_, err = runCommand(t, "", "enclave", "init", "-n", "mycc", "--peer", "peer1.org1.example.com:7051")
+	assert.EqualError(t, err, "ERCC accepts a single enclave per chaincode, but an enclave is already registered at peer0.org1.example.com:7051")
+	assert.Equal(t, 0, net.client.ExecuteCallCount())
+
+	simulatedERCC(t, net)
+	out, err = runCommand(t, "", "enclave", "init", "-n", "mycc", "--peer", "peer1.org1.example.com:7051", "--json")
+	require.NoError(t, err)
+	var result enclaveInitResult
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, enclaveInitResult{Peer: "peer1.org1.example.com:7051", TxID: "registerTxID"}, result)
+
+	assert.Equal(t, 1, net.client.ExecuteCallCount())
+	chaincodeID, fcn, args := net.client.ExecuteArgsForCall(0)
+	assert.Equal(t, lifecycle.ERCC, chaincodeID)
+	assert.Equal(t, lifecycle.RegisterEnclaveCMD, fcn)
+	assert.Equal(t, simulatedCredentials(t, "peer1.org1.example.com:7051"), string(args[0]))
+
+	_, err = runCommand(t, "", "enclave", "init", "-n", "mycc", "--peer", "peer2.org1.example.com:7051")
+	assert.EqualError(t, err, "Failed to query init enclave: peer not available")
+
+	_, err = runCommand(t, "", "enclave", "init", "-n", "mycc")
+	assert.EqualError(t, err, "target peer, which spawns the enclave, is required")
+
+	_, err = runCommand(t, "", "enclave", "init")
+	assert.EqualError(t, err, "a chaincode ID is required, use --name")
+
+	t.Setenv("SGX_MODE", "")
+	_, err = runCommand(t, "", "enclave", "init", "-n", "mycc")
+	assert.ErrorContains(t, err, "SGX_MODE environment variable ill-defined")
+}
+
+func TestEnclaveList(t *testing.T) {
+	net := useFakeNetwork(t)
+	simulatedERCC(t, net, "peer0.org1.example.com:7051")
+
+	out, err := runCommand(t, "", "enclave", "list", "-n", "mycc", "-C", "otherchannel")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Peer endpoint:              peer0.org1.example.com:7051")
+	assert.Contains(t, out, "Peer MSP ID:                Org1MSP")
+	assert.Contains(t, out, "Version (mrenclave):        "+testMrenclave)
+	assert.Contains(t, out, "Evidence type:              simulated")
+	assert.Equal(t, []string{"otherchannel"}, net.channelIDs)
+
+	chaincodeID, fcn, args, _ := net.client.QueryArgsForCall(0)
+	assert.Equal(t, lifecycle.ERCC, chaincodeID)
+	assert.Equal(t, lifecycle.QueryListEnclaveCredentialsCMD, fcn)
+	assert.Equal(t, [][]byte{[]byte("mycc")}, args)
+
+	out, err = runCommand(t, "", "enclave", "list", "-n", "mycc", "--json")
+	require.NoError(t, err)
+	var enclaves []enclaveInfo
+	require.NoError(t, json.Unmarshal([]byte(out), &enclaves))
+	require.Len(t, enclaves, 1)
+	assert.Equal(t, "peer0.org1.example.com:7051", enclaves[0].PeerEndpoint)
+	assert.Equal(t, int64(1), enclaves[0].Sequence)
+
+	// no enclaves
+	net.client.QueryReturns(nil, nil)
+	out, err = runCommand(t, "", "enclave", "list", "-n", "mycc")
+	require.NoError(t, err)
+	assert.Equal(t, "No enclaves registered for mycc\n", out)
+
+	out, err = runCommand(t, "", "enclave", "list", "-n", "mycc", "--json")
+	require.NoError(t, err)
+	assert.Equal(t, "[]\n", out)
+
+	net.client.QueryReturns([]byte("not json"), nil)
+	_, err = runCommand(t, "", "enclave", "list", "-n", "mycc")
+	assert.ErrorContains(t, err, "invalid enclave credentials list")
+}
+
+func TestEnclaveEndpoints(t *testing.T) {
+	net := useFakeNetwork(t)
+	net.client.QueryReturns([]byte("peer0.org1.example.com:7051,peer1.org1.example.com:7051"), nil)
+
+	out, err := runCommand(t, "", "enclave", "endpoints", "-n", "mycc")
+	require.NoError(t, err)
+	assert.Equal(t, "peer0.org1.example.com:7051\npeer1.org1.example.com:7051\n", out)
+
+	_, fcn, _, _ := net.client.QueryArgsForCall(0)
+	assert.Equal(t, "queryChaincodeEndPoints", fcn)
+
+	net.client.QueryReturns(nil, nil)
+	out, err = runCommand(t, "", "enclave", "endpoints", "-n", "mycc", "--json")
+	require.NoError(t, err)
+	assert.Equal(t, "[]\n", out)
+
+	net.client.QueryReturns(nil, fmt.Errorf("ercc not available"))
+	_, err = runCommand(t, "", "enclave", "endpoints", "-n", "mycc")
+	assert.EqualError(t, err, "failed to query queryChaincodeEndPoints: ercc not available")
+}
+
+func TestEnclaveExportKeys(t *testing.T) {
+	net := useFakeNetwork(t)
+
+	out, err := runCommand(t, "", "enclave", "export-keys", "-n", "mycc", "--peer", "peer0.org1.example.com:7051", "--json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"peer": "peer0.org1.example.com:7051"}`, out)
+
+	chaincodeID, fcn, _, targets := net.client.QueryArgsForCall(0)
+	assert.Equal(t, "mycc", chaincodeID)
+	assert.Equal(t, lifecycle.ExportCCKeysCMD, fcn)
+	assert.Equal(t, []string{"peer0.org1.example.com:7051"}, targets)
+
+	_, err = runCommand(t, "", "enclave", "export-keys", "-n", "mycc")
+	assert.EqualError(t, err, "peer of the registered enclave is required")
+}
+
+func TestEnclaveUpgrade(t *testing.T) {
+	t.Setenv("SGX_MODE", "SIM")
+	net := useFakeNetwork(t)
+	net.client.QueryCalls(func(chaincodeID string, fcn string, args [][]byte, targets ...string) ([]byte, error) {
+		if fcn == lifecycle.InitEnclaveCMD {
+			return []byte(simulatedCredentials(t, targets[0])), nil
+		}
+		return nil, nil
+	})
+	net.client.ExecuteReturns("upgradeTxID", nil)
+
+	out, err := runCommand(t, "", "enclave", "rotate-keys", "-n", "mycc", "--peer", "peer0.org1.example.com:7051")
+	require.NoError(t, err)
+	assert.Equal(t, "Enclave at peer0.org1.example.com:7051 upgraded (txID: upgradeTxID)\n", out)
+
+	_, fcn, args := net.client.ExecuteArgsForCall(0)
+	assert.Equal(t, lifecycle.UpgradeEnclaveCMD, fcn)
+	assert.Len(t, args, 1)
+	_, fcn, _, targets := net.client.QueryArgsForCall(1)
+	assert.Equal(t, lifecycle.ImportCCKeysCMD, fcn)
+	assert.Equal(t, []string{"peer0.org1.example.com:7051"}, targets)
+}
+
+func TestEnclaveApproveUpgradePolicy(t *testing.T) {
+	net := useFakeNetwork(t)
+	net.client.ExecuteReturns("approvalTxID", nil)
+
+	out, err := runCommand(t, "", "enclave", "approve-upgrade-policy", "-n", "mycc", testMrenclave)
+	require.NoError(t, err)
+	assert.Equal(t, "Upgrade policy approved (txID: approvalTxID)\n", out)
+
+	_, fcn, args := net.client.ExecuteArgsForCall(0)
+	assert.Equal(t, lifecycle.ApproveUpgradePolicyCMD, fcn)
+	policyBytes, err := base64.StdEncoding.DecodeString(string(args[0]))
+	require.NoError(t, err)
+	policy := &protos.UpgradePolicy{}
+	require.NoError(t, proto.Unmarshal(policyBytes, policy))
+	assert.Equal(t, []string{testMrenclave}, policy.AllowedVersions)
+
+	_, err = runCommand(t, "", "enclave", "approve-upgrade-policy", "-n", "mycc", "1.0")
+	assert.EqualError(t, err, "invalid version '1.0': the version of a FPC chaincode must be its mrenclave")
+}
+
+func TestEnclaveSetUpgradePolicy(t *testing.T) {
+	net := useFakeNetwork(t)
+	net.client.ExecuteReturns("policyTxID", nil)
+
+	out, err := runCommand(t, "", "enclave", "set-upgrade-policy", "-n", "mycc", testMrenclave, "--json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"tx_id": "policyTxID"}`, out)
+
+	_, fcn, args := net.client.ExecuteArgsForCall(0)
+	assert.Equal(t, lifecycle.SetUpgradePolicyCMD, fcn)
+	policyBytes, err := base64.StdEncoding.DecodeString(string(args[0]))
+	require.NoError(t, err)
+	policy := &protos.UpgradePolicy{}
+	require.NoError(t, proto.Unmarshal(policyBytes, policy))
+	assert.Equal(t, "mycc", policy.ChaincodeId)
+	assert.Equal(t, []string{testMrenclave}, policy.AllowedVersions)
+
+	_, err = runCommand(t, "", "enclave", "set-upgrade-policy", "-n", "mycc", "1.0")
+	assert.EqualError(t, err, "invalid version '1.0': the version of a FPC chaincode must be its mrenclave")
+	assert.Equal(t, 1, net.client.ExecuteCallCount())
+}
@@ -0,0 +1,131 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"path/filepath"
+
+	fpcmgmt "github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/client/resmgmt"
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/lifecycle"
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/sgx"
+	ercc "github.com/hyperledger/fabric-private-chaincode/ercc/attestation"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/resmgmt"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// network provides the clients used by the commands that interact with a Fabric network
+type network interface {
+	// ChannelClient returns a client to query and execute chaincodes on the given channel
+	ChannelClient(channelID string) (lifecycle.ChannelClient, error)
+	// ChaincodeAdmin returns a client to install, approve and commit chaincodes
+	ChaincodeAdmin() (chaincodeAdmin, error)
+	Close()
+}
+
+// chaincodeAdmin contains the functions of the Fabric SDK resource management client used by fpcctl
+type chaincodeAdmin interface {
+	LifecycleInstallCC(req resmgmt.LifecycleInstallCCRequest, options ...resmgmt.RequestOption) ([]resmgmt.LifecycleInstallCCResponse, error)
+	LifecycleApproveCC(channelID string, req resmgmt.LifecycleApproveCCRequest, options ...resmgmt.RequestOption) (fab.TransactionID, error)
+	LifecycleCommitCC(channelID string, req resmgmt.LifecycleCommitCCRequest, options ...resmgmt.RequestOption) (fab.TransactionID, error)
+}
+
+// connect establishes the connection to the network; tests replace it to run the commands against fakes
+var connect = connectSDK
+
+var netOpts networkOptions
+
+type networkOptions struct {
+	config          string
+	org             string
+	user            string
+	channel         string
+	sgxCredentials  string
+	credentialStore string
+}
+
+// addNetworkFlags adds the flags required to connect to a network to the given command and its sub commands
+func addNetworkFlags(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&netOpts.config, "config", "", "the connection profile of the network")
+	flags.StringVar(&netOpts.org, "org", "", "the organization of the user as defined in the connection profile")
+	flags.StringVar(&netOpts.user, "user", "Admin", "the user as defined in the connection profile")
+	flags.StringVarP(&netOpts.channel, "channel", "C", "mychannel", "the channel")
+}
+
+// sdkNetwork connects to a network using the Fabric Go SDK
+type sdkNetwork struct {
+	sdk            *fabsdk.FabricSDK
+	ctx            context.ClientProvider
+	channelClients *fpcmgmt.ChannelClientProvider
+}
+
+func connectSDK() (network, error) {
+	if netOpts.config == "" {
+		return nil, errors.New("a connection profile is required, use --config")
+	}
+
+	sdk, err := fabsdk.New(config.FromFile(filepath.Clean(netOpts.config)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create sdk")
+	}
+
+	var ctxOpts []fabsdk.ContextOption
+	ctxOpts = append(ctxOpts, fabsdk.WithUser(netOpts.user))
+	if netOpts.org != "" {
+		ctxOpts = append(ctxOpts, fabsdk.WithOrg(netOpts.org))
+	}
+	ctx := sdk.Context(ctxOpts...)
+
+	return &sdkNetwork{
+		sdk:            sdk,
+		ctx:            ctx,
+		channelClients: fpcmgmt.NewChannelClientProvider(ctx),
+	}, nil
+}
+
+func (n *sdkNetwork) ChannelClient(channelID string) (lifecycle.ChannelClient, error) {
+	return n.channelClients.ChannelClient(channelID)
+}
+
+func (n *sdkNetwork) ChaincodeAdmin() (chaincodeAdmin, error) {
+	return resmgmt.New(n.ctx)
+}
+
+func (n *sdkNetwork) Close() {
+	n.sdk.Close()
+}
+
+// newLifecycleClient returns a FPC lifecycle client; credentials of new enclaves are verified before registration
+func newLifecycleClient(net network) (*lifecycle.Client, error) {
+	opts := []lifecycle.Option{
+		lifecycle.WithCredentialVerifier(ercc.GetAvailableVerifier()),
+	}
+
+	if netOpts.credentialStore != "" {
+		store, err := lifecycle.NewFileCredentialStore(netOpts.credentialStore)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, lifecycle.WithCredentialStore(store))
+	}
+
+	return lifecycle.New(net.ChannelClient, opts...)
+}
+
+// attestationParams returns the attestation params from the sgx credentials directory, if given,
+// or from the environment (SGX_MODE and SGX_CREDENTIALS_PATH)
+func attestationParams() (*sgx.AttestationParams, error) {
+	if netOpts.sgxCredentials != "" {
+		return sgx.CreateAttestationParamsFromCredentialsPath(netOpts.sgxCredentials)
+	}
+	return sgx.CreateAttestationParamsFromEnvironment()
+}
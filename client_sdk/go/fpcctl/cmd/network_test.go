@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/lifecycle"
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/lifecycle/fakes"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/resmgmt"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNetwork serves the channel client fake to the commands
+type fakeNetwork struct {
+	client     *fakes.ChannelClient
+	admin      *fakeAdmin
+	channelIDs []string
+	closed     int
+}
+
+func (n *fakeNetwork) ChannelClient(channelID string) (lifecycle.ChannelClient, error) {
+	n.channelIDs = append(n.channelIDs, channelID)
+	return n.client, nil
+}
+
+func (n *fakeNetwork) ChaincodeAdmin() (chaincodeAdmin, error) {
+	return n.admin, nil
+}
+
+func (n *fakeNetwork) Close() {
+	n.closed++
+}
+
+// fakeAdmin records the requests of the chaincode commands
+type fakeAdmin struct {
+	installReq   resmgmt.LifecycleInstallCCRequest
+	installResp  []resmgmt.LifecycleInstallCCResponse
+	approveReq   resmgmt.LifecycleApproveCCRequest
+	commitReq    resmgmt.LifecycleCommitCCRequest
+	channelID    string
+	optionsCount int
+}
+
+func (a *fakeAdmin) LifecycleInstallCC(req resmgmt.LifecycleInstallCCRequest, options ...resmgmt.RequestOption) ([]resmgmt.LifecycleInstallCCResponse, error) {
+	a.installReq = req
+	a.optionsCount = len(options)
+	return a.installResp, nil
+}
+
+func (a *fakeAdmin) LifecycleApproveCC(channelID string, req resmgmt.LifecycleApproveCCRequest, options ...resmgmt.RequestOption) (fab.TransactionID, error) {
+	a.channelID = channelID
+	a.approveReq = req
+	a.optionsCount = len(options)
+	return "approveTxID", nil
+}
+
+func (a *fakeAdmin) LifecycleCommitCC(channelID string, req resmgmt.LifecycleCommitCCRequest, options ...resmgmt.RequestOption) (fab.TransactionID, error) {
+	a.channelID = channelID
+	a.commitReq = req
+	a.optionsCount = len(options)
+	return "commitTxID", nil
+}
+
+// useFakeNetwork replaces the network connection of the commands for the duration of the test
+func useFakeNetwork(t *testing.T) *fakeNetwork {
+	net := &fakeNetwork{client: &fakes.ChannelClient{}, admin: &fakeAdmin{}}
+	connect = func() (network, error) {
+		return net, nil
+	}
+	t.Cleanup(func() {
+		connect = connectSDK
+	})
+	return net
+}
+
+func TestConnectRequiresConfig(t *testing.T) {
+	_, err := runCommand(t, "", "enclave", "list", "-n", "mycc")
+	assert.EqualError(t, err, "a connection profile is required, use --config")
+}
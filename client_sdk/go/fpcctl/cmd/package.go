@@ -0,0 +1,360 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/fab/ccpackager"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	packageCmd = &cobra.Command{
+		Use:   "package",
+		Short: "Work with FPC chaincode packages",
+	}
+
+	verifyCmd = &cobra.Command{
+		Use:   "verify <package_file>",
+		Short: "Verify a FPC chaincode package before approving its chaincode definition",
+		Long: `Verify a FPC chaincode package before approving its chaincode definition.
+The package structure is checked, the package ID is computed, and the mrenclave embedded in the package is extracted.
+The mrenclave is the version of the FPC chaincode definition, so it is compared against the given references:
+an expected mrenclave, the measurement computed from the embedded enclave binary, a rebuild of the package
+from a local build directory, and a signed build manifest. At least one reference is required.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runVerify,
+	}
+
+	verifyOpts verifyOptions
+)
+
+type verifyOptions struct {
+	mrenclave         string
+	compute           bool
+	buildDir          string
+	manifest          string
+	manifestSignature string
+	manifestKey       string
+}
+
+func init() {
+	verifyCmd.Flags().StringVar(&verifyOpts.mrenclave, "mrenclave", "", "the expected mrenclave")
+	verifyCmd.Flags().BoolVar(&verifyOpts.compute, "compute", false, "compute mrenclave of the embedded enclave binary (fpc-go packages only, requires EGo)")
+	verifyCmd.Flags().StringVar(&verifyOpts.buildDir, "build-dir", "", "rebuild the package from the enclave artifacts in the given directory and compare the package ID")
+	verifyCmd.Flags().StringVar(&verifyOpts.manifest, "manifest", "", "a build manifest (json) stating label, package_id, and mrenclave")
+	verifyCmd.Flags().StringVar(&verifyOpts.manifestSignature, "manifest-sig", "", "the base64 encoded ECDSA signature of the build manifest")
+	verifyCmd.Flags().StringVar(&verifyOpts.manifestKey, "manifest-key", "", "the PEM encoded public key or certificate of the manifest signer")
+	packageCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(packageCmd)
+}
+
+// buildManifest is a statement of the chaincode builder about a package
+type buildManifest struct {
+	Label     string `json:"label"`
+	PackageID string `json:"package_id"`
+	Mrenclave string `json:"mrenclave"`
+}
+
+type checkResult struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// packageReport contains the outcome of the package verification
+type packageReport struct {
+	File      string        `json:"file"`
+	PackageID string        `json:"package_id,omitempty"`
+	Label     string        `json:"label,omitempty"`
+	Type      string        `json:"type,omitempty"`
+	SGXMode   string        `json:"sgx_mode,omitempty"`
+	Mrenclave string        `json:"mrenclave,omitempty"`
+	Checks    []checkResult `json:"checks"`
+	// References is the number of checks against an independent reference; without any, a package is not verified
+	References int  `json:"references"`
+	Verified   bool `json:"verified"`
+}
+
+func (r *packageReport) add(name string, err error, detail string) {
+	result := checkResult{Name: name, Passed: err == nil, Detail: detail}
+	if err != nil {
+		result.Detail = err.Error()
+	}
+	r.Checks = append(r.Checks, result)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	pkgBytes, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	report := verifyPackage(args[0], pkgBytes, &verifyOpts)
+
+	out := cmd.OutOrStdout()
+	if jsonOutput {
+		if err := printJSON(out, report); err != nil {
+			return err
+		}
+	} else {
+		printPackageReport(out, report)
+	}
+
+	if !report.Verified {
+		if report.References == 0 {
+			return errors.New("package verification failed: no reference given, use --mrenclave, --compute, --build-dir, or --manifest")
+		}
+		return errors.New("package verification failed")
+	}
+	return nil
+}
+
+func verifyPackage(file string, pkgBytes []byte, opts *verifyOptions) *packageReport {
+	report := &packageReport{File: file}
+
+	pkg, err := ccpackager.ReadCCPackage(pkgBytes)
+	report.add("structure", err, "")
+	if err != nil {
+		return report
+	}
+
+	report.PackageID = pkg.ID
+	report.Label = pkg.Metadata.Label
+	report.Type = pkg.Metadata.Type
+	report.SGXMode = pkg.Metadata.SGXMode
+
+	if pkg.HasMrenclave() {
+		report.Mrenclave, err = pkg.Mrenclave()
+		report.add("mrenclave", err, "")
+	}
+
+	report.References = len(report.Checks)
+	if opts.mrenclave != "" {
+		report.add("expected mrenclave", compareMrenclave(pkg, opts.mrenclave), opts.mrenclave)
+	}
+
+	if opts.compute {
+		computed, err := computeMrenclave(pkg)
+		if err == nil {
+			err = compareMrenclave(pkg, computed)
+		}
+		report.add("computed mrenclave", err, computed)
+	}
+
+	if opts.buildDir != "" {
+		detail, err := rebuild(pkg, opts.buildDir)
+		report.add("rebuild", err, detail)
+	}
+
+	if opts.manifest != "" {
+		report.add("build manifest", verifyManifest(pkg, opts), opts.manifest)
+	}
+
+	report.References = len(report.Checks) - report.References
+
+	report.Verified = report.References > 0
+	for _, check := range report.Checks {
+		report.Verified = report.Verified && check.Passed
+	}
+	return report
+}
+
+func compareMrenclave(pkg *ccpackager.Package, expected string) error {
+	mrenclave, err := pkg.Mrenclave()
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(mrenclave, expected) {
+		return errors.Errorf("mrenclave mismatch: package=%s, expected=%s", mrenclave, expected)
+	}
+	return nil
+}
+
+// computeMrenclave computes the measurement of the enclave binary embedded in a fpc-go package
+func computeMrenclave(pkg *ccpackager.Package) (string, error) {
+	if pkg.Metadata.Type != ccpackager.GoChaincodeType {
+		return "", errors.Errorf("computing mrenclave is not supported for %s packages", pkg.Metadata.Type)
+	}
+
+	name, binary, err := pkg.EnclaveBinary()
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp("", "fpcctl")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	binaryPath := filepath.Join(dir, name)
+	if err := os.WriteFile(binaryPath, binary, 0600); err != nil {
+		return "", err
+	}
+
+	return ccpackager.ComputeGoMrenclave(binaryPath)
+}
+
+// rebuild creates the package from the enclave artifacts in buildDir and checks that it contains the same artifacts.
+// Note that the package IDs only match if also the package metadata is identical; fpc-c packages, for instance,
+// contain the build directory.
+func rebuild(pkg *ccpackager.Package, buildDir string) (string, error) {
+	rebuilt, err := ccpackager.NewCCPackage(&ccpackager.Descriptor{
+		Type:    pkg.Metadata.Type,
+		Label:   pkg.Metadata.Label,
+		Path:    buildDir,
+		SGXMode: pkg.Metadata.SGXMode,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	rebuiltPkg, err := ccpackager.ReadCCPackage(rebuilt)
+	if err != nil {
+		return "", err
+	}
+
+	for name, content := range pkg.CodeFiles {
+		if !bytes.Equal(content, rebuiltPkg.CodeFiles[name]) {
+			return "", errors.Errorf("artifact %s differs from %s", name, filepath.Join(buildDir, name))
+		}
+	}
+
+	if rebuiltPkg.ID != pkg.ID {
+		return fmt.Sprintf("artifacts match, package ID differs: %s", rebuiltPkg.ID), nil
+	}
+	return "package ID matches", nil
+}
+
+// verifyManifest checks the signature of the build manifest and compares its statements with the package
+func verifyManifest(pkg *ccpackager.Package, opts *verifyOptions) error {
+	if opts.manifestSignature == "" || opts.manifestKey == "" {
+		return errors.New("verifying a build manifest requires --manifest-sig and --manifest-key")
+	}
+
+	manifestBytes, err := os.ReadFile(opts.manifest)
+	if err != nil {
+		return err
+	}
+
+	sigBase64, err := os.ReadFile(opts.manifestSignature)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigBase64)))
+	if err != nil {
+		return errors.Wrap(err, "cannot decode manifest signature")
+	}
+
+	pub, err := readPublicKey(opts.manifestKey)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.Sum256(manifestBytes)
+	if !ecdsa.VerifyASN1(pub, h[:], sig) {
+		return errors.New("invalid manifest signature")
+	}
+
+	manifest := &buildManifest{}
+	if err := json.Unmarshal(manifestBytes, manifest); err != nil {
+		return errors.Wrap(err, "invalid manifest")
+	}
+
+	if manifest.Label != pkg.Metadata.Label {
+		return errors.Errorf("label mismatch: package=%s, manifest=%s", pkg.Metadata.Label, manifest.Label)
+	}
+	if manifest.PackageID != pkg.ID {
+		return errors.Errorf("package ID mismatch: package=%s, manifest=%s", pkg.ID, manifest.PackageID)
+	}
+	if pkg.HasMrenclave() || manifest.Mrenclave != "" {
+		return compareMrenclave(pkg, manifest.Mrenclave)
+	}
+	return nil
+}
+
+// readPublicKey reads an ECDSA public key from a PEM encoded public key or certificate
+func readPublicKey(path string) (*ecdsa.PublicKey, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.Errorf("no PEM data found in %s", path)
+	}
+
+	var pub interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub = cert.PublicKey
+	case "PUBLIC KEY":
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unsupported PEM type %s", block.Type)
+	}
+
+	ecdsaPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("manifest key is not an ECDSA key")
+	}
+	return ecdsaPub, nil
+}
+
+func printPackageReport(w io.Writer, report *packageReport) {
+	fmt.Fprintf(w, "Package:                    %s\n", report.File)
+	fmt.Fprintf(w, "Package ID:                 %s\n", report.PackageID)
+	fmt.Fprintf(w, "Label:                      %s\n", report.Label)
+	fmt.Fprintf(w, "Type:                       %s\n", report.Type)
+	fmt.Fprintf(w, "SGX mode:                   %s\n", report.SGXMode)
+	fmt.Fprintf(w, "Mrenclave:                  %s\n", report.Mrenclave)
+	fmt.Fprintf(w, "Checks:\n")
+	for _, check := range report.Checks {
+		status := "OK"
+		if !check.Passed {
+			status = "FAILED"
+		}
+		if check.Detail != "" {
+			status += " (" + check.Detail + ")"
+		}
+		fmt.Fprintf(w, "  %-24s  %s\n", check.Name+":", status)
+	}
+
+	if !report.Verified {
+		fmt.Fprintf(w, "\nVerification:               FAILED\n")
+		if report.References == 0 {
+			fmt.Fprintf(w, "Note: no reference given, the mrenclave was not verified\n")
+		}
+		return
+	}
+	fmt.Fprintf(w, "\nVerification:               OK\n")
+	if report.Mrenclave != "" {
+		fmt.Fprintf(w, "Approve with:               --package-id %s --version %s\n", report.PackageID, report.Mrenclave)
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/fab/ccpackager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBuildDir(t *testing.T, binary string) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "enclave.json"), []byte(`{"exe": "ecc"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ecc"), []byte(binary), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "mrenclave"), []byte(testMrenclave+"\n"), 0644))
+	return dir
+}
+
+func newPackageFile(t *testing.T, buildDir string) (string, []byte) {
+	pkgBytes, err := ccpackager.NewCCPackage(&ccpackager.Descriptor{
+		Type:    ccpackager.GoChaincodeType,
+		Label:   "mycc",
+		Path:    buildDir,
+		SGXMode: "SIM",
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "mycc.tar.gz")
+	require.NoError(t, os.WriteFile(path, pkgBytes, 0644))
+	return path, pkgBytes
+}
+
+func TestPackageVerify(t *testing.T) {
+	path, pkgBytes := newPackageFile(t, newBuildDir(t, "some binary"))
+	pkg, err := ccpackager.ReadCCPackage(pkgBytes)
+	require.NoError(t, err)
+
+	// structure only is not sufficient
+	out, err := runCommand(t, "", "package", "verify", path)
+	assert.EqualError(t, err, "package verification failed: no reference given, use --mrenclave, --compute, --build-dir, or --manifest")
+	assert.Contains(t, out, "Package ID:                 "+pkg.ID)
+	assert.Contains(t, out, "Type:                       fpc-go")
+	assert.Contains(t, out, "Mrenclave:                  "+testMrenclave)
+	assert.Contains(t, out, "Verification:               FAILED")
+	assert.Contains(t, out, "Note: no reference given")
+	assert.NotContains(t, out, "Approve with:")
+
+	// expected mrenclave
+	out, err = runCommand(t, "", "package", "verify", path, "--mrenclave", testMrenclave)
+	require.NoError(t, err)
+	assert.Contains(t, out, "expected mrenclave:       OK")
+	assert.Contains(t, out, "Verification:               OK")
+	assert.NotContains(t, out, "Note: no reference given")
+	assert.Contains(t, out, "Approve with:               --package-id "+pkg.ID+" --version "+testMrenclave)
+
+	out, err = runCommand(t, "", "package", "verify", path, "--mrenclave", "some other mrenclave")
+	assert.EqualError(t, err, "package verification failed")
+	assert.Contains(t, out, "expected mrenclave:       FAILED (mrenclave mismatch")
+	assert.Contains(t, out, "Verification:               FAILED")
+	assert.NotContains(t, out, "Approve with:")
+
+	// rebuild from another directory with the same artifacts
+	out, err = runCommand(t, "", "package", "verify", path, "--build-dir", newBuildDir(t, "some binary"))
+	require.NoError(t, err)
+	assert.Contains(t, out, "rebuild:                  OK (package ID matches)")
+
+	out, err = runCommand(t, "", "package", "verify", path, "--build-dir", newBuildDir(t, "other binary"))
+	assert.Error(t, err)
+	assert.Contains(t, out, "rebuild:                  FAILED (artifact ecc differs")
+
+	// the embedded binary is not an enclave
+	out, err = runCommand(t, "", "package", "verify", path, "--compute")
+	assert.Error(t, err)
+	assert.Contains(t, out, "computed mrenclave:       FAILED")
+
+	// json
+	out, err = runCommand(t, "", "package", "verify", path, "--mrenclave", testMrenclave, "--json")
+	require.NoError(t, err)
+	report := &packageReport{}
+	require.NoError(t, json.Unmarshal([]byte(out), report))
+	assert.True(t, report.Verified)
+	assert.Equal(t, pkg.ID, report.PackageID)
+	assert.Equal(t, testMrenclave, report.Mrenclave)
+	assert.Len(t, report.Checks, 3)
+	assert.Equal(t, 1, report.References)
+
+	out, err = runCommand(t, "", "package", "verify", path, "--json")
+	assert.Error(t, err)
+	report = &packageReport{}
+	require.NoError(t, json.NewDecoder(strings.NewReader(out)).Decode(report))
+	assert.False(t, report.Verified)
+	assert.Equal(t, 0, report.References)
+	assert.Len(t, report.Checks, 2)
+
+	// not a package
+	notAPackage := filepath.Join(t.TempDir(), "not_a_package.tar.gz")
+	require.NoError(t, os.WriteFile(notAPackage, []byte("not a package"), 0644))
+	out, err = runCommand(t, "", "package", "verify", notAPackage)
+	assert.Error(t, err)
+	assert.Contains(t, out, "structure:                FAILED")
+
+	_, err = runCommand(t, "", "package", "verify", filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestPackageVerifyTampered(t *testing.T) {
+	_, pkgBytes := newPackageFile(t, newBuildDir(t, "some binary"))
+
+	// add a file to the package
+	gr, err := gzip.NewReader(bytes.NewReader(pkgBytes))
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+
+	tampered := &bytes.Buffer{}
+	gw := gzip.NewWriter(tampered)
+	tw := tar.NewWriter(gw)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err = io.Copy(tw, tr)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "extra", Mode: 0644, Size: 5, Typeflag: tar.TypeReg}))
+	_, err = tw.Write([]byte("extra"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+
+	path := filepath.Join(t.TempDir(), "tampered.tar.gz")
+	require.NoError(t, os.WriteFile(path, tampered.Bytes(), 0644))
+
+	out, err := runCommand(t, "", "package", "verify", path, "--mrenclave", testMrenclave)
+	assert.Error(t, err)
+	assert.Contains(t, out, "structure:                FAILED")
+}
+
+func TestPackageVerifyManifest(t *testing.T) {
+	path, pkgBytes := newPackageFile(t, newBuildDir(t, "some binary"))
+	pkg, err := ccpackager.ReadCCPackage(pkgBytes)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pubBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	keyPath := filepath.Join(dir, "builder.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}), 0644))
+
+	writeManifest := func(manifest *buildManifest) (string, string) {
+		manifestBytes, err := json.Marshal(manifest)
+		require.NoError(t, err)
+		h := sha256.Sum256(manifestBytes)
+		sig, err := ecdsa.SignASN1(rand.Reader, key, h[:])
+		require.NoError(t, err)
+
+		manifestPath := filepath.Join(dir, "manifest.json")
+		sigPath := filepath.Join(dir, "manifest.sig")
+		require.NoError(t, os.WriteFile(manifestPath, manifestBytes, 0644))
+		require.NoError(t, os.WriteFile(sigPath, []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), 0644))
+		return manifestPath, sigPath
+	}
+
+	manifestPath, sigPath := writeManifest(&buildManifest{Label: "mycc", PackageID: pkg.ID, Mrenclave: testMrenclave})
+	out, err := runCommand(t, "", "package", "verify", path, "--manifest", manifestPath, "--manifest-sig", sigPath, "--manifest-key", keyPath)
+	require.NoError(t, err)
+	assert.Contains(t, out, "build manifest:           OK")
+
+	// missing signature
+	out, err = runCommand(t, "", "package", "verify", path, "--manifest", manifestPath)
+	assert.Error(t, err)
+	assert.Contains(t, out, "requires --manifest-sig and --manifest-key")
+
+	// signature of another manifest
+	_, otherSigPath := writeManifest(&buildManifest{Label: "mycc", PackageID: pkg.ID, Mrenclave: "other mrenclave"})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "signed.json"), []byte(`{"label":"mycc"}`), 0644))
+	out, err = runCommand(t, "", "package", "verify", path, "--manifest", filepath.Join(dir, "signed.json"), "--manifest-sig", otherSigPath, "--manifest-key", keyPath)
+	assert.Error(t, err)
+	assert.Contains(t, out, "invalid manifest signature")
+
+	// signed manifest of another package
+	manifestPath, sigPath = writeManifest(&buildManifest{Label: "mycc", PackageID: "mycc:other", Mrenclave: testMrenclave})
+	out, err = runCommand(t, "", "package", "verify", path, "--manifest", manifestPath, "--manifest-sig", sigPath, "--manifest-key", keyPath)
+	assert.Error(t, err)
+	assert.Contains(t, out, "package ID mismatch")
+
+	manifestPath, sigPath = writeManifest(&buildManifest{Label: "mycc", PackageID: pkg.ID, Mrenclave: "other mrenclave"})
+	out, err = runCommand(t, "", "package", "verify", path, "--manifest", manifestPath, "--manifest-sig", sigPath, "--manifest-key", keyPath)
+	assert.Error(t, err)
+	assert.Contains(t, out, "mrenclave mismatch")
+}
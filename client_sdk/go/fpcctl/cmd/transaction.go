@@ -0,0 +1,139 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/contract"
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/lifecycle"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	invokeCmd = &cobra.Command{
+		Use:   "invoke <function> [args...]",
+		Short: "Submit an encrypted transaction to a FPC chaincode",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTransaction(cmd, args, true)
+		},
+	}
+
+	queryCmd = &cobra.Command{
+		Use:   "query <function> [args...]",
+		Short: "Evaluate an encrypted query of a FPC chaincode",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTransaction(cmd, args, false)
+		},
+	}
+
+	txChaincodeID string
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{invokeCmd, queryCmd} {
+		cmd.Flags().StringVarP(&txChaincodeID, "name", "n", "", "the chaincode ID")
+		addNetworkFlags(cmd)
+		rootCmd.AddCommand(cmd)
+	}
+}
+
+// transactionResult is the output of the invoke and query commands
+type transactionResult struct {
+	Function string `json:"function"`
+	Result   string `json:"result"`
+}
+
+func runTransaction(cmd *cobra.Command, args []string, submit bool) error {
+	if txChaincodeID == "" {
+		return errors.New("a chaincode ID is required, use --name")
+	}
+
+	net, err := connect()
+	if err != nil {
+		return err
+	}
+	defer net.Close()
+
+	client, err := net.ChannelClient(netOpts.channel)
+	if err != nil {
+		return err
+	}
+
+	fpcContract := contract.GetContract(&channelContractProvider{client: client}, txChaincodeID)
+
+	var result []byte
+	if submit {
+		result, err = fpcContract.SubmitTransaction(args[0], args[1:]...)
+	} else {
+		result, err = fpcContract.EvaluateTransaction(args[0], args[1:]...)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s", args[0])
+	}
+
+	out := cmd.OutOrStdout()
+	if jsonOutput {
+		return printJSON(out, &transactionResult{Function: args[0], Result: string(result)})
+	}
+	fmt.Fprintln(out, string(result))
+	return nil
+}
+
+// channelContractProvider provides the contracts required by the FPC client protocol based on a channel client
+type channelContractProvider struct {
+	client lifecycle.ChannelClient
+}
+
+func (p *channelContractProvider) GetContract(id string) contract.Contract {
+	return &channelContract{client: p.client, name: id}
+}
+
+type channelContract struct {
+	client lifecycle.ChannelClient
+	name   string
+}
+
+func (c *channelContract) Name() string {
+	return c.name
+}
+
+func (c *channelContract) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	return c.client.Query(c.name, name, toBytes(args))
+}
+
+// SubmitTransaction returns no payload as the FPC client protocol only submits the endorsement of a result
+// which it has evaluated already
+func (c *channelContract) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	_, err := c.client.Execute(c.name, name, toBytes(args))
+	return nil, err
+}
+
+func (c *channelContract) CreateTransaction(name string, peerEndpoints ...string) (contract.Transaction, error) {
+	return &channelTransaction{contract: c, name: name, peerEndpoints: peerEndpoints}, nil
+}
+
+type channelTransaction struct {
+	contract      *channelContract
+	name          string
+	peerEndpoints []string
+}
+
+func (t *channelTransaction) Evaluate(args ...string) ([]byte, error) {
+	return t.contract.client.Query(t.contract.name, t.name, toBytes(args), t.peerEndpoints...)
+}
+
+func toBytes(args []string) [][]byte {
+	bytes := make([][]byte, len(args))
+	for i, arg := range args {
+		bytes[i] = []byte(arg)
+	}
+	return bytes
+}
@@ -0,0 +1,113 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeEnclave decrypts requests with the chaincode decryption key and returns the encrypted function and args
+type fakeEnclave struct {
+	t        *testing.T
+	csp      crypto.CSP
+	ccEk     []byte
+	ccDk     []byte
+	requests [][]string
+}
+
+func newFakeEnclave(t *testing.T) *fakeEnclave {
+	csp := crypto.GetDefaultCSP()
+	ccEk, ccDk, err := csp.NewRSAKeys()
+	require.NoError(t, err)
+	return &fakeEnclave{t: t, csp: csp, ccEk: ccEk, ccDk: ccDk}
+}
+
+func (e *fakeEnclave) invoke(requestBase64 string) []byte {
+	requestBytes, err := base64.StdEncoding.DecodeString(requestBase64)
+	require.NoError(e.t, err)
+	request := &protos.ChaincodeRequestMessage{}
+	require.NoError(e.t, proto.Unmarshal(requestBytes, request))
+
+	keyTransportBytes, err := e.csp.PkDecryptMessage(e.ccDk, request.EncryptedKeyTransportMessage)
+	require.NoError(e.t, err)
+	keyTransport := &protos.KeyTransportMessage{}
+	require.NoError(e.t, proto.Unmarshal(keyTransportBytes, keyTransport))
+
+	cleartextBytes, err := e.csp.DecryptMessage(keyTransport.RequestEncryptionKey, request.EncryptedRequest)
+	require.NoError(e.t, err)
+	cleartext := &protos.CleartextChaincodeRequest{}
+	require.NoError(e.t, proto.Unmarshal(cleartextBytes, cleartext))
+
+	var args []string
+	for _, arg := range cleartext.Input.Args {
+		args = append(args, string(arg))
+	}
+	e.requests = append(e.requests, args)
+
+	responseBytes, err := protoutil.Marshal(&peer.Response{Status: 200, Payload: []byte(strings.Join(args, ","))})
+	require.NoError(e.t, err)
+	encryptedResponse, err := e.csp.EncryptMessage(keyTransport.ResponseEncryptionKey, responseBytes)
+	require.NoError(e.t, err)
+	responseMessage, err := proto.Marshal(&protos.ChaincodeResponseMessage{EncryptedResponse: encryptedResponse})
+	require.NoError(e.t, err)
+
+	return []byte(utils.MarshallProtoBase64(&protos.SignedChaincodeResponseMessage{ChaincodeResponseMessage: responseMessage}))
+}
+
+func TestInvokeAndQuery(t *testing.T) {
+	net := useFakeNetwork(t)
+	enclave := newFakeEnclave(t)
+	net.client.QueryCalls(func(chaincodeID string, fcn string, args [][]byte, targets ...string) ([]byte, error) {
+		switch {
+		case chaincodeID == "ercc" && fcn == "queryChaincodeEncryptionKey":
+			return []byte(base64.StdEncoding.EncodeToString(enclave.ccEk)), nil
+		case chaincodeID == "ercc" && fcn == "queryChaincodeEndPoints":
+			return []byte("peer0.org1.example.com:7051"), nil
+		case chaincodeID == "mycc" && fcn == "__invoke":
+			assert.Equal(t, []string{"peer0.org1.example.com:7051"}, targets)
+			return enclave.invoke(string(args[0])), nil
+		default:
+			return nil, fmt.Errorf("unexpected query %s", fcn)
+		}
+	})
+	net.client.ExecuteReturns("endorseTxID", nil)
+
+	out, err := runCommand(t, "", "query", "-n", "mycc", "getBalance", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "getBalance,alice\n", out)
+	assert.Equal(t, 0, net.client.ExecuteCallCount())
+
+	out, err = runCommand(t, "", "invoke", "-n", "mycc", "transfer", "alice", "bob", "10", "--json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"function": "transfer", "result": "transfer,alice,bob,10"}`, out)
+	assert.Equal(t, [][]string{{"getBalance", "alice"}, {"transfer", "alice", "bob", "10"}}, enclave.requests)
+
+	// the endorsed response is submitted
+	require.Equal(t, 1, net.client.ExecuteCallCount())
+	chaincodeID, fcn, _ := net.client.ExecuteArgsForCall(0)
+	assert.Equal(t, "mycc", chaincodeID)
+	assert.Equal(t, "__endorse", fcn)
+
+	net.client.ExecuteReturns("", fmt.Errorf("endorsement policy failure"))
+	_, err = runCommand(t, "", "invoke", "-n", "mycc", "transfer", "alice", "bob", "10")
+	assert.EqualError(t, err, "failed to call transfer: endorsement policy failure")
+
+	_, err = runCommand(t, "", "query", "getBalance")
+	assert.EqualError(t, err, "a chaincode ID is required, use --name")
+}
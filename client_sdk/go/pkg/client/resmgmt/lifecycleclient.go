@@ -34,11 +34,20 @@ SPDX-License-Identifier: Apache-2.0
 //		log.Fatal(err)
 //	}
 //
+// FPC specific behavior, such as the credential converter, can be configured with Option using NewWithOptions:
+//
+//	client, err := resmgmt.NewWithOptions(adminContext, []resmgmt.Option{
+//		resmgmt.WithIASUrl("https://my-ias-proxy.example.com/attestation/v4/report"),
+//		resmgmt.WithTimeout(30 * time.Second),
+//	})
+//
 // See also `lifecycle_test.go` and `$FPC_PATH/integration/client_sdk/go/utils/utils.go`
 // for a running example.
 package resmgmt
 
 import (
+	"time"
+
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/lifecycle"
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/sgx"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/resmgmt"
@@ -63,15 +72,73 @@ type Client struct {
 	lifecycleClient *lifecycle.Client
 }
 
+type options struct {
+	lifecycleOpts []lifecycle.Option
+	timeout       time.Duration
+}
+
+// Option configures the FPC specific behavior of a Client
+type Option func(*options)
+
+// WithCredentialConverter option allows to replace the default credential converter
+func WithCredentialConverter(converter lifecycle.CredentialConverter) Option {
+	return func(o *options) {
+		o.lifecycleOpts = append(o.lifecycleOpts, lifecycle.WithCredentialConverter(converter))
+	}
+}
+
+// WithIASUrl option allows to override the IAS endpoint used by the default credential converter
+func WithIASUrl(url string) Option {
+	return func(o *options) {
+		o.lifecycleOpts = append(o.lifecycleOpts, lifecycle.WithIASUrl(url))
+	}
+}
+
+// WithCredentialVerifier option enables the local verification of credentials before they are registered at ERCC
+func WithCredentialVerifier(verifier lifecycle.CredentialVerifier) Option {
+	return func(o *options) {
+		o.lifecycleOpts = append(o.lifecycleOpts, lifecycle.WithCredentialVerifier(verifier))
+	}
+}
+
+// WithCredentialStore option enables resuming failed enclave registrations without initializing another enclave,
+// see lifecycle.NewFileCredentialStore for a store keeping the credentials in a local directory.
+func WithCredentialStore(store lifecycle.CredentialStore) Option {
+	return func(o *options) {
+		o.lifecycleOpts = append(o.lifecycleOpts, lifecycle.WithCredentialStore(store))
+	}
+}
+
+// WithTimeout option sets a time limit for the requests sent to the peers and to the attestation service
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+		o.lifecycleOpts = append(o.lifecycleOpts, lifecycle.WithTimeout(timeout))
+	}
+}
+
 // New returns a FPC resource management client instance.
 func New(ctxProvider context.ClientProvider, opts ...resmgmt.ClientOption) (*Client, error) {
+	return NewWithOptions(ctxProvider, nil, opts...)
+}
+
+// NewWithOptions returns a FPC resource management client instance configured with the given FPC options.
+func NewWithOptions(ctxProvider context.ClientProvider, fpcOpts []Option, opts ...resmgmt.ClientOption) (*Client, error) {
+	o := &options{}
+	for _, opt := range fpcOpts {
+		opt(o)
+	}
+
 	// get resource management client
 	client, err := resmgmt.New(ctxProvider, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	lifecycleClient, err := lifecycle.New(NewChannelClientProvider(ctxProvider).ChannelClient)
+	channelClientProvider := NewChannelClientProvider(ctxProvider)
+	channelClientProvider.timeout = o.timeout
+
+	lifecycleClient, err := lifecycle.New(channelClientProvider.ChannelClient, o.lifecycleOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -83,6 +150,8 @@ func New(ctxProvider context.ClientProvider, opts ...resmgmt.ClientOption) (*Cli
 }
 
 // LifecycleInitEnclave initializes and registers an enclave for a particular FPC chaincode.
+// If an enclave is registered for the target peer already, an empty transaction id is returned.
+// As ERCC accepts a single enclave per chaincode, the request fails if an enclave is registered at another peer.
 func (rc *Client) LifecycleInitEnclave(channelId string, req LifecycleInitEnclaveRequest, options ...resmgmt.RequestOption) (fab.TransactionID, error) {
 	txID, err := rc.lifecycleClient.LifecycleInitEnclave(channelId, lifecycle.LifecycleInitEnclaveRequest{
 		ChaincodeID:         req.ChaincodeID,
@@ -94,3 +163,42 @@ func (rc *Client) LifecycleInitEnclave(channelId string, req LifecycleInitEnclav
 	}
 	return fab.TransactionID(txID), nil
 }
+
+// LifecycleExportCCKeys lets the registered enclave of a FPC chaincode hand over its chaincode keys for the enclave of an
+// upgraded chaincode version. It must be called before the new chaincode definition is committed.
+func (rc *Client) LifecycleExportCCKeys(channelId string, chaincodeId string, enclavePeerEndpoint string, options ...resmgmt.RequestOption) error {
+	return rc.lifecycleClient.LifecycleExportCCKeys(channelId, chaincodeId, enclavePeerEndpoint)
+}
+
+// LifecycleUpgradeEnclave initializes an enclave for the upgraded version of a FPC chaincode and hands over
+// the chaincode keys from the enclave of the previous version, which exported them with LifecycleExportCCKeys.
+func (rc *Client) LifecycleUpgradeEnclave(channelId string, req LifecycleInitEnclaveRequest, options ...resmgmt.RequestOption) (fab.TransactionID, error) {
+	txID, err := rc.lifecycleClient.LifecycleUpgradeEnclave(channelId, lifecycle.LifecycleInitEnclaveRequest{
+		ChaincodeID:         req.ChaincodeID,
+		EnclavePeerEndpoint: req.EnclavePeerEndpoint,
+		AttestationParams:   req.AttestationParams,
+	})
+	if err != nil {
+		return fab.TransactionID(txID), err
+	}
+	return fab.TransactionID(txID), nil
+}
+
+// LifecycleApproveUpgradePolicy approves an upgrade policy for a FPC chaincode on behalf of the organization of the client.
+func (rc *Client) LifecycleApproveUpgradePolicy(channelId string, chaincodeId string, allowedVersions []string, options ...resmgmt.RequestOption) (fab.TransactionID, error) {
+	txID, err := rc.lifecycleClient.LifecycleApproveUpgradePolicy(channelId, chaincodeId, allowedVersions)
+	if err != nil {
+		return fab.EmptyTransactionID, err
+	}
+	return fab.TransactionID(txID), nil
+}
+
+// LifecycleSetUpgradePolicy sets the chaincode versions (mrenclave) the enclave of a FPC chaincode may export its keys to.
+// The policy must be approved by the organizations of all endorsing peers of ERCC (see LifecycleApproveUpgradePolicy).
+func (rc *Client) LifecycleSetUpgradePolicy(channelId string, chaincodeId string, allowedVersions []string, options ...resmgmt.RequestOption) (fab.TransactionID, error) {
+	txID, err := rc.lifecycleClient.LifecycleSetUpgradePolicy(channelId, chaincodeId, allowedVersions)
+	if err != nil {
+		return fab.EmptyTransactionID, err
+	}
+	return fab.TransactionID(txID), nil
+}
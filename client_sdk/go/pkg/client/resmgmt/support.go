@@ -1,6 +1,8 @@
 package resmgmt
 
 import (
+	"time"
+
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/lifecycle"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
@@ -20,6 +22,7 @@ type GoSDKChannelClient interface {
 
 type channelClient struct {
 	goSDKChannelClient GoSDKChannelClient
+	timeout            time.Duration
 }
 
 func (c *channelClient) Query(chaincodeID string, fcn string, args [][]byte, targetEndpoints ...string) ([]byte, error) {
@@ -32,6 +35,9 @@ func (c *channelClient) Query(chaincodeID string, fcn string, args [][]byte, tar
 	var initOpts []channel.RequestOption
 	initOpts = append(initOpts, channel.WithRetry(retry.Opts{Attempts: 0}))
 	initOpts = append(initOpts, channel.WithTargetEndpoints(targetEndpoints...))
+	if c.timeout > 0 {
+		initOpts = append(initOpts, channel.WithTimeout(fab.Query, c.timeout))
+	}
 
 	// send query to create (init) enclave at the target peer
 	initResponse, err := c.goSDKChannelClient.Query(initRequest, initOpts...)
@@ -51,6 +57,9 @@ func (c *channelClient) Execute(chaincodeID string, fcn string, args [][]byte) (
 	var opts []channel.RequestOption
 	// TODO translate `resmgmt.RequestOption` to `channel.Option` options so we can pass it to execute
 	//opts = append(opts, options...)
+	if c.timeout > 0 {
+		opts = append(opts, channel.WithTimeout(fab.Execute, c.timeout))
+	}
 
 	// invoke registerEnclave at enclave registry
 	response, err := c.goSDKChannelClient.Execute(request, opts...)
@@ -63,6 +72,7 @@ func (c *channelClient) Execute(chaincodeID string, fcn string, args [][]byte) (
 
 type ChannelClientProvider struct {
 	ctxProvider context.ClientProvider
+	timeout     time.Duration
 }
 
 func NewChannelClientProvider(ctxProvider context.ClientProvider) *ChannelClientProvider {
@@ -77,5 +87,5 @@ func (c *ChannelClientProvider) ChannelClient(id string) (lifecycle.ChannelClien
 	if err != nil {
 		return nil, err
 	}
-	return &channelClient{goSDKChannelClient: client}, nil
+	return &channelClient{goSDKChannelClient: client, timeout: c.timeout}, nil
 }
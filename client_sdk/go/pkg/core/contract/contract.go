@@ -12,12 +12,28 @@ import (
 	"strings"
 
 	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric/bccsp"
 	"github.com/hyperledger/fabric/common/flogging"
 )
 
 var logger = flogging.MustGetLogger("fpc-client-contract")
 
+// ChaincodeError is returned by EvaluateTransaction and SubmitTransaction if the chaincode enclave reports an error.
+// Use errors.As to inspect the error code.
+type ChaincodeError = crypto.ChaincodeError
+
+// ErrorCode classifies the errors reported by a chaincode enclave
+type ErrorCode = protos.ErrorCode
+
+const (
+	CodeChaincodeError  = protos.ErrorCode_ERROR_CODE_CHAINCODE_ERROR
+	CodeInvalidProposal = protos.ErrorCode_ERROR_CODE_INVALID_PROPOSAL
+	CodeInvalidRequest  = protos.ErrorCode_ERROR_CODE_INVALID_REQUEST
+	CodeInternalError   = protos.ErrorCode_ERROR_CODE_INTERNAL_ERROR
+)
+
 // Transaction interface that is needed by the FPC contract implementation
 type Transaction interface {
 	Evaluate(args ...string) ([]byte, error)
@@ -35,18 +51,45 @@ type Provider interface {
 	GetContract(id string) Contract
 }
 
+type options struct {
+	csp crypto.CSP
+}
+
+// Option configures the contracts created by GetContract
+type Option func(o *options)
+
+// WithBCCSP sets the Fabric BCCSP, e.g., a PKCS#11 implementation, used for the FPC cryptography of the client.
+// Note that the client only creates ephemeral symmetric keys, which it encrypts with the chaincode encryption key,
+// and the BCCSP offers neither AES-GCM nor RSA-OAEP; hence, all these operations still use the Go implementation
+// (see crypto.BCCSPCrypto) and no key of the client is held by the BCCSP. The identity which signs the proposals
+// and transactions is not affected by this option; to keep it in an HSM, configure the BCCSP of the Fabric SDK.
+func WithBCCSP(csp bccsp.BCCSP) Option {
+	return func(o *options) {
+		if csp == nil {
+			panic("invalid bccsp")
+		}
+		o.csp = crypto.NewBCCSPCrypto(csp)
+	}
+}
+
 // GetContract is the factory method for creating FPC Contract objects.
 //
 //	Parameters:
 //	network is an initialized Fabric network object
 //	chaincodeID is the ID of the target chaincode
+//	options configure the contract, e.g., WithBCCSP
 //
 //	Returns:
 //	The contractImpl object
-func GetContract(p Provider, chaincodeID string) *contractImpl {
+func GetContract(p Provider, chaincodeID string, opts ...Option) *contractImpl {
+	o := &options{csp: crypto.GetDefaultCSP()}
+	for _, option := range opts {
+		option(o)
+	}
+
 	ercc := p.GetContract("ercc")
 	return New(p.GetContract(chaincodeID), ercc, nil, &crypto.EncryptionProviderImpl{
-		CSP: crypto.GetDefaultCSP(),
+		CSP: o.csp,
 		GetCcEncryptionKey: func() ([]byte, error) {
 			// Note that this function is called during EncryptionProvider.NewEncryptionContext()
 			return ercc.EvaluateTransaction("queryChaincodeEncryptionKey", chaincodeID)
@@ -117,19 +160,25 @@ func (c *contractImpl) SubmitTransaction(name string, args ...string) ([]byte, e
 		return nil, err
 	}
 
-	logger.Debugf("calling __endorse!")
-	_, err = c.target.SubmitTransaction("__endorse", string(encryptedResponse))
+	// reveal the response first as failed invocations are not submitted
+	clearResponseBytes, err := ctx.Reveal(encryptedResponse)
 	if err != nil {
 		return nil, err
 	}
 
-	clearResponseBytes, err := ctx.Reveal(encryptedResponse)
+	// unwrap Response.Payload
+	payload, err := utils.UnwrapResponse(clearResponseBytes)
 	if err != nil {
 		return nil, err
 	}
 
-	// unwrap Response.Payload
-	return utils.UnwrapResponse(clearResponseBytes)
+	logger.Debugf("calling __endorse!")
+	_, err = c.target.SubmitTransaction("__endorse", string(encryptedResponse))
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
 }
 
 // getPeerEndpoints returns an array of peer endpoints that host the FPC chaincode enclave
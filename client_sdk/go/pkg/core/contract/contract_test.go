@@ -14,6 +14,7 @@ import (
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/contract/fakes"
 	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
 	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/bccsp/factory"
 	"github.com/hyperledger/fabric/protoutil"
 	"github.com/stretchr/testify/assert"
 )
@@ -61,6 +62,16 @@ func TestNewContract(t *testing.T) {
 	assert.Equal(t, chaincodeID, mockProvider.GetContractArgsForCall(1))
 }
 
+func TestNewContractWithBCCSP(t *testing.T) {
+	mockProvider := &fakes.ContractProvider{}
+	mockProvider.GetContractReturns(&fakes.Contract{})
+
+	contract := fpccontract.GetContract(mockProvider, "myChaincode", fpccontract.WithBCCSP(factory.GetDefault()))
+	assert.NotNil(t, contract)
+
+	assert.Panics(t, func() { fpccontract.GetContract(mockProvider, "myChaincode", fpccontract.WithBCCSP(nil)) })
+}
+
 func TestContractName(t *testing.T) {
 
 	chaincodeID := "myChaincode"
@@ -192,15 +203,28 @@ func TestContractEvaluateAndSubmitTransactionFail(t *testing.T) {
 	assert.Nil(t, resp)
 	assert.Error(t, err)
 
-	// see what happens if __endorse fails
-	txn.EvaluateReturnsOnCall(0, expectedResult, nil)
+	// see what happens if the enclave reports an error
+	chaincodeErr := &fpccontract.ChaincodeError{Code: fpccontract.CodeChaincodeError, Message: "chaincode failed"}
+	mockEncryptionContext.RevealReturns(nil, chaincodeErr)
+	txn.EvaluateReturns(expectedResult, nil)
 	mockContract.CreateTransactionReturns(txn, nil)
+
+	// failed without submitting the response
+	resp, err = contract.SubmitTransaction("someFunction", "arg1", "arg2")
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, chaincodeErr)
+	assert.Equal(t, 0, mockContract.SubmitTransactionCallCount())
+
+	// see what happens if __endorse fails
+	mockEncryptionContext.RevealCalls(func(input []byte) ([]byte, error) {
+		return asResponseBytes(input), nil
+	})
 	mockContract.SubmitTransactionReturns(nil, fmt.Errorf("endorse failed"))
 
 	// failed
 	resp, err = contract.SubmitTransaction("someFunction", "arg1", "arg2")
 	assert.Nil(t, resp)
-	assert.Error(t, err)
+	assert.EqualError(t, err, "endorse failed")
 }
 
 func TestContractSubmitTransaction(t *testing.T) {
@@ -13,7 +13,10 @@ SPDX-License-Identifier: Apache-2.0
 //
 //	adminContext := sdk.Context(fabsdk.WithUser(orgAdmin), fabsdk.WithOrg(orgName))
 //
-//	client, err := lifecycle.New(adminContext)
+//	client, err := lifecycle.New(getChannelClient,
+//		lifecycle.WithIASUrl("https://my-ias-proxy.example.com/attestation/v4/report"),
+//		lifecycle.WithCredentialVerifier(ercc.GetAvailableVerifier()),
+//	)
 //	if err != nil {
 //		log.Fatal(err)
 //	}
@@ -39,19 +42,35 @@ SPDX-License-Identifier: Apache-2.0
 package lifecycle
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
 	"github.com/hyperledger/fabric/common/flogging"
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/sgx"
 	"github.com/hyperledger/fabric-private-chaincode/internal/attestation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
 	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
 )
 
 const (
-	ERCC               = "ercc"
-	InitEnclaveCMD     = "__initEnclave"
-	RegisterEnclaveCMD = "registerEnclave"
+	ERCC                           = "ercc"
+	InitEnclaveCMD                 = "__initEnclave"
+	ExportCCKeysCMD                = "__exportCCKeys"
+	ImportCCKeysCMD                = "__importCCKeys"
+	EnclaveInfoCMD                 = "__enclaveInfo"
+	RegisterEnclaveCMD             = "registerEnclave"
+	UpgradeEnclaveCMD              = "upgradeEnclave"
+	ApproveUpgradePolicyCMD        = "approveUpgradePolicy"
+	SetUpgradePolicyCMD            = "setUpgradePolicy"
+	QueryListEnclaveCredentialsCMD = "queryListEnclaveCredentials"
 )
 
 var logger = flogging.MustGetLogger("fpc-client-lifecycle")
@@ -69,6 +88,12 @@ type CredentialConverter interface {
 	ConvertCredentials(credentialsOnlyAttestation string) (credentialsWithEvidence string, err error)
 }
 
+// CredentialVerifier verifies converted credentials before they are submitted to the enclave registry.
+// Note that ercc.GetAvailableVerifier() returns a verifier performing the same checks as ERCC.
+type CredentialVerifier interface {
+	VerifyCredentials(credentials *protos.Credentials, expectedMrenclave string) error
+}
+
 // ChannelClient models an interface to query and execute chaincodes
 type ChannelClient interface {
 	Query(chaincodeID string, fcn string, args [][]byte, targetEndpoints ...string) ([]byte, error)
@@ -83,21 +108,89 @@ type GetChannelClientFunction func(channelID string) (ChannelClient, error)
 type Client struct {
 	GetChannelClient GetChannelClientFunction
 	Converter        CredentialConverter
+	// Verifier is optional; if set, credentials are verified locally before registerEnclave is submitted
+	Verifier CredentialVerifier
+	// Store is optional; if set, credentials are kept until their registration succeeded
+	Store CredentialStore
+}
+
+type options struct {
+	converter CredentialConverter
+	verifier  CredentialVerifier
+	store     CredentialStore
+	iasOpts   []epid.IASClientOption
+}
+
+// Option configures a Client
+type Option func(*options)
+
+// WithCredentialConverter option allows to replace the default credential converter, for instance, to support
+// additional attestation types or to use an attestation proxy.
+func WithCredentialConverter(converter CredentialConverter) Option {
+	return func(o *options) {
+		o.converter = converter
+	}
+}
+
+// WithIASUrl option allows to override the IAS endpoint used by the default credential converter
+func WithIASUrl(url string) Option {
+	return func(o *options) {
+		o.iasOpts = append(o.iasOpts, epid.WithUrl(url))
+	}
+}
+
+// WithTimeout option sets a time limit for attestation service requests of the default credential converter
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.iasOpts = append(o.iasOpts, epid.WithTimeout(timeout))
+	}
+}
+
+// WithCredentialVerifier option enables the local verification of credentials before they are registered at ERCC.
+// The credentials are verified against the chaincode version (mrenclave) contained in the attested data.
+func WithCredentialVerifier(verifier CredentialVerifier) Option {
+	return func(o *options) {
+		o.verifier = verifier
+	}
+}
+
+// WithCredentialStore option enables resuming failed enclave registrations. The credentials of a new enclave
+// are stored before its registration is submitted and re-used instead of initializing another enclave
+// at the same peer until the registration succeeded. Stored credentials are only re-used if the peer still runs
+// their enclave; otherwise, they are dropped and a new enclave is initialized.
+func WithCredentialStore(store CredentialStore) Option {
+	return func(o *options) {
+		o.store = store
+	}
 }
 
 // New returns a FPC resource management client instance.
-func New(getChannelClient GetChannelClientFunction) (*Client, error) {
-	// use default credentials converter
-	// TODO allow to override credential converter using opts
+// By default, the client uses the default credential converter and does not verify credentials before registration.
+// Note that WithIASUrl and WithTimeout have no effect if a custom converter is set via WithCredentialConverter.
+func New(getChannelClient GetChannelClientFunction, opts ...Option) (*Client, error) {
 	if getChannelClient == nil {
 		return nil, errors.Errorf("invalid arguments, channel client loader is nil")
 	}
 
-	converter := attestation.NewDefaultCredentialConverter()
-	return &Client{GetChannelClient: getChannelClient, Converter: converter}, nil
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	converter := o.converter
+	if converter == nil {
+		converter = attestation.NewDefaultCredentialConverter(o.iasOpts...)
+	}
+
+	return &Client{GetChannelClient: getChannelClient, Converter: converter, Verifier: o.verifier, Store: o.store}, nil
 }
 
 // LifecycleInitEnclave initializes and registers an enclave for a particular FPC chaincode.
+// If ERCC has an enclave registered for the target peer already, no new enclave is initialized and
+// an empty transaction id is returned, so a failed request can simply be re-run.
+// Note that ERCC accepts a single enclave per chaincode, as the chaincode keys generated by an enclave cannot be
+// distributed to the enclaves at other peers yet (key distribution is a Post-MVP feature). Hence, the request fails
+// before an enclave is initialized if an enclave is registered at another peer.
 func (rc *Client) LifecycleInitEnclave(channelID string, req LifecycleInitEnclaveRequest) (string, error) {
 	err := rc.verifyInitEnclaveRequest(req)
 	if err != nil {
@@ -109,6 +202,291 @@ func (rc *Client) LifecycleInitEnclave(channelID string, req LifecycleInitEnclav
 		return "", errors.Wrap(err, "Failed to create new channel client")
 	}
 
+	registered, err := registeredEndpoints(channelClient, req.ChaincodeID)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to query registered enclaves")
+	}
+	if registered[req.EnclavePeerEndpoint] {
+		logger.Infof("enclave already registered for %s", req.EnclavePeerEndpoint)
+		rc.deletePendingCredentials(channelID, req)
+		return "", nil
+	}
+	for endpoint := range registered {
+		// the enclave of another peer
+		return "", errors.Errorf("ERCC accepts a single enclave per chaincode, but an enclave is already registered at %s", endpoint)
+	}
+
+	convertedCredentials, err := rc.enclaveCredentials(channelClient, channelID, req)
+	if err != nil {
+		return "", err
+	}
+
+	return rc.registerEnclave(channelClient, channelID, req, convertedCredentials)
+}
+
+// LifecycleExportCCKeys lets the registered enclave of a FPC chaincode hand over its chaincode keys for the enclave of an
+// upgraded chaincode version allowed by the upgrade policy (see LifecycleSetUpgradePolicy). The keys are escrowed at the
+// trusted ledger (TLCC) of the peer hosting the enclave. As the peer dispatches to the upgraded chaincode once its
+// definition is committed, the keys must be exported before the new chaincode definition is committed; the new enclave
+// must then be initialized at the same peer (see LifecycleUpgradeEnclave).
+func (rc *Client) LifecycleExportCCKeys(channelID string, chaincodeID string, enclavePeerEndpoint string) error {
+	if chaincodeID == "" {
+		return errors.New("chaincodeId is required")
+	}
+
+	if enclavePeerEndpoint == "" {
+		return errors.New("peer of the registered enclave is required")
+	}
+
+	channelClient, err := rc.GetChannelClient(channelID)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create new channel client")
+	}
+
+	logger.Debugf("calling __exportCCKeys")
+	if _, err := channelClient.Query(chaincodeID, ExportCCKeysCMD, nil, enclavePeerEndpoint); err != nil {
+		return errors.Wrap(err, "Failed to query export cc keys")
+	}
+
+	return nil
+}
+
+// LifecycleUpgradeEnclave initializes an enclave for the upgraded version of a FPC chaincode and hands over
+// the chaincode keys from the enclave of the previous version. That is, the new enclave replaces the previous enclave
+// at ERCC and imports the keys the previous enclave escrowed with LifecycleExportCCKeys.
+// The new chaincode definition must be committed, the upgrade policy must allow the new chaincode version, and the new
+// enclave must be initialized at the peer which hosted the previous enclave.
+func (rc *Client) LifecycleUpgradeEnclave(channelID string, req LifecycleInitEnclaveRequest) (string, error) {
+	err := rc.verifyInitEnclaveRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	channelClient, err := rc.GetChannelClient(channelID)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to create new channel client")
+	}
+
+	convertedCredentials, err := rc.initEnclave(channelClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Debugf("calling upgradeEnclave")
+	txID, err := channelClient.Execute(ERCC, UpgradeEnclaveCMD, [][]byte{[]byte(convertedCredentials)})
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to execute upgrade enclave")
+	}
+
+	logger.Debugf("calling __importCCKeys")
+	_, err = channelClient.Query(req.ChaincodeID, ImportCCKeysCMD, nil, req.EnclavePeerEndpoint)
+	if err != nil {
+		return txID, errors.Wrap(err, "Failed to query import cc keys")
+	}
+
+	return txID, nil
+}
+
+// LifecycleApproveUpgradePolicy approves an upgrade policy for a FPC chaincode on behalf of the organization of the client.
+// The policy is set with LifecycleSetUpgradePolicy once the organizations required by the endorsement policy of ERCC
+// approved it.
+func (rc *Client) LifecycleApproveUpgradePolicy(channelID string, chaincodeID string, allowedVersions []string) (string, error) {
+	args, err := upgradePolicyArgs(chaincodeID, allowedVersions)
+	if err != nil {
+		return "", err
+	}
+
+	channelClient, err := rc.GetChannelClient(channelID)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to create new channel client")
+	}
+
+	txID, err := channelClient.Execute(ERCC, ApproveUpgradePolicyCMD, args)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to execute approve upgrade policy")
+	}
+
+	return txID, nil
+}
+
+// LifecycleSetUpgradePolicy sets the chaincode versions (mrenclave) the enclave of a FPC chaincode may export its keys to.
+// The policy must be approved by the organizations of all endorsing peers of ERCC (see LifecycleApproveUpgradePolicy).
+func (rc *Client) LifecycleSetUpgradePolicy(channelID string, chaincodeID string, allowedVersions []string) (string, error) {
+	args, err := upgradePolicyArgs(chaincodeID, allowedVersions)
+	if err != nil {
+		return "", err
+	}
+
+	channelClient, err := rc.GetChannelClient(channelID)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to create new channel client")
+	}
+
+	txID, err := channelClient.Execute(ERCC, SetUpgradePolicyCMD, args)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to execute set upgrade policy")
+	}
+
+	return txID, nil
+}
+
+// upgradePolicyArgs returns the ERCC arguments for an upgrade policy, i.e., the base64-encoded policy
+func upgradePolicyArgs(chaincodeID string, allowedVersions []string) ([][]byte, error) {
+	if chaincodeID == "" {
+		return nil, errors.New("chaincodeId is required")
+	}
+
+	if len(allowedVersions) == 0 {
+		return nil, errors.New("allowed versions are required")
+	}
+
+	upgradePolicy := &protos.UpgradePolicy{
+		ChaincodeId:     chaincodeID,
+		AllowedVersions: allowedVersions,
+	}
+
+	return [][]byte{[]byte(utils.MarshallProtoBase64(upgradePolicy))}, nil
+}
+
+// registeredEndpoints returns the peer endpoints of the enclaves registered at ERCC for the given chaincode
+func registeredEndpoints(channelClient ChannelClient, chaincodeID string) (map[string]bool, error) {
+	payload, err := channelClient.Query(ERCC, QueryListEnclaveCredentialsCMD, [][]byte{[]byte(chaincodeID)})
+	if err != nil {
+		return nil, err
+	}
+
+	registered := make(map[string]bool)
+	if len(payload) == 0 {
+		return registered, nil
+	}
+
+	// ERCC returns a json encoded list of base64 encoded credentials
+	var allCredentials []string
+	if err := json.Unmarshal(payload, &allCredentials); err != nil {
+		return nil, errors.Wrap(err, "invalid enclave credentials list")
+	}
+
+	for _, credentialsBase64 := range allCredentials {
+		credentials, err := utils.UnmarshalCredentials(credentialsBase64)
+		if err != nil {
+			return nil, err
+		}
+		endpoint, err := utils.ExtractEndpoint(credentials)
+		if err != nil {
+			return nil, err
+		}
+		registered[endpoint] = true
+	}
+	return registered, nil
+}
+
+// enclaveCredentials returns the pending credentials of the enclave at the target peer, if a previous
+// registration failed, or initializes a new enclave
+func (rc *Client) enclaveCredentials(channelClient ChannelClient, channelID string, req LifecycleInitEnclaveRequest) (string, error) {
+	if rc.Store != nil {
+		pending, err := rc.Store.Get(channelID, req.ChaincodeID, req.EnclavePeerEndpoint)
+		if err != nil {
+			return "", errors.Wrap(err, "Failed to load pending credentials")
+		}
+		if pending != "" {
+			// the peer may have been restarted or another enclave initialized there meanwhile
+			if err := verifyPendingEnclave(channelClient, req, pending); err != nil {
+				logger.Warningf("dropping pending credentials for %s: %s", req.EnclavePeerEndpoint, err)
+				rc.deletePendingCredentials(channelID, req)
+			} else {
+				logger.Infof("resuming registration of pending enclave at %s", req.EnclavePeerEndpoint)
+				return pending, nil
+			}
+		}
+	}
+
+	convertedCredentials, err := rc.initEnclave(channelClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	if rc.Store != nil {
+		// we continue with the registration anyway as the enclave is lost otherwise
+		if err := rc.Store.Put(channelID, req.ChaincodeID, req.EnclavePeerEndpoint, convertedCredentials); err != nil {
+			logger.Warningf("cannot store pending credentials for %s: %s", req.EnclavePeerEndpoint, err)
+		}
+	}
+
+	return convertedCredentials, nil
+}
+
+// verifyPendingEnclave checks that the enclave of the pending credentials still runs at the target peer.
+// The peer returns the enclave info signed by its current enclave along with a fresh nonce.
+func verifyPendingEnclave(channelClient ChannelClient, req LifecycleInitEnclaveRequest, pending string) error {
+	credentials, err := utils.UnmarshalCredentials(pending)
+	if err != nil {
+		return err
+	}
+	attestedData, err := utils.UnmarshalAttestedData(credentials.GetSerializedAttestedData())
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "cannot create nonce")
+	}
+	encodedNonce := hex.EncodeToString(nonce)
+
+	resp, err := channelClient.Query(req.ChaincodeID, EnclaveInfoCMD, [][]byte{[]byte(encodedNonce)}, req.EnclavePeerEndpoint)
+	if err != nil {
+		return errors.Wrap(err, "Failed to query enclave info")
+	}
+	signedEnclaveInfoBytes, err := base64.StdEncoding.DecodeString(string(resp))
+	if err != nil {
+		return errors.Wrap(err, "invalid enclave info")
+	}
+	signedEnclaveInfo := &protos.SignedEnclaveInfo{}
+	if err := proto.Unmarshal(signedEnclaveInfoBytes, signedEnclaveInfo); err != nil {
+		return errors.Wrap(err, "invalid enclave info")
+	}
+	enclaveInfo := &protos.EnclaveInfo{}
+	if err := proto.Unmarshal(signedEnclaveInfo.GetEnclaveInfo(), enclaveInfo); err != nil {
+		return errors.Wrap(err, "invalid enclave info")
+	}
+
+	if enclaveId := utils.GetEnclaveId(attestedData); enclaveInfo.GetEnclaveId() != enclaveId {
+		return errors.Errorf("peer runs enclave %s instead of %s", enclaveInfo.GetEnclaveId(), enclaveId)
+	}
+	if string(enclaveInfo.GetNonce()) != encodedNonce {
+		return errors.New("enclave info does not contain the nonce")
+	}
+	if err := crypto.GetDefaultCSP().VerifyMessage(attestedData.GetEnclaveVk(), signedEnclaveInfo.GetEnclaveInfo(), signedEnclaveInfo.GetSignature()); err != nil {
+		return errors.Wrap(err, "invalid enclave info signature")
+	}
+	return nil
+}
+
+func (rc *Client) registerEnclave(channelClient ChannelClient, channelID string, req LifecycleInitEnclaveRequest, credentials string) (string, error) {
+	logger.Debugf("calling registerEnclave")
+	// invoke registerEnclave at enclave registry
+	txID, err := channelClient.Execute(ERCC, RegisterEnclaveCMD, [][]byte{[]byte(credentials)})
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to execute register enclave")
+	}
+
+	rc.deletePendingCredentials(channelID, req)
+	return txID, nil
+}
+
+// deletePendingCredentials removes the credentials of a registered enclave from the store.
+// Failures are only logged, as the registration is detected at ERCC on the next run anyway.
+func (rc *Client) deletePendingCredentials(channelID string, req LifecycleInitEnclaveRequest) {
+	if rc.Store == nil {
+		return
+	}
+	if err := rc.Store.Delete(channelID, req.ChaincodeID, req.EnclavePeerEndpoint); err != nil {
+		logger.Warningf("cannot delete pending credentials for %s: %s", req.EnclavePeerEndpoint, err)
+	}
+}
+
+// initEnclave creates a new enclave at the target peer and returns its converted (and optionally verified) credentials
+func (rc *Client) initEnclave(channelClient ChannelClient, req LifecycleInitEnclaveRequest) (string, error) {
 	// serialize provided attestation params
 	serializedJSONParams, err := req.AttestationParams.ToBase64EncodedJSON()
 	if err != nil {
@@ -141,14 +519,28 @@ func (rc *Client) LifecycleInitEnclave(channelID string, req LifecycleInitEnclav
 		return "", errors.Wrap(err, "credentials conversion error")
 	}
 
-	logger.Debugf("calling registerEnclave")
-	// invoke registerEnclave at enclave registry
-	txID, err := channelClient.Execute(ERCC, RegisterEnclaveCMD, [][]byte{[]byte(convertedCredentials)})
+	if rc.Verifier != nil {
+		logger.Debugf("verifying credentials")
+		if err := rc.verifyCredentials(convertedCredentials); err != nil {
+			return "", errors.Wrap(err, "credentials verification error")
+		}
+	}
+
+	return convertedCredentials, nil
+}
+
+func (rc *Client) verifyCredentials(credentialsBase64 string) error {
+	credentials, err := utils.UnmarshalCredentials(credentialsBase64)
 	if err != nil {
-		return "", errors.Wrap(err, "Failed to execute register enclave")
+		return err
 	}
 
-	return txID, nil
+	attestedData, err := utils.UnmarshalAttestedData(credentials.GetSerializedAttestedData())
+	if err != nil {
+		return err
+	}
+
+	return rc.Verifier.VerifyCredentials(credentials, attestedData.GetCcParams().GetVersion())
 }
 
 func (rc *Client) verifyInitEnclaveRequest(req LifecycleInitEnclaveRequest) error {
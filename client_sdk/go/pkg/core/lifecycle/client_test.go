@@ -7,14 +7,22 @@ SPDX-License-Identifier: Apache-2.0
 package lifecycle_test
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/lifecycle"
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/lifecycle/fakes"
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/sgx"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
 //go:generate counterfeiter -o fakes/channelclient.go -fake-name ChannelClient . chClient
@@ -29,11 +37,17 @@ type credConverter interface {
 	lifecycle.CredentialConverter
 }
 
+//go:generate counterfeiter -o fakes/credential_verifier.go -fake-name CredentialVerifier . credVerifier
+//lint:ignore U1000 This is just used to generate fake
+type credVerifier interface {
+	lifecycle.CredentialVerifier
+}
+
 const (
 	channelID           = "mychannel"
 	chaincodeId         = "my-fpc-chaincode"
 	enclavePeerEndpoint = "mypeer.myorg.example.com"
-	attestationType     = "simulation"
+	attestationType     = "simulated"
 	expectedTxID        = "someTxID"
 )
 
@@ -51,6 +65,29 @@ func TestCreateNewClient(t *testing.T) {
 	assert.Error(t, err, "invalid arguments, channel client loader is nil")
 }
 
+func TestCreateNewClientWithOptions(t *testing.T) {
+	getChannelClient := func(channelId string) (lifecycle.ChannelClient, error) {
+		return &fakes.ChannelClient{}, nil
+	}
+
+	// defaults
+	client, err := lifecycle.New(getChannelClient)
+	assert.NoError(t, err)
+	assert.NotNil(t, client.Converter)
+	assert.Nil(t, client.Verifier)
+
+	client, err = lifecycle.New(getChannelClient, lifecycle.WithIASUrl("http://localhost:8090/report"), lifecycle.WithTimeout(time.Second))
+	assert.NoError(t, err)
+	assert.NotNil(t, client.Converter)
+
+	fakeConverter := &fakes.CredentialConverter{}
+	fakeVerifier := &fakes.CredentialVerifier{}
+	client, err = lifecycle.New(getChannelClient, lifecycle.WithCredentialConverter(fakeConverter), lifecycle.WithCredentialVerifier(fakeVerifier))
+	assert.NoError(t, err)
+	assert.Equal(t, fakeConverter, client.Converter)
+	assert.Equal(t, fakeVerifier, client.Verifier)
+}
+
 func TestLifecycleInitEnclaveFailedWithInvalidRequest(t *testing.T) {
 	fakeChannelClient := &fakes.ChannelClient{}
 	fakeConverter := &fakes.CredentialConverter{}
@@ -75,12 +112,19 @@ func TestLifecycleInitEnclaveFailedWithInvalidRequest(t *testing.T) {
 	assert.Error(t, err)
 
 	// invalid AttestationParams
-	// TODO implement me once
-	//request = LifecycleInitEnclaveRequest{ChaincodeID: chaincodeId, EnclavePeerEndpoint: enclavePeerEndpoint, AttestationParams: &sgx.AttestationParams{
-	//	AttestationType: "InvalidType",
-	//}}
-	//_, err = client.LifecycleInitEnclave(channelID, request)
-	//assert.Error(t, err)
+	request = lifecycle.LifecycleInitEnclaveRequest{ChaincodeID: chaincodeId, EnclavePeerEndpoint: enclavePeerEndpoint, AttestationParams: &sgx.AttestationParams{
+		AttestationType: "InvalidType",
+	}}
+	_, err = client.LifecycleInitEnclave(channelID, request)
+	assert.EqualError(t, err, "attestation params are invalid: unsupported attestation type 'InvalidType'")
+
+	// inconsistent AttestationParams
+	request = lifecycle.LifecycleInitEnclaveRequest{ChaincodeID: chaincodeId, EnclavePeerEndpoint: enclavePeerEndpoint, AttestationParams: &sgx.AttestationParams{
+		AttestationType: "epid-linkable",
+		HexSpid:         "not a spid",
+	}}
+	_, err = client.LifecycleInitEnclave(channelID, request)
+	assert.Error(t, err)
 }
 
 func TestLifecycleInitEnclaveFailedToCreateChannelClient(t *testing.T) {
@@ -182,10 +226,16 @@ func TestLifecycleInitEnclaveSuccess(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectedTxID, txId)
 
-	assert.Equal(t, 1, fakeChannelClient.QueryCallCount())
+	assert.Equal(t, 2, fakeChannelClient.QueryCallCount())
 	assert.Equal(t, 1, fakeChannelClient.ExecuteCallCount())
 
+	// ERCC is queried for existing registrations first
 	chaincodeID, Fcn, Args, _ := fakeChannelClient.QueryArgsForCall(0)
+	assert.Equal(t, lifecycle.ERCC, chaincodeID)
+	assert.Equal(t, lifecycle.QueryListEnclaveCredentialsCMD, Fcn)
+	assert.Equal(t, [][]byte{[]byte(chaincodeId)}, Args)
+
+	chaincodeID, Fcn, Args, _ = fakeChannelClient.QueryArgsForCall(1)
 	assert.Equal(t, chaincodeId, chaincodeID)
 	assert.Equal(t, lifecycle.InitEnclaveCMD, Fcn)
 	assert.Len(t, Args, 1)
@@ -195,3 +245,352 @@ func TestLifecycleInitEnclaveSuccess(t *testing.T) {
 	assert.Equal(t, lifecycle.RegisterEnclaveCMD, Fcn)
 	assert.Len(t, Args, 1)
 }
+
+func newCredentials(t *testing.T, mrenclave string) string {
+	serializedAttestedData, err := anypb.New(&protos.AttestedData{
+		EnclaveVk: []byte("some enclave vk"),
+		CcParams:  &protos.CCParameters{ChaincodeId: chaincodeId, Version: mrenclave},
+	})
+	assert.NoError(t, err)
+	return utils.MarshallProtoBase64(&protos.Credentials{SerializedAttestedData: serializedAttestedData})
+}
+
+func TestLifecycleInitEnclaveWithVerifier(t *testing.T) {
+	const mrenclave = "some mrenclave"
+	expectedError := fmt.Errorf("someVerificationError")
+
+	initReq := lifecycle.LifecycleInitEnclaveRequest{
+		ChaincodeID:         chaincodeId,
+		EnclavePeerEndpoint: enclavePeerEndpoint,
+		AttestationParams: &sgx.AttestationParams{
+			AttestationType: attestationType,
+		},
+	}
+
+	fakeChannelClient := &fakes.ChannelClient{}
+	fakeChannelClient.ExecuteReturns(expectedTxID, nil)
+	fakeConverter := &fakes.CredentialConverter{}
+	fakeConverter.ConvertCredentialsReturns(newCredentials(t, mrenclave), nil)
+	fakeVerifier := &fakes.CredentialVerifier{}
+	client := setupClient(fakeChannelClient, fakeConverter)
+	client.Verifier = fakeVerifier
+
+	// success
+	txId, err := client.LifecycleInitEnclave(channelID, initReq)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedTxID, txId)
+	assert.Equal(t, 1, fakeVerifier.VerifyCredentialsCallCount())
+	credentials, expectedMrenclave := fakeVerifier.VerifyCredentialsArgsForCall(0)
+	assert.Equal(t, mrenclave, expectedMrenclave)
+	assert.NotNil(t, credentials.SerializedAttestedData)
+
+	// verification fails, registerEnclave is not invoked
+	fakeVerifier.VerifyCredentialsReturns(expectedError)
+	_, err = client.LifecycleInitEnclave(channelID, initReq)
+	assert.ErrorIs(t, err, expectedError)
+	assert.Equal(t, 1, fakeChannelClient.ExecuteCallCount())
+
+	// invalid credentials
+	fakeConverter.ConvertCredentialsReturns("not credentials", nil)
+	_, err = client.LifecycleInitEnclave(channelID, initReq)
+	assert.Error(t, err)
+	assert.Equal(t, 2, fakeVerifier.VerifyCredentialsCallCount())
+	assert.Equal(t, 1, fakeChannelClient.ExecuteCallCount())
+}
+
+func TestLifecycleExportCCKeys(t *testing.T) {
+	expectedError := fmt.Errorf("someExportError")
+	fakeChannelClient := &fakes.ChannelClient{}
+	client := setupClient(fakeChannelClient, nil)
+
+	err := client.LifecycleExportCCKeys(channelID, "", enclavePeerEndpoint)
+	assert.EqualError(t, err, "chaincodeId is required")
+
+	err = client.LifecycleExportCCKeys(channelID, chaincodeId, "")
+	assert.EqualError(t, err, "peer of the registered enclave is required")
+	assert.Equal(t, 0, fakeChannelClient.QueryCallCount())
+
+	fakeChannelClient.QueryReturns(nil, expectedError)
+	err = client.LifecycleExportCCKeys(channelID, chaincodeId, enclavePeerEndpoint)
+	assert.ErrorIs(t, err, expectedError)
+
+	fakeChannelClient.QueryReturns([]byte("OK"), nil)
+	err = client.LifecycleExportCCKeys(channelID, chaincodeId, enclavePeerEndpoint)
+	assert.NoError(t, err)
+
+	chaincodeID, fcn, args, endpoints := fakeChannelClient.QueryArgsForCall(1)
+	assert.Equal(t, chaincodeId, chaincodeID)
+	assert.Equal(t, lifecycle.ExportCCKeysCMD, fcn)
+	assert.Empty(t, args)
+	assert.Equal(t, []string{enclavePeerEndpoint}, endpoints)
+}
+
+func TestLifecycleUpgradeEnclave(t *testing.T) {
+	expectedError := fmt.Errorf("someUpgradeError")
+
+	upgradeReq := lifecycle.LifecycleInitEnclaveRequest{
+		ChaincodeID:         chaincodeId,
+		EnclavePeerEndpoint: enclavePeerEndpoint,
+		AttestationParams: &sgx.AttestationParams{
+			AttestationType: attestationType,
+		},
+	}
+
+	fakeChannelClient := &fakes.ChannelClient{}
+	fakeConverter := &fakes.CredentialConverter{}
+	fakeConverter.ConvertCredentialsReturns("someCredentials", nil)
+	client := setupClient(fakeChannelClient, fakeConverter)
+
+	// upgrade fails
+	fakeChannelClient.ExecuteReturns("", expectedError)
+	_, err := client.LifecycleUpgradeEnclave(channelID, upgradeReq)
+	assert.ErrorIs(t, err, expectedError)
+	assert.Equal(t, 1, fakeChannelClient.QueryCallCount())
+
+	// import fails, the upgrade transaction is returned nevertheless
+	fakeChannelClient.QueryReturnsOnCall(2, nil, expectedError)
+	fakeChannelClient.ExecuteReturns(expectedTxID, nil)
+	txId, err := client.LifecycleUpgradeEnclave(channelID, upgradeReq)
+	assert.ErrorIs(t, err, expectedError)
+	assert.Equal(t, expectedTxID, txId)
+
+	// success
+	txId, err = client.LifecycleUpgradeEnclave(channelID, upgradeReq)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedTxID, txId)
+	assert.Equal(t, 5, fakeChannelClient.QueryCallCount())
+
+	chaincodeID, fcn, _, endpoints := fakeChannelClient.QueryArgsForCall(3)
+	assert.Equal(t, chaincodeId, chaincodeID)
+	assert.Equal(t, lifecycle.InitEnclaveCMD, fcn)
+	assert.Equal(t, []string{enclavePeerEndpoint}, endpoints)
+
+	chaincodeID, fcn, args := fakeChannelClient.ExecuteArgsForCall(2)
+	assert.Equal(t, lifecycle.ERCC, chaincodeID)
+	assert.Equal(t, lifecycle.UpgradeEnclaveCMD, fcn)
+	assert.Equal(t, [][]byte{[]byte("someCredentials")}, args)
+
+	chaincodeID, fcn, _, endpoints = fakeChannelClient.QueryArgsForCall(4)
+	assert.Equal(t, chaincodeId, chaincodeID)
+	assert.Equal(t, lifecycle.ImportCCKeysCMD, fcn)
+	assert.Equal(t, []string{enclavePeerEndpoint}, endpoints)
+}
+
+func TestLifecycleApproveUpgradePolicy(t *testing.T) {
+	fakeChannelClient := &fakes.ChannelClient{}
+	fakeChannelClient.ExecuteReturns(expectedTxID, nil)
+	client := setupClient(fakeChannelClient, nil)
+
+	_, err := client.LifecycleApproveUpgradePolicy(channelID, "", []string{"someVersion"})
+	assert.EqualError(t, err, "chaincodeId is required")
+
+	txId, err := client.LifecycleApproveUpgradePolicy(channelID, chaincodeId, []string{"someVersion"})
+	assert.NoError(t, err)
+	assert.Equal(t, expectedTxID, txId)
+
+	chaincodeID, fcn, args := fakeChannelClient.ExecuteArgsForCall(0)
+	assert.Equal(t, lifecycle.ERCC, chaincodeID)
+	assert.Equal(t, lifecycle.ApproveUpgradePolicyCMD, fcn)
+	assert.Len(t, args, 1)
+
+	// the same policy is set once approved
+	_, err = client.LifecycleSetUpgradePolicy(channelID, chaincodeId, []string{"someVersion"})
+	assert.NoError(t, err)
+	_, _, setArgs := fakeChannelClient.ExecuteArgsForCall(1)
+	assert.Equal(t, args, setArgs)
+}
+
+func TestLifecycleSetUpgradePolicy(t *testing.T) {
+	fakeChannelClient := &fakes.ChannelClient{}
+	fakeChannelClient.ExecuteReturns(expectedTxID, nil)
+	client := setupClient(fakeChannelClient, nil)
+
+	_, err := client.LifecycleSetUpgradePolicy(channelID, "", []string{"someVersion"})
+	assert.EqualError(t, err, "chaincodeId is required")
+
+	_, err = client.LifecycleSetUpgradePolicy(channelID, chaincodeId, nil)
+	assert.EqualError(t, err, "allowed versions are required")
+
+	txId, err := client.LifecycleSetUpgradePolicy(channelID, chaincodeId, []string{"someVersion"})
+	assert.NoError(t, err)
+	assert.Equal(t, expectedTxID, txId)
+
+	chaincodeID, fcn, args := fakeChannelClient.ExecuteArgsForCall(0)
+	assert.Equal(t, lifecycle.ERCC, chaincodeID)
+	assert.Equal(t, lifecycle.SetUpgradePolicyCMD, fcn)
+	assert.Len(t, args, 1)
+	upgradePolicy := &protos.UpgradePolicy{}
+	upgradePolicyBytes, err := base64.StdEncoding.DecodeString(string(args[0]))
+	assert.NoError(t, err)
+	assert.NoError(t, proto.Unmarshal(upgradePolicyBytes, upgradePolicy))
+	assert.Equal(t, chaincodeId, upgradePolicy.ChaincodeId)
+	assert.Equal(t, []string{"someVersion"}, upgradePolicy.AllowedVersions)
+}
+
+// registeredCredentials returns the ERCC response listing credentials of enclaves at the given peers
+func registeredCredentials(t *testing.T, endpoints ...string) []byte {
+	var allCredentials []string
+	for _, endpoint := range endpoints {
+		serializedAttestedData, err := anypb.New(&protos.AttestedData{
+			EnclaveVk:  []byte("enclave vk of " + endpoint),
+			CcParams:   &protos.CCParameters{ChaincodeId: chaincodeId},
+			HostParams: &protos.HostParameters{PeerEndpoint: endpoint},
+		})
+		assert.NoError(t, err)
+		allCredentials = append(allCredentials, utils.MarshallProtoBase64(&protos.Credentials{SerializedAttestedData: serializedAttestedData}))
+	}
+	payload, err := json.Marshal(allCredentials)
+	assert.NoError(t, err)
+	return payload
+}
+
+func TestLifecycleInitEnclaveRegisteredAtOtherPeer(t *testing.T) {
+	fakeChannelClient := &fakes.ChannelClient{}
+	fakeChannelClient.QueryReturns(registeredCredentials(t, "otherpeer.myorg.example.com"), nil)
+	fakeConverter := &fakes.CredentialConverter{}
+	client := setupClient(fakeChannelClient, fakeConverter)
+
+	initReq := lifecycle.LifecycleInitEnclaveRequest{
+		ChaincodeID:         chaincodeId,
+		EnclavePeerEndpoint: enclavePeerEndpoint,
+		AttestationParams: &sgx.AttestationParams{
+			AttestationType: attestationType,
+		},
+	}
+
+	// ERCC accepts a single enclave per chaincode, thus, no enclave is initialized
+	txId, err := client.LifecycleInitEnclave(channelID, initReq)
+	assert.EqualError(t, err, "ERCC accepts a single enclave per chaincode, but an enclave is already registered at otherpeer.myorg.example.com")
+	assert.Empty(t, txId)
+	assert.Equal(t, 1, fakeChannelClient.QueryCallCount())
+	assert.Equal(t, 0, fakeConverter.ConvertCredentialsCallCount())
+	assert.Equal(t, 0, fakeChannelClient.ExecuteCallCount())
+}
+
+func TestLifecycleInitEnclaveAlreadyRegistered(t *testing.T) {
+	fakeChannelClient := &fakes.ChannelClient{}
+	fakeChannelClient.QueryReturns(registeredCredentials(t, "otherpeer.myorg.example.com", enclavePeerEndpoint), nil)
+	fakeConverter := &fakes.CredentialConverter{}
+	client := setupClient(fakeChannelClient, fakeConverter)
+
+	initReq := lifecycle.LifecycleInitEnclaveRequest{
+		ChaincodeID:         chaincodeId,
+		EnclavePeerEndpoint: enclavePeerEndpoint,
+		AttestationParams: &sgx.AttestationParams{
+			AttestationType: attestationType,
+		},
+	}
+
+	txId, err := client.LifecycleInitEnclave(channelID, initReq)
+	assert.NoError(t, err)
+	assert.Empty(t, txId)
+	assert.Equal(t, 1, fakeChannelClient.QueryCallCount())
+	assert.Equal(t, 0, fakeChannelClient.ExecuteCallCount())
+
+	// invalid response
+	fakeChannelClient.QueryReturns([]byte("not a list"), nil)
+	_, err = client.LifecycleInitEnclave(channelID, initReq)
+	assert.Contains(t, err.Error(), "invalid enclave credentials list")
+}
+
+func TestLifecycleInitEnclaveResumesRegistration(t *testing.T) {
+	expectedError := fmt.Errorf("someRegisterError")
+
+	store, err := lifecycle.NewFileCredentialStore(t.TempDir())
+	assert.NoError(t, err)
+
+	csp := crypto.NewGoCrypto()
+	enclaveVk, enclaveSk, err := csp.NewECDSAKeys()
+	assert.NoError(t, err)
+	attestedData := &protos.AttestedData{EnclaveVk: enclaveVk}
+	serializedAttestedData, err := anypb.New(attestedData)
+	assert.NoError(t, err)
+	credentials := utils.MarshallProtoBase64(&protos.Credentials{SerializedAttestedData: serializedAttestedData})
+
+	// the enclave id reported by the peer; empty if the peer has no enclave running
+	runningEnclaveId := utils.GetEnclaveId(attestedData)
+	var registered []string
+	fakeChannelClient := &fakes.ChannelClient{}
+	fakeChannelClient.QueryCalls(func(chaincodeID string, fcn string, args [][]byte, endpoints ...string) ([]byte, error) {
+		switch fcn {
+		case lifecycle.QueryListEnclaveCredentialsCMD:
+			return registeredCredentials(t, registered...), nil
+		case lifecycle.EnclaveInfoCMD:
+			if runningEnclaveId == "" {
+				return nil, fmt.Errorf("enclave not yet initialized")
+			}
+			enclaveInfo, err := proto.Marshal(&protos.EnclaveInfo{EnclaveId: runningEnclaveId, Nonce: args[0]})
+			assert.NoError(t, err)
+			sig, err := csp.SignMessage(enclaveSk, enclaveInfo)
+			assert.NoError(t, err)
+			signedEnclaveInfo, err := proto.Marshal(&protos.SignedEnclaveInfo{EnclaveInfo: enclaveInfo, Signature: sig})
+			assert.NoError(t, err)
+			return []byte(base64.StdEncoding.EncodeToString(signedEnclaveInfo)), nil
+		default:
+			return []byte("someAttestation"), nil
+		}
+	})
+	fakeChannelClient.ExecuteReturns("", expectedError)
+	fakeConverter := &fakes.CredentialConverter{}
+	fakeConverter.ConvertCredentialsReturns(credentials, nil)
+	client := setupClient(fakeChannelClient, fakeConverter)
+	client.Store = store
+
+	initReq := lifecycle.LifecycleInitEnclaveRequest{
+		ChaincodeID:         chaincodeId,
+		EnclavePeerEndpoint: enclavePeerEndpoint,
+		AttestationParams: &sgx.AttestationParams{
+			AttestationType: attestationType,
+		},
+	}
+
+	// registration fails, the credentials are kept
+	_, err = client.LifecycleInitEnclave(channelID, initReq)
+	assert.ErrorIs(t, err, expectedError)
+	pending, err := store.Get(channelID, chaincodeId, enclavePeerEndpoint)
+	assert.NoError(t, err)
+	assert.Equal(t, credentials, pending)
+
+	// the registration is resubmitted without initializing another enclave
+	fakeChannelClient.ExecuteReturns(expectedTxID, nil)
+	txId, err := client.LifecycleInitEnclave(channelID, initReq)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedTxID, txId)
+	assert.Equal(t, 1, fakeConverter.ConvertCredentialsCallCount())
+	assert.Equal(t, 4, fakeChannelClient.QueryCallCount())
+	chaincodeID, fcn, args, endpoints := fakeChannelClient.QueryArgsForCall(3)
+	assert.Equal(t, chaincodeId, chaincodeID)
+	assert.Equal(t, lifecycle.EnclaveInfoCMD, fcn)
+	assert.Len(t, args, 1)
+	assert.Equal(t, []string{enclavePeerEndpoint}, endpoints)
+	_, _, executeArgs := fakeChannelClient.ExecuteArgsForCall(1)
+	assert.Equal(t, [][]byte{[]byte(credentials)}, executeArgs)
+
+	pending, err = store.Get(channelID, chaincodeId, enclavePeerEndpoint)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// pending credentials are dropped and a new enclave is initialized if the peer runs another enclave or none
+	for _, enclaveId := range []string{"someOtherEnclaveId", ""} {
+		runningEnclaveId = enclaveId
+		assert.NoError(t, store.Put(channelID, chaincodeId, enclavePeerEndpoint, credentials))
+		convertCalls := fakeConverter.ConvertCredentialsCallCount()
+		_, err = client.LifecycleInitEnclave(channelID, initReq)
+		assert.NoError(t, err)
+		assert.Equal(t, convertCalls+1, fakeConverter.ConvertCredentialsCallCount())
+		pending, err = store.Get(channelID, chaincodeId, enclavePeerEndpoint)
+		assert.NoError(t, err)
+		assert.Empty(t, pending)
+	}
+
+	// pending credentials of an enclave registered meanwhile are removed
+	executeCalls := fakeChannelClient.ExecuteCallCount()
+	registered = []string{enclavePeerEndpoint}
+	assert.NoError(t, store.Put(channelID, chaincodeId, enclavePeerEndpoint, credentials))
+	_, err = client.LifecycleInitEnclave(channelID, initReq)
+	assert.NoError(t, err)
+	assert.Equal(t, executeCalls, fakeChannelClient.ExecuteCallCount())
+	pending, err = store.Get(channelID, chaincodeId, enclavePeerEndpoint)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
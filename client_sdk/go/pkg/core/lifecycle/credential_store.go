@@ -0,0 +1,92 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lifecycle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// CredentialStore persists the credentials of initialized enclaves until they are registered at ERCC.
+// This allows to resubmit a failed registration without initializing a new enclave.
+// Note that pending credentials are only of use as long as the enclave is still running at the peer.
+type CredentialStore interface {
+	// Get returns the pending credentials for the enclave at the given peer or an empty string if there are none
+	Get(channelID, chaincodeID, peerEndpoint string) (string, error)
+	Put(channelID, chaincodeID, peerEndpoint, credentials string) error
+	Delete(channelID, chaincodeID, peerEndpoint string) error
+}
+
+// FileCredentialStore is a CredentialStore keeping pending credentials as files in a local directory
+type FileCredentialStore struct {
+	dir string
+}
+
+// NewFileCredentialStore returns a FileCredentialStore using the given directory, which is created if it does not exist
+func NewFileCredentialStore(dir string) (*FileCredentialStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "cannot create credential store directory")
+	}
+	return &FileCredentialStore{dir: dir}, nil
+}
+
+func (s *FileCredentialStore) Get(channelID, chaincodeID, peerEndpoint string) (string, error) {
+	credentials, err := os.ReadFile(s.path(channelID, chaincodeID, peerEndpoint))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(credentials), nil
+}
+
+// Put stores the credentials; the file is replaced atomically so that a crash never leaves partial credentials behind
+func (s *FileCredentialStore) Put(channelID, chaincodeID, peerEndpoint, credentials string) error {
+	tmp, err := os.CreateTemp(s.dir, "pending-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(credentials); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(channelID, chaincodeID, peerEndpoint))
+}
+
+func (s *FileCredentialStore) Delete(channelID, chaincodeID, peerEndpoint string) error {
+	err := os.Remove(s.path(channelID, chaincodeID, peerEndpoint))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// path returns the file for the given enclave; the name is derived from a hash as
+// channel ids, chaincode ids and endpoints are not necessarily valid file names
+func (s *FileCredentialStore) path(channelID, chaincodeID, peerEndpoint string) string {
+	h := sha256.New()
+	for _, part := range []string{channelID, chaincodeID, peerEndpoint} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return filepath.Join(s.dir, hex.EncodeToString(h.Sum(nil))+".credentials")
+}
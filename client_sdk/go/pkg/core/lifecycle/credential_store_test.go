@@ -0,0 +1,58 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lifecycle_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/lifecycle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCredentialStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pending")
+	store, err := lifecycle.NewFileCredentialStore(dir)
+	require.NoError(t, err)
+
+	credentials, err := store.Get(channelID, chaincodeId, enclavePeerEndpoint)
+	assert.NoError(t, err)
+	assert.Empty(t, credentials)
+
+	assert.NoError(t, store.Put(channelID, chaincodeId, enclavePeerEndpoint, "someCredentials"))
+	assert.NoError(t, store.Put(channelID, chaincodeId, "../../otherpeer:7051", "otherCredentials"))
+
+	credentials, err = store.Get(channelID, chaincodeId, enclavePeerEndpoint)
+	assert.NoError(t, err)
+	assert.Equal(t, "someCredentials", credentials)
+
+	// overwrite
+	assert.NoError(t, store.Put(channelID, chaincodeId, enclavePeerEndpoint, "newCredentials"))
+	credentials, err = store.Get(channelID, chaincodeId, enclavePeerEndpoint)
+	assert.NoError(t, err)
+	assert.Equal(t, "newCredentials", credentials)
+
+	// all files are kept in the store directory
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// a new store on the same directory sees the pending credentials
+	store, err = lifecycle.NewFileCredentialStore(dir)
+	require.NoError(t, err)
+	credentials, err = store.Get(channelID, chaincodeId, "../../otherpeer:7051")
+	assert.NoError(t, err)
+	assert.Equal(t, "otherCredentials", credentials)
+
+	assert.NoError(t, store.Delete(channelID, chaincodeId, enclavePeerEndpoint))
+	assert.NoError(t, store.Delete(channelID, chaincodeId, enclavePeerEndpoint))
+	credentials, err = store.Get(channelID, chaincodeId, enclavePeerEndpoint)
+	assert.NoError(t, err)
+	assert.Empty(t, credentials)
+}
@@ -23,22 +23,29 @@ Notice: This file is based on fabric-sdk-go/pkg/fab/ccpackager/lifecycle/package
 //	if err != nil {
 //		log.Fatal(err)
 //	}
+//
+// Go FPC chaincodes built with EGo (see `$FPC_PATH/ecc_go`) are packaged with the type ccpackager.GoChaincodeType,
+// where Path is the build directory containing the signed chaincode binary, enclave.json, and the mrenclave file.
+//
+// The packages are deterministic, i.e., packaging the same artifacts with the same descriptor always results in
+// the same package bytes and hence in the same package ID.
 package ccpackager
 
 import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/sgx"
 	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
 	"github.com/hyperledger/fabric/core/chaincode/persistence"
-	"github.com/hyperledger/fabric/core/chaincode/platforms/util"
 	"github.com/pkg/errors"
 )
 
@@ -48,8 +55,10 @@ const (
 	connectionsName          = "connection.json"
 	mrenclaveFileName        = "mrenclave"
 	enclaveBinaryName        = "enclave.signed.so"
+	enclaveConfigName        = "enclave.json"
 	gzipCompressionLevel     = gzip.DefaultCompression
 	ChaincodeType            = "fpc-c"
+	GoChaincodeType          = "fpc-go"
 	CaaSType                 = "external"
 	defaultConnectionTimeout = "10s"
 )
@@ -79,12 +88,81 @@ func ReadMrenclave(ccPath string) (string, error) {
 	return strings.TrimSuffix(string(mrenclave), "\n"), nil
 }
 
-// Descriptor holds the package data. FPC supports two types of packages, ChaincodeType and CaaSType.
+// EnclaveConfig contains the fields of an EGo enclave configuration (enclave.json) relevant for packaging
+type EnclaveConfig struct {
+	// Exe is the name of the signed chaincode binary
+	Exe             string `json:"exe"`
+	Debug           bool   `json:"debug"`
+	ProductID       int    `json:"productID"`
+	SecurityVersion int    `json:"securityVersion"`
+}
+
+// ReadEnclaveConfig returns the EGo enclave configuration of the Go FPC chaincode at ccPath
+func ReadEnclaveConfig(ccPath string) (*EnclaveConfig, error) {
+	configBytes, err := os.ReadFile(filepath.Join(ccPath, enclaveConfigName))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseEnclaveConfig(configBytes)
+}
+
+func parseEnclaveConfig(configBytes []byte) (*EnclaveConfig, error) {
+	config := &EnclaveConfig{}
+	if err := json.Unmarshal(configBytes, config); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", enclaveConfigName)
+	}
+
+	// the binary is packaged by name, so it must be located next to enclave.json
+	if config.Exe == "" || config.Exe != filepath.Base(config.Exe) || config.Exe == enclaveConfigName || config.Exe == mrenclaveFileName {
+		return nil, errors.Errorf("invalid exe in %s: '%s'", enclaveConfigName, config.Exe)
+	}
+
+	return config, nil
+}
+
+// ComputeGoMrenclave computes mrenclave of a signed EGo binary. This requires the EGo tools to be installed.
+func ComputeGoMrenclave(binaryPath string) (string, error) {
+	out, err := exec.Command("ego", "uniqueid", binaryPath).Output()
+	if err != nil {
+		return "", errors.Wrap(err, "cannot compute mrenclave with 'ego uniqueid'")
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// readGoMrenclave returns mrenclave of the Go FPC chaincode at ccPath. If there is no mrenclave file,
+// mrenclave is computed from the signed binary.
+func readGoMrenclave(ccPath string, config *EnclaveConfig) (string, error) {
+	mrenclave, err := ReadMrenclave(ccPath)
+	if os.IsNotExist(err) {
+		mrenclave, err = ComputeGoMrenclave(filepath.Join(ccPath, config.Exe))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := validateMrenclave(mrenclave); err != nil {
+		return "", err
+	}
+
+	return mrenclave, nil
+}
+
+func validateMrenclave(mrenclave string) error {
+	if b, err := hex.DecodeString(mrenclave); err != nil || len(b) != 32 {
+		return errors.Errorf("invalid mrenclave: '%s'", mrenclave)
+	}
+	return nil
+}
+
+// Descriptor holds the package data. FPC supports three types of packages, ChaincodeType, GoChaincodeType, and CaaSType.
 // For normal chaincode deployments, the package type ChaincodeType is used. It requires to define Type, Label, Path, and SGXMode.
+// Go chaincodes built with EGo use the package type GoChaincodeType, which requires the same fields.
 // Alternatively, for deployments as Chaincode as a Service (CaaS), the package type CaaSType is used.
 // It requires to define Type, Label, Path, and CaaSEndpoint. Optionally, CaaSTimeout and CaaSUseTLS can be set.
 type Descriptor struct {
-	// Type defines the FPC package type. Supported types are fpc.ChaincodeType or fpc.CaaSType.
+	// Type defines the FPC package type. Supported types are fpc.ChaincodeType, fpc.GoChaincodeType, or fpc.CaaSType.
 	Type string
 	// Label defines a succinct and human readable description of the package.
 	Label string
@@ -109,6 +187,12 @@ func (p *Descriptor) validate() error {
 			return err
 		}
 		return nil
+	case GoChaincodeType:
+		err := validateGoPackageInput(p)
+		if err != nil {
+			return err
+		}
+		return nil
 	case CaaSType:
 		err := validateCaaSPackageInput(p)
 		if err != nil {
@@ -116,7 +200,7 @@ func (p *Descriptor) validate() error {
 		}
 		return nil
 	default:
-		return errors.New(fmt.Sprintf("chaincode language must be %s or %s", ChaincodeType, CaaSType))
+		return errors.New(fmt.Sprintf("chaincode language must be %s, %s, or %s", ChaincodeType, GoChaincodeType, CaaSType))
 	}
 }
 
@@ -125,8 +209,39 @@ func validateRegularPackageInput(p *Descriptor) error {
 		return errors.New("chaincode path must be specified")
 	}
 
-	// TODO we should also check that the enclave artifacts exist at the given path.
-	// If they do not exist, getDeploymentPayload will fail, better fail at validation earlier here.
+	for _, name := range []string{mrenclaveFileName, enclaveBinaryName} {
+		if err := checkArtifact(p.Path, name); err != nil {
+			return err
+		}
+	}
+
+	if p.SGXMode != sgx.SGXModeHwType && p.SGXMode != sgx.SGXModeSimType {
+		return errors.Errorf("SGXMode must be set either to %s or %s, actual: %s", sgx.SGXModeHwType, sgx.SGXModeSimType, p.SGXMode)
+	}
+
+	if err := persistence.ValidateLabel(p.Label); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateGoPackageInput(p *Descriptor) error {
+	if p.Path == "" {
+		return errors.New("chaincode path must be specified")
+	}
+
+	config, err := ReadEnclaveConfig(p.Path)
+	if err != nil {
+		return errors.Wrap(err, "cannot read enclave config")
+	}
+
+	if err := checkArtifact(p.Path, config.Exe); err != nil {
+		return err
+	}
+
+	if _, err := readGoMrenclave(p.Path, config); err != nil {
+		return err
+	}
 
 	if p.SGXMode != sgx.SGXModeHwType && p.SGXMode != sgx.SGXModeSimType {
 		return errors.Errorf("SGXMode must be set either to %s or %s, actual: %s", sgx.SGXModeHwType, sgx.SGXModeSimType, p.SGXMode)
@@ -138,6 +253,18 @@ func validateRegularPackageInput(p *Descriptor) error {
 	return nil
 }
 
+// checkArtifact checks that the enclave artifact name is a regular file at ccPath
+func checkArtifact(ccPath, name string) error {
+	fi, err := os.Stat(filepath.Join(ccPath, name))
+	if err != nil {
+		return errors.Wrapf(err, "enclave artifact %s not found", name)
+	}
+	if !fi.Mode().IsRegular() {
+		return errors.Errorf("enclave artifact %s is not a regular file", name)
+	}
+	return nil
+}
+
 func validateCaaSPackageInput(p *Descriptor) error {
 
 	err := utils.ValidateEndpoint(p.CaaSEndpoint)
@@ -159,7 +286,12 @@ func getTarGzBytes(desc *Descriptor, writeBytesToPackage writer) ([]byte, error)
 	tw := tar.NewWriter(gw)
 
 	// create metadata.json
-	metadataBytes, err := metadataToJSON(desc.Path, desc.Type, desc.Label, desc.SGXMode)
+	// the build directory is specific to the host, so it is omitted for Go packages to keep the package ID reproducible
+	metadataPath := desc.Path
+	if desc.Type == GoChaincodeType {
+		metadataPath = ""
+	}
+	metadataBytes, err := metadataToJSON(metadataPath, desc.Type, desc.Label, desc.SGXMode)
 	if err != nil {
 		return nil, err
 	}
@@ -178,6 +310,11 @@ func getTarGzBytes(desc *Descriptor, writeBytesToPackage writer) ([]byte, error)
 		if err != nil {
 			return nil, errors.Wrap(err, "error getting chaincode bytes")
 		}
+	case GoChaincodeType:
+		codeBytes, err = getGoDeploymentPayload(desc.Path, writeBytesToPackage)
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting chaincode bytes")
+		}
 	case CaaSType:
 		codeBytes, err = getCaaSDeploymentPayload(desc, writeBytesToPackage)
 		if err != nil {
@@ -204,12 +341,15 @@ func getTarGzBytes(desc *Descriptor, writeBytesToPackage writer) ([]byte, error)
 	return payload.Bytes(), nil
 }
 
+// writePackage writes the payload as file to the tar. Note that the header does not contain any
+// timestamps or ownership information to keep the package deterministic.
 func writePackage(tw *tar.Writer, name string, payload []byte) error {
 	err := tw.WriteHeader(
 		&tar.Header{
-			Name: name,
-			Size: int64(len(payload)),
-			Mode: 0100644,
+			Name:   name,
+			Size:   int64(len(payload)),
+			Mode:   0100644,
+			Format: tar.FormatUSTAR,
 		},
 	)
 	if err != nil {
@@ -220,15 +360,16 @@ func writePackage(tw *tar.Writer, name string, payload []byte) error {
 	return err
 }
 
-func metadataToJSON(path, ccType, label, sgxMode string) ([]byte, error) {
-	type packageMetadata struct {
-		Path    string `json:"path,omitempty"`
-		Type    string `json:"type"`
-		Label   string `json:"label"`
-		SGXMode string `json:"sgx_mode,omitempty"`
-	}
+// PackageMetadata is the content of metadata.json of a FPC chaincode package
+type PackageMetadata struct {
+	Path    string `json:"path,omitempty"`
+	Type    string `json:"type"`
+	Label   string `json:"label"`
+	SGXMode string `json:"sgx_mode,omitempty"`
+}
 
-	metadata := &packageMetadata{
+func metadataToJSON(path, ccType, label, sgxMode string) ([]byte, error) {
+	metadata := &PackageMetadata{
 		Path:    path,
 		Type:    ccType,
 		Label:   label,
@@ -284,7 +425,11 @@ func getDeploymentPayload(ccPath string) ([]byte, error) {
 	tw := tar.NewWriter(gw)
 
 	for _, file := range files {
-		err = util.WriteFileToPackage(filepath.Join(file.Path, file.Name), file.Name, tw)
+		fileBytes, err := os.ReadFile(filepath.Join(file.Path, file.Name))
+		if err != nil {
+			return nil, err
+		}
+		err = writePackage(tw, file.Name, fileBytes)
 		if err != nil {
 			return nil, errors.Wrapf(err, "error writing %s to tar", file.Name)
 		}
@@ -301,6 +446,62 @@ func getDeploymentPayload(ccPath string) ([]byte, error) {
 	return payload.Bytes(), nil
 }
 
+func getGoDeploymentPayload(ccPath string, writeBytesToPackage writer) ([]byte, error) {
+	config, err := ReadEnclaveConfig(ccPath)
+	if err != nil {
+		return nil, err
+	}
+
+	mrenclave, err := readGoMrenclave(ccPath, config)
+	if err != nil {
+		return nil, err
+	}
+
+	binary, err := os.ReadFile(filepath.Join(ccPath, config.Exe))
+	if err != nil {
+		return nil, err
+	}
+
+	configBytes, err := os.ReadFile(filepath.Join(ccPath, enclaveConfigName))
+	if err != nil {
+		return nil, err
+	}
+
+	// Go FPC code package (code.tar.gz) contains the mrenclave file, enclave.json, and the signed binary
+	files := []struct {
+		name    string
+		payload []byte
+	}{
+		{mrenclaveFileName, []byte(mrenclave + "\n")},
+		{enclaveConfigName, configBytes},
+		{config.Exe, binary},
+	}
+
+	payload := bytes.NewBuffer(nil)
+	gw, err := gzip.NewWriterLevel(payload, gzipCompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	tw := tar.NewWriter(gw)
+
+	for _, file := range files {
+		err = writeBytesToPackage(tw, file.name, file.payload)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error writing %s to tar", file.name)
+		}
+	}
+
+	err = tw.Close()
+	if err == nil {
+		err = gw.Close()
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create code.tar.gz for chaincode")
+	}
+
+	return payload.Bytes(), nil
+}
+
 func getCaaSDeploymentPayload(desc *Descriptor, writeBytesToPackage writer) ([]byte, error) {
 
 	// set default timeout
@@ -7,9 +7,14 @@ SPDX-License-Identifier: Apache-2.0
 package ccpackager_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/fab/ccpackager"
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/sgx"
@@ -29,15 +34,11 @@ var (
 	mrenclave string
 )
 
-var _ = BeforeSuite(func() {
-	fpcPath = os.Getenv("FPC_PATH")
-	if fpcPath == "" {
-		panic("FPC_PATH not set")
-	}
-})
-
 var _ = Describe("Go Client SDK Test", func() {
 	BeforeEach(func() {
+		fpcPath = os.Getenv("FPC_PATH")
+		Expect(fpcPath).ShouldNot(BeEmpty(), "FPC_PATH not set")
+
 		ccPath = filepath.Join(fpcPath, "samples", "chaincode", "auction", "_build", "lib")
 		var err error
 		mrenclave, err = ccpackager.ReadMrenclave(ccPath)
@@ -172,9 +173,176 @@ var _ = Describe("Go Client SDK Test", func() {
 				Expect(err).ShouldNot(HaveOccurred())
 				Expect(payload).ShouldNot(BeNil())
 
+				pkg, err := ccpackager.ReadCCPackage(payload)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(pkg.HasMrenclave()).Should(BeFalse())
+				_, err = pkg.Mrenclave()
+				Expect(err).Should(HaveOccurred())
+
 				err = os.WriteFile("/tmp/pack.tar.gz", payload, 0644)
 				Expect(err).ShouldNot(HaveOccurred())
 			})
 		})
 	})
 })
+
+// the fpc-go specs use fake enclave artifacts, i.e., they do not require a built chaincode
+var _ = Describe("Go Chaincode Package", func() {
+	const (
+		goLabel     = "auction"
+		goMrenclave = "98aed61c91f258a37c68ed4943297695647ec7bbe6008cc111b0a12650ebeb91"
+	)
+	var goPath string
+
+	BeforeEach(func() {
+		var err error
+		goPath, err = os.MkdirTemp("", "fpc-go")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(goPath, "enclave.json"), []byte(`{"exe": "ecc", "key": "private.pem", "debug": true, "productID": 1, "securityVersion": 1}`), 0644)).Should(Succeed())
+		Expect(os.WriteFile(filepath.Join(goPath, "ecc"), []byte("signed ego binary"), 0755)).Should(Succeed())
+		Expect(os.WriteFile(filepath.Join(goPath, "mrenclave"), []byte(goMrenclave+"\n"), 0644)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(goPath)).Should(Succeed())
+	})
+
+	newDescriptor := func() *ccpackager.Descriptor {
+		return &ccpackager.Descriptor{
+			Path:    goPath,
+			Type:    ccpackager.GoChaincodeType,
+			Label:   goLabel,
+			SGXMode: sgx.SGXModeSimType,
+		}
+	}
+
+	When("enclave.json does not exist", func() {
+		It("should return an error", func() {
+			Expect(os.Remove(filepath.Join(goPath, "enclave.json"))).Should(Succeed())
+			_, err := ccpackager.NewCCPackage(newDescriptor())
+			Expect(err).Should(MatchError(ContainSubstring("cannot read enclave config")))
+		})
+	})
+
+	When("enclave.json points outside of the build directory", func() {
+		It("should return an error", func() {
+			Expect(os.WriteFile(filepath.Join(goPath, "enclave.json"), []byte(`{"exe": "../ecc"}`), 0644)).Should(Succeed())
+			_, err := ccpackager.NewCCPackage(newDescriptor())
+			Expect(err).Should(MatchError(ContainSubstring("invalid exe")))
+		})
+	})
+
+	When("the binary does not exist", func() {
+		It("should return an error", func() {
+			Expect(os.Remove(filepath.Join(goPath, "ecc"))).Should(Succeed())
+			_, err := ccpackager.NewCCPackage(newDescriptor())
+			Expect(err).Should(MatchError(ContainSubstring("enclave artifact ecc not found")))
+		})
+	})
+
+	When("mrenclave is invalid", func() {
+		It("should return an error", func() {
+			Expect(os.WriteFile(filepath.Join(goPath, "mrenclave"), []byte("fake_mrenclave\n"), 0644)).Should(Succeed())
+			_, err := ccpackager.NewCCPackage(newDescriptor())
+			Expect(err).Should(MatchError(ContainSubstring("invalid mrenclave")))
+		})
+	})
+
+	When("SGX_MODE is not set", func() {
+		It("should return an error", func() {
+			desc := newDescriptor()
+			desc.SGXMode = ""
+			_, err := ccpackager.NewCCPackage(desc)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	When("everything is set", func() {
+		It("should contain the enclave artifacts", func() {
+			ccPkg, err := ccpackager.NewCCPackage(newDescriptor())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			pkgFiles := untar(ccPkg)
+			Expect(pkgFiles).Should(HaveKey("metadata.json"))
+			Expect(string(pkgFiles["metadata.json"])).Should(ContainSubstring(`"type":"fpc-go"`))
+
+			codeFiles := untar(pkgFiles["code.tar.gz"])
+			Expect(codeFiles).Should(HaveLen(3))
+			Expect(string(codeFiles["mrenclave"])).Should(Equal(goMrenclave + "\n"))
+			Expect(string(codeFiles["ecc"])).Should(Equal("signed ego binary"))
+			Expect(codeFiles).Should(HaveKey("enclave.json"))
+		})
+
+		It("should be readable", func() {
+			ccPkg, err := ccpackager.NewCCPackage(newDescriptor())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			pkg, err := ccpackager.ReadCCPackage(ccPkg)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(pkg.ID).Should(HavePrefix(goLabel + ":"))
+			Expect(pkg.Metadata.Type).Should(Equal(ccpackager.GoChaincodeType))
+			Expect(pkg.Metadata.SGXMode).Should(Equal(sgx.SGXModeSimType))
+
+			pkgMrenclave, err := pkg.Mrenclave()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(pkgMrenclave).Should(Equal(goMrenclave))
+
+			name, binary, err := pkg.EnclaveBinary()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(name).Should(Equal("ecc"))
+			Expect(string(binary)).Should(Equal("signed ego binary"))
+
+			_, err = ccpackager.ReadCCPackage([]byte("not a package"))
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should be deterministic", func() {
+			ccPkg, err := ccpackager.NewCCPackage(newDescriptor())
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// same artifacts in another build directory
+			otherPath, err := os.MkdirTemp("", "fpc-go")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer os.RemoveAll(otherPath)
+			for _, name := range []string{"enclave.json", "ecc", "mrenclave"} {
+				content, err := os.ReadFile(filepath.Join(goPath, name))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(otherPath, name), content, 0600)).Should(Succeed())
+			}
+			desc := newDescriptor()
+			desc.Path = otherPath
+			otherPkg, err := ccpackager.NewCCPackage(desc)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(otherPkg).Should(Equal(ccPkg))
+
+			// same artifacts with different timestamps
+			later := time.Now().Add(time.Hour)
+			for _, name := range []string{"enclave.json", "ecc", "mrenclave"} {
+				Expect(os.Chtimes(filepath.Join(goPath, name), later, later)).Should(Succeed())
+			}
+
+			otherPkg, err = ccpackager.NewCCPackage(newDescriptor())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(otherPkg).Should(Equal(ccPkg))
+		})
+	})
+})
+
+// untar returns the files of a tar.gz
+func untar(tarGz []byte) map[string][]byte {
+	gr, err := gzip.NewReader(bytes.NewReader(tarGz))
+	Expect(err).ShouldNot(HaveOccurred())
+	tr := tar.NewReader(gr)
+
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		Expect(err).ShouldNot(HaveOccurred())
+		content, err := io.ReadAll(tr)
+		Expect(err).ShouldNot(HaveOccurred())
+		files[header.Name] = content
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ccpackager
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
+	"github.com/pkg/errors"
+)
+
+// Package is a parsed FPC chaincode package
+type Package struct {
+	// ID is the package ID as computed by the peer when the package is installed
+	ID       string
+	Metadata PackageMetadata
+	// CodeFiles contains the files of code.tar.gz by name
+	CodeFiles map[string][]byte
+}
+
+// ReadCCPackage parses a FPC chaincode package as created by NewCCPackage and checks its structure.
+// That is, the package must only contain metadata.json and code.tar.gz, and the code package must only
+// contain the artifacts of the package type.
+func ReadCCPackage(pkgBytes []byte) (*Package, error) {
+	files, err := untarGz(pkgBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid package")
+	}
+
+	if err := expectFiles(files, metadataPackageName, codePackageName); err != nil {
+		return nil, errors.Wrap(err, "invalid package")
+	}
+
+	pkg := &Package{}
+	if err := json.Unmarshal(files[metadataPackageName], &pkg.Metadata); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", metadataPackageName)
+	}
+
+	if err := persistence.ValidateLabel(pkg.Metadata.Label); err != nil {
+		return nil, err
+	}
+
+	pkg.CodeFiles, err = untarGz(files[codePackageName])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", codePackageName)
+	}
+
+	switch pkg.Metadata.Type {
+	case ChaincodeType:
+		err = expectFiles(pkg.CodeFiles, mrenclaveFileName, enclaveBinaryName)
+	case GoChaincodeType:
+		var config *EnclaveConfig
+		config, err = parseEnclaveConfig(pkg.CodeFiles[enclaveConfigName])
+		if err == nil {
+			err = expectFiles(pkg.CodeFiles, mrenclaveFileName, enclaveConfigName, config.Exe)
+		}
+	case CaaSType:
+		err = expectFiles(pkg.CodeFiles, connectionsName)
+	default:
+		err = errors.Errorf("unknown package type '%s'", pkg.Metadata.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", codePackageName)
+	}
+
+	pkg.ID = persistence.PackageID(pkg.Metadata.Label, pkgBytes)
+	return pkg, nil
+}
+
+// HasMrenclave returns true if the package type embeds mrenclave, i.e., it is not a CaaS package
+func (p *Package) HasMrenclave() bool {
+	return p.Metadata.Type != CaaSType
+}
+
+// Mrenclave returns the mrenclave embedded in the package
+func (p *Package) Mrenclave() (string, error) {
+	if !p.HasMrenclave() {
+		return "", errors.Errorf("%s packages do not embed mrenclave", p.Metadata.Type)
+	}
+
+	mrenclave := strings.TrimSuffix(string(p.CodeFiles[mrenclaveFileName]), "\n")
+	if err := validateMrenclave(mrenclave); err != nil {
+		return "", err
+	}
+	return mrenclave, nil
+}
+
+// EnclaveBinary returns the name and content of the enclave binary embedded in the package
+func (p *Package) EnclaveBinary() (string, []byte, error) {
+	switch p.Metadata.Type {
+	case ChaincodeType:
+		return enclaveBinaryName, p.CodeFiles[enclaveBinaryName], nil
+	case GoChaincodeType:
+		config, err := parseEnclaveConfig(p.CodeFiles[enclaveConfigName])
+		if err != nil {
+			return "", nil, err
+		}
+		return config.Exe, p.CodeFiles[config.Exe], nil
+	default:
+		return "", nil, errors.Errorf("%s packages do not embed an enclave binary", p.Metadata.Type)
+	}
+}
+
+// untarGz returns the regular files of a tar.gz by name
+func untarGz(tarGz []byte) (map[string][]byte, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(tarGz))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gr)
+
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return nil, errors.Errorf("unexpected entry %s of type %c", header.Name, header.Typeflag)
+		}
+		if _, exists := files[header.Name]; exists {
+			return nil, errors.Errorf("duplicate file %s", header.Name)
+		}
+
+		files[header.Name], err = io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// expectFiles checks that exactly the given files exist
+func expectFiles(files map[string][]byte, names ...string) error {
+	for _, name := range names {
+		if _, exists := files[name]; !exists {
+			return errors.Errorf("missing file %s", name)
+		}
+	}
+
+	if len(files) != len(names) {
+		var unexpected []string
+		for name := range files {
+			if !contains(names, name) {
+				unexpected = append(unexpected, name)
+			}
+		}
+		sort.Strings(unexpected)
+		return errors.Errorf("unexpected files %s", strings.Join(unexpected, ", "))
+	}
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
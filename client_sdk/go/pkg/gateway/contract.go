@@ -11,6 +11,7 @@ package gateway
 import (
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/core/contract"
 	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+	"github.com/hyperledger/fabric/bccsp"
 )
 
 // Contract provides functions to query/invoke FPC chaincodes based on the Gateway API.
@@ -81,14 +82,25 @@ func (cp *contractProvider) GetContract(id string) contract.Contract {
 	return &gatewayContract{cp.network.GetContract(id)}
 }
 
+// Option configures the contracts created by GetContract
+type Option = contract.Option
+
+// WithBCCSP sets the Fabric BCCSP, e.g., a PKCS#11 implementation, used for the FPC cryptography of the client.
+// Note that the signing identity of the client is configured with the Fabric SDK, not with this option
+// (see contract.WithBCCSP).
+func WithBCCSP(csp bccsp.BCCSP) Option {
+	return contract.WithBCCSP(csp)
+}
+
 // GetContract is the factory method for creating FPC Contract objects.
 //
 //	Parameters:
 //	network is an initialized Fabric network object
 //	chaincodeID is the ID of the target chaincode
+//	options configure the contract, e.g., WithBCCSP
 //
 //	Returns:
 //	The contract object
-func GetContract(network Network, chaincodeID string) Contract {
-	return contract.GetContract(&contractProvider{network: network}, chaincodeID)
+func GetContract(network Network, chaincodeID string, options ...Option) Contract {
+	return contract.GetContract(&contractProvider{network: network}, chaincodeID, options...)
 }
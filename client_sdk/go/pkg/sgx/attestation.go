@@ -9,11 +9,15 @@ package sgx
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/cvm"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/simulation"
 	"github.com/pkg/errors"
 )
 
@@ -22,6 +26,9 @@ const (
 	SGXModeHwType         = "HW"
 	SGXModeSimType        = "SIM"
 	SGXCredentialsPathKey = "SGX_CREDENTIALS_PATH"
+
+	// SPIDLength is the length of a Service Provider ID in bytes
+	SPIDLength = 16
 )
 
 // AttestationParams holds additional attestation information that is required to perform LifecycleInitEnclave.
@@ -42,10 +49,35 @@ func (p *AttestationParams) ToBase64EncodedJSON() ([]byte, error) {
 }
 
 // Validate checks that the attestation information are correct.
+// EPID attestation types require a hex encoded SPID and an optional (base64 encoded) SigRL, whereas all other
+// attestation types must neither provide a SPID nor a SigRL.
 func (p *AttestationParams) Validate() error {
-	// TODO implement me
-	// check that attestation type is correct (epid, cap, simulated)
-	// check that hexspid has right format? etc ...
+	switch p.AttestationType {
+	case epid.LinkableType, epid.UnlinkableType:
+		spid, err := hex.DecodeString(p.HexSpid)
+		if err != nil {
+			return errors.Wrap(err, "invalid spid, hex encoding expected")
+		}
+		if len(spid) != SPIDLength {
+			return errors.Errorf("invalid spid length: expected %d bytes, actual %d", SPIDLength, len(spid))
+		}
+		if len(p.SigRL) != 0 {
+			if _, err := ParseSigRL(p.SigRL); err != nil {
+				return errors.Wrap(err, "invalid sig_rl")
+			}
+		}
+
+	case simulation.SimulationType, cvm.TDXType, cvm.SEVSNPType:
+		if len(p.HexSpid) != 0 || len(p.SigRL) != 0 {
+			return errors.Errorf("attestation type '%s' does not use spid and sig_rl", p.AttestationType)
+		}
+
+	case "":
+		return errors.New("attestation type is required")
+
+	default:
+		return errors.Errorf("unsupported attestation type '%s'", p.AttestationType)
+	}
 
 	return nil
 }
@@ -109,10 +141,28 @@ func ReadSPID(sgxCredentialsPath string) (string, error) {
 	return readFile(hexSpidPath)
 }
 
-// ReadSigRL reads the Signature Revocation List from a credentials path and returns it as string.
+// ReadSigRL reads the Signature Revocation List from a credentials path and returns it as (base64 encoded) string.
+// The SigRL is optional; if there is no sig_rl.txt in the credentials path, an empty SigRL is returned.
 func ReadSigRL(sgxCredentialsPath string) (string, error) {
-	// TODO implement me
-	return "", nil
+	sigRLPath := filepath.Join(sgxCredentialsPath, "sig_rl.txt")
+	content, err := os.ReadFile(sigRLPath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read %s", sigRLPath)
+	}
+
+	sigRL := strings.TrimSpace(string(content))
+	if len(sigRL) == 0 {
+		return "", nil
+	}
+
+	if _, err := ParseSigRL(sigRL); err != nil {
+		return "", errors.Wrapf(err, "invalid sig_rl in %s", sigRLPath)
+	}
+
+	return sigRL, nil
 }
 
 func readFile(path string) (string, error) {
@@ -7,8 +7,11 @@ SPDX-License-Identifier: Apache-2.0
 package sgx_test
 
 import (
+	"encoding/base64"
+	"encoding/binary"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/hyperledger/fabric-private-chaincode/client_sdk/go/pkg/sgx"
@@ -27,8 +30,81 @@ func TestAttestationParamsToBase64EncodedJSON(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+const testSpid = "EEEEAAAABBBBAAAAEEEEAAAABBBBAAAA"
+
+// loadSigRL returns the base64 encoded SigRL fixture with two entries, laid out as se_sig_rl_t of the Intel SGX SDK
+func loadSigRL(t *testing.T) string {
+	sigRL, err := os.ReadFile(filepath.Join("testdata", "sig_rl.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(sigRL))
+}
+
+// emptySigRL returns a base64 encoded SigRL of the fixture group without entries
+func emptySigRL(t *testing.T) string {
+	raw, _ := base64.StdEncoding.DecodeString(loadSigRL(t))
+	sigRL := append([]byte{}, raw[:14]...)
+	binary.BigEndian.PutUint32(sigRL[10:], 0)
+	sigRL = append(sigRL, raw[len(raw)-64:]...)
+	return base64.StdEncoding.EncodeToString(sigRL)
+}
+
 func TestAttestationParamsValidate(t *testing.T) {
-	// TODO implement with validate
+	valid := []*sgx.AttestationParams{
+		{AttestationType: "simulated"},
+		{AttestationType: "epid-linkable", HexSpid: testSpid},
+		{AttestationType: "epid-unlinkable", HexSpid: testSpid, SigRL: emptySigRL(t)},
+		{AttestationType: "epid-unlinkable", HexSpid: "eeeeaaaabbbbaaaaeeeeaaaabbbbaaaa", SigRL: loadSigRL(t)},
+		{AttestationType: "cvm-tdx"},
+	}
+	for _, p := range valid {
+		assert.NoError(t, p.Validate(), "%v", p)
+	}
+
+	invalid := map[string]*sgx.AttestationParams{
+		"attestation type is required":                                              {HexSpid: testSpid},
+		"unsupported attestation type 'simulation'":                                 {AttestationType: "simulation"},
+		"attestation type 'simulated' does not use spid and sig_rl":                 {AttestationType: "simulated", HexSpid: testSpid},
+		"attestation type 'cvm-sev-snp' does not use spid and sig_rl":               {AttestationType: "cvm-sev-snp", SigRL: emptySigRL(t)},
+		"invalid spid length: expected 16 bytes, actual 0":                          {AttestationType: "epid-linkable"},
+		"invalid spid length: expected 16 bytes, actual 15":                         {AttestationType: "epid-linkable", HexSpid: testSpid[2:]},
+		"invalid spid, hex encoding expected: encoding/hex: odd length hex string":  {AttestationType: "epid-linkable", HexSpid: testSpid[1:]},
+		"invalid sig_rl: cannot decode sig_rl: illegal base64 data at input byte 4": {AttestationType: "epid-linkable", HexSpid: testSpid, SigRL: "some sig_rl"},
+	}
+	for expectedErr, p := range invalid {
+		assert.EqualError(t, p.Validate(), expectedErr)
+	}
+}
+
+func TestParseSigRL(t *testing.T) {
+	sigRL, err := sgx.ParseSigRL(loadSigRL(t))
+	assert.NoError(t, err)
+	assert.Equal(t, &sgx.SigRL{GroupId: "00000b3a", Version: 7, Entries: 2}, sigRL)
+
+	sigRL, err = sgx.ParseSigRL(emptySigRL(t))
+	assert.NoError(t, err)
+	assert.Equal(t, &sgx.SigRL{GroupId: "00000b3a", Version: 7, Entries: 0}, sigRL)
+
+	// too short
+	_, err = sgx.ParseSigRL(base64.StdEncoding.EncodeToString([]byte("too short")))
+	assert.EqualError(t, err, "sig_rl too short: 9 bytes")
+
+	raw, _ := base64.StdEncoding.DecodeString(loadSigRL(t))
+
+	// entries do not match length
+	_, err = sgx.ParseSigRL(base64.StdEncoding.EncodeToString(raw[:len(raw)-1]))
+	assert.EqualError(t, err, "invalid sig_rl length for 2 entries: expected 334 bytes, actual 333")
+
+	// wrong blob id
+	raw[1] = 0x0d
+	_, err = sgx.ParseSigRL(base64.StdEncoding.EncodeToString(raw))
+	assert.EqualError(t, err, "invalid sig_rl blob id: 13")
+
+	// wrong version
+	raw[0] = 0x01
+	_, err = sgx.ParseSigRL(base64.StdEncoding.EncodeToString(raw))
+	assert.EqualError(t, err, "unsupported sig_rl version: 1")
 }
 
 func TestCreateAttestationParamsFromEnvironment(t *testing.T) {
@@ -81,16 +157,21 @@ func TestCreateAttestationParamsFromCredentialsPath(t *testing.T) {
 	err = os.WriteFile(filepath.Join(testPath, "spid.txt"), []byte("EEEEAAAABBBBAAAEEEEAAAABBBBAAAA"), 0644)
 	assert.NoError(t, err)
 
-	// TODO once ReadSigRL is implemented
-	// write recovaction list to file
-
+	// no revocation list available
 	attestationParams, err = sgx.CreateAttestationParamsFromCredentialsPath(testPath)
 	assert.NoError(t, err)
 
 	assert.Equal(t, attestationParams.AttestationType, "simulation")
 	assert.Equal(t, attestationParams.HexSpid, "EEEEAAAABBBBAAAEEEEAAAABBBBAAAA")
-	// TODO once ReadSigRL is implemented
 	assert.Equal(t, attestationParams.SigRL, "")
+
+	sigRL := loadSigRL(t)
+	err = os.WriteFile(filepath.Join(testPath, "sig_rl.txt"), []byte(sigRL), 0644)
+	assert.NoError(t, err)
+
+	attestationParams, err = sgx.CreateAttestationParamsFromCredentialsPath(testPath)
+	assert.NoError(t, err)
+	assert.Equal(t, attestationParams.SigRL, sigRL)
 }
 
 func TestReadSPIDType(t *testing.T) {
@@ -144,8 +225,34 @@ func TestReadSPID(t *testing.T) {
 }
 
 func TestReadSigRL(t *testing.T) {
-	// TODO once ReadSigRL is implemented
-	sigRL, err := sgx.ReadSigRL("TODO")
+	testPath, err := os.MkdirTemp("/tmp/", "attestation")
+	assert.NoError(t, err)
+	defer os.RemoveAll(testPath)
+
+	// does not exist, the sig_rl is optional
+	sigRL, err := sgx.ReadSigRL(testPath)
 	assert.Empty(t, sigRL)
 	assert.NoError(t, err)
+
+	// empty sig_rl file
+	err = os.WriteFile(filepath.Join(testPath, "sig_rl.txt"), []byte("\n"), 0644)
+	assert.NoError(t, err)
+	sigRL, err = sgx.ReadSigRL(testPath)
+	assert.Empty(t, sigRL)
+	assert.NoError(t, err)
+
+	// invalid sig_rl
+	err = os.WriteFile(filepath.Join(testPath, "sig_rl.txt"), []byte("some invalid sig_rl"), 0644)
+	assert.NoError(t, err)
+	sigRL, err = sgx.ReadSigRL(testPath)
+	assert.Empty(t, sigRL)
+	assert.Error(t, err)
+
+	// success
+	expectedSigRL := loadSigRL(t)
+	err = os.WriteFile(filepath.Join(testPath, "sig_rl.txt"), []byte(expectedSigRL+"\n"), 0644)
+	assert.NoError(t, err)
+	sigRL, err = sgx.ReadSigRL(testPath)
+	assert.Equal(t, expectedSigRL, sigRL)
+	assert.NoError(t, err)
 }
@@ -0,0 +1,68 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package sgx
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+)
+
+// layout of an EPID signature revocation list (se_sig_rl_t of the Intel SGX SDK): a 1-byte protocol version and a
+// 1-byte EPID identifier, followed by the 4-byte group id, the 4-byte version and the 4-byte number of entries
+// (all big-endian), the entries and the ECDSA signature of IAS
+const (
+	sigRLVersion       = 0x02
+	sigRLBlobId        = 0x0e
+	sigRLHeaderSize    = 14
+	sigRLEntrySize     = 128
+	sigRLSignatureSize = 64
+)
+
+// SigRL is a parsed EPID signature revocation list as provided by IAS.
+type SigRL struct {
+	// GroupId is the hex encoded EPID group id the revocation list belongs to
+	GroupId string
+	// Version is the revocation list version
+	Version uint32
+	// Entries is the number of revoked signatures
+	Entries uint32
+}
+
+// ParseSigRL parses a base64 encoded EPID signature revocation list and checks its structure.
+func ParseSigRL(sigRLBase64 string) (*SigRL, error) {
+	sigRL, err := base64.StdEncoding.DecodeString(sigRLBase64)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode sig_rl")
+	}
+
+	if len(sigRL) < sigRLHeaderSize+sigRLSignatureSize {
+		return nil, errors.Errorf("sig_rl too short: %d bytes", len(sigRL))
+	}
+
+	if v := sigRL[0]; v != sigRLVersion {
+		return nil, errors.Errorf("unsupported sig_rl version: %d", v)
+	}
+
+	if id := sigRL[1]; id != sigRLBlobId {
+		return nil, errors.Errorf("invalid sig_rl blob id: %d", id)
+	}
+
+	entries := binary.BigEndian.Uint32(sigRL[10:14])
+	expectedLen := sigRLHeaderSize + uint64(entries)*sigRLEntrySize + sigRLSignatureSize
+	if uint64(len(sigRL)) != expectedLen {
+		return nil, errors.Errorf("invalid sig_rl length for %d entries: expected %d bytes, actual %d", entries, expectedLen, len(sigRL))
+	}
+
+	return &SigRL{
+		GroupId: hex.EncodeToString(sigRL[2:6]),
+		Version: binary.BigEndian.Uint32(sigRL[6:10]),
+		Entries: entries,
+	}, nil
+}
@@ -117,11 +117,13 @@ bool init_attestation(uint8_t* params, uint32_t params_length)
         }
     }
 
-    {  // set sig_rl
+    {  // set sig_rl (base64 encoded as provided by IAS, empty if there are no revoked signatures)
         const char* p;
+        std::string sig_rl;
         p = json_object_get_string(json_object(root), SIG_RL_TAG);
         COND2LOGERR(p == NULL, "no sig_rl provided");
-        g_attestation_state.sig_rl.assign(p, p + strlen(p));
+        sig_rl = base64_decode(std::string(p));
+        g_attestation_state.sig_rl.assign(sig_rl.begin(), sig_rl.end());
     }
 
 init_success:
@@ -7,11 +7,15 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-private-chaincode/ecc/chaincode/ercc"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
 	"github.com/hyperledger/fabric-private-chaincode/internal/endorsement"
 	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
@@ -22,12 +26,21 @@ import (
 
 var logger = flogging.MustGetLogger("ecc")
 
+// Command implements an ECC system function, that is, a function handled by ECC itself rather than by the
+// chaincode running inside the enclave.
+type Command func(stub shim.ChaincodeStubInterface) pb.Response
+
 // EnclaveChaincode struct
 type EnclaveChaincode struct {
 	Enclave   Enclave
 	Validator endorsement.Validation
 	Extractor Extractors
 	Ercc      ercc.Stub
+	// Metrics is optional; if set, ECC exports the invocation metrics reported by the enclave
+	Metrics *Metrics
+
+	commands     map[string]Command
+	commandsOnce sync.Once
 }
 
 // Init sets the chaincode state to "init"
@@ -40,16 +53,44 @@ func (t *EnclaveChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response
 	function, _ := stub.GetFunctionAndParameters()
 	logger.Infof("Invoke is running [%s]", function)
 
-	switch function {
-	case "__initEnclave":
-		return t.initEnclave(stub)
-	case "__invoke":
-		return t.invoke(stub)
-	case "__endorse":
-		return t.endorse(stub)
-	default:
+	t.commandsOnce.Do(t.registerSystemCommands)
+	command, ok := t.commands[function]
+	if !ok {
 		return shim.Error("invalid invocation")
 	}
+	return command(stub)
+}
+
+// RegisterCommand adds an ECC system function, e.g., for an extension such as key distribution.
+// The name must start with "__" and must not be taken by another command. Commands must be registered
+// before the chaincode is started.
+func (t *EnclaveChaincode) RegisterCommand(name string, command Command) error {
+	if !strings.HasPrefix(name, "__") {
+		return fmt.Errorf("invalid command name '%s': system functions must start with '__'", name)
+	}
+	if command == nil {
+		return fmt.Errorf("command %s is nil", name)
+	}
+
+	t.commandsOnce.Do(t.registerSystemCommands)
+	if _, exists := t.commands[name]; exists {
+		return fmt.Errorf("command %s already registered", name)
+	}
+	t.commands[name] = command
+	return nil
+}
+
+func (t *EnclaveChaincode) registerSystemCommands() {
+	t.commands = map[string]Command{
+		"__initEnclave":  t.initEnclave,
+		"__invoke":       t.invoke,
+		"__endorse":      t.endorse,
+		"__exportCCKeys": t.exportCCKeys,
+		"__importCCKeys": t.importCCKeys,
+		// read-only functions
+		"__enclaveInfo": t.enclaveInfo,
+		"__health":      t.health,
+	}
 }
 
 func (t *EnclaveChaincode) initEnclave(stub shim.ChaincodeStubInterface) pb.Response {
@@ -96,42 +137,28 @@ func (t *EnclaveChaincode) initEnclave(stub shim.ChaincodeStubInterface) pb.Resp
 	return shim.Success([]byte(base64.StdEncoding.EncodeToString(credentialsBytes)))
 }
 
+// invoke forwards an encrypted request to the enclave. Errors of the request or the chaincode are returned to the
+// client in the signed response of the enclave; thus, the peer only sees a generic status if the enclave fails.
 func (t *EnclaveChaincode) invoke(stub shim.ChaincodeStubInterface) pb.Response {
-	var errMsg string
-
 	serializedChaincodeRequest, err := t.Extractor.GetSerializedChaincodeRequest(stub)
 	if err != nil {
-		errMsg = fmt.Sprintf("cannot get chaincode request message from input: %s", err.Error())
+		errMsg := fmt.Sprintf("cannot get chaincode request message from input: %s", err.Error())
 		logger.Error(errMsg)
 		return shim.Error(errMsg)
 	}
 
-	signedChaincodeResponseMessage, errInvoke := t.Enclave.ChaincodeInvoke(stub, serializedChaincodeRequest)
-	if errInvoke != nil {
-		errMsg = fmt.Sprintf("t.Enclave.Invoke failed: %s", errInvoke)
-		logger.Error(errMsg)
-		// likely a chaincode error, so we still want response go back ...
+	signedChaincodeResponseMessage, err := t.Enclave.ChaincodeInvoke(stub, serializedChaincodeRequest)
+	if err != nil {
+		logger.Errorf("t.Enclave.Invoke failed: %s", err)
+		return shim.Error("enclave invocation failed")
 	}
 
+	signedChaincodeResponseMessage = t.takeMetrics(stub.GetChannelID(), signedChaincodeResponseMessage)
+
 	signedChaincodeResponseMessageB64 := []byte(base64.StdEncoding.EncodeToString(signedChaincodeResponseMessage))
 	logger.Debugf("base64-encoded response message: '%s'", signedChaincodeResponseMessageB64)
 
-	var response pb.Response
-	if errInvoke == nil {
-		response = pb.Response{
-			Status:  shim.OK,
-			Payload: signedChaincodeResponseMessageB64,
-			Message: errMsg,
-		}
-	} else {
-		response = pb.Response{
-			Status:  shim.ERROR,
-			Payload: signedChaincodeResponseMessageB64,
-			Message: errMsg,
-		}
-	}
-
-	return response
+	return shim.Success(signedChaincodeResponseMessageB64)
 }
 
 func (t *EnclaveChaincode) endorse(stub shim.ChaincodeStubInterface) pb.Response {
@@ -150,6 +177,11 @@ func (t *EnclaveChaincode) endorse(stub shim.ChaincodeStubInterface) pb.Response
 		return shim.Error(errMsg)
 	}
 
+	// responses reporting an error must not be committed
+	if responseMsg.ErrorCode != protos.ErrorCode_ERROR_CODE_OK {
+		return shim.Error(fmt.Sprintf("enclave response reports an error (%s)", responseMsg.ErrorCode))
+	}
+
 	logger.Infof("try to get credentials from ERCC for channel: %s ccId: %s EnclaveId: %s ", chaincodeParams.ChannelId, chaincodeParams.ChaincodeId, responseMsg.EnclaveId)
 
 	// get corresponding enclave credentials from ercc
@@ -181,6 +213,23 @@ func (t *EnclaveChaincode) endorse(stub shim.ChaincodeStubInterface) pb.Response
 		return shim.Error(err.Error())
 	}
 
+	// the response must belong to a fresh proposal of the submitter, and must not have been committed before
+	err = t.Validator.ValidateProposal(stub, responseMsg)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// the rwset must match the signed digest, if any
+	if responseMsg.RwSetDigest != nil {
+		digest, err := utils.GetRwSetDigest(responseMsg.FpcRwSet, crypto.Hasher(crypto.GetDefaultCSP(), responseMsg.GetHashAlgorithm()))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if !bytes.Equal(digest, responseMsg.RwSetDigest) {
+			return shim.Error("rwset does not match rwset digest")
+		}
+	}
+
 	// replay read/writes from kvrwset from Enclave (to prepare commitment to ledger) and extract kvrwset for subsequent validation
 	logger.Debug("Replaying rwset")
 	err = t.Validator.ReplayReadWrites(stub, responseMsg.FpcRwSet)
@@ -192,6 +241,90 @@ func (t *EnclaveChaincode) endorse(stub shim.ChaincodeStubInterface) pb.Response
 	return shim.Success([]byte("OK")) // make sure we have a non-empty return on success so we can distinguish success from failure in cli ...
 }
 
+// exportCCKeys hands over the chaincode keys of this enclave to the enclave of an upgraded chaincode version allowed by
+// the upgrade policy in ERCC. It must be called before the upgraded chaincode definition is committed, as the peer
+// dispatches to the new chaincode afterwards. The enclave enforces the upgrade policy itself.
+func (t *EnclaveChaincode) exportCCKeys(stub shim.ChaincodeStubInterface) pb.Response {
+	chaincodeParams, err := t.Extractor.GetChaincodeParams(stub)
+	if err != nil {
+		errMsg := fmt.Sprintf("cannot extract chaincode params: %s", err.Error())
+		logger.Error(errMsg)
+		return shim.Error(errMsg)
+	}
+
+	upgradePolicy, err := t.Ercc.QueryUpgradePolicy(stub, chaincodeParams.ChannelId, chaincodeParams.ChaincodeId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if upgradePolicy == nil {
+		return shim.Error(fmt.Sprintf("no upgrade policy set for chaincode %s", chaincodeParams.ChaincodeId))
+	}
+
+	if err := t.Enclave.ExportCCKeys(upgradePolicy); err != nil {
+		errMsg := fmt.Sprintf("Enclave ExportCCKeys function failed: %s", err.Error())
+		logger.Error(errMsg)
+		return shim.Error(errMsg)
+	}
+
+	return shim.Success([]byte("OK"))
+}
+
+// importCCKeys imports the chaincode keys handed over by the enclave which this enclave replaced with an enclave upgrade
+func (t *EnclaveChaincode) importCCKeys(stub shim.ChaincodeStubInterface) pb.Response {
+	chaincodeParams, err := t.Extractor.GetChaincodeParams(stub)
+	if err != nil {
+		errMsg := fmt.Sprintf("cannot extract chaincode params: %s", err.Error())
+		logger.Error(errMsg)
+		return shim.Error(errMsg)
+	}
+
+	enclaveId, err := t.Enclave.GetEnclaveId()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	previousEnclaveId, err := t.Ercc.QueryPreviousEnclaveId(stub, chaincodeParams.ChannelId, chaincodeParams.ChaincodeId, enclaveId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	signedCCKeyRegistrationMessage, err := t.Enclave.ImportCCKeys(previousEnclaveId)
+	if err != nil {
+		errMsg := fmt.Sprintf("Enclave ImportCCKeys function failed: %s", err.Error())
+		logger.Error(errMsg)
+		return shim.Error(errMsg)
+	}
+
+	return shim.Success([]byte(base64.StdEncoding.EncodeToString(signedCCKeyRegistrationMessage)))
+}
+
+// enclaveInfo returns the enclave id, mrenclave, attestation type and uptime of the enclave signed by the enclave.
+// An optional argument is included as nonce in the signed response.
+func (t *EnclaveChaincode) enclaveInfo(stub shim.ChaincodeStubInterface) pb.Response {
+	var nonce []byte
+	if _, args := stub.GetFunctionAndParameters(); len(args) > 0 {
+		nonce = []byte(args[0])
+	}
+
+	signedEnclaveInfo, err := t.Enclave.GetEnclaveInfo(nonce)
+	if err != nil {
+		errMsg := fmt.Sprintf("Enclave GetEnclaveInfo function failed: %s", err.Error())
+		logger.Error(errMsg)
+		return shim.Error(errMsg)
+	}
+
+	return shim.Success([]byte(base64.StdEncoding.EncodeToString(signedEnclaveInfo)))
+}
+
+// health reports whether the enclave is initialized and ECC is ready to process transactions, e.g., for liveness probes
+func (t *EnclaveChaincode) health(stub shim.ChaincodeStubInterface) pb.Response {
+	if _, err := t.Enclave.GetEnclaveId(); err != nil {
+		return shim.Error(fmt.Sprintf("enclave not ready: %s", err.Error()))
+	}
+
+	return shim.Success([]byte("OK"))
+}
+
 func ccParamsMatch(expected, actual *protos.CCParameters) bool {
 	return expected.ChaincodeId == actual.ChaincodeId &&
 		expected.ChannelId == actual.ChannelId &&
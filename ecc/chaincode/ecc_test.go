@@ -8,9 +8,14 @@ import (
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-private-chaincode/ecc/chaincode/ercc"
 	"github.com/hyperledger/fabric-private-chaincode/ecc/chaincode/fakes"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
 	"github.com/hyperledger/fabric-private-chaincode/internal/endorsement"
 	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
 	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/hyperledger/fabric/protoutil"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/types/known/anypb"
 )
@@ -140,17 +145,16 @@ func TestInvokeEnclave(t *testing.T) {
 	ex.GetSerializedChaincodeRequestReturns([]byte("someChaincodeRequest"), nil)
 	ec.ChaincodeInvokeReturns(expectedResp, expectedErr)
 	r = ecc.Invoke(stub)
-	expectError(t, fmt.Sprintf("t.Enclave.Invoke failed: %s", expectedErr), r)
-	p, err := base64.StdEncoding.DecodeString(string(r.Payload))
-	assert.NoError(t, err)
-	assert.EqualValues(t, expectedResp, p)
+	// the error of the enclave is not revealed to the peer
+	expectError(t, "enclave invocation failed", r)
+	assert.Empty(t, r.Payload)
 
 	// no error
 	ex.GetSerializedChaincodeRequestReturns([]byte("someChaincodeRequest"), nil)
 	ec.ChaincodeInvokeReturns(expectedResp, nil)
 	r = ecc.Invoke(stub)
 	assert.EqualValues(t, shim.OK, r.Status)
-	p, err = base64.StdEncoding.DecodeString(string(r.Payload))
+	p, err := base64.StdEncoding.DecodeString(string(r.Payload))
 	assert.NoError(t, err)
 	assert.EqualValues(t, expectedResp, p)
 	s, scr := ec.ChaincodeInvokeArgsForCall(1)
@@ -158,6 +162,76 @@ func TestInvokeEnclave(t *testing.T) {
 	assert.Equal(t, []byte("someChaincodeRequest"), scr)
 }
 
+func TestInvokeEnclaveMetrics(t *testing.T) {
+	stub := &fakes.ChaincodeStub{}
+	stub.GetFunctionAndParametersReturns("__invoke", nil)
+	stub.GetChannelIDReturns("mychannel")
+	ec, _, ex, _ := newFakes()
+	ecc := newECC(ec, nil, ex, nil)
+
+	histogram := &metricsfakes.Histogram{}
+	histogram.WithReturns(histogram)
+	counter := &metricsfakes.Counter{}
+	counter.WithReturns(counter)
+	provider := &metricsfakes.Provider{}
+	provider.NewHistogramReturns(histogram)
+	provider.NewCounterReturns(counter)
+	ecc.Metrics = NewMetrics(provider)
+
+	// response without metrics
+	ex.GetSerializedChaincodeRequestReturns([]byte("someChaincodeRequest"), nil)
+	response := &protos.ChaincodeResponseMessage{EnclaveId: "someEnclaveId"}
+	ec.ChaincodeInvokeReturns(protoutil.MarshalOrPanic(&protos.SignedChaincodeResponseMessage{ChaincodeResponseMessage: protoutil.MarshalOrPanic(response)}), nil)
+	r := ecc.Invoke(stub)
+	assert.EqualValues(t, shim.OK, r.Status)
+	assert.Equal(t, 0, histogram.ObserveCallCount())
+
+	// response with metrics
+	response.ErrorCode = protos.ErrorCode_ERROR_CODE_CHAINCODE_ERROR
+	signedResponse := &protos.SignedChaincodeResponseMessage{
+		ChaincodeResponseMessage: protoutil.MarshalOrPanic(response),
+		Signature:                []byte("someSignature"),
+	}
+	signedResponseWithMetrics := &protos.SignedChaincodeResponseMessage{
+		ChaincodeResponseMessage: signedResponse.ChaincodeResponseMessage,
+		Signature:                signedResponse.Signature,
+		Metrics: &protos.InvocationMetrics{
+			Function:       "someFunction",
+			Reads:          2,
+			Writes:         1,
+			BytesEncrypted: 100,
+			BytesDecrypted: 200,
+			DurationMicros: 1500,
+		},
+	}
+	ec.ChaincodeInvokeReturns(protoutil.MarshalOrPanic(signedResponseWithMetrics), nil)
+	r = ecc.Invoke(stub)
+	assert.EqualValues(t, shim.OK, r.Status)
+
+	// the metrics are removed from the response
+	assert.Equal(t, base64.StdEncoding.EncodeToString(protoutil.MarshalOrPanic(signedResponse)), string(r.Payload))
+
+	assert.Equal(t, 1, histogram.ObserveCallCount())
+	assert.Equal(t, 0.0015, histogram.ObserveArgsForCall(0))
+	assert.Equal(t, []string{"channel", "mychannel", "function", "someFunction", "status", "ERROR_CODE_CHAINCODE_ERROR"}, histogram.WithArgsForCall(0))
+	assert.Equal(t, 4, counter.AddCallCount())
+	assert.Equal(t, []float64{2, 1, 100, 200}, []float64{counter.AddArgsForCall(0), counter.AddArgsForCall(1), counter.AddArgsForCall(2), counter.AddArgsForCall(3)})
+	assert.Equal(t, []string{"channel", "mychannel", "function", "someFunction"}, counter.WithArgsForCall(0))
+
+	// the metrics are removed even if ECC does not export them
+	ecc.Metrics = nil
+	r = ecc.Invoke(stub)
+	assert.EqualValues(t, shim.OK, r.Status)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(protoutil.MarshalOrPanic(signedResponse)), string(r.Payload))
+	ecc.Metrics = NewMetrics(provider)
+
+	// invalid response
+	ec.ChaincodeInvokeReturns([]byte("invalid response"), nil)
+	r = ecc.Invoke(stub)
+	assert.EqualValues(t, shim.OK, r.Status)
+	assert.Equal(t, 1, histogram.ObserveCallCount())
+}
+
 func TestEndorse(t *testing.T) {
 	stub := &fakes.ChaincodeStub{}
 	stub.GetFunctionAndParametersReturns("__endorse", nil)
@@ -194,6 +268,13 @@ func TestEndorse(t *testing.T) {
 	r = ecc.Invoke(stub)
 	expectError(t, fmt.Sprintf("cannot extract chaincode response message: %s", expectedErr), r)
 
+	// response reports an error
+	ex.GetChaincodeParamsReturns(expectedCCParams, nil)
+	ex.GetChaincodeResponseMessagesReturns(expectedSignedResp, &protos.ChaincodeResponseMessage{EnclaveId: "someEnclaveId", ErrorCode: protos.ErrorCode_ERROR_CODE_CHAINCODE_ERROR}, nil)
+	r = ecc.Invoke(stub)
+	expectError(t, "enclave response reports an error (ERROR_CODE_CHAINCODE_ERROR)", r)
+	assert.Equal(t, 0, ercc.QueryEnclaveCredentialsCallCount())
+
 	// queryEnclaveCredentials returns error
 	ex.GetChaincodeParamsReturns(expectedCCParams, nil)
 	ex.GetChaincodeResponseMessagesReturns(expectedSignedResp, expectedResp, nil)
@@ -242,6 +323,36 @@ func TestEndorse(t *testing.T) {
 	r = ecc.Invoke(stub)
 	expectError(t, expectedErr.Error(), r)
 
+	// error when validating the proposal
+	val.ValidateReturns(nil)
+	val.ValidateProposalReturns(expectedErr)
+	r = ecc.Invoke(stub)
+	expectError(t, expectedErr.Error(), r)
+	_, validatedResp := val.ValidateProposalArgsForCall(0)
+	assert.Equal(t, expectedResp, validatedResp)
+	assert.Equal(t, 0, val.ReplayReadWritesCallCount())
+	val.ValidateProposalReturns(nil)
+
+	// rwset does not match digest
+	fpcRwSet := &protos.FPCKVSet{RwSet: &kvrwset.KVRWSet{Writes: []*kvrwset.KVWrite{{Key: "someKey", Value: []byte("someValue")}}}}
+	digest, err := utils.GetRwSetDigest(fpcRwSet, crypto.Hasher(crypto.GetDefaultCSP(), crypto.DefaultHashAlgorithm))
+	assert.NoError(t, err)
+	respWithDigest := &protos.ChaincodeResponseMessage{EnclaveId: "someEnclaveId", FpcRwSet: &protos.FPCKVSet{}, RwSetDigest: digest}
+	ex.GetChaincodeParamsReturns(expectedCCParams, nil)
+	ex.GetChaincodeResponseMessagesReturns(expectedSignedResp, respWithDigest, nil)
+	ercc.QueryEnclaveCredentialsReturns(expectedCred, nil)
+	val.ValidateReturns(nil)
+	r = ecc.Invoke(stub)
+	expectError(t, "rwset does not match rwset digest", r)
+	assert.Equal(t, 0, val.ReplayReadWritesCallCount())
+
+	// rwset matches digest
+	respWithDigest.FpcRwSet = fpcRwSet
+	r = ecc.Invoke(stub)
+	assert.EqualValues(t, shim.OK, r.Status)
+	_, replayedRwSet := val.ReplayReadWritesArgsForCall(0)
+	assert.Equal(t, fpcRwSet, replayedRwSet)
+
 	// error when checking rwset
 	ex.GetChaincodeParamsReturns(expectedCCParams, nil)
 	ex.GetChaincodeResponseMessagesReturns(expectedSignedResp, expectedResp, nil)
@@ -262,6 +373,160 @@ func TestEndorse(t *testing.T) {
 	assert.EqualValues(t, []byte("OK"), r.Payload)
 }
 
+func TestExportCCKeys(t *testing.T) {
+	stub := &fakes.ChaincodeStub{}
+	stub.GetFunctionAndParametersReturns("__exportCCKeys", nil)
+	ec, _, ex, ercc := newFakes()
+	ecc := newECC(ec, nil, ex, ercc)
+	expectedErr := fmt.Errorf("some error")
+
+	// error getting chaincode params
+	ex.GetChaincodeParamsReturns(nil, expectedErr)
+	r := ecc.Invoke(stub)
+	expectError(t, fmt.Sprintf("cannot extract chaincode params: %s", expectedErr), r)
+
+	// no upgrade policy
+	ex.GetChaincodeParamsReturns(&protos.CCParameters{ChaincodeId: "SomeChaincodeId", ChannelId: "mychannel"}, nil)
+	ercc.QueryUpgradePolicyReturns(nil, nil)
+	r = ecc.Invoke(stub)
+	expectError(t, "no upgrade policy set for chaincode SomeChaincodeId", r)
+	assert.Equal(t, 0, ec.ExportCCKeysCallCount())
+	_, channelId, chaincodeId := ercc.QueryUpgradePolicyArgsForCall(0)
+	assert.Equal(t, "mychannel", channelId)
+	assert.Equal(t, "SomeChaincodeId", chaincodeId)
+
+	// error when exporting, e.g., the enclave rejects the policy
+	ercc.QueryUpgradePolicyReturns([]byte("somePolicy"), nil)
+	ec.ExportCCKeysReturns(expectedErr)
+	r = ecc.Invoke(stub)
+	expectError(t, fmt.Sprintf("Enclave ExportCCKeys function failed: %s", expectedErr), r)
+
+	// no error
+	ec.ExportCCKeysReturns(nil)
+	r = ecc.Invoke(stub)
+	assert.EqualValues(t, shim.OK, r.Status)
+	assert.EqualValues(t, []byte("OK"), r.Payload)
+	assert.Equal(t, []byte("somePolicy"), ec.ExportCCKeysArgsForCall(1))
+}
+
+func TestImportCCKeys(t *testing.T) {
+	stub := &fakes.ChaincodeStub{}
+	stub.GetFunctionAndParametersReturns("__importCCKeys", nil)
+	ec, _, ex, ercc := newFakes()
+	ecc := newECC(ec, nil, ex, ercc)
+	expectedErr := fmt.Errorf("some error")
+
+	// error getting chaincode params
+	ex.GetChaincodeParamsReturns(nil, expectedErr)
+	r := ecc.Invoke(stub)
+	expectError(t, fmt.Sprintf("cannot extract chaincode params: %s", expectedErr), r)
+
+	// no upgrade recorded in ercc
+	ex.GetChaincodeParamsReturns(&protos.CCParameters{ChaincodeId: "SomeChaincodeId", ChannelId: "mychannel"}, nil)
+	ec.GetEnclaveIdReturns("someEnclaveId", nil)
+	ercc.QueryPreviousEnclaveIdReturns("", expectedErr)
+	r = ecc.Invoke(stub)
+	expectError(t, expectedErr.Error(), r)
+	_, channelId, chaincodeId, enclaveId := ercc.QueryPreviousEnclaveIdArgsForCall(0)
+	assert.Equal(t, "mychannel", channelId)
+	assert.Equal(t, "SomeChaincodeId", chaincodeId)
+	assert.Equal(t, "someEnclaveId", enclaveId)
+
+	// error when importing
+	ercc.QueryPreviousEnclaveIdReturns("somePreviousEnclaveId", nil)
+	ec.ImportCCKeysReturns(nil, expectedErr)
+	r = ecc.Invoke(stub)
+	expectError(t, fmt.Sprintf("Enclave ImportCCKeys function failed: %s", expectedErr), r)
+
+	// no error
+	expectedResp := []byte("someCCKeyRegistrationMessage")
+	ec.ImportCCKeysReturns(expectedResp, nil)
+	r = ecc.Invoke(stub)
+	assert.EqualValues(t, shim.OK, r.Status)
+	p, err := base64.StdEncoding.DecodeString(string(r.Payload))
+	assert.NoError(t, err)
+	assert.EqualValues(t, expectedResp, p)
+	assert.Equal(t, "somePreviousEnclaveId", ec.ImportCCKeysArgsForCall(1))
+}
+
+func TestEnclaveInfo(t *testing.T) {
+	stub := &fakes.ChaincodeStub{}
+	stub.GetFunctionAndParametersReturns("__enclaveInfo", []string{"someNonce"})
+	ec, _, _, _ := newFakes()
+	ecc := newECC(ec, nil, nil, nil)
+	expectedErr := fmt.Errorf("some error")
+
+	// error getting enclave info
+	ec.GetEnclaveInfoReturns(nil, expectedErr)
+	r := ecc.Invoke(stub)
+	expectError(t, fmt.Sprintf("Enclave GetEnclaveInfo function failed: %s", expectedErr), r)
+
+	// no error
+	expectedResp := []byte("someSignedEnclaveInfo")
+	ec.GetEnclaveInfoReturns(expectedResp, nil)
+	r = ecc.Invoke(stub)
+	assert.EqualValues(t, shim.OK, r.Status)
+	p, err := base64.StdEncoding.DecodeString(string(r.Payload))
+	assert.NoError(t, err)
+	assert.EqualValues(t, expectedResp, p)
+	assert.Equal(t, []byte("someNonce"), ec.GetEnclaveInfoArgsForCall(1))
+
+	// without nonce
+	stub.GetFunctionAndParametersReturns("__enclaveInfo", nil)
+	r = ecc.Invoke(stub)
+	assert.EqualValues(t, shim.OK, r.Status)
+	assert.Nil(t, ec.GetEnclaveInfoArgsForCall(2))
+}
+
+func TestHealth(t *testing.T) {
+	stub := &fakes.ChaincodeStub{}
+	stub.GetFunctionAndParametersReturns("__health", nil)
+	ec, _, _, _ := newFakes()
+	ecc := newECC(ec, nil, nil, nil)
+
+	// enclave not initialized
+	ec.GetEnclaveIdReturns("", fmt.Errorf("enclave not yet initialized"))
+	r := ecc.Invoke(stub)
+	expectError(t, "enclave not ready: enclave not yet initialized", r)
+
+	ec.GetEnclaveIdReturns("someEnclaveId", nil)
+	r = ecc.Invoke(stub)
+	assert.Equal(t, shim.Success([]byte("OK")), r)
+}
+
+func TestRegisterCommand(t *testing.T) {
+	stub := &fakes.ChaincodeStub{}
+	ecc := newECC(newFakes())
+
+	err := ecc.RegisterCommand("__someCommand", func(stub shim.ChaincodeStubInterface) peer.Response {
+		return shim.Success([]byte("someResponse"))
+	})
+	assert.NoError(t, err)
+
+	stub.GetFunctionAndParametersReturns("__someCommand", nil)
+	r := ecc.Invoke(stub)
+	assert.Equal(t, shim.Success([]byte("someResponse")), r)
+
+	// system functions cannot be replaced
+	err = ecc.RegisterCommand("__endorse", func(stub shim.ChaincodeStubInterface) peer.Response {
+		return shim.Success(nil)
+	})
+	assert.EqualError(t, err, "command __endorse already registered")
+
+	err = ecc.RegisterCommand("__someCommand", func(stub shim.ChaincodeStubInterface) peer.Response {
+		return shim.Success(nil)
+	})
+	assert.EqualError(t, err, "command __someCommand already registered")
+
+	err = ecc.RegisterCommand("someCommand", func(stub shim.ChaincodeStubInterface) peer.Response {
+		return shim.Success(nil)
+	})
+	assert.EqualError(t, err, "invalid command name 'someCommand': system functions must start with '__'")
+
+	err = ecc.RegisterCommand("__nilCommand", nil)
+	assert.EqualError(t, err, "command __nilCommand is nil")
+}
+
 func expectError(t *testing.T, errorMsg string, r peer.Response) {
 	assert.EqualValues(t, shim.ERROR, r.Status)
 	assert.EqualValues(t, errorMsg, r.Message)
@@ -21,19 +21,23 @@ type Enclave interface {
 	// GetEnclaveId returns the EnclaveId hosted by the peer
 	GetEnclaveId() (string, error)
 
+	// GetEnclaveInfo returns a SignedEnclaveInfo message including the given nonce
+	// The output parameter is a serialized protobuf
+	GetEnclaveInfo(nonce []byte) (signedEnclaveInfo []byte, err error)
+
 	// key distribution (Post-MVP Feature)
 
 	// GenerateCCKeys returns a signed CCKeyRegistration Message including
 	// The output parameters is a serialized protobuf
 	GenerateCCKeys() (signedCCKeyRegistrationMessage []byte, err error)
 
-	// ExportCCKeys exports chaincode secrets to enclave with provided credentials
-	// The input and output parameters are serialized protobufs
-	ExportCCKeys(credentials []byte) (signedExportMessage []byte, err error)
+	// ExportCCKeys hands over chaincode secrets to the enclaves of the chaincode versions allowed by the given
+	// upgrade policy, as stored by ERCC
+	ExportCCKeys(upgradePolicy []byte) error
 
-	// ImportCCKeys imports chaincode secrets
+	// ImportCCKeys imports chaincode secrets handed over by the enclave this enclave replaced
 	// The output parameters is a serialized protobuf
-	ImportCCKeys() (signedCCKeyRegistrationMessage []byte, err error)
+	ImportCCKeys(previousEnclaveId string) (signedCCKeyRegistrationMessage []byte, err error)
 
 	// ChaincodeInvoke invokes fpc chaincode inside enclave
 	// chaincodeRequestMessage and chaincodeResponseMessage are serialized protobuf
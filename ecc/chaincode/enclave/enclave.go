@@ -17,9 +17,12 @@ import (
 	"unsafe"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
 	"github.com/hyperledger/fabric/common/flogging"
 	"github.com/hyperledger/fabric/protoutil"
 	"golang.org/x/sync/semaphore"
+	"google.golang.org/protobuf/proto"
 )
 
 // #cgo CFLAGS: -I${SRCDIR}/ecc-enclave-include -I${SRCDIR}/../../../common/sgxcclib
@@ -38,6 +41,7 @@ type EnclaveStub struct {
 	eid           C.enclave_id_t
 	sem           *semaphore.Weighted
 	isInitialized bool
+	enclaveId     string
 }
 
 func NewEnclaveStub() *EnclaveStub {
@@ -87,26 +91,50 @@ func (e *EnclaveStub) Init(chaincodeParams, hostParams, attestationParams []byte
 	e.sem.Release(1)
 	logger.Infof("Enclave created with eid=%d", e.eid)
 
+	credentialsBytes := C.GoBytes(credentialsBuffer, C.int(credentialsSize))
+
+	// remember the enclave id as given by the enclave vk in the credentials
+	credentials := &protos.Credentials{}
+	if err := proto.Unmarshal(credentialsBytes, credentials); err != nil {
+		return nil, fmt.Errorf("invalid credentials: %s", err.Error())
+	}
+	attestedData, err := utils.UnmarshalAttestedData(credentials.SerializedAttestedData)
+	if err != nil {
+		return nil, err
+	}
+	e.enclaveId = utils.GetEnclaveId(attestedData)
+
 	e.isInitialized = true
 
 	// return credential bytes from sgx call
-	return C.GoBytes(credentialsBuffer, C.int(credentialsSize)), nil
+	return credentialsBytes, nil
 }
 
 func (e *EnclaveStub) GenerateCCKeys() ([]byte, error) {
 	panic("implement me")
 }
 
-func (e *EnclaveStub) ExportCCKeys(credentials []byte) ([]byte, error) {
+func (e *EnclaveStub) ExportCCKeys(upgradePolicy []byte) error {
 	panic("implement me")
 }
 
-func (e *EnclaveStub) ImportCCKeys() ([]byte, error) {
+func (e *EnclaveStub) ImportCCKeys(previousEnclaveId string) ([]byte, error) {
 	panic("implement me")
 }
 
 func (e *EnclaveStub) GetEnclaveId() (string, error) {
-	panic("implement me")
+	if !e.isInitialized {
+		return "", errors.New("enclave not yet initialized")
+	}
+	return e.enclaveId, nil
+}
+
+// GetEnclaveInfo is not yet supported by the C++ enclave
+func (e *EnclaveStub) GetEnclaveInfo(nonce []byte) ([]byte, error) {
+	if !e.isInitialized {
+		return nil, errors.New("enclave not yet initialized")
+	}
+	return nil, errors.New("enclave info is not supported by this enclave")
 }
 
 // ChaincodeInvoke calls the enclave for transaction processing
@@ -16,6 +16,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
@@ -35,6 +36,8 @@ type MockEnclaveStub struct {
 	publicKey    []byte
 	enclaveId    string
 	ccPrivateKey []byte
+	mrenclave    string
+	initTime     time.Time
 }
 
 func NewEnclaveStub() *MockEnclaveStub {
@@ -74,6 +77,8 @@ func (m *MockEnclaveStub) Init(serializedChaincodeParams, serializedHostParamsBy
 
 	// calculate enclave id
 	m.enclaveId, _ = m.GetEnclaveId()
+	m.mrenclave = chaincodeParams.Version
+	m.initTime = time.Now()
 
 	logger.Debug("Init")
 
@@ -97,12 +102,11 @@ func (m MockEnclaveStub) GenerateCCKeys() ([]byte, error) {
 	// -> *protos.SignedCCKeyRegistrationMessage
 }
 
-func (m MockEnclaveStub) ExportCCKeys(credentials []byte) ([]byte, error) {
+func (m MockEnclaveStub) ExportCCKeys(upgradePolicy []byte) error {
 	panic("implement me")
-	// credentials *protos.Credentials -> *protos.SignedExportMessage,
 }
 
-func (m MockEnclaveStub) ImportCCKeys() ([]byte, error) {
+func (m MockEnclaveStub) ImportCCKeys(previousEnclaveId string) ([]byte, error) {
 	panic("implement me")
 	// -> *protos.SignedCCKeyRegistrationMessage
 }
@@ -112,6 +116,33 @@ func (m *MockEnclaveStub) GetEnclaveId() (string, error) {
 	return strings.ToUpper(hex.EncodeToString(hash[:])), nil
 }
 
+func (m *MockEnclaveStub) GetEnclaveInfo(nonce []byte) ([]byte, error) {
+	if m.privateKey == nil {
+		return nil, fmt.Errorf("enclave not yet initialized")
+	}
+
+	enclaveInfoBytes, err := proto.Marshal(&protos.EnclaveInfo{
+		EnclaveId:        m.enclaveId,
+		ChaincodeVersion: m.mrenclave,
+		AttestationType:  "simulated",
+		UptimeSeconds:    uint64(time.Since(m.initTime).Seconds()),
+		Nonce:            nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := m.csp.SignMessage(m.privateKey, enclaveInfoBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&protos.SignedEnclaveInfo{
+		EnclaveInfo: enclaveInfoBytes,
+		Signature:   sig,
+	})
+}
+
 func (m *MockEnclaveStub) ChaincodeInvoke(stub shim.ChaincodeStubInterface, chaincodeRequestMessageBytes []byte) ([]byte, error) {
 	logger.Debug("ChaincodeInvoke")
 
@@ -171,7 +202,10 @@ func (m *MockEnclaveStub) ChaincodeInvoke(stub shim.ChaincodeStubInterface, chai
 	// do some read/write ops
 	_ = stub.PutState("SomeOtherKey", []byte("some value"))
 	v, _ := stub.GetState("helloKey")
-	v_hash := sha256.Sum256(v)
+	v_hash, err := m.csp.Hash(crypto.DefaultHashAlgorithm, v)
+	if err != nil {
+		return nil, err
+	}
 	logger.Debugf("get state: %s with hash %s", v, v_hash)
 
 	// construct rwset for validation
@@ -189,10 +223,13 @@ func (m *MockEnclaveStub) ChaincodeInvoke(stub shim.ChaincodeStubInterface, chai
 
 	fpcKvSet := &protos.FPCKVSet{
 		RwSet:           rwset,
-		ReadValueHashes: [][]byte{v_hash[:]},
+		ReadValueHashes: [][]byte{v_hash},
 	}
 
-	requestMessageHash := sha256.Sum256(chaincodeRequestMessageBytes)
+	requestMessageHash, err := m.csp.Hash(crypto.DefaultHashAlgorithm, chaincodeRequestMessageBytes)
+	if err != nil {
+		return nil, err
+	}
 
 	//create dummy response
 	responseData := []byte("some response")
@@ -211,7 +248,7 @@ func (m *MockEnclaveStub) ChaincodeInvoke(stub shim.ChaincodeStubInterface, chai
 		FpcRwSet:                    fpcKvSet,
 		EnclaveId:                   m.enclaveId,
 		Proposal:                    signedProposal,
-		ChaincodeRequestMessageHash: requestMessageHash[:],
+		ChaincodeRequestMessageHash: requestMessageHash,
 	}
 
 	responseBytes, err := proto.Marshal(response)
@@ -17,6 +17,8 @@ import (
 
 type Stub interface {
 	QueryEnclaveCredentials(stub shim.ChaincodeStubInterface, channelId, chaincodeId, enclaveId string) (*protos.Credentials, error)
+	QueryUpgradePolicy(stub shim.ChaincodeStubInterface, channelId, chaincodeId string) ([]byte, error)
+	QueryPreviousEnclaveId(stub shim.ChaincodeStubInterface, channelId, chaincodeId, enclaveId string) (string, error)
 }
 
 type StubImpl struct {
@@ -33,3 +35,31 @@ func (ercc *StubImpl) QueryEnclaveCredentials(stub shim.ChaincodeStubInterface,
 
 	return utils.UnmarshalCredentials(string(resp.Payload))
 }
+
+// QueryUpgradePolicy returns the upgrade policy of a chaincode as stored by ERCC, i.e., base64-encoded, or nil if no policy is set
+func (ercc *StubImpl) QueryUpgradePolicy(stub shim.ChaincodeStubInterface, channelId, chaincodeId string) ([]byte, error) {
+	args := [][]byte{[]byte("queryUpgradePolicy"), []byte(chaincodeId)}
+
+	resp := stub.InvokeChaincode("ercc", args, channelId)
+	if resp.Status != shim.OK {
+		return nil, fmt.Errorf("error: %s", resp.Message)
+	}
+
+	if len(resp.Payload) == 0 {
+		return nil, nil
+	}
+
+	return resp.Payload, nil
+}
+
+// QueryPreviousEnclaveId returns the id of the enclave which was replaced by the given enclave
+func (ercc *StubImpl) QueryPreviousEnclaveId(stub shim.ChaincodeStubInterface, channelId, chaincodeId, enclaveId string) (string, error) {
+	args := [][]byte{[]byte("queryPreviousEnclaveId"), []byte(chaincodeId), []byte(enclaveId)}
+
+	resp := stub.InvokeChaincode("ercc", args, channelId)
+	if resp.Status != shim.OK {
+		return "", fmt.Errorf("error: %s", resp.Message)
+	}
+
+	return string(resp.Payload), nil
+}
@@ -22,18 +22,16 @@ type EnclaveStub struct {
 		result1 []byte
 		result2 error
 	}
-	ExportCCKeysStub        func([]byte) ([]byte, error)
+	ExportCCKeysStub        func([]byte) error
 	exportCCKeysMutex       sync.RWMutex
 	exportCCKeysArgsForCall []struct {
 		arg1 []byte
 	}
 	exportCCKeysReturns struct {
-		result1 []byte
-		result2 error
+		result1 error
 	}
 	exportCCKeysReturnsOnCall map[int]struct {
-		result1 []byte
-		result2 error
+		result1 error
 	}
 	GenerateCCKeysStub        func() ([]byte, error)
 	generateCCKeysMutex       sync.RWMutex
@@ -59,9 +57,23 @@ type EnclaveStub struct {
 		result1 string
 		result2 error
 	}
-	ImportCCKeysStub        func() ([]byte, error)
+	GetEnclaveInfoStub        func([]byte) ([]byte, error)
+	getEnclaveInfoMutex       sync.RWMutex
+	getEnclaveInfoArgsForCall []struct {
+		arg1 []byte
+	}
+	getEnclaveInfoReturns struct {
+		result1 []byte
+		result2 error
+	}
+	getEnclaveInfoReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
+	ImportCCKeysStub        func(string) ([]byte, error)
 	importCCKeysMutex       sync.RWMutex
 	importCCKeysArgsForCall []struct {
+		arg1 string
 	}
 	importCCKeysReturns struct {
 		result1 []byte
@@ -160,7 +172,7 @@ func (fake *EnclaveStub) ChaincodeInvokeReturnsOnCall(i int, result1 []byte, res
 	}{result1, result2}
 }
 
-func (fake *EnclaveStub) ExportCCKeys(arg1 []byte) ([]byte, error) {
+func (fake *EnclaveStub) ExportCCKeys(arg1 []byte) error {
 	var arg1Copy []byte
 	if arg1 != nil {
 		arg1Copy = make([]byte, len(arg1))
@@ -179,9 +191,9 @@ func (fake *EnclaveStub) ExportCCKeys(arg1 []byte) ([]byte, error) {
 		return stub(arg1)
 	}
 	if specificReturn {
-		return ret.result1, ret.result2
+		return ret.result1
 	}
-	return fakeReturns.result1, fakeReturns.result2
+	return fakeReturns.result1
 }
 
 func (fake *EnclaveStub) ExportCCKeysCallCount() int {
@@ -190,7 +202,7 @@ func (fake *EnclaveStub) ExportCCKeysCallCount() int {
 	return len(fake.exportCCKeysArgsForCall)
 }
 
-func (fake *EnclaveStub) ExportCCKeysCalls(stub func([]byte) ([]byte, error)) {
+func (fake *EnclaveStub) ExportCCKeysCalls(stub func([]byte) error) {
 	fake.exportCCKeysMutex.Lock()
 	defer fake.exportCCKeysMutex.Unlock()
 	fake.ExportCCKeysStub = stub
@@ -203,30 +215,27 @@ func (fake *EnclaveStub) ExportCCKeysArgsForCall(i int) []byte {
 	return argsForCall.arg1
 }
 
-func (fake *EnclaveStub) ExportCCKeysReturns(result1 []byte, result2 error) {
+func (fake *EnclaveStub) ExportCCKeysReturns(result1 error) {
 	fake.exportCCKeysMutex.Lock()
 	defer fake.exportCCKeysMutex.Unlock()
 	fake.ExportCCKeysStub = nil
 	fake.exportCCKeysReturns = struct {
-		result1 []byte
-		result2 error
-	}{result1, result2}
+		result1 error
+	}{result1}
 }
 
-func (fake *EnclaveStub) ExportCCKeysReturnsOnCall(i int, result1 []byte, result2 error) {
+func (fake *EnclaveStub) ExportCCKeysReturnsOnCall(i int, result1 error) {
 	fake.exportCCKeysMutex.Lock()
 	defer fake.exportCCKeysMutex.Unlock()
 	fake.ExportCCKeysStub = nil
 	if fake.exportCCKeysReturnsOnCall == nil {
 		fake.exportCCKeysReturnsOnCall = make(map[int]struct {
-			result1 []byte
-			result2 error
+			result1 error
 		})
 	}
 	fake.exportCCKeysReturnsOnCall[i] = struct {
-		result1 []byte
-		result2 error
-	}{result1, result2}
+		result1 error
+	}{result1}
 }
 
 func (fake *EnclaveStub) GenerateCCKeys() ([]byte, error) {
@@ -341,17 +350,87 @@ func (fake *EnclaveStub) GetEnclaveIdReturnsOnCall(i int, result1 string, result
 	}{result1, result2}
 }
 
-func (fake *EnclaveStub) ImportCCKeys() ([]byte, error) {
+func (fake *EnclaveStub) GetEnclaveInfo(arg1 []byte) ([]byte, error) {
+	var arg1Copy []byte
+	if arg1 != nil {
+		arg1Copy = make([]byte, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.getEnclaveInfoMutex.Lock()
+	ret, specificReturn := fake.getEnclaveInfoReturnsOnCall[len(fake.getEnclaveInfoArgsForCall)]
+	fake.getEnclaveInfoArgsForCall = append(fake.getEnclaveInfoArgsForCall, struct {
+		arg1 []byte
+	}{arg1Copy})
+	stub := fake.GetEnclaveInfoStub
+	fakeReturns := fake.getEnclaveInfoReturns
+	fake.recordInvocation("GetEnclaveInfo", []interface{}{arg1Copy})
+	fake.getEnclaveInfoMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *EnclaveStub) GetEnclaveInfoCallCount() int {
+	fake.getEnclaveInfoMutex.RLock()
+	defer fake.getEnclaveInfoMutex.RUnlock()
+	return len(fake.getEnclaveInfoArgsForCall)
+}
+
+func (fake *EnclaveStub) GetEnclaveInfoCalls(stub func([]byte) ([]byte, error)) {
+	fake.getEnclaveInfoMutex.Lock()
+	defer fake.getEnclaveInfoMutex.Unlock()
+	fake.GetEnclaveInfoStub = stub
+}
+
+func (fake *EnclaveStub) GetEnclaveInfoArgsForCall(i int) []byte {
+	fake.getEnclaveInfoMutex.RLock()
+	defer fake.getEnclaveInfoMutex.RUnlock()
+	argsForCall := fake.getEnclaveInfoArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *EnclaveStub) GetEnclaveInfoReturns(result1 []byte, result2 error) {
+	fake.getEnclaveInfoMutex.Lock()
+	defer fake.getEnclaveInfoMutex.Unlock()
+	fake.GetEnclaveInfoStub = nil
+	fake.getEnclaveInfoReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *EnclaveStub) GetEnclaveInfoReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.getEnclaveInfoMutex.Lock()
+	defer fake.getEnclaveInfoMutex.Unlock()
+	fake.GetEnclaveInfoStub = nil
+	if fake.getEnclaveInfoReturnsOnCall == nil {
+		fake.getEnclaveInfoReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.getEnclaveInfoReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *EnclaveStub) ImportCCKeys(arg1 string) ([]byte, error) {
 	fake.importCCKeysMutex.Lock()
 	ret, specificReturn := fake.importCCKeysReturnsOnCall[len(fake.importCCKeysArgsForCall)]
 	fake.importCCKeysArgsForCall = append(fake.importCCKeysArgsForCall, struct {
-	}{})
+		arg1 string
+	}{arg1})
 	stub := fake.ImportCCKeysStub
 	fakeReturns := fake.importCCKeysReturns
-	fake.recordInvocation("ImportCCKeys", []interface{}{})
+	fake.recordInvocation("ImportCCKeys", []interface{}{arg1})
 	fake.importCCKeysMutex.Unlock()
 	if stub != nil {
-		return stub()
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -365,12 +444,19 @@ func (fake *EnclaveStub) ImportCCKeysCallCount() int {
 	return len(fake.importCCKeysArgsForCall)
 }
 
-func (fake *EnclaveStub) ImportCCKeysCalls(stub func() ([]byte, error)) {
+func (fake *EnclaveStub) ImportCCKeysCalls(stub func(string) ([]byte, error)) {
 	fake.importCCKeysMutex.Lock()
 	defer fake.importCCKeysMutex.Unlock()
 	fake.ImportCCKeysStub = stub
 }
 
+func (fake *EnclaveStub) ImportCCKeysArgsForCall(i int) string {
+	fake.importCCKeysMutex.RLock()
+	defer fake.importCCKeysMutex.RUnlock()
+	argsForCall := fake.importCCKeysArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *EnclaveStub) ImportCCKeysReturns(result1 []byte, result2 error) {
 	fake.importCCKeysMutex.Lock()
 	defer fake.importCCKeysMutex.Unlock()
@@ -489,6 +575,8 @@ func (fake *EnclaveStub) Invocations() map[string][][]interface{} {
 	defer fake.generateCCKeysMutex.RUnlock()
 	fake.getEnclaveIdMutex.RLock()
 	defer fake.getEnclaveIdMutex.RUnlock()
+	fake.getEnclaveInfoMutex.RLock()
+	defer fake.getEnclaveInfoMutex.RUnlock()
 	fake.importCCKeysMutex.RLock()
 	defer fake.importCCKeysMutex.RUnlock()
 	fake.initMutex.RLock()
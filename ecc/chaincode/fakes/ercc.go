@@ -25,6 +25,37 @@ type ErccStub struct {
 		result1 *protos.Credentials
 		result2 error
 	}
+	QueryPreviousEnclaveIdStub        func(shim.ChaincodeStubInterface, string, string, string) (string, error)
+	queryPreviousEnclaveIdMutex       sync.RWMutex
+	queryPreviousEnclaveIdArgsForCall []struct {
+		arg1 shim.ChaincodeStubInterface
+		arg2 string
+		arg3 string
+		arg4 string
+	}
+	queryPreviousEnclaveIdReturns struct {
+		result1 string
+		result2 error
+	}
+	queryPreviousEnclaveIdReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	QueryUpgradePolicyStub        func(shim.ChaincodeStubInterface, string, string) ([]byte, error)
+	queryUpgradePolicyMutex       sync.RWMutex
+	queryUpgradePolicyArgsForCall []struct {
+		arg1 shim.ChaincodeStubInterface
+		arg2 string
+		arg3 string
+	}
+	queryUpgradePolicyReturns struct {
+		result1 []byte
+		result2 error
+	}
+	queryUpgradePolicyReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -96,11 +127,148 @@ func (fake *ErccStub) QueryEnclaveCredentialsReturnsOnCall(i int, result1 *proto
 	}{result1, result2}
 }
 
+func (fake *ErccStub) QueryPreviousEnclaveId(arg1 shim.ChaincodeStubInterface, arg2 string, arg3 string, arg4 string) (string, error) {
+	fake.queryPreviousEnclaveIdMutex.Lock()
+	ret, specificReturn := fake.queryPreviousEnclaveIdReturnsOnCall[len(fake.queryPreviousEnclaveIdArgsForCall)]
+	fake.queryPreviousEnclaveIdArgsForCall = append(fake.queryPreviousEnclaveIdArgsForCall, struct {
+		arg1 shim.ChaincodeStubInterface
+		arg2 string
+		arg3 string
+		arg4 string
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.QueryPreviousEnclaveIdStub
+	fakeReturns := fake.queryPreviousEnclaveIdReturns
+	fake.recordInvocation("QueryPreviousEnclaveId", []interface{}{arg1, arg2, arg3, arg4})
+	fake.queryPreviousEnclaveIdMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *ErccStub) QueryPreviousEnclaveIdCallCount() int {
+	fake.queryPreviousEnclaveIdMutex.RLock()
+	defer fake.queryPreviousEnclaveIdMutex.RUnlock()
+	return len(fake.queryPreviousEnclaveIdArgsForCall)
+}
+
+func (fake *ErccStub) QueryPreviousEnclaveIdCalls(stub func(shim.ChaincodeStubInterface, string, string, string) (string, error)) {
+	fake.queryPreviousEnclaveIdMutex.Lock()
+	defer fake.queryPreviousEnclaveIdMutex.Unlock()
+	fake.QueryPreviousEnclaveIdStub = stub
+}
+
+func (fake *ErccStub) QueryPreviousEnclaveIdArgsForCall(i int) (shim.ChaincodeStubInterface, string, string, string) {
+	fake.queryPreviousEnclaveIdMutex.RLock()
+	defer fake.queryPreviousEnclaveIdMutex.RUnlock()
+	argsForCall := fake.queryPreviousEnclaveIdArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *ErccStub) QueryPreviousEnclaveIdReturns(result1 string, result2 error) {
+	fake.queryPreviousEnclaveIdMutex.Lock()
+	defer fake.queryPreviousEnclaveIdMutex.Unlock()
+	fake.QueryPreviousEnclaveIdStub = nil
+	fake.queryPreviousEnclaveIdReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ErccStub) QueryPreviousEnclaveIdReturnsOnCall(i int, result1 string, result2 error) {
+	fake.queryPreviousEnclaveIdMutex.Lock()
+	defer fake.queryPreviousEnclaveIdMutex.Unlock()
+	fake.QueryPreviousEnclaveIdStub = nil
+	if fake.queryPreviousEnclaveIdReturnsOnCall == nil {
+		fake.queryPreviousEnclaveIdReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.queryPreviousEnclaveIdReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ErccStub) QueryUpgradePolicy(arg1 shim.ChaincodeStubInterface, arg2 string, arg3 string) ([]byte, error) {
+	fake.queryUpgradePolicyMutex.Lock()
+	ret, specificReturn := fake.queryUpgradePolicyReturnsOnCall[len(fake.queryUpgradePolicyArgsForCall)]
+	fake.queryUpgradePolicyArgsForCall = append(fake.queryUpgradePolicyArgsForCall, struct {
+		arg1 shim.ChaincodeStubInterface
+		arg2 string
+		arg3 string
+	}{arg1, arg2, arg3})
+	stub := fake.QueryUpgradePolicyStub
+	fakeReturns := fake.queryUpgradePolicyReturns
+	fake.recordInvocation("QueryUpgradePolicy", []interface{}{arg1, arg2, arg3})
+	fake.queryUpgradePolicyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *ErccStub) QueryUpgradePolicyCallCount() int {
+	fake.queryUpgradePolicyMutex.RLock()
+	defer fake.queryUpgradePolicyMutex.RUnlock()
+	return len(fake.queryUpgradePolicyArgsForCall)
+}
+
+func (fake *ErccStub) QueryUpgradePolicyCalls(stub func(shim.ChaincodeStubInterface, string, string) ([]byte, error)) {
+	fake.queryUpgradePolicyMutex.Lock()
+	defer fake.queryUpgradePolicyMutex.Unlock()
+	fake.QueryUpgradePolicyStub = stub
+}
+
+func (fake *ErccStub) QueryUpgradePolicyArgsForCall(i int) (shim.ChaincodeStubInterface, string, string) {
+	fake.queryUpgradePolicyMutex.RLock()
+	defer fake.queryUpgradePolicyMutex.RUnlock()
+	argsForCall := fake.queryUpgradePolicyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *ErccStub) QueryUpgradePolicyReturns(result1 []byte, result2 error) {
+	fake.queryUpgradePolicyMutex.Lock()
+	defer fake.queryUpgradePolicyMutex.Unlock()
+	fake.QueryUpgradePolicyStub = nil
+	fake.queryUpgradePolicyReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ErccStub) QueryUpgradePolicyReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.queryUpgradePolicyMutex.Lock()
+	defer fake.queryUpgradePolicyMutex.Unlock()
+	fake.QueryUpgradePolicyStub = nil
+	if fake.queryUpgradePolicyReturnsOnCall == nil {
+		fake.queryUpgradePolicyReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.queryUpgradePolicyReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *ErccStub) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.queryEnclaveCredentialsMutex.RLock()
 	defer fake.queryEnclaveCredentialsMutex.RUnlock()
+	fake.queryPreviousEnclaveIdMutex.RLock()
+	defer fake.queryPreviousEnclaveIdMutex.RUnlock()
+	fake.queryUpgradePolicyMutex.RLock()
+	defer fake.queryUpgradePolicyMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
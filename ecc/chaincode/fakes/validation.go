@@ -33,6 +33,18 @@ type Validator struct {
 	validateReturnsOnCall map[int]struct {
 		result1 error
 	}
+	ValidateProposalStub        func(shim.ChaincodeStubInterface, *protos.ChaincodeResponseMessage) error
+	validateProposalMutex       sync.RWMutex
+	validateProposalArgsForCall []struct {
+		arg1 shim.ChaincodeStubInterface
+		arg2 *protos.ChaincodeResponseMessage
+	}
+	validateProposalReturns struct {
+		result1 error
+	}
+	validateProposalReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -161,6 +173,68 @@ func (fake *Validator) ValidateReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *Validator) ValidateProposal(arg1 shim.ChaincodeStubInterface, arg2 *protos.ChaincodeResponseMessage) error {
+	fake.validateProposalMutex.Lock()
+	ret, specificReturn := fake.validateProposalReturnsOnCall[len(fake.validateProposalArgsForCall)]
+	fake.validateProposalArgsForCall = append(fake.validateProposalArgsForCall, struct {
+		arg1 shim.ChaincodeStubInterface
+		arg2 *protos.ChaincodeResponseMessage
+	}{arg1, arg2})
+	stub := fake.ValidateProposalStub
+	fakeReturns := fake.validateProposalReturns
+	fake.recordInvocation("ValidateProposal", []interface{}{arg1, arg2})
+	fake.validateProposalMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Validator) ValidateProposalCallCount() int {
+	fake.validateProposalMutex.RLock()
+	defer fake.validateProposalMutex.RUnlock()
+	return len(fake.validateProposalArgsForCall)
+}
+
+func (fake *Validator) ValidateProposalCalls(stub func(shim.ChaincodeStubInterface, *protos.ChaincodeResponseMessage) error) {
+	fake.validateProposalMutex.Lock()
+	defer fake.validateProposalMutex.Unlock()
+	fake.ValidateProposalStub = stub
+}
+
+func (fake *Validator) ValidateProposalArgsForCall(i int) (shim.ChaincodeStubInterface, *protos.ChaincodeResponseMessage) {
+	fake.validateProposalMutex.RLock()
+	defer fake.validateProposalMutex.RUnlock()
+	argsForCall := fake.validateProposalArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *Validator) ValidateProposalReturns(result1 error) {
+	fake.validateProposalMutex.Lock()
+	defer fake.validateProposalMutex.Unlock()
+	fake.ValidateProposalStub = nil
+	fake.validateProposalReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Validator) ValidateProposalReturnsOnCall(i int, result1 error) {
+	fake.validateProposalMutex.Lock()
+	defer fake.validateProposalMutex.Unlock()
+	fake.ValidateProposalStub = nil
+	if fake.validateProposalReturnsOnCall == nil {
+		fake.validateProposalReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.validateProposalReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *Validator) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -168,6 +242,8 @@ func (fake *Validator) Invocations() map[string][][]interface{} {
 	defer fake.replayReadWritesMutex.RUnlock()
 	fake.validateMutex.RLock()
 	defer fake.validateMutex.RUnlock()
+	fake.validateProposalMutex.RLock()
+	defer fake.validateProposalMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
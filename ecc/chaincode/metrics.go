@@ -0,0 +1,119 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric/common/metrics"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	invocationDuration = metrics.HistogramOpts{
+		Namespace:    "fpc",
+		Subsystem:    "ecc",
+		Name:         "invocation_duration",
+		Help:         "The time to process a chaincode invocation inside the enclave in seconds.",
+		LabelNames:   []string{"channel", "function", "status"},
+		StatsdFormat: "%{#fqname}.%{channel}.%{function}.%{status}",
+	}
+	stateReads = metrics.CounterOpts{
+		Namespace:    "fpc",
+		Subsystem:    "ecc",
+		Name:         "state_reads",
+		Help:         "The number of keys read by chaincode invocations.",
+		LabelNames:   []string{"channel", "function"},
+		StatsdFormat: "%{#fqname}.%{channel}.%{function}",
+	}
+	stateWrites = metrics.CounterOpts{
+		Namespace:    "fpc",
+		Subsystem:    "ecc",
+		Name:         "state_writes",
+		Help:         "The number of keys written by chaincode invocations.",
+		LabelNames:   []string{"channel", "function"},
+		StatsdFormat: "%{#fqname}.%{channel}.%{function}",
+	}
+	encryptedBytes = metrics.CounterOpts{
+		Namespace:    "fpc",
+		Subsystem:    "ecc",
+		Name:         "encrypted_bytes",
+		Help:         "The number of bytes encrypted by chaincode invocations.",
+		LabelNames:   []string{"channel", "function"},
+		StatsdFormat: "%{#fqname}.%{channel}.%{function}",
+	}
+	decryptedBytes = metrics.CounterOpts{
+		Namespace:    "fpc",
+		Subsystem:    "ecc",
+		Name:         "decrypted_bytes",
+		Help:         "The number of bytes decrypted by chaincode invocations.",
+		LabelNames:   []string{"channel", "function"},
+		StatsdFormat: "%{#fqname}.%{channel}.%{function}",
+	}
+)
+
+// Metrics exports the invocation metrics reported by the enclave, e.g., through the fabric operations system
+type Metrics struct {
+	InvocationDuration metrics.Histogram
+	StateReads         metrics.Counter
+	StateWrites        metrics.Counter
+	EncryptedBytes     metrics.Counter
+	DecryptedBytes     metrics.Counter
+}
+
+func NewMetrics(p metrics.Provider) *Metrics {
+	return &Metrics{
+		InvocationDuration: p.NewHistogram(invocationDuration),
+		StateReads:         p.NewCounter(stateReads),
+		StateWrites:        p.NewCounter(stateWrites),
+		EncryptedBytes:     p.NewCounter(encryptedBytes),
+		DecryptedBytes:     p.NewCounter(decryptedBytes),
+	}
+}
+
+// takeMetrics removes the metrics attached to a signed chaincode response, if any, and records them if ECC exports
+// metrics. As the metrics are not covered by the signature of the enclave, the response remains valid and the metrics
+// are never committed. Note that the metrics are not verified as they are for monitoring only.
+// Responses that cannot be parsed are returned as they are, i.e., the client rejects them.
+func (t *EnclaveChaincode) takeMetrics(channelID string, signedResponseBytes []byte) []byte {
+	signedResponse, err := utils.UnmarshalSignedChaincodeResponseMessage(signedResponseBytes)
+	if err != nil {
+		logger.Debugf("cannot extract metrics: %s", err)
+		return signedResponseBytes
+	}
+
+	invocationMetrics := signedResponse.GetMetrics()
+	if invocationMetrics == nil {
+		return signedResponseBytes
+	}
+
+	if t.Metrics != nil {
+		if response, err := utils.UnmarshalChaincodeResponseMessage(signedResponse.GetChaincodeResponseMessage()); err != nil {
+			logger.Debugf("cannot extract metrics: %s", err)
+		} else {
+			t.Metrics.record(channelID, response.GetErrorCode(), invocationMetrics)
+		}
+	}
+
+	signedResponse.Metrics = nil
+	strippedResponseBytes, err := proto.Marshal(signedResponse)
+	if err != nil {
+		logger.Debugf("cannot remove metrics: %s", err)
+		return signedResponseBytes
+	}
+	return strippedResponseBytes
+}
+
+func (m *Metrics) record(channelID string, code protos.ErrorCode, im *protos.InvocationMetrics) {
+	labels := []string{"channel", channelID, "function", im.GetFunction()}
+
+	m.InvocationDuration.With(append(labels, "status", code.String())...).Observe(float64(im.GetDurationMicros()) / 1e6)
+	m.StateReads.With(labels...).Add(float64(im.GetReads()))
+	m.StateWrites.With(labels...).Add(float64(im.GetWrites()))
+	m.EncryptedBytes.With(labels...).Add(float64(im.GetBytesEncrypted()))
+	m.DecryptedBytes.With(labels...).Add(float64(im.GetBytesDecrypted()))
+}
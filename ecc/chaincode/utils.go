@@ -65,22 +65,7 @@ func (s *ExtractorImpl) GetChaincodeResponseMessages(stub shim.ChaincodeStubInte
 		return nil, nil, fmt.Errorf("initEnclaveMessage missing")
 	}
 
-	serializedSignedChaincodeResponseMessage, err := base64.StdEncoding.DecodeString(stub.GetStringArgs()[1])
-	if err != nil {
-		return nil, nil, err
-	}
-
-	signedResponseMsg, err := utils.UnmarshalSignedChaincodeResponseMessage(serializedSignedChaincodeResponseMessage)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	responseMsg, err := utils.UnmarshalChaincodeResponseMessage(signedResponseMsg.GetChaincodeResponseMessage())
-	if err != nil {
-		return nil, nil, err
-	}
-
-	return signedResponseMsg, responseMsg, err
+	return utils.UnmarshalChaincodeResponseMessages(stub.GetStringArgs()[1])
 }
 
 func (s *ExtractorImpl) GetChaincodeParams(stub shim.ChaincodeStubInterface) (*protos.CCParameters, error) {
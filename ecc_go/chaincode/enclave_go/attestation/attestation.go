@@ -7,13 +7,13 @@ SPDX-License-Identifier: Apache-2.0
 package attestation
 
 import (
-	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/simulation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
 	"github.com/pkg/errors"
 	"google.golang.org/protobuf/types/known/anypb"
 )
 
-func Issue(attestedData *anypb.Any) ([]byte, error) {
-	issuer := simulation.NewSimulationIssuer()
+// Issue creates an attestation over the serialized attested data using the given issuer
+func Issue(issuer *types.Issuer, attestedData *anypb.Any) ([]byte, error) {
 	att, err := issuer.Issue(attestedData.Value)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot get attestation")
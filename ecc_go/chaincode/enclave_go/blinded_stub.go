@@ -0,0 +1,33 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// NewBlindedStub returns an enclave which blinds the keys of the chaincode state (see BlindedStubInterface)
+func NewBlindedStub(cc shim.Chaincode) *EnclaveStub {
+	enclaveStub := NewEnclaveStub(cc)
+	enclaveStub.EnableKeyBlinding()
+	return enclaveStub
+}
+
+// EnableKeyBlinding lets the enclave blind the keys of the chaincode state (see BlindedStubInterface)
+func (e *EnclaveStub) EnableKeyBlinding() {
+	e.stubProvider = func(stub shim.ChaincodeStubInterface, input *pb.ChaincodeInput, rwset *readWriteSet, sep StateEncryptionFunctions) shim.ChaincodeStubInterface {
+		// the blinding key is derived on every invocation as the state key may be imported after initialization
+		blindingKey, err := e.ccKeys.KeyBlindingKey()
+		if err != nil {
+			panic(fmt.Sprintf("Deriving key blinding key failed, err: %v", err))
+		}
+		return NewBlindedStubInterface(stub, input, rwset, sep, e.csp, blindingKey)
+	}
+}
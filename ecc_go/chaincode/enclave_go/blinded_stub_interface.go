@@ -0,0 +1,191 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/pkg/errors"
+)
+
+// BlindedStubInterface hides the keys of the chaincode state from the peer. A ledger key is the hex encoded
+// HMAC-SHA256 of the key under the key blinding key of the chaincode, computed with the CSP. Composite keys are blinded per attribute, where an
+// attribute is bound to the object type and its position, thus, the ledger key is again a composite key and partial
+// composite key queries keep working. As HMACs cannot be inverted, the key is stored along with the encrypted value.
+//
+// Note that the peer still learns which transactions access the same keys and, for composite keys, which keys share
+// an object type or attribute prefix. Range queries are not supported as blinding does not preserve the key order.
+type BlindedStubInterface struct {
+	*FpcStubInterface
+	csp         crypto.CSP
+	blindingKey []byte
+}
+
+// NewBlindedStubInterface returns a stub which blinds all state keys with the given key
+func NewBlindedStubInterface(stub shim.ChaincodeStubInterface, input *pb.ChaincodeInput, rwset *readWriteSet, sep StateEncryptionFunctions, csp crypto.CSP, blindingKey []byte) *BlindedStubInterface {
+	return &BlindedStubInterface{
+		FpcStubInterface: NewFpcStubInterface(stub, input, rwset, sep),
+		csp:              csp,
+		blindingKey:      blindingKey,
+	}
+}
+
+func (b *BlindedStubInterface) GetState(key string) ([]byte, error) {
+	ledgerKey, err := b.blindKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	encValue, err := b.GetPublicState(ledgerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// in case the key does not exist, return early
+	if len(encValue) == 0 {
+		return nil, nil
+	}
+
+	storedKey, value, err := b.openEntry(ledgerKey, encValue)
+	if err != nil {
+		return nil, err
+	}
+
+	// the value is bound to the ledger key, which identifies the key only by its HMAC, thus, we check the stored key as well
+	if storedKey != key {
+		return nil, fmt.Errorf("state of key %s is bound to a different key", key)
+	}
+
+	return value, nil
+}
+
+func (b *BlindedStubInterface) PutState(key string, value []byte) error {
+	ledgerKey, err := b.blindKey(key)
+	if err != nil {
+		return err
+	}
+
+	encValue, err := b.sep.EncryptState(ledgerKey, encodeEntry(key, value))
+	if err != nil {
+		return err
+	}
+	return b.PutPublicState(ledgerKey, encValue)
+}
+
+func (b *BlindedStubInterface) DelState(key string) error {
+	ledgerKey, err := b.blindKey(key)
+	if err != nil {
+		return err
+	}
+	return b.FpcStubInterface.DelState(ledgerKey)
+}
+
+func (b *BlindedStubInterface) GetStateByRange(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+	return nil, fmt.Errorf("range queries are not supported with blinded keys")
+}
+
+func (b *BlindedStubInterface) GetStateByRangeWithPagination(startKey string, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	return nil, nil, fmt.Errorf("range queries are not supported with blinded keys")
+}
+
+func (b *BlindedStubInterface) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	blindedObjectType, attributes, err := b.blindComposite(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := b.stub.GetStateByPartialCompositeKey(blindedObjectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFpcIterator(iterator, b.rwset.AddRead, b.openEntry), nil
+}
+
+// blindKey returns the ledger key of a key; the components of a composite key are blinded separately
+func (b *BlindedStubInterface) blindKey(key string) (string, error) {
+	if !utils.IsFPCCompositeKey(key) {
+		return b.blind([]byte(key))
+	}
+
+	comp := utils.SplitFPCCompositeKey(key)
+	blindedObjectType, attributes, err := b.blindComposite(comp[0], comp[1:])
+	if err != nil {
+		return "", err
+	}
+	return b.CreateCompositeKey(blindedObjectType, attributes)
+}
+
+// blindComposite blinds the object type and the attributes of a (partial) composite key
+func (b *BlindedStubInterface) blindComposite(objectType string, attributes []string) (string, []string, error) {
+	blindedObjectType, err := b.blindAttribute(objectType, 0, objectType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	blindedAttributes := make([]string, len(attributes))
+	for i, attr := range attributes {
+		blindedAttributes[i], err = b.blindAttribute(objectType, i+1, attr)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return blindedObjectType, blindedAttributes, nil
+}
+
+// blindAttribute blinds the attribute at the given position of a composite key, where position 0 is the object type
+func (b *BlindedStubInterface) blindAttribute(objectType string, position int, attr string) (string, error) {
+	data := []byte(objectType)
+	data = append(data, 0)
+	data = strconv.AppendInt(data, int64(position), 10)
+	data = append(data, 0)
+	data = append(data, attr...)
+	return b.blind(data)
+}
+
+func (b *BlindedStubInterface) blind(data []byte) (string, error) {
+	if len(b.blindingKey) == 0 {
+		return "", fmt.Errorf("key blinding key is missing")
+	}
+	mac, err := b.csp.HMAC(protos.HashAlgorithm_HASH_ALGORITHM_SHA256, b.blindingKey, data)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot blind key")
+	}
+	return hex.EncodeToString(mac), nil
+}
+
+func (b *BlindedStubInterface) openEntry(ledgerKey string, ciphertext []byte) (string, []byte, error) {
+	plaintext, err := b.sep.DecryptState(ledgerKey, ciphertext)
+	if err != nil {
+		return "", nil, err
+	}
+	return decodeEntry(plaintext)
+}
+
+// encodeEntry prepends the length of the key and the key to the value
+func encodeEntry(key string, value []byte) []byte {
+	entry := binary.AppendUvarint(nil, uint64(len(key)))
+	entry = append(entry, key...)
+	return append(entry, value...)
+}
+
+func decodeEntry(entry []byte) (string, []byte, error) {
+	keyLen, n := binary.Uvarint(entry)
+	if n <= 0 || keyLen > uint64(len(entry)-n) {
+		return "", nil, errors.New("invalid blinded state entry")
+	}
+	entry = entry[n:]
+	return string(entry[:keyLen]), entry[keyLen:], nil
+}
@@ -0,0 +1,240 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBlindedStub(t *testing.T, ledger testLedger, keys *ChaincodeKeys, rwset *readWriteSet) *BlindedStubInterface {
+	blindingKey, err := keys.KeyBlindingKey()
+	require.NoError(t, err)
+	return NewBlindedStubInterface(newTestStub(ledger), &pb.ChaincodeInput{}, rwset, keys, keys.csp, blindingKey)
+}
+
+func blindAttribute(t *testing.T, b *BlindedStubInterface, objectType string, position int, attr string) string {
+	blinded, err := b.blindAttribute(objectType, position, attr)
+	require.NoError(t, err)
+	return blinded
+}
+
+func TestBlindKey(t *testing.T) {
+	keys := newTestKeys(t)
+	b := newTestBlindedStub(t, testLedger{}, keys, newTestRwSet())
+
+	ledgerKey, err := b.blindKey("alice")
+	require.NoError(t, err)
+	mac, err := hex.DecodeString(ledgerKey)
+	require.NoError(t, err)
+	assert.Len(t, mac, 32)
+	blindingKey, err := keys.KeyBlindingKey()
+	require.NoError(t, err)
+	expectedMac, err := keys.csp.HMAC(protos.HashAlgorithm_HASH_ALGORITHM_SHA256, blindingKey, []byte("alice"))
+	require.NoError(t, err)
+	assert.Equal(t, expectedMac, mac)
+
+	// blinding is deterministic but depends on the key and the blinding key
+	sameLedgerKey, err := b.blindKey("alice")
+	require.NoError(t, err)
+	assert.Equal(t, ledgerKey, sameLedgerKey)
+
+	otherLedgerKey, err := b.blindKey("bob")
+	require.NoError(t, err)
+	assert.NotEqual(t, ledgerKey, otherLedgerKey)
+
+	otherStub := newTestBlindedStub(t, testLedger{}, newTestKeys(t), newTestRwSet())
+	otherLedgerKey, err = otherStub.blindKey("alice")
+	require.NoError(t, err)
+	assert.NotEqual(t, ledgerKey, otherLedgerKey)
+
+	// composite keys remain composite keys with blinded components
+	compositeKey, err := b.CreateCompositeKey("wallet", []string{"alice", "eur"})
+	require.NoError(t, err)
+	blindedCompositeKey, err := b.blindKey(compositeKey)
+	require.NoError(t, err)
+	require.True(t, utils.IsFPCCompositeKey(blindedCompositeKey))
+	comp := utils.SplitFPCCompositeKey(blindedCompositeKey)
+	require.Len(t, comp, 3)
+	assert.Equal(t, blindAttribute(t, b, "wallet", 0, "wallet"), comp[0])
+	assert.Equal(t, blindAttribute(t, b, "wallet", 1, "alice"), comp[1])
+	assert.Equal(t, blindAttribute(t, b, "wallet", 2, "eur"), comp[2])
+	assert.NotContains(t, blindedCompositeKey, "wallet")
+	assert.NotContains(t, blindedCompositeKey, "alice")
+
+	// partial composite key queries use the same blinding
+	blindedObjectType, attributes, err := b.blindComposite("wallet", []string{"alice"})
+	require.NoError(t, err)
+	assert.Equal(t, comp[0], blindedObjectType)
+	assert.Equal(t, comp[1:2], attributes)
+
+	// a stub without blinding key refuses to access the state
+	noKey := NewBlindedStubInterface(newTestStub(testLedger{}), &pb.ChaincodeInput{}, newTestRwSet(), keys, keys.csp, nil)
+	_, err = noKey.GetState("alice")
+	assert.EqualError(t, err, "key blinding key is missing")
+
+	// attributes are bound to the object type and their position
+	assert.NotEqual(t, blindAttribute(t, b, "wallet", 1, "alice"), blindAttribute(t, b, "wallet", 2, "alice"))
+	assert.NotEqual(t, blindAttribute(t, b, "wallet", 1, "alice"), blindAttribute(t, b, "account", 1, "alice"))
+}
+
+func TestBlindedEntry(t *testing.T) {
+	for _, key := range []string{"", "alice", string(make([]byte, 200))} {
+		entry := encodeEntry(key, []byte("value"))
+		decodedKey, value, err := decodeEntry(entry)
+		require.NoError(t, err)
+		assert.Equal(t, key, decodedKey)
+		assert.Equal(t, []byte("value"), value)
+	}
+
+	// empty values
+	decodedKey, value, err := decodeEntry(encodeEntry("alice", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", decodedKey)
+	assert.Empty(t, value)
+
+	// malformed entries
+	for _, entry := range [][]byte{
+		nil,
+		{0x80},             // truncated length
+		{0x06, 'a', 'b'},   // length exceeds the entry
+		{0xff, 0xff, 0xff}, // truncated length
+	} {
+		_, _, err := decodeEntry(entry)
+		assert.EqualError(t, err, "invalid blinded state entry", "entry %x", entry)
+	}
+}
+
+func TestBlindedState(t *testing.T) {
+	ledger := testLedger{}
+	keys := newTestKeys(t)
+
+	rwset := newTestRwSet()
+	b := newTestBlindedStub(t, ledger, keys, rwset)
+	require.NoError(t, b.PutState("alice", []byte("100")))
+	require.NoError(t, b.PutState("bob", []byte("50")))
+
+	// only blinded keys are written
+	aliceKey, err := b.blindKey("alice")
+	require.NoError(t, err)
+	bobKey, err := b.blindKey("bob")
+	require.NoError(t, err)
+	_, writes := rwsetKeys(rwset)
+	assert.ElementsMatch(t, []string{aliceKey, bobKey}, writes)
+	ledger.commit(t, rwset)
+
+	rwset = newTestRwSet()
+	b = newTestBlindedStub(t, ledger, keys, rwset)
+	value, err := b.GetState("alice")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("100"), value)
+	reads, _ := rwsetKeys(rwset)
+	assert.Equal(t, []string{aliceKey}, reads)
+
+	value, err = b.GetState("carol")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	// the stored entry of a key opens under its ledger key only
+	storedKey, value, err := b.openEntry(aliceKey, ledger[aliceKey])
+	require.NoError(t, err)
+	assert.Equal(t, "alice", storedKey)
+	assert.Equal(t, []byte("100"), value)
+	_, _, err = b.openEntry(bobKey, ledger[aliceKey])
+	assert.Error(t, err)
+
+	// the peer cannot move an entry to another ledger key
+	ledger[bobKey] = ledger[aliceKey]
+	_, err = b.GetState("bob")
+	assert.Error(t, err)
+
+	// an entry bound to the ledger key of a different key is rejected by the stored key check
+	ciphertext, err := keys.EncryptState(bobKey, encodeEntry("alice", []byte("100")))
+	require.NoError(t, err)
+	ledger[bobKey] = ciphertext
+	_, err = b.GetState("bob")
+	assert.EqualError(t, err, "state of key bob is bound to a different key")
+
+	rwset = newTestRwSet()
+	b = newTestBlindedStub(t, ledger, keys, rwset)
+	require.NoError(t, b.DelState("alice"))
+	ledger.commit(t, rwset)
+	_, ok := ledger[aliceKey]
+	assert.False(t, ok)
+
+	_, err = b.GetStateByRange("a", "z")
+	assert.Error(t, err)
+}
+
+func TestBlindedPartialCompositeKeyQuery(t *testing.T) {
+	ledger := testLedger{}
+	keys := newTestKeys(t)
+
+	rwset := newTestRwSet()
+	b := newTestBlindedStub(t, ledger, keys, rwset)
+	entries := map[string][]string{
+		"alice eur": {"wallet", "alice", "eur"},
+		"alice usd": {"wallet", "alice", "usd"},
+		"bob eur":   {"wallet", "bob", "eur"},
+		"account":   {"account", "alice"},
+	}
+	compositeKeys := make(map[string]string)
+	for value, comp := range entries {
+		key, err := b.CreateCompositeKey(comp[0], comp[1:])
+		require.NoError(t, err)
+		compositeKeys[value] = key
+		require.NoError(t, b.PutState(key, []byte(value)))
+	}
+	ledger.commit(t, rwset)
+
+	query := func(objectType string, attributes ...string) map[string]string {
+		rwset := newTestRwSet()
+		b := newTestBlindedStub(t, ledger, keys, rwset)
+		iterator, err := b.GetStateByPartialCompositeKey(objectType, attributes)
+		require.NoError(t, err)
+		defer iterator.Close()
+
+		results := make(map[string]string)
+		for iterator.HasNext() {
+			kv, err := iterator.Next()
+			require.NoError(t, err)
+			results[kv.Key] = string(kv.Value)
+		}
+
+		// every result is recorded in the rwset with its ledger key
+		reads, _ := rwsetKeys(rwset)
+		assert.Len(t, reads, len(results))
+		return results
+	}
+
+	// the iterator returns the original keys and values
+	assert.Equal(t, map[string]string{
+		compositeKeys["alice eur"]: "alice eur",
+		compositeKeys["alice usd"]: "alice usd",
+		compositeKeys["bob eur"]:   "bob eur",
+	}, query("wallet"))
+
+	assert.Equal(t, map[string]string{
+		compositeKeys["alice eur"]: "alice eur",
+		compositeKeys["alice usd"]: "alice usd",
+	}, query("wallet", "alice"))
+
+	assert.Equal(t, map[string]string{
+		compositeKeys["alice usd"]: "alice usd",
+	}, query("wallet", "alice", "usd"))
+
+	assert.Empty(t, query("wallet", "carol"))
+
+	// attribute values do not match across positions
+	assert.Empty(t, query("wallet", "eur"))
+}
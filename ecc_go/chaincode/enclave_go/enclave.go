@@ -9,15 +9,20 @@ SPDX-License-Identifier: Apache-2.0
 package enclave_go
 
 import (
-	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"time"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-private-chaincode/ecc_go/chaincode/enclave_go/attestation"
+	fpcattestation "github.com/hyperledger/fabric-private-chaincode/internal/attestation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/simulation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
 	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
 	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/session"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/bccsp"
 	"github.com/hyperledger/fabric/bccsp/factory"
@@ -38,7 +43,17 @@ type EnclaveStub struct {
 	hostParams           *protos.HostParameters
 	chaincodeParams      *protos.CCParameters
 	fabricCryptoProvider bccsp.BCCSP
+	issuer               *types.Issuer
+	verifier             fpcattestation.Verifier
 	stubProvider         func(shim.ChaincodeStubInterface, *pb.ChaincodeInput, *readWriteSet, StateEncryptionFunctions) shim.ChaincodeStubInterface
+	initTime             time.Time
+	metricsEnabled       bool
+	metricsFunctions     map[string]bool
+	tlTransport          session.Transport
+	tlMrenclave          string
+	trustedLedger        trustedLedger
+	hashAlgorithm        protos.HashAlgorithm
+	legacyState          bool
 }
 
 func NewEnclaveStub(cc shim.Chaincode) *EnclaveStub {
@@ -49,8 +64,11 @@ func NewEnclaveStub(cc shim.Chaincode) *EnclaveStub {
 
 	return &EnclaveStub{
 		csp:                  crypto.GetDefaultCSP(),
+		hashAlgorithm:        crypto.DefaultHashAlgorithm,
 		ccRef:                cc,
 		fabricCryptoProvider: cryptoProvider,
+		issuer:               simulation.NewSimulationIssuer(),
+		verifier:             newVerifier(nil),
 		stubProvider: func(stub shim.ChaincodeStubInterface, input *pb.ChaincodeInput, rwset *readWriteSet, sep StateEncryptionFunctions) shim.ChaincodeStubInterface {
 			return NewFpcStubInterface(stub, input, rwset, sep)
 		},
@@ -68,13 +86,6 @@ func (e *EnclaveStub) Init(serializedChaincodeParams, serializedHostParamsBytes,
 		return nil, errors.Wrap(err, "cannot create new enclave identity")
 	}
 
-	// as we currently support a single enclave instance per chaincode, we also generate a new chaincode identity here
-	// this needs to be refactored once multi enclave support will be integrated
-	e.ccKeys, err = NewChaincodeKeys(e.csp)
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot create new enclave identity")
-	}
-
 	e.hostParams = &protos.HostParameters{}
 	if err := proto.Unmarshal(serializedHostParamsBytes, e.hostParams); err != nil {
 		return nil, err
@@ -85,6 +96,16 @@ func (e *EnclaveStub) Init(serializedChaincodeParams, serializedHostParamsBytes,
 		return nil, err
 	}
 
+	// as we currently support a single enclave instance per chaincode, we also generate a new chaincode identity here
+	// this needs to be refactored once multi enclave support will be integrated
+	e.ccKeys, err = NewChaincodeKeys(e.csp, e.chaincodeParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create new enclave identity")
+	}
+	if e.legacyState {
+		e.ccKeys.EnableLegacyState()
+	}
+
 	serializedAttestedData, _ := anypb.New(&protos.AttestedData{
 		EnclaveVk:   e.identity.GetPublicKey(),
 		CcParams:    e.chaincodeParams,
@@ -92,7 +113,7 @@ func (e *EnclaveStub) Init(serializedChaincodeParams, serializedHostParamsBytes,
 		ChaincodeEk: e.ccKeys.GetPublicKey(),
 	})
 
-	att, err := attestation.Issue(serializedAttestedData)
+	att, err := attestation.Issue(e.issuer, serializedAttestedData)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot create attestation")
 	}
@@ -104,22 +125,170 @@ func (e *EnclaveStub) Init(serializedChaincodeParams, serializedHostParamsBytes,
 
 	logger.Infof("Create credentials: %s", credentials)
 
+	if e.tlTransport != nil {
+		e.trustedLedger = &trustedLedgerSession{
+			csp:       e.csp,
+			identity:  &session.Identity{Credentials: credentials, Signer: e.identity},
+			verify:    session.AttestedCredentials(e.verifier, e.tlMrenclave),
+			transport: e.tlTransport,
+			ccParams:  e.chaincodeParams,
+			enclaveId: e.identity.GetEnclaveId(),
+		}
+	}
+
+	e.initTime = time.Now()
+
 	return proto.Marshal(credentials)
 }
 
+// SetAttestationIssuer replaces the default (simulation) attestation issuer, e.g., with a confidential VM issuer
+func (e *EnclaveStub) SetAttestationIssuer(issuer *types.Issuer) {
+	e.issuer = issuer
+}
+
+// EnableMetrics lets the enclave attach invocation metrics to its responses. Note that the metrics reveal the names
+// of the invoked functions to the peer; functions other than the given ones are reported as OtherFunction.
+func (e *EnclaveStub) EnableMetrics(functions ...string) {
+	e.metricsEnabled = true
+	e.metricsFunctions = make(map[string]bool, len(functions))
+	for _, function := range functions {
+		e.metricsFunctions[function] = true
+	}
+}
+
+// SetTrustedLedger lets the enclave check all state read from the peer against the trusted ledger, i.e., TLCC.
+// The enclave sends its requests through a session with TLCC over the given transport, and accepts TLCC only if
+// it is attested with the given mrenclave for the channel of the chaincode. It must be called before Init.
+func (e *EnclaveStub) SetTrustedLedger(transport session.Transport, tlccMrenclave string) {
+	e.tlTransport = transport
+	e.tlMrenclave = tlccMrenclave
+}
+
+// EnableLegacyStateMigration lets the enclave read state which was encrypted without binding to its key by an earlier
+// version of the enclave; such state is re-encrypted with binding when it is written again
+func (e *EnclaveStub) EnableLegacyStateMigration() {
+	e.legacyState = true
+}
+
+// SetHashAlgorithm sets the hash function of the request hashes, read value hashes and rwset digests in the
+// responses of the enclave; the algorithm is recorded in the responses
+func (e *EnclaveStub) SetHashAlgorithm(algorithm protos.HashAlgorithm) error {
+	if _, err := e.csp.Hash(algorithm, nil); err != nil {
+		return err
+	}
+	e.hashAlgorithm = algorithm
+	return nil
+}
+
 func (e EnclaveStub) GenerateCCKeys() ([]byte, error) {
 	panic("implement me")
 	// -> *protos.SignedCCKeyRegistrationMessage
 }
 
-func (e EnclaveStub) ExportCCKeys(credentials []byte) ([]byte, error) {
-	panic("implement me")
-	// credentials *protos.Credentials -> *protos.SignedExportMessage,
+// ExportCCKeys hands over the state encryption key to the enclave of an upgraded chaincode version. As the upgraded
+// enclave only exists once the new chaincode definition is committed, when the peer does not dispatch to this enclave
+// anymore, the key is escrowed at the trusted ledger (TLCC). The upgrade policy must be the policy set in ERCC, as
+// checked against the trusted ledger; TLCC releases the key only to a registered enclave which is attested for a
+// chaincode version allowed by the policy (see ImportCCKeys). Note that the upgraded enclave keeps its own chaincode
+// encryption key, thus, the chaincode encryption key is rotated with every upgrade.
+func (e *EnclaveStub) ExportCCKeys(upgradePolicy []byte) error {
+	if e.identity == nil {
+		return fmt.Errorf("enclave not yet initialized")
+	}
+
+	if e.trustedLedger == nil {
+		return fmt.Errorf("key export requires a trusted ledger")
+	}
+
+	// the upgrade policy is set in ERCC by channel governance, which the peer must not bypass
+	key, err := shim.CreateCompositeKey("namespaces/upgrade_policy", []string{e.chaincodeParams.ChaincodeId})
+	if err != nil {
+		return err
+	}
+	if err := verifyState(e.csp, e.trustedLedger, nil, erccNamespace, key, upgradePolicy); err != nil {
+		return errors.Wrap(err, "invalid upgrade policy")
+	}
+
+	response, err := requestTrustedLedger(e.trustedLedger, &protos.Request{
+		Request: &protos.Request_EscrowKeys{EscrowKeys: &protos.EscrowKeysRequest{
+			ChaincodeId:   e.chaincodeParams.ChaincodeId,
+			UpgradePolicy: upgradePolicy,
+			StateKey:      e.ccKeys.ExportStateKey(),
+		}},
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot escrow chaincode keys")
+	}
+	if response.GetEscrowKeys() == nil {
+		return fmt.Errorf("invalid trusted ledger response")
+	}
+
+	return nil
 }
 
-func (e EnclaveStub) ImportCCKeys() ([]byte, error) {
-	panic("implement me")
-	// -> *protos.SignedCCKeyRegistrationMessage
+// ImportCCKeys imports the state encryption key escrowed at the trusted ledger by the enclave of the previous chaincode
+// version, which must be the enclave this enclave replaced in ERCC
+func (e *EnclaveStub) ImportCCKeys(previousEnclaveId string) ([]byte, error) {
+	if e.identity == nil {
+		return nil, fmt.Errorf("enclave not yet initialized")
+	}
+
+	if e.trustedLedger == nil {
+		return nil, fmt.Errorf("key import requires a trusted ledger")
+	}
+
+	key, err := shim.CreateCompositeKey("namespaces/upgraded", []string{e.chaincodeParams.ChaincodeId, e.identity.GetEnclaveId()})
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyState(e.csp, e.trustedLedger, nil, erccNamespace, key, []byte(previousEnclaveId)); err != nil {
+		return nil, errors.Wrap(err, "invalid previous enclave")
+	}
+
+	response, err := requestTrustedLedger(e.trustedLedger, &protos.Request{
+		Request: &protos.Request_ReleaseKeys{ReleaseKeys: &protos.ReleaseKeysRequest{
+			ChaincodeId: e.chaincodeParams.ChaincodeId,
+		}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot release chaincode keys")
+	}
+	released := response.GetReleaseKeys()
+	if released == nil {
+		return nil, fmt.Errorf("invalid trusted ledger response")
+	}
+
+	if released.GetSenderEnclaveId() != previousEnclaveId {
+		return nil, fmt.Errorf("chaincode keys were escrowed by enclave %s instead of the previous enclave %s", released.GetSenderEnclaveId(), previousEnclaveId)
+	}
+
+	if err := e.ccKeys.ImportStateKey(released.GetStateKey()); err != nil {
+		return nil, errors.Wrap(err, "cannot import chaincode keys")
+	}
+
+	ccParamsHash, err := utils.GetCCParamsHash(e.chaincodeParams)
+	if err != nil {
+		return nil, err
+	}
+
+	serializedRegistrationMessage, err := anypb.New(&protos.CCKeyRegistrationMessage{
+		CcParamsHash: ccParamsHash,
+		ChaincodeEk:  e.ccKeys.GetPublicKey(),
+		EnclaveId:    []byte(e.identity.GetEnclaveId()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := e.identity.Sign(serializedRegistrationMessage.GetValue())
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&protos.SignedCCKeyRegistrationMessage{
+		SerializedCckeyRegMsg: serializedRegistrationMessage,
+		Signature:             sig,
+	})
 }
 
 func (e *EnclaveStub) GetEnclaveId() (string, error) {
@@ -130,45 +299,123 @@ func (e *EnclaveStub) GetEnclaveId() (string, error) {
 	return e.identity.GetEnclaveId(), nil
 }
 
+// GetEnclaveInfo returns the identity, chaincode version and uptime of this enclave signed with the enclave key.
+// Note that the chaincode version and the uptime are provided by the host and, thus, not trustworthy; the mrenclave
+// of the enclave is only attested by its credentials.
+func (e *EnclaveStub) GetEnclaveInfo(nonce []byte) ([]byte, error) {
+	if e.identity == nil {
+		return nil, fmt.Errorf("enclave not yet initialized")
+	}
+
+	enclaveInfoBytes, err := proto.Marshal(&protos.EnclaveInfo{
+		EnclaveId:        e.identity.GetEnclaveId(),
+		ChaincodeVersion: e.chaincodeParams.GetVersion(),
+		AttestationType:  e.issuer.Type,
+		UptimeSeconds:    uint64(time.Since(e.initTime).Seconds()),
+		Nonce:            nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := e.identity.Sign(enclaveInfoBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&protos.SignedEnclaveInfo{
+		EnclaveInfo: enclaveInfoBytes,
+		Signature:   sig,
+	})
+}
+
+// ChaincodeInvoke processes an encrypted chaincode request and returns a signed response.
+// Errors of the request or the chaincode are reported by an error code in the signed response; the error message is
+// only included in the encrypted response, thus, it is revealed to the client but not to the peer.
 func (e *EnclaveStub) ChaincodeInvoke(stub shim.ChaincodeStubInterface, chaincodeRequestMessageBytes []byte) ([]byte, error) {
 	logger.Debug("ChaincodeInvoke")
 
+	if e.identity == nil {
+		return nil, fmt.Errorf("enclave not yet initialized")
+	}
+
 	signedProposal, err := stub.GetSignedProposal()
 	if err != nil {
 		return nil, err
 	}
 
+	chaincodeRequestMessageHash, err := e.csp.Hash(e.hashAlgorithm, chaincodeRequestMessageBytes)
+	if err != nil {
+		return nil, err
+	}
+	response := &protos.ChaincodeResponseMessage{
+		Proposal:                    signedProposal,
+		ChaincodeRequestMessageHash: chaincodeRequestMessageHash,
+		HashAlgorithm:               e.hashAlgorithm,
+	}
+
 	if err := e.verifySignedProposal(stub, chaincodeRequestMessageBytes); err != nil {
-		return nil, errors.Wrap(err, "signed proposal verification failed")
+		logger.Debugf("signed proposal verification failed: %s", err)
+		return e.errorResponse(response, protos.ErrorCode_ERROR_CODE_INVALID_PROPOSAL, nil, err)
 	}
 
 	// unmarshal chaincodeRequest
 	chaincodeRequestMessage := &protos.ChaincodeRequestMessage{}
 	err = proto.Unmarshal(chaincodeRequestMessageBytes, chaincodeRequestMessage)
 	if err != nil {
-		return nil, err
+		return e.errorResponse(response, protos.ErrorCode_ERROR_CODE_INVALID_REQUEST, nil, err)
 	}
 
 	// get key transport message including the encryption keys for request and response
 	keyTransportMessage, err := e.extractKeyTransportMessage(chaincodeRequestMessage)
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot extract keyTransportMessage")
+		logger.Debugf("cannot extract keyTransportMessage: %s", err)
+		return e.errorResponse(response, protos.ErrorCode_ERROR_CODE_INVALID_REQUEST, nil, err)
 	}
+	responseEncryptionKey := keyTransportMessage.GetResponseEncryptionKey()
 
 	// decrypt request
 	cleartextChaincodeRequest, err := e.extractCleartextChaincodeRequest(chaincodeRequestMessage, keyTransportMessage)
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot decrypt chaincode request")
+		return e.errorResponse(response, protos.ErrorCode_ERROR_CODE_INVALID_REQUEST, responseEncryptionKey, errors.Wrap(err, "cannot decrypt chaincode request"))
 	}
 
 	// create a new instance of a FPC RWSet that we pass to the stub and later return with the response
-	rwset := NewReadWriteSet()
+	rwset := NewReadWriteSet(e.csp, e.hashAlgorithm)
+
+	// meter the invocation if enabled
+	var sep StateEncryptionFunctions = e.ccKeys
+	var meter *invocationMeter
+	if e.metricsEnabled {
+		meter = newInvocationMeter(e.ccKeys)
+		meter.decrypted(proto.Size(cleartextChaincodeRequest))
+		sep = meter
+	}
+
+	// check the state read from the peer against the trusted ledger if enabled
+	if e.trustedLedger != nil {
+		stub, err = newTrustedLedgerStub(stub, e.csp, e.trustedLedger, e.chaincodeParams.GetChaincodeId())
+		if err != nil {
+			return e.errorResponse(response, protos.ErrorCode_ERROR_CODE_INTERNAL_ERROR, responseEncryptionKey, err)
+		}
+	}
 
 	// Invoke chaincode
 	// we wrap the stub with our FpcStubInterface
-	fpcStub := e.stubProvider(stub, cleartextChaincodeRequest.GetInput(), rwset, e.ccKeys)
+	fpcStub := e.stubProvider(stub, cleartextChaincodeRequest.GetInput(), rwset, sep)
 	ccResponse := e.ccRef.Invoke(fpcStub)
 
+	if ccResponse.Status >= shim.ERRORTHRESHOLD {
+		// the rwset of a failed invocation is never committed
+		response.ErrorCode = protos.ErrorCode_ERROR_CODE_CHAINCODE_ERROR
+	} else {
+		response.FpcRwSet = rwset.ToFPCKVSet()
+		response.RwSetDigest, err = utils.GetRwSetDigest(response.FpcRwSet, crypto.Hasher(e.csp, e.hashAlgorithm))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// marshal chaincode response
 	ccResponseBytes, err := protoutil.Marshal(&ccResponse)
 	if err != nil {
@@ -176,21 +423,63 @@ func (e *EnclaveStub) ChaincodeInvoke(stub shim.ChaincodeStubInterface, chaincod
 	}
 
 	//encrypt response
-	encryptedResponse, err := e.csp.EncryptMessage(keyTransportMessage.GetResponseEncryptionKey(), ccResponseBytes)
+	response.EncryptedResponse, err = e.csp.EncryptMessage(responseEncryptionKey, ccResponseBytes)
 	if err != nil {
 		return nil, err
 	}
 
-	chaincodeRequestMessageHash := sha256.Sum256(chaincodeRequestMessageBytes)
+	signedResponse, err := e.newSignedResponse(response)
+	if err != nil {
+		return nil, err
+	}
 
-	response := &protos.ChaincodeResponseMessage{
-		EncryptedResponse:           encryptedResponse,
-		FpcRwSet:                    rwset.ToFPCKVSet(),
-		EnclaveId:                   e.identity.GetEnclaveId(),
-		Proposal:                    signedProposal,
-		ChaincodeRequestMessageHash: chaincodeRequestMessageHash[:],
+	// metrics are attached outside of the signed response, so ECC can remove them before the response is committed
+	if meter != nil {
+		meter.encrypted(len(ccResponseBytes))
+		function, _ := fpcStub.GetFunctionAndParameters()
+		if !e.metricsFunctions[function] {
+			function = OtherFunction
+		}
+		signedResponse.Metrics = meter.metrics(function, rwset.ToFPCKVSet())
+	}
+
+	return proto.Marshal(signedResponse)
+}
+
+// errorResponse returns a signed response with the given error code. The error message is only included if the
+// response encryption key of the client is known.
+func (e *EnclaveStub) errorResponse(response *protos.ChaincodeResponseMessage, code protos.ErrorCode, responseEncryptionKey []byte, cause error) ([]byte, error) {
+	response.ErrorCode = code
+
+	if responseEncryptionKey != nil {
+		ccResponseBytes, err := protoutil.Marshal(&pb.Response{
+			Status:  shim.ERROR,
+			Message: cause.Error(),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		response.EncryptedResponse, err = e.csp.EncryptMessage(responseEncryptionKey, ccResponseBytes)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	return e.signResponse(response)
+}
+
+func (e *EnclaveStub) signResponse(response *protos.ChaincodeResponseMessage) ([]byte, error) {
+	signedResponse, err := e.newSignedResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(signedResponse)
+}
+
+func (e *EnclaveStub) newSignedResponse(response *protos.ChaincodeResponseMessage) (*protos.SignedChaincodeResponseMessage, error) {
+	response.EnclaveId = e.identity.GetEnclaveId()
+
 	responseBytes, err := proto.Marshal(response)
 	if err != nil {
 		return nil, err
@@ -202,12 +491,10 @@ func (e *EnclaveStub) ChaincodeInvoke(stub shim.ChaincodeStubInterface, chaincod
 		return nil, err
 	}
 
-	signedResponse := &protos.SignedChaincodeResponseMessage{
+	return &protos.SignedChaincodeResponseMessage{
 		ChaincodeResponseMessage: responseBytes,
 		Signature:                sig,
-	}
-
-	return proto.Marshal(signedResponse)
+	}, nil
 }
 
 func (e *EnclaveStub) verifySignedProposal(stub shim.ChaincodeStubInterface, chaincodeRequestMessageBytes []byte) error {
@@ -319,7 +606,7 @@ func (e *EnclaveStub) extractKeyTransportMessage(chaincodeRequestMessage *protos
 		return nil, fmt.Errorf("no request encryption key")
 	}
 
-	if keyTransportMessage.GetRequestEncryptionKey() == nil {
+	if keyTransportMessage.GetResponseEncryptionKey() == nil {
 		return nil, fmt.Errorf("no response encryption key")
 	}
 	return keyTransportMessage, err
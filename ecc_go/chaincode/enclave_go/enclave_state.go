@@ -8,12 +8,20 @@ package enclave_go
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"strings"
 
 	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/pkg/errors"
 )
 
+// StateEncryptionV1 is the version prefix of state ciphertexts which are bound to their ledger key, channel and
+// chaincode. State without version prefix is encrypted without binding; it is only decrypted when the migration of
+// legacy state is enabled and is re-encrypted with binding when written again.
+const StateEncryptionV1 byte = 1
+
 type EnclaveIdentity struct {
 	csp        crypto.CSP
 	privateKey []byte
@@ -63,6 +71,9 @@ type ChaincodeKeys struct {
 	ccPrivateKey []byte
 	ccPublicKey  []byte
 	stateKey     []byte
+	channelId    string
+	chaincodeId  string
+	legacyState  bool
 }
 
 type ChaincodeIdentityFunctions interface {
@@ -71,15 +82,18 @@ type ChaincodeIdentityFunctions interface {
 	StateEncryptionFunctions
 }
 
+// StateEncryptionFunctions encrypt the value of a ledger key; the ciphertext is bound to the key
 type StateEncryptionFunctions interface {
-	EncryptState(plaintext []byte) (ciphertext []byte, err error)
-	DecryptState(ciphertext []byte) (plaintext []byte, err error)
+	EncryptState(key string, plaintext []byte) (ciphertext []byte, err error)
+	DecryptState(key string, ciphertext []byte) (plaintext []byte, err error)
 }
 
-func NewChaincodeKeys(csp crypto.CSP) (*ChaincodeKeys, error) {
+func NewChaincodeKeys(csp crypto.CSP, ccParams *protos.CCParameters) (*ChaincodeKeys, error) {
 	var err error
 	c := &ChaincodeKeys{}
 	c.csp = csp
+	c.channelId = ccParams.GetChannelId()
+	c.chaincodeId = ccParams.GetChaincodeId()
 
 	// create chaincode encryption keys
 	c.ccPublicKey, c.ccPrivateKey, err = csp.NewRSAKeys()
@@ -104,11 +118,80 @@ func (c *ChaincodeKeys) PkDecryptMessage(ciphertext []byte) (plaintext []byte, e
 	return c.csp.PkDecryptMessage(c.ccPrivateKey, ciphertext)
 }
 
-func (c *ChaincodeKeys) EncryptState(plaintext []byte) (ciphertext []byte, err error) {
-	return c.csp.EncryptMessage(c.stateKey, plaintext)
+// ExportStateKey returns the state key, e.g., to hand it over to the enclave of an upgraded chaincode version
+func (c *ChaincodeKeys) ExportStateKey() []byte {
+	return c.stateKey
+}
+
+// ImportStateKey replaces the state key with the key handed over by the enclave of the previous chaincode version
+func (c *ChaincodeKeys) ImportStateKey(stateKey []byte) error {
+	if len(stateKey) != len(c.stateKey) {
+		return errors.Errorf("invalid state key length %d", len(stateKey))
+	}
+	c.stateKey = stateKey
+	return nil
+}
+
+// EncryptState encrypts the value of a ledger key and authenticates the key, the channel and the chaincode ID as
+// additional data, so that the ciphertext cannot be moved to another key
+func (c *ChaincodeKeys) EncryptState(key string, plaintext []byte) (ciphertext []byte, err error) {
+	ciphertext, err = c.csp.EncryptMessageWithAD(c.stateKey, plaintext, c.stateBinding(key))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{StateEncryptionV1}, ciphertext...), nil
 }
 
-func (c *ChaincodeKeys) DecryptState(ciphertext []byte) (plaintext []byte, err error) {
-	return c.csp.DecryptMessage(c.stateKey, ciphertext)
+// DecryptState decrypts the value of a ledger key. Values encrypted before state binding was introduced are only
+// decrypted, without binding, if the migration of legacy state is enabled; otherwise, the peer could replace the value
+// of any key by a legacy ciphertext of another key, even after the key has been rewritten with binding.
+func (c *ChaincodeKeys) DecryptState(key string, ciphertext []byte) (plaintext []byte, err error) {
+	if len(ciphertext) > 0 && ciphertext[0] == StateEncryptionV1 {
+		plaintext, err = c.csp.DecryptMessageWithAD(c.stateKey, ciphertext[1:], c.stateBinding(key))
+		if err == nil {
+			return plaintext, nil
+		}
+		if !c.legacyState {
+			return nil, errors.Wrapf(err, "cannot decrypt state of key %s", key)
+		}
+	}
+
+	if !c.legacyState {
+		return nil, errors.Errorf("cannot decrypt state of key %s: state is not bound to its key", key)
+	}
+
+	// the first byte of an unversioned ciphertext is random, thus, it may match the version prefix
+	plaintext, err = c.csp.DecryptMessage(c.stateKey, ciphertext)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot decrypt state of key %s", key)
+	}
+	return plaintext, nil
+}
+
+// EnableLegacyState lets DecryptState decrypt state which was encrypted without binding, i.e., by an earlier version
+// of the enclave; it must only be enabled to migrate such state, as legacy ciphertexts can be moved to another key
+func (c *ChaincodeKeys) EnableLegacyState() {
+	c.legacyState = true
+}
+
+// stateBinding returns the additional data of a ledger key, i.e., the length-prefixed channel, chaincode ID and key
+func (c *ChaincodeKeys) stateBinding(key string) []byte {
+	var ad []byte
+	for _, field := range []string{c.channelId, c.chaincodeId, key} {
+		ad = binary.AppendUvarint(ad, uint64(len(field)))
+		ad = append(ad, field...)
+	}
+	return ad
+}
+
+// BucketKey returns the key used to assign state keys to SKVS buckets; like the key blinding key, it is derived from
+// the state key, thus, the assignment is the same at all enclaves of the chaincode but unknown to the peer
+func (c *ChaincodeKeys) BucketKey() ([]byte, error) {
+	return c.csp.DeriveKey(protos.HashAlgorithm_HASH_ALGORITHM_SHA256, c.stateKey, nil, "fpc skvs buckets", 32)
+}
 
+// KeyBlindingKey returns the key used to blind ledger keys; it is derived from the state key, thus, it is
+// shared by all enclaves of the chaincode and survives key export and import
+func (c *ChaincodeKeys) KeyBlindingKey() ([]byte, error) {
+	return c.csp.DeriveKey(protos.HashAlgorithm_HASH_ALGORITHM_SHA256, c.stateKey, nil, "fpc key blinding", 32)
 }
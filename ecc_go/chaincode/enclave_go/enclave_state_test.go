@@ -0,0 +1,68 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateEncryption(t *testing.T) {
+	keys := newTestKeys(t)
+
+	ciphertext, err := keys.EncryptState("alice", []byte("100"))
+	require.NoError(t, err)
+	assert.Equal(t, StateEncryptionV1, ciphertext[0])
+
+	plaintext, err := keys.DecryptState("alice", ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("100"), plaintext)
+
+	// a bound ciphertext cannot be moved to another key, channel or chaincode
+	_, err = keys.DecryptState("bob", ciphertext)
+	assert.Error(t, err)
+
+	otherChannel := *keys
+	otherChannel.channelId = "otherchannel"
+	_, err = otherChannel.DecryptState("alice", ciphertext)
+	assert.Error(t, err)
+
+	otherChaincode := *keys
+	otherChaincode.chaincodeId = "othercc"
+	_, err = otherChaincode.DecryptState("alice", ciphertext)
+	assert.Error(t, err)
+
+	// nor can it be moved when the migration of legacy state is enabled
+	keys.EnableLegacyState()
+	_, err = keys.DecryptState("bob", ciphertext)
+	assert.Error(t, err)
+}
+
+func TestLegacyStateEncryption(t *testing.T) {
+	keys := newTestKeys(t)
+
+	legacyCiphertext, err := keys.csp.EncryptMessage(keys.stateKey, []byte("100"))
+	require.NoError(t, err)
+
+	// unbound state is rejected by default
+	_, err = keys.DecryptState("alice", legacyCiphertext)
+	assert.Error(t, err)
+
+	keys.EnableLegacyState()
+	plaintext, err := keys.DecryptState("alice", legacyCiphertext)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("100"), plaintext)
+
+	// state is written with binding again
+	ciphertext, err := keys.EncryptState("alice", plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, StateEncryptionV1, ciphertext[0])
+	_, err = keys.DecryptState("bob", ciphertext)
+	assert.Error(t, err)
+}
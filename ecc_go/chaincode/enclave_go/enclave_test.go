@@ -0,0 +1,157 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// testEscrowLedger is a trusted ledger which answers metadata requests with the value hashes of the given ledger and
+// keeps the escrowed state key; it attributes the escrow to the enclave set as sender
+type testEscrowLedger struct {
+	csp      crypto.CSP
+	ledger   testLedger
+	sender   string
+	stateKey []byte
+}
+
+func (l *testEscrowLedger) Request(requestBytes []byte) ([]byte, error) {
+	request := &protos.Request{}
+	if err := proto.Unmarshal(requestBytes, request); err != nil {
+		return nil, err
+	}
+
+	response := &protos.Response{}
+	switch r := request.GetRequest().(type) {
+	case *protos.Request_Metadata:
+		hash := make([]byte, 32)
+		if value, ok := l.ledger[r.Metadata.GetKey()]; ok {
+			var err error
+			if hash, err = l.csp.Hash(crypto.DefaultHashAlgorithm, value); err != nil {
+				return nil, err
+			}
+		}
+		response.Response = &protos.Response_Metadata{Metadata: &protos.GetMetadataResponse{Hash: hash}}
+	case *protos.Request_EscrowKeys:
+		l.stateKey = r.EscrowKeys.GetStateKey()
+		response.Response = &protos.Response_EscrowKeys{EscrowKeys: &protos.EscrowKeysResponse{}}
+	case *protos.Request_ReleaseKeys:
+		if l.stateKey == nil {
+			return nil, fmt.Errorf("no keys escrowed")
+		}
+		response.Response = &protos.Response_ReleaseKeys{ReleaseKeys: &protos.ReleaseKeysResponse{StateKey: l.stateKey, SenderEnclaveId: l.sender}}
+	}
+	return proto.Marshal(response)
+}
+
+func newTestEnclaveStub(t *testing.T, tl trustedLedger, version string) *EnclaveStub {
+	csp := crypto.GetDefaultCSP()
+	identity, err := NewEnclaveIdentity(csp)
+	require.NoError(t, err)
+	ccParams := &protos.CCParameters{ChannelId: testChannelId, ChaincodeId: "cc", Version: version}
+	ccKeys, err := NewChaincodeKeys(csp, ccParams)
+	require.NoError(t, err)
+	return &EnclaveStub{csp: csp, identity: identity, ccKeys: ccKeys, chaincodeParams: ccParams, trustedLedger: tl}
+}
+
+func TestExportImportCCKeys(t *testing.T) {
+	csp := crypto.GetDefaultCSP()
+	tl := &testEscrowLedger{csp: csp, ledger: testLedger{}}
+	previous := newTestEnclaveStub(t, tl, "v1")
+	upgraded := newTestEnclaveStub(t, tl, "v2")
+	tl.sender = previous.identity.GetEnclaveId()
+
+	upgradePolicy := []byte(utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: "cc", AllowedVersions: []string{"v2"}}))
+	policyKey, err := shim.CreateCompositeKey("namespaces/upgrade_policy", []string{"cc"})
+	require.NoError(t, err)
+	upgradedKey, err := shim.CreateCompositeKey("namespaces/upgraded", []string{"cc", upgraded.identity.GetEnclaveId()})
+	require.NoError(t, err)
+
+	ciphertext, err := previous.ccKeys.EncryptState("alice", []byte("100"))
+	require.NoError(t, err)
+
+	// the upgrade policy must be set in ERCC
+	err = previous.ExportCCKeys(upgradePolicy)
+	assert.ErrorContains(t, err, "invalid upgrade policy")
+	assert.Nil(t, tl.stateKey)
+
+	tl.ledger[policyKey] = upgradePolicy
+	otherPolicy := []byte(utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: "cc", AllowedVersions: []string{"v3"}}))
+	err = previous.ExportCCKeys(otherPolicy)
+	assert.ErrorContains(t, err, "invalid upgrade policy")
+
+	require.NoError(t, previous.ExportCCKeys(upgradePolicy))
+	assert.Equal(t, previous.ccKeys.ExportStateKey(), tl.stateKey)
+
+	// the upgraded enclave must have replaced the previous enclave in ERCC
+	_, err = upgraded.ImportCCKeys(previous.identity.GetEnclaveId())
+	assert.ErrorContains(t, err, "invalid previous enclave")
+
+	tl.ledger[upgradedKey] = []byte(previous.identity.GetEnclaveId())
+	_, err = upgraded.ImportCCKeys("otherenclave")
+	assert.ErrorContains(t, err, "invalid previous enclave")
+
+	// the keys must be escrowed by the previous enclave
+	tl.sender = "otherenclave"
+	_, err = upgraded.ImportCCKeys(previous.identity.GetEnclaveId())
+	assert.EqualError(t, err, fmt.Sprintf("chaincode keys were escrowed by enclave otherenclave instead of the previous enclave %s", previous.identity.GetEnclaveId()))
+
+	tl.sender = previous.identity.GetEnclaveId()
+	signedRegistrationMessageBytes, err := upgraded.ImportCCKeys(previous.identity.GetEnclaveId())
+	require.NoError(t, err)
+
+	signedRegistrationMessage := &protos.SignedCCKeyRegistrationMessage{}
+	require.NoError(t, proto.Unmarshal(signedRegistrationMessageBytes, signedRegistrationMessage))
+	registrationMessage := &protos.CCKeyRegistrationMessage{}
+	require.NoError(t, signedRegistrationMessage.GetSerializedCckeyRegMsg().UnmarshalTo(registrationMessage))
+	assert.Equal(t, []byte(upgraded.identity.GetEnclaveId()), registrationMessage.GetEnclaveId())
+	assert.Equal(t, upgraded.ccKeys.GetPublicKey(), registrationMessage.GetChaincodeEk())
+
+	// the upgraded enclave reads the state of the previous enclave
+	plaintext, err := upgraded.ccKeys.DecryptState("alice", ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("100"), plaintext)
+
+	// the keys are only handed over through the trusted ledger
+	withoutTrustedLedger := newTestEnclaveStub(t, nil, "v1")
+	assert.EqualError(t, withoutTrustedLedger.ExportCCKeys(upgradePolicy), "key export requires a trusted ledger")
+	_, err = withoutTrustedLedger.ImportCCKeys(previous.identity.GetEnclaveId())
+	assert.EqualError(t, err, "key import requires a trusted ledger")
+}
+
+func TestStateLayoutKeepsSettings(t *testing.T) {
+	// the state layout can be chosen after the other settings of the enclave
+	layouts := map[string]func(e *EnclaveStub){
+		"skvs":         func(e *EnclaveStub) { e.EnableSkvs() },
+		"sharded skvs": func(e *EnclaveStub) { e.EnableShardedSkvs(4) },
+		"key blinding": func(e *EnclaveStub) { e.EnableKeyBlinding() },
+		"oram":         func(e *EnclaveStub) { require.NoError(t, e.EnableOram(testOramHeight, testOramBlockSize)) },
+	}
+	for name, enable := range layouts {
+		e := NewEnclaveStub(nil)
+		require.NoError(t, e.SetHashAlgorithm(protos.HashAlgorithm_HASH_ALGORITHM_SHA384), name)
+		e.EnableMetrics("f")
+		e.EnableLegacyStateMigration()
+		enable(e)
+
+		assert.Equal(t, protos.HashAlgorithm_HASH_ALGORITHM_SHA384, e.hashAlgorithm, name)
+		assert.True(t, e.metricsEnabled, name)
+		assert.True(t, e.legacyState, name)
+	}
+
+	e := NewEnclaveStub(nil)
+	assert.EqualError(t, e.EnableOram(17, testOramBlockSize), "invalid ORAM height 17, must be between 1 and 16")
+}
@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+)
+
+// OtherFunction is reported in the metrics of invocations of functions the chaincode does not report metrics for
+const OtherFunction = "other"
+
+// invocationMeter counts the bytes encrypted and decrypted during a single chaincode invocation
+type invocationMeter struct {
+	StateEncryptionFunctions
+	start          time.Time
+	bytesEncrypted atomic.Uint64
+	bytesDecrypted atomic.Uint64
+}
+
+func newInvocationMeter(sep StateEncryptionFunctions) *invocationMeter {
+	return &invocationMeter{StateEncryptionFunctions: sep, start: time.Now()}
+}
+
+func (m *invocationMeter) EncryptState(key string, plaintext []byte) ([]byte, error) {
+	ciphertext, err := m.StateEncryptionFunctions.EncryptState(key, plaintext)
+	if err == nil {
+		m.encrypted(len(plaintext))
+	}
+	return ciphertext, err
+}
+
+func (m *invocationMeter) DecryptState(key string, ciphertext []byte) ([]byte, error) {
+	plaintext, err := m.StateEncryptionFunctions.DecryptState(key, ciphertext)
+	if err == nil {
+		m.decrypted(len(plaintext))
+	}
+	return plaintext, err
+}
+
+func (m *invocationMeter) encrypted(n int) {
+	m.bytesEncrypted.Add(uint64(n))
+}
+
+func (m *invocationMeter) decrypted(n int) {
+	m.bytesDecrypted.Add(uint64(n))
+}
+
+// metrics returns the metrics of the invocation; reads and writes are counted by the rwset
+func (m *invocationMeter) metrics(function string, rwset *protos.FPCKVSet) *protos.InvocationMetrics {
+	return &protos.InvocationMetrics{
+		Function:       function,
+		Reads:          uint32(len(rwset.GetRwSet().GetReads())),
+		Writes:         uint32(len(rwset.GetRwSet().GetWrites())),
+		BytesEncrypted: m.bytesEncrypted.Load(),
+		BytesDecrypted: m.bytesDecrypted.Load(),
+		DurationMicros: uint64(time.Since(m.start).Microseconds()),
+	}
+}
@@ -0,0 +1,43 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// NewOramStub returns an enclave which keeps the chaincode state in a Path ORAM (see OramStubInterface)
+// with 2^height leaves and blocks of blockSize bytes. As the sealed position map is padded to 2^height entries of
+// 48 bytes and rewritten by every transaction, the height is limited to 16.
+func NewOramStub(cc shim.Chaincode, height, blockSize int) (*EnclaveStub, error) {
+	enclaveStub := NewEnclaveStub(cc)
+	if err := enclaveStub.EnableOram(height, blockSize); err != nil {
+		return nil, err
+	}
+	return enclaveStub, nil
+}
+
+// EnableOram lets the enclave keep the chaincode state in a Path ORAM with 2^height leaves and blocks of blockSize
+// bytes (see NewOramStub)
+func (e *EnclaveStub) EnableOram(height, blockSize int) error {
+	if height < 1 || height > 16 {
+		return fmt.Errorf("invalid ORAM height %d, must be between 1 and 16", height)
+	}
+	if blockSize < 1 || blockSize > math.MaxUint16 {
+		return fmt.Errorf("invalid ORAM block size %d, must be between 1 and %d", blockSize, math.MaxUint16)
+	}
+
+	cache := NewOramCache()
+	e.stubProvider = func(stub shim.ChaincodeStubInterface, input *pb.ChaincodeInput, rwset *readWriteSet, sep StateEncryptionFunctions) shim.ChaincodeStubInterface {
+		return NewOramStubInterface(stub, input, rwset, sep, cache, height, blockSize)
+	}
+	return nil
+}
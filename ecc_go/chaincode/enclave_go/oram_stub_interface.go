@@ -0,0 +1,512 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ORAMMetaKey is the ledger key of the sealed position map and stash
+	ORAMMetaKey = "ORAM_META"
+	// ORAMNodeKeyPrefix is the prefix of the ledger keys of the tree nodes, e.g., ORAM_1 for the root
+	ORAMNodeKeyPrefix = "ORAM_"
+	// ORAMBucketSize is the number of blocks per tree node
+	ORAMBucketSize = 4
+	// ORAMStashSize is the maximum number of blocks in the stash; with a bucket size of 4 the stash stays far below
+	// with overwhelming probability, an access which would exceed it fails
+	ORAMStashSize = 64
+
+	// a block is encoded as used flag (1), leaf (8), version (8), key length (2), value length (4), key and value
+	oramBlockHeaderSize = 23
+	// a position is encoded as the SHA-256 hash of the key (32), leaf (8) and version (8)
+	oramPositionSize = sha256.Size + 16
+)
+
+// OramStubInterface implements Path ORAM on top of the ledger to hide the access pattern of a chaincode.
+// The state is kept in a binary tree of fixed-size encrypted buckets; every access reads and rewrites the
+// path from the root to a random leaf, thus, the peer only learns the paths of the accesses but neither the keys
+// nor whether an access is a read or a write. The position map and the stash are sealed under ORAMMetaKey, padded
+// to the capacity of 2^height entries and ORAMStashSize blocks, thus, their size does not reveal the number of keys.
+//
+// A block is remapped to a new random path by every access, but the new position only takes effect once the
+// transaction is committed. To keep the peer from linking repeated accesses to the same committed position, e.g.,
+// by invoking the same query repeatedly, the enclave keeps the blocks it read in an OramCache and accesses a random
+// path instead if it read the path of the committed position before. The remaining leakage is:
+//   - the number of transactions and the union of the paths accessed by each transaction, i.e., an upper bound of
+//     the number of accesses, as nodes are only read and written once per transaction;
+//   - repeated accesses to the same committed position after the enclave restarted, as the cache is not sealed;
+//   - failed accesses, i.e., when the position map is full or the stash overflows.
+//
+// Note that all transactions read and write the root and the position map, thus, transactions are serialized
+// by MVCC validation. Moreover, reads return the writes of the same transaction. Range and composite key queries
+// are not supported.
+type OramStubInterface struct {
+	*FpcStubInterface
+	height    int
+	blockSize int
+	cache     *OramCache
+
+	// nodes caches the buckets read or written by this transaction
+	nodes map[uint64][]*oramBlock
+	meta  *oramMeta
+}
+
+type oramBlock struct {
+	Key     string
+	Leaf    uint64
+	Version uint64
+	Value   []byte
+}
+
+// oramPosition is the leaf of a block and the random version of its last write; copies of a block with another
+// position are stale, e.g., when the block was remapped without reading its path
+type oramPosition struct {
+	leaf    uint64
+	version uint64
+}
+
+type oramMeta struct {
+	// positions are indexed by the SHA-256 hash of the key, which keeps the entries of the sealed map at a fixed size
+	positions map[string]oramPosition
+	stash     []*oramBlock
+}
+
+// OramCache keeps the blocks of an enclave whose committed paths were read, i.e., revealed to the peer, by an earlier
+// access (see OramStubInterface). The cache is shared by the invocations of the enclave.
+type OramCache struct {
+	mutex  sync.Mutex
+	blocks map[string]*oramBlock
+}
+
+func NewOramCache() *OramCache {
+	return &OramCache{blocks: make(map[string]*oramBlock)}
+}
+
+// get returns a copy of the cached block of a key if its path was read at the given position
+func (c *OramCache) get(key string, position oramPosition) *oramBlock {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	block, found := c.blocks[key]
+	if !found {
+		return nil
+	}
+	// the block was remapped by a committed transaction since
+	if block.Leaf != position.leaf || block.Version != position.version {
+		delete(c.blocks, key)
+		return nil
+	}
+	b := *block
+	return &b
+}
+
+func (c *OramCache) put(block *oramBlock) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	b := *block
+	c.blocks[block.Key] = &b
+}
+
+// NewOramStubInterface returns a Path ORAM stub for a tree with the given height, i.e., 2^height leaves.
+// The key and value of a state entry must fit into a block of blockSize bytes. The cache must be shared by all
+// invocations of the enclave.
+func NewOramStubInterface(stub shim.ChaincodeStubInterface, input *pb.ChaincodeInput, rwset *readWriteSet, sep StateEncryptionFunctions, cache *OramCache, height, blockSize int) *OramStubInterface {
+	return &OramStubInterface{
+		FpcStubInterface: NewFpcStubInterface(stub, input, rwset, sep),
+		height:           height,
+		blockSize:        blockSize,
+		cache:            cache,
+		nodes:            make(map[uint64][]*oramBlock),
+	}
+}
+
+func (o *OramStubInterface) GetState(key string) ([]byte, error) {
+	var value []byte
+	err := o.access(key, func(block *oramBlock) *oramBlock {
+		if block != nil {
+			value = block.Value
+		}
+		return block
+	})
+	return value, err
+}
+
+func (o *OramStubInterface) PutState(key string, value []byte) error {
+	if len(key)+len(value) > o.blockSize {
+		return fmt.Errorf("key and value of %d bytes exceed the ORAM block size of %d bytes", len(key)+len(value), o.blockSize)
+	}
+
+	return o.access(key, func(block *oramBlock) *oramBlock {
+		return &oramBlock{Key: key, Value: value}
+	})
+}
+
+func (o *OramStubInterface) DelState(key string) error {
+	return o.access(key, func(block *oramBlock) *oramBlock {
+		return nil
+	})
+}
+
+func (o *OramStubInterface) GetStateByRange(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+	return nil, fmt.Errorf("range queries are not supported by ORAM")
+}
+
+func (o *OramStubInterface) GetStateByRangeWithPagination(startKey string, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	return nil, nil, fmt.Errorf("range queries are not supported by ORAM")
+}
+
+func (o *OramStubInterface) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	return nil, fmt.Errorf("composite key queries are not supported by ORAM")
+}
+
+// access reads the path of the block with the given key, lets update change the block, and rewrites the path.
+// A missing block is passed as nil to update; returning nil deletes the block.
+func (o *OramStubInterface) access(key string, update func(block *oramBlock) *oramBlock) error {
+	if err := o.loadMeta(); err != nil {
+		return err
+	}
+
+	// a missing block is accessed on a random path; so is a block whose path was read at this position before,
+	// which is taken from the cache instead
+	id := oramKeyID(key)
+	position, found := o.meta.positions[id]
+	var cached *oramBlock
+	if found {
+		cached = o.cache.get(key, position)
+	}
+	leaf := position.leaf
+	if !found || cached != nil {
+		var err error
+		if leaf, err = o.randomLeaf(); err != nil {
+			return err
+		}
+	}
+
+	// read the path into the stash, without the stale copies of remapped blocks
+	for level := 0; level <= o.height; level++ {
+		blocks, err := o.readNode(o.node(leaf, level))
+		if err != nil {
+			return err
+		}
+		for _, b := range blocks {
+			if o.live(b) {
+				o.meta.stash = append(o.meta.stash, b)
+			}
+		}
+	}
+
+	// take the block out of the stash
+	var block *oramBlock
+	stash := o.meta.stash[:0]
+	for _, b := range o.meta.stash {
+		if b.Key == key {
+			block = b
+		} else {
+			stash = append(stash, b)
+		}
+	}
+	o.meta.stash = stash
+
+	if cached != nil {
+		block = cached
+	} else if found && block != nil {
+		o.cache.put(block)
+	}
+
+	// remap the block to a new random leaf
+	if block = update(block); block != nil {
+		if !found && uint64(len(o.meta.positions)) >= o.capacity() {
+			return fmt.Errorf("ORAM is full, it holds at most %d entries", o.capacity())
+		}
+
+		newLeaf, err := o.randomLeaf()
+		if err != nil {
+			return err
+		}
+		newVersion, err := randomUint64()
+		if err != nil {
+			return err
+		}
+
+		block = &oramBlock{Key: block.Key, Leaf: newLeaf, Version: newVersion, Value: block.Value}
+		o.meta.positions[id] = oramPosition{leaf: newLeaf, version: newVersion}
+		o.meta.stash = append(o.meta.stash, block)
+	} else {
+		delete(o.meta.positions, id)
+	}
+
+	// write the path back, from the leaf to the root, with as many blocks from the stash as possible
+	for level := o.height; level >= 0; level-- {
+		node := o.node(leaf, level)
+
+		var blocks []*oramBlock
+		stash := o.meta.stash[:0]
+		for _, b := range o.meta.stash {
+			if len(blocks) < ORAMBucketSize && o.node(b.Leaf, level) == node {
+				blocks = append(blocks, b)
+			} else {
+				stash = append(stash, b)
+			}
+		}
+		o.meta.stash = stash
+
+		if err := o.writeNode(node, blocks); err != nil {
+			return err
+		}
+	}
+
+	if len(o.meta.stash) > ORAMStashSize {
+		return fmt.Errorf("ORAM stash overflow, consider a larger tree")
+	}
+
+	return o.storeMeta()
+}
+
+// live returns whether a block is at the position of its key, i.e., whether it is not a stale copy
+func (o *OramStubInterface) live(b *oramBlock) bool {
+	position, found := o.meta.positions[oramKeyID(b.Key)]
+	return found && position == oramPosition{leaf: b.Leaf, version: b.Version}
+}
+
+// node returns the index of the node at the given level on the path to a leaf; the root has index 1
+func (o *OramStubInterface) node(leaf uint64, level int) uint64 {
+	return ((uint64(1) << o.height) + leaf) >> (o.height - level)
+}
+
+// capacity returns the maximum number of entries, i.e., the number of leaves
+func (o *OramStubInterface) capacity() uint64 {
+	return uint64(1) << o.height
+}
+
+func (o *OramStubInterface) randomLeaf() (uint64, error) {
+	n, err := rand.Int(rand.Reader, new(big.Int).SetUint64(o.capacity()))
+	if err != nil {
+		return 0, err
+	}
+	return n.Uint64(), nil
+}
+
+func randomUint64() (uint64, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+func oramKeyID(key string) string {
+	id := sha256.Sum256([]byte(key))
+	return string(id[:])
+}
+
+func (o *OramStubInterface) loadMeta() error {
+	if o.meta != nil {
+		return nil
+	}
+
+	encValue, err := o.GetPublicState(ORAMMetaKey)
+	if err != nil {
+		return err
+	}
+
+	// return if the ORAM is not initialized yet
+	if len(encValue) == 0 {
+		o.meta = &oramMeta{positions: make(map[string]oramPosition)}
+		return nil
+	}
+
+	value, err := o.sep.DecryptState(ORAMMetaKey, encValue)
+	if err != nil {
+		return errors.Wrap(err, "cannot decrypt ORAM position map")
+	}
+
+	o.meta, err = o.decodeMeta(value)
+	return err
+}
+
+func (o *OramStubInterface) storeMeta() error {
+	encValue, err := o.sep.EncryptState(ORAMMetaKey, o.encodeMeta())
+	if err != nil {
+		return err
+	}
+
+	return o.PutPublicState(ORAMMetaKey, encValue)
+}
+
+// readNode returns the blocks of a node; the node is only read from the ledger, and thereby recorded in the rwset,
+// if it is not accessed before by this transaction
+func (o *OramStubInterface) readNode(node uint64) ([]*oramBlock, error) {
+	if blocks, found := o.nodes[node]; found {
+		return blocks, nil
+	}
+
+	encValue, err := o.GetPublicState(oramNodeKey(node))
+	if err != nil {
+		return nil, err
+	}
+
+	// nodes which are not written yet are empty
+	if len(encValue) == 0 {
+		return nil, nil
+	}
+
+	value, err := o.sep.DecryptState(oramNodeKey(node), encValue)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot decrypt ORAM node %d", node)
+	}
+
+	return o.decodeBucket(value)
+}
+
+func (o *OramStubInterface) writeNode(node uint64, blocks []*oramBlock) error {
+	o.nodes[node] = blocks
+
+	encValue, err := o.sep.EncryptState(oramNodeKey(node), o.encodeBucket(blocks))
+	if err != nil {
+		return err
+	}
+
+	return o.PutPublicState(oramNodeKey(node), encValue)
+}
+
+func oramNodeKey(node uint64) string {
+	return fmt.Sprintf("%s%d", ORAMNodeKeyPrefix, node)
+}
+
+// encodeBucket returns the fixed-size encoding of a bucket; unused blocks are zero
+func (o *OramStubInterface) encodeBucket(blocks []*oramBlock) []byte {
+	return o.encodeBlocks(blocks, ORAMBucketSize)
+}
+
+func (o *OramStubInterface) decodeBucket(buf []byte) ([]*oramBlock, error) {
+	if len(buf) != ORAMBucketSize*o.slotSize() {
+		return nil, fmt.Errorf("invalid ORAM bucket size %d, expected %d", len(buf), ORAMBucketSize*o.slotSize())
+	}
+	return o.decodeBlocks(buf, ORAMBucketSize)
+}
+
+// encodeMeta returns the fixed-size encoding of the position map and the stash: the number of positions (4), the
+// positions padded to the capacity, the number of stashed blocks (4) and the blocks padded to ORAMStashSize
+func (o *OramStubInterface) encodeMeta() []byte {
+	positionsSize := int(o.capacity()) * oramPositionSize
+	buf := make([]byte, 4+positionsSize+4+ORAMStashSize*o.slotSize())
+
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(o.meta.positions)))
+	entry := buf[4:]
+	for id, p := range o.meta.positions {
+		copy(entry[:sha256.Size], id)
+		binary.BigEndian.PutUint64(entry[sha256.Size:sha256.Size+8], p.leaf)
+		binary.BigEndian.PutUint64(entry[sha256.Size+8:oramPositionSize], p.version)
+		entry = entry[oramPositionSize:]
+	}
+
+	stash := buf[4+positionsSize:]
+	binary.BigEndian.PutUint32(stash[0:4], uint32(len(o.meta.stash)))
+	copy(stash[4:], o.encodeBlocks(o.meta.stash, ORAMStashSize))
+
+	return buf
+}
+
+func (o *OramStubInterface) decodeMeta(buf []byte) (*oramMeta, error) {
+	positionsSize := int(o.capacity()) * oramPositionSize
+	if len(buf) != 4+positionsSize+4+ORAMStashSize*o.slotSize() {
+		return nil, fmt.Errorf("invalid ORAM position map size %d, expected %d", len(buf), 4+positionsSize+4+ORAMStashSize*o.slotSize())
+	}
+
+	n := binary.BigEndian.Uint32(buf[0:4])
+	if uint64(n) > o.capacity() {
+		return nil, fmt.Errorf("invalid ORAM position map")
+	}
+	meta := &oramMeta{positions: make(map[string]oramPosition, n)}
+	entry := buf[4:]
+	for i := uint32(0); i < n; i++ {
+		meta.positions[string(entry[:sha256.Size])] = oramPosition{
+			leaf:    binary.BigEndian.Uint64(entry[sha256.Size : sha256.Size+8]),
+			version: binary.BigEndian.Uint64(entry[sha256.Size+8 : oramPositionSize]),
+		}
+		entry = entry[oramPositionSize:]
+	}
+
+	stash := buf[4+positionsSize:]
+	blocks, err := o.decodeBlocks(stash[4:], ORAMStashSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) != int(binary.BigEndian.Uint32(stash[0:4])) {
+		return nil, fmt.Errorf("invalid ORAM stash")
+	}
+	meta.stash = blocks
+
+	return meta, nil
+}
+
+func (o *OramStubInterface) slotSize() int {
+	return oramBlockHeaderSize + o.blockSize
+}
+
+// encodeBlocks returns the encoding of the given number of slots with the blocks in the first slots
+func (o *OramStubInterface) encodeBlocks(blocks []*oramBlock, slots int) []byte {
+	slotSize := o.slotSize()
+	buf := make([]byte, slots*slotSize)
+
+	for i, b := range blocks {
+		slot := buf[i*slotSize : (i+1)*slotSize]
+		slot[0] = 1
+		binary.BigEndian.PutUint64(slot[1:9], b.Leaf)
+		binary.BigEndian.PutUint64(slot[9:17], b.Version)
+		binary.BigEndian.PutUint16(slot[17:19], uint16(len(b.Key)))
+		binary.BigEndian.PutUint32(slot[19:23], uint32(len(b.Value)))
+		copy(slot[oramBlockHeaderSize:], b.Key)
+		copy(slot[oramBlockHeaderSize+len(b.Key):], b.Value)
+	}
+
+	return buf
+}
+
+func (o *OramStubInterface) decodeBlocks(buf []byte, slots int) ([]*oramBlock, error) {
+	slotSize := o.slotSize()
+
+	var blocks []*oramBlock
+	for i := 0; i < slots; i++ {
+		slot := buf[i*slotSize : (i+1)*slotSize]
+		switch slot[0] {
+		case 0:
+			continue
+		case 1:
+		default:
+			return nil, fmt.Errorf("invalid ORAM block")
+		}
+
+		keyLen := int(binary.BigEndian.Uint16(slot[17:19]))
+		valueLen := int(binary.BigEndian.Uint32(slot[19:23]))
+		leaf := binary.BigEndian.Uint64(slot[1:9])
+		if keyLen+valueLen > o.blockSize || leaf >= o.capacity() {
+			return nil, fmt.Errorf("invalid ORAM block")
+		}
+
+		data := slot[oramBlockHeaderSize:]
+		blocks = append(blocks, &oramBlock{
+			Key:     string(data[:keyLen]),
+			Leaf:    leaf,
+			Version: binary.BigEndian.Uint64(slot[9:17]),
+			Value:   append([]byte(nil), data[keyLen:keyLen+valueLen]...),
+		})
+	}
+
+	return blocks, nil
+}
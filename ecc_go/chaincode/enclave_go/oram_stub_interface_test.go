@@ -0,0 +1,273 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testOramHeight    = 3
+	testOramBlockSize = 32
+)
+
+func newTestOram(ledger testLedger, keys *ChaincodeKeys, cache *OramCache, rwset *readWriteSet) *OramStubInterface {
+	return NewOramStubInterface(newTestStub(ledger), &pb.ChaincodeInput{}, rwset, keys, cache, testOramHeight, testOramBlockSize)
+}
+
+// oramNodes returns the nodes among the given keys, by level
+func oramNodes(t *testing.T, keys []string) map[int]uint64 {
+	nodes := make(map[int]uint64)
+	for _, key := range keys {
+		if key == ORAMMetaKey {
+			continue
+		}
+		var node uint64
+		_, err := fmt.Sscanf(strings.TrimPrefix(key, ORAMNodeKeyPrefix), "%d", &node)
+		require.NoError(t, err)
+		level := 0
+		for n := node; n > 1; n >>= 1 {
+			level++
+		}
+		_, duplicate := nodes[level]
+		require.False(t, duplicate, "two nodes at level %d", level)
+		nodes[level] = node
+	}
+	return nodes
+}
+
+// checkOramInvariant checks that every live block is in the stash or on the path to its leaf
+func checkOramInvariant(t *testing.T, ledger testLedger, keys *ChaincodeKeys) {
+	o := newTestOram(ledger, keys, NewOramCache(), newTestRwSet())
+	require.NoError(t, o.loadMeta())
+	assert.LessOrEqual(t, len(o.meta.stash), ORAMStashSize)
+
+	live := len(o.meta.stash)
+	for node := uint64(1); node < uint64(2)<<testOramHeight; node++ {
+		blocks, err := o.readNode(node)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(blocks), ORAMBucketSize)
+
+		level := 0
+		for n := node; n > 1; n >>= 1 {
+			level++
+		}
+		for _, b := range blocks {
+			if o.live(b) {
+				assert.Equal(t, node, o.node(b.Leaf, level), "block %s is not on the path to its leaf", b.Key)
+				live++
+			}
+		}
+	}
+	assert.Equal(t, len(o.meta.positions), live)
+}
+
+func TestOramState(t *testing.T) {
+	ledger := testLedger{}
+	keys := newTestKeys(t)
+	cache := NewOramCache()
+
+	rwset := newTestRwSet()
+	o := newTestOram(ledger, keys, cache, rwset)
+	require.NoError(t, o.PutState("a", []byte("value a")))
+	require.NoError(t, o.PutState("b", []byte("value b")))
+
+	// reads return the writes of the same transaction
+	value, err := o.GetState("a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value a"), value)
+	ledger.commit(t, rwset)
+
+	rwset = newTestRwSet()
+	o = newTestOram(ledger, keys, cache, rwset)
+	value, err = o.GetState("a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value a"), value)
+	require.NoError(t, o.PutState("a", []byte("new value a")))
+	require.NoError(t, o.DelState("b"))
+	ledger.commit(t, rwset)
+
+	o = newTestOram(ledger, keys, cache, newTestRwSet())
+	value, err = o.GetState("a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new value a"), value)
+	value, err = o.GetState("b")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+	value, err = o.GetState("c")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	// the key and value must fit into a block
+	err = o.PutState("c", make([]byte, testOramBlockSize))
+	assert.EqualError(t, err, "key and value of 33 bytes exceed the ORAM block size of 32 bytes")
+
+	checkOramInvariant(t, ledger, keys)
+}
+
+func TestOramCapacity(t *testing.T) {
+	ledger := testLedger{}
+	keys := newTestKeys(t)
+	cache := NewOramCache()
+
+	// the sealed position map and stash have the same size, however many keys are stored
+	var metaSize int
+	for i := 0; i < 1<<testOramHeight; i++ {
+		rwset := newTestRwSet()
+		o := newTestOram(ledger, keys, cache, rwset)
+		require.NoError(t, o.PutState(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i))))
+		ledger.commit(t, rwset)
+
+		if i == 0 {
+			metaSize = len(ledger[ORAMMetaKey])
+		}
+		assert.Equal(t, metaSize, len(ledger[ORAMMetaKey]))
+		checkOramInvariant(t, ledger, keys)
+	}
+
+	// the blocks are evicted from the stash into the tree while they are accessed
+	for round := 0; round < 10; round++ {
+		rwset := newTestRwSet()
+		o := newTestOram(ledger, keys, cache, rwset)
+		for i := 0; i < 1<<testOramHeight; i++ {
+			value, err := o.GetState(fmt.Sprintf("key%d", i))
+			require.NoError(t, err)
+			assert.Equal(t, []byte(fmt.Sprintf("value%d", i)), value)
+		}
+		ledger.commit(t, rwset)
+		checkOramInvariant(t, ledger, keys)
+	}
+
+	// the position map is full, but existing keys can still be updated and deleted
+	rwset := newTestRwSet()
+	o := newTestOram(ledger, keys, cache, rwset)
+	err := o.PutState("other", []byte("value"))
+	assert.EqualError(t, err, "ORAM is full, it holds at most 8 entries")
+
+	o = newTestOram(ledger, keys, cache, rwset)
+	require.NoError(t, o.PutState("key0", []byte("new value")))
+	require.NoError(t, o.DelState("key1"))
+	require.NoError(t, o.PutState("other", []byte("value")))
+	ledger.commit(t, rwset)
+	checkOramInvariant(t, ledger, keys)
+}
+
+func TestOramAccessPattern(t *testing.T) {
+	ledger := testLedger{}
+	keys := newTestKeys(t)
+	cache := NewOramCache()
+
+	rwset := newTestRwSet()
+	require.NoError(t, newTestOram(ledger, keys, cache, rwset).PutState("a", []byte("value a")))
+	ledger.commit(t, rwset)
+
+	// every access reads and writes the nodes on a path from the root to a leaf and the position map,
+	// whether it reads, writes or deletes, and whether the key exists
+	accesses := map[string]func(o *OramStubInterface) error{
+		"get":         func(o *OramStubInterface) error { _, err := o.GetState("a"); return err },
+		"get missing": func(o *OramStubInterface) error { _, err := o.GetState("b"); return err },
+		"put":         func(o *OramStubInterface) error { return o.PutState("a", []byte("new value a")) },
+		"put new":     func(o *OramStubInterface) error { return o.PutState("b", []byte("value b")) },
+		"del":         func(o *OramStubInterface) error { return o.DelState("a") },
+	}
+	for name, access := range accesses {
+		rwset := newTestRwSet()
+		require.NoError(t, access(newTestOram(ledger, keys, cache, rwset)), name)
+
+		reads, writes := rwsetKeys(rwset)
+		sort.Strings(reads)
+		sort.Strings(writes)
+		assert.Len(t, reads, testOramHeight+2, name)
+		assert.Contains(t, reads, ORAMMetaKey, name)
+		assert.Equal(t, reads, writes, name)
+
+		nodes := oramNodes(t, reads)
+		require.Len(t, nodes, testOramHeight+1, name)
+		assert.Equal(t, uint64(1), nodes[0], name)
+		for level := 1; level <= testOramHeight; level++ {
+			assert.Equal(t, nodes[level-1], nodes[level]>>1, "%s: nodes are not on a path", name)
+		}
+	}
+}
+
+func TestOramRepeatedAccess(t *testing.T) {
+	ledger := testLedger{}
+	keys := newTestKeys(t)
+
+	rwset := newTestRwSet()
+	require.NoError(t, newTestOram(ledger, keys, NewOramCache(), rwset).PutState("a", []byte("value a")))
+	ledger.commit(t, rwset)
+
+	// leaves returns the leaves read by repeated queries of a committed key
+	leaves := func(cache func() *OramCache) map[uint64]bool {
+		leaves := make(map[uint64]bool)
+		for i := 0; i < 20; i++ {
+			rwset := newTestRwSet()
+			value, err := newTestOram(ledger, keys, cache(), rwset).GetState("a")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("value a"), value)
+
+			reads, _ := rwsetKeys(rwset)
+			leaves[oramNodes(t, reads)[testOramHeight]] = true
+		}
+		return leaves
+	}
+
+	// without the cache, the committed path is read by every query
+	assert.Len(t, leaves(NewOramCache), 1)
+
+	// with the cache, only the first query reads the committed path; the others read random paths
+	cache := NewOramCache()
+	assert.Greater(t, len(leaves(func() *OramCache { return cache })), 1)
+
+	// once another transaction is committed, the cached block is replaced
+	rwset = newTestRwSet()
+	require.NoError(t, newTestOram(ledger, keys, cache, rwset).PutState("a", []byte("new value a")))
+	ledger.commit(t, rwset)
+
+	value, err := newTestOram(ledger, keys, cache, newTestRwSet()).GetState("a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new value a"), value)
+	checkOramInvariant(t, ledger, keys)
+}
+
+func TestOramDecodeBucket(t *testing.T) {
+	o := newTestOram(testLedger{}, newTestKeys(t), NewOramCache(), newTestRwSet())
+	blocks := []*oramBlock{
+		{Key: "a", Leaf: 1, Version: 2, Value: []byte("value a")},
+		{Key: "b", Leaf: 7, Version: 3},
+	}
+
+	buf := o.encodeBucket(blocks)
+	assert.Len(t, buf, ORAMBucketSize*(oramBlockHeaderSize+testOramBlockSize))
+	decoded, err := o.decodeBucket(buf)
+	require.NoError(t, err)
+	assert.Equal(t, blocks, decoded)
+
+	_, err = o.decodeBucket(buf[1:])
+	assert.EqualError(t, err, fmt.Sprintf("invalid ORAM bucket size %d, expected %d", len(buf)-1, len(buf)))
+
+	malformed := map[string]func(slot []byte){
+		"used flag":    func(slot []byte) { slot[0] = 2 },
+		"leaf":         func(slot []byte) { slot[8] = 1 << testOramHeight },
+		"key length":   func(slot []byte) { slot[17], slot[18] = 0, testOramBlockSize+1 },
+		"value length": func(slot []byte) { slot[21], slot[22] = 0, testOramBlockSize },
+	}
+	for name, malform := range malformed {
+		buf := o.encodeBucket(blocks)
+		malform(buf)
+		_, err := o.decodeBucket(buf)
+		assert.EqualError(t, err, "invalid ORAM block", name)
+	}
+}
@@ -7,14 +7,16 @@ SPDX-License-Identifier: Apache-2.0
 package enclave_go
 
 import (
+	"sort"
 	"sync"
 
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
 	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
 )
 
 type ReadWriteSet interface {
-	AddRead(key string, hash []byte)
+	AddRead(key string, value []byte) error
 	AddWrite(key string, value []byte)
 	AddDelete(key string)
 	ToFPCKVSet() *protos.FPCKVSet
@@ -30,19 +32,30 @@ type write struct {
 }
 
 type readWriteSet struct {
-	mu     sync.Mutex
-	reads  map[string]read
-	writes map[string]write
+	mu            sync.Mutex
+	reads         map[string]read
+	writes        map[string]write
+	csp           crypto.CSP
+	hashAlgorithm protos.HashAlgorithm
 }
 
-func NewReadWriteSet() *readWriteSet {
+// NewReadWriteSet returns an empty rwset, whose read value hashes are computed with the given hash algorithm
+func NewReadWriteSet(csp crypto.CSP, hashAlgorithm protos.HashAlgorithm) *readWriteSet {
 	return &readWriteSet{
-		reads:  make(map[string]read),
-		writes: make(map[string]write),
+		reads:         make(map[string]read),
+		writes:        make(map[string]write),
+		csp:           csp,
+		hashAlgorithm: hashAlgorithm,
 	}
 }
 
-func (rwset *readWriteSet) AddRead(key string, hash []byte) {
+// AddRead adds a read of a key and the hash of the value read from the ledger
+func (rwset *readWriteSet) AddRead(key string, value []byte) error {
+	hash, err := rwset.csp.Hash(rwset.hashAlgorithm, value)
+	if err != nil {
+		return err
+	}
+
 	rwset.mu.Lock()
 	defer rwset.mu.Unlock()
 	rwset.reads[key] = read{
@@ -52,6 +65,7 @@ func (rwset *readWriteSet) AddRead(key string, hash []byte) {
 		},
 		hash: hash,
 	}
+	return nil
 }
 
 func (rwset *readWriteSet) AddWrite(key string, value []byte) {
@@ -77,6 +91,7 @@ func (rwset *readWriteSet) AddDelete(key string) {
 	}
 }
 
+// ToFPCKVSet returns the reads and writes sorted by key, so the serialization of the rwset is canonical
 func (rwset *readWriteSet) ToFPCKVSet() *protos.FPCKVSet {
 	rwset.mu.Lock()
 	defer rwset.mu.Unlock()
@@ -86,18 +101,29 @@ func (rwset *readWriteSet) ToFPCKVSet() *protos.FPCKVSet {
 			Writes: []*kvrwset.KVWrite{},
 		},
 		ReadValueHashes: [][]byte{},
+		HashAlgorithm:   rwset.hashAlgorithm,
 	}
 
 	// fill with reads
-	for _, read := range rwset.reads {
+	for _, key := range sortedKeys(rwset.reads) {
+		read := rwset.reads[key]
 		fpcKVSet.RwSet.Reads = append(fpcKVSet.RwSet.Reads, read.kvread)
 		fpcKVSet.ReadValueHashes = append(fpcKVSet.ReadValueHashes, read.hash)
 	}
 
 	// fill with writes
-	for _, write := range rwset.writes {
-		fpcKVSet.RwSet.Writes = append(fpcKVSet.RwSet.Writes, write.kvwrite)
+	for _, key := range sortedKeys(rwset.writes) {
+		fpcKVSet.RwSet.Writes = append(fpcKVSet.RwSet.Writes, rwset.writes[key].kvwrite)
 	}
 
 	return fpcKVSet
 }
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
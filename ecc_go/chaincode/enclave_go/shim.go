@@ -93,22 +93,34 @@ func (f *FpcStubInterface) GetState(key string) ([]byte, error) {
 		return nil, nil
 	}
 
-	return f.sep.DecryptState(encValue)
+	return f.sep.DecryptState(key, encValue)
 }
 
 func (f *FpcStubInterface) GetPublicState(key string) ([]byte, error) {
-	value, err := f.stub.GetState(key)
+	// check if composite key, if so, derive Fabric key
+	fabricKey := key
+	if utils.IsFPCCompositeKey(key) {
+		comp := utils.SplitFPCCompositeKey(key)
+		var err error
+		if fabricKey, err = f.stub.CreateCompositeKey(comp[0], comp[1:]); err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := f.stub.GetState(fabricKey)
 	if err != nil {
 		return nil, err
 	}
 
-	f.rwset.AddRead(key, hash(value))
+	if err := f.rwset.AddRead(key, value); err != nil {
+		return nil, err
+	}
 
 	return value, nil
 }
 
 func (f *FpcStubInterface) PutState(key string, value []byte) error {
-	encValue, err := f.sep.EncryptState(value)
+	encValue, err := f.sep.EncryptState(key, value)
 	if err != nil {
 		return err
 	}
@@ -153,7 +165,12 @@ func (f *FpcStubInterface) GetStateByPartialCompositeKey(objectType string, keys
 		return nil, err
 	}
 
-	return newFpcIterator(iterator, f.rwset.AddRead, f.sep.DecryptState), nil
+	return newFpcIterator(iterator, f.rwset.AddRead, f.decryptEntry), nil
+}
+
+func (f *FpcStubInterface) decryptEntry(ledgerKey string, ciphertext []byte) (string, []byte, error) {
+	plaintext, err := f.sep.DecryptState(ledgerKey, ciphertext)
+	return ledgerKey, plaintext, err
 }
 
 func (f *FpcStubInterface) GetPublicStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
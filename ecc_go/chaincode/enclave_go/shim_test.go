@@ -0,0 +1,113 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-private-chaincode/ecc/chaincode/fakes"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/require"
+)
+
+// testLedger is the committed state the fake stub reads from
+type testLedger map[string][]byte
+
+// commit applies the writes of an rwset to the ledger as the peer does after validation
+func (l testLedger) commit(t *testing.T, rwset *readWriteSet) {
+	for _, w := range rwset.ToFPCKVSet().GetRwSet().GetWrites() {
+		// FPC composite keys are stored as Fabric composite keys
+		key := w.GetKey()
+		if utils.IsFPCCompositeKey(key) {
+			comp := utils.SplitFPCCompositeKey(key)
+			var err error
+			key, err = shim.CreateCompositeKey(comp[0], comp[1:])
+			require.NoError(t, err)
+		}
+		if w.GetIsDelete() {
+			delete(l, key)
+		} else {
+			l[key] = w.GetValue()
+		}
+	}
+}
+
+// newTestStub returns a fake stub reading the given ledger
+func newTestStub(ledger testLedger) *fakes.ChaincodeStub {
+	stub := &fakes.ChaincodeStub{}
+	stub.GetStateCalls(func(key string) ([]byte, error) {
+		return ledger[key], nil
+	})
+	stub.CreateCompositeKeyCalls(shim.CreateCompositeKey)
+	stub.GetStateByPartialCompositeKeyCalls(func(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+		prefix, err := shim.CreateCompositeKey(objectType, attributes)
+		if err != nil {
+			return nil, err
+		}
+		var keys []string
+		for key := range ledger {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		results := make([]*queryresult.KV, len(keys))
+		for i, key := range keys {
+			results[i] = &queryresult.KV{Key: key, Value: ledger[key]}
+		}
+		return &testIterator{results: results}, nil
+	})
+	return stub
+}
+
+type testIterator struct {
+	results []*queryresult.KV
+}
+
+func (i *testIterator) HasNext() bool {
+	return len(i.results) > 0
+}
+
+func (i *testIterator) Close() error {
+	return nil
+}
+
+func (i *testIterator) Next() (*queryresult.KV, error) {
+	next := i.results[0]
+	i.results = i.results[1:]
+	return next, nil
+}
+
+// newTestKeys returns chaincode keys with a fresh state key; the chaincode encryption keys are not needed
+func newTestKeys(t *testing.T) *ChaincodeKeys {
+	csp := crypto.GetDefaultCSP()
+	stateKey, err := csp.NewSymmetricKey()
+	require.NoError(t, err)
+	return &ChaincodeKeys{csp: csp, stateKey: stateKey, channelId: "mychannel", chaincodeId: "mycc"}
+}
+
+func newTestRwSet() *readWriteSet {
+	return NewReadWriteSet(crypto.GetDefaultCSP(), protos.HashAlgorithm_HASH_ALGORITHM_SHA256)
+}
+
+// rwsetKeys returns the keys read and written by an rwset
+func rwsetKeys(rwset *readWriteSet) (reads []string, writes []string) {
+	fpcKVSet := rwset.ToFPCKVSet()
+	for _, r := range fpcKVSet.GetRwSet().GetReads() {
+		reads = append(reads, r.GetKey())
+	}
+	for _, w := range fpcKVSet.GetRwSet().GetWrites() {
+		writes = append(writes, w.GetKey())
+	}
+	return reads, writes
+}
@@ -7,14 +7,43 @@ SPDX-License-Identifier: Apache-2.0
 package enclave_go
 
 import (
+	"fmt"
+
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 )
 
+// NewSkvsStub returns an enclave which keeps the chaincode state in a single encrypted bucket (see SkvsStubInterface)
 func NewSkvsStub(cc shim.Chaincode) *EnclaveStub {
 	enclaveStub := NewEnclaveStub(cc)
-	enclaveStub.stubProvider = func(stub shim.ChaincodeStubInterface, input *pb.ChaincodeInput, rwset *readWriteSet, sep StateEncryptionFunctions) shim.ChaincodeStubInterface {
+	enclaveStub.EnableSkvs()
+	return enclaveStub
+}
+
+// NewShardedSkvsStub returns an enclave which keeps the chaincode state in the given number of encrypted buckets
+// (see SkvsStubInterface)
+func NewShardedSkvsStub(cc shim.Chaincode, shards int) *EnclaveStub {
+	enclaveStub := NewEnclaveStub(cc)
+	enclaveStub.EnableShardedSkvs(shards)
+	return enclaveStub
+}
+
+// EnableSkvs lets the enclave keep the chaincode state in a single encrypted bucket (see SkvsStubInterface)
+func (e *EnclaveStub) EnableSkvs() {
+	e.stubProvider = func(stub shim.ChaincodeStubInterface, input *pb.ChaincodeInput, rwset *readWriteSet, sep StateEncryptionFunctions) shim.ChaincodeStubInterface {
 		return NewSkvsStubInterface(stub, input, rwset, sep)
 	}
-	return enclaveStub
+}
+
+// EnableShardedSkvs lets the enclave keep the chaincode state in the given number of encrypted buckets
+// (see SkvsStubInterface)
+func (e *EnclaveStub) EnableShardedSkvs(shards int) {
+	e.stubProvider = func(stub shim.ChaincodeStubInterface, input *pb.ChaincodeInput, rwset *readWriteSet, sep StateEncryptionFunctions) shim.ChaincodeStubInterface {
+		// the bucket key is derived on every invocation as the state key may be imported after initialization
+		bucketKey, err := e.ccKeys.BucketKey()
+		if err != nil {
+			panic(fmt.Sprintf("Deriving SKVS bucket key failed, err: %v", err))
+		}
+		return NewShardedSkvsStubInterface(stub, input, rwset, sep, e.csp, bucketKey, shards)
+	}
 }
@@ -7,63 +7,132 @@ SPDX-License-Identifier: Apache-2.0
 package enclave_go
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 )
 
 const SKVSKey = "SKVS"
 
+// SkvsStubInterface keeps the chaincode state in encrypted buckets to hide which keys a transaction accesses.
+// Keys are assigned to buckets by their HMAC under a key derived from the state key, so the peer cannot tell
+// which bucket holds a given key. With a single bucket, stored under SKVSKey, the access pattern is
+// hidden entirely but every transaction re-encrypts the whole state and conflicts with every other transaction.
+// With more buckets, only the access pattern within a bucket is hidden, but a transaction only re-encrypts the
+// buckets it writes and only conflicts with transactions accessing the same buckets.
 type SkvsStubInterface struct {
 	*FpcStubInterface
-	allDataOld map[string][]byte
-	allDataNew map[string][]byte
-	key        string
+	csp       crypto.CSP
+	bucketKey []byte
+	buckets   []*skvsBucket
+}
+
+type skvsBucket struct {
+	key     string
+	loaded  bool
+	dataOld map[string][]byte
+	dataNew map[string][]byte
 }
 
 func NewSkvsStubInterface(stub shim.ChaincodeStubInterface, input *pb.ChaincodeInput, rwset *readWriteSet, sep StateEncryptionFunctions) *SkvsStubInterface {
+	// a single bucket holds all keys, thus, no bucket key is needed
+	return NewShardedSkvsStubInterface(stub, input, rwset, sep, nil, nil, 1)
+}
+
+// NewShardedSkvsStubInterface returns a SKVS stub with the given number of buckets, to which keys are assigned with
+// the given bucket key. Note that the number of buckets must not change during the lifetime of a chaincode as the
+// keys would be assigned to different buckets.
+func NewShardedSkvsStubInterface(stub shim.ChaincodeStubInterface, input *pb.ChaincodeInput, rwset *readWriteSet, sep StateEncryptionFunctions, csp crypto.CSP, bucketKey []byte, shards int) *SkvsStubInterface {
+	if shards < 1 {
+		shards = 1
+	}
+
 	fpcStub := NewFpcStubInterface(stub, input, rwset, sep)
 	skvsStub := &SkvsStubInterface{
 		FpcStubInterface: fpcStub,
-		allDataOld:       make(map[string][]byte),
-		allDataNew:       make(map[string][]byte),
-		key:              SKVSKey,
+		csp:              csp,
+		bucketKey:        bucketKey,
+		buckets:          make([]*skvsBucket, shards),
 	}
-	err := skvsStub.initSKVS()
-	if err != nil {
-		panic(fmt.Sprintf("Initializing SKVS failed, err: %v", err))
+	for i := range skvsStub.buckets {
+		skvsStub.buckets[i] = &skvsBucket{
+			key:     bucketLedgerKey(i, shards),
+			dataOld: make(map[string][]byte),
+			dataNew: make(map[string][]byte),
+		}
+	}
+
+	// a single bucket is read by every transaction, regardless of the keys accessed
+	if shards == 1 {
+		if err := skvsStub.loadBucket(skvsStub.buckets[0]); err != nil {
+			panic(fmt.Sprintf("Initializing SKVS failed, err: %v", err))
+		}
 	}
 	return skvsStub
 }
 
-func (s *SkvsStubInterface) initSKVS() error {
+func bucketLedgerKey(index, shards int) string {
+	if shards == 1 {
+		return SKVSKey
+	}
+	return fmt.Sprintf("%s_%d", SKVSKey, index)
+}
+
+// bucket returns the bucket of a key; it is loaded from the ledger, and thereby recorded in the rwset,
+// when first accessed. A bucket must also be loaded before writing it as it contains other keys.
+func (s *SkvsStubInterface) bucket(key string) (*skvsBucket, error) {
+	b := s.buckets[0]
+	if len(s.buckets) > 1 {
+		if len(s.bucketKey) == 0 {
+			return nil, fmt.Errorf("SKVS bucket key is missing")
+		}
+		mac, err := s.csp.HMAC(protos.HashAlgorithm_HASH_ALGORITHM_SHA256, s.bucketKey, []byte(key))
+		if err != nil {
+			return nil, err
+		}
+		b = s.buckets[binary.BigEndian.Uint64(mac[:8])%uint64(len(s.buckets))]
+	}
+	if err := s.loadBucket(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
 
-	// get current state, this will only operate once
-	encValue, err := s.GetPublicState(s.key)
+func (s *SkvsStubInterface) loadBucket(b *skvsBucket) error {
+	if b.loaded {
+		return nil
+	}
+
+	// get current state, this will only operate once per bucket
+	encValue, err := s.GetPublicState(b.key)
 	if err != nil {
 		return err
 	}
+	b.loaded = true
 
 	// return if the key initially does not exist
 	if len(encValue) == 0 {
-		logger.Warningf("SKVS is empty, Initiating.")
+		logger.Warningf("SKVS bucket %s is empty, Initiating.", b.key)
 		return nil
 	}
 
-	value, err := s.sep.DecryptState(encValue)
+	value, err := s.sep.DecryptState(b.key, encValue)
 	if err != nil {
 		return err
 	}
-	logger.Debug("SKVS has default value, loading current value.")
+	logger.Debugf("SKVS bucket %s has default value, loading current value.", b.key)
 
-	err = json.Unmarshal(value, &s.allDataOld)
+	err = json.Unmarshal(value, &b.dataOld)
 	if err != nil {
 		logger.Errorf("SKVS Json unmarshal error: %s", err)
 		return err
 	}
-	err = json.Unmarshal(value, &s.allDataNew)
+	err = json.Unmarshal(value, &b.dataNew)
 	if err != nil {
 		logger.Errorf("SKVS Json unmarshal error: %s", err)
 		return err
@@ -71,8 +140,27 @@ func (s *SkvsStubInterface) initSKVS() error {
 	return nil
 }
 
+// storeBucket re-encrypts the bucket and writes it
+func (s *SkvsStubInterface) storeBucket(b *skvsBucket) error {
+	byteAllData, err := json.Marshal(b.dataNew)
+	if err != nil {
+		return err
+	}
+	encValue, err := s.sep.EncryptState(b.key, byteAllData)
+	if err != nil {
+		return err
+	}
+
+	return s.PutPublicState(b.key, encValue)
+}
+
 func (s *SkvsStubInterface) GetState(key string) ([]byte, error) {
-	value, found := s.allDataOld[key]
+	b, err := s.bucket(key)
+	if err != nil {
+		return nil, err
+	}
+
+	value, found := b.dataOld[key]
 	if !found {
 		logger.Errorf("skvs allDataOld key: %s, not found", key)
 		return nil, nil
@@ -81,31 +169,23 @@ func (s *SkvsStubInterface) GetState(key string) ([]byte, error) {
 }
 
 func (s *SkvsStubInterface) PutState(key string, value []byte) error {
-
-	s.allDataNew[key] = value
-	byteAllData, err := json.Marshal(s.allDataNew)
-	if err != nil {
-		return err
-	}
-	encValue, err := s.sep.EncryptState(byteAllData)
+	b, err := s.bucket(key)
 	if err != nil {
 		return err
 	}
 
-	return s.PutPublicState(s.key, encValue)
+	b.dataNew[key] = value
+	return s.storeBucket(b)
 }
 
 func (s *SkvsStubInterface) DelState(key string) error {
-	delete(s.allDataNew, key)
-	byteAllData, err := json.Marshal(s.allDataNew)
+	b, err := s.bucket(key)
 	if err != nil {
 		return err
 	}
-	encValue, err := s.sep.EncryptState(byteAllData)
-	if err != nil {
-		return err
-	}
-	return s.PutPublicState(s.key, encValue)
+
+	delete(b.dataNew, key)
+	return s.storeBucket(b)
 }
 
 func (s *SkvsStubInterface) GetStateByRange(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
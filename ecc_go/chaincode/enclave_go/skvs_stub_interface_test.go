@@ -0,0 +1,149 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkvsSingleBucket(t *testing.T) {
+	ledger := testLedger{}
+	keys := newTestKeys(t)
+
+	// the bucket is read by every transaction
+	rwset := newTestRwSet()
+	s := NewSkvsStubInterface(newTestStub(ledger), &pb.ChaincodeInput{}, rwset, keys)
+	reads, writes := rwsetKeys(rwset)
+	assert.Equal(t, []string{SKVSKey}, reads)
+	assert.Empty(t, writes)
+
+	require.NoError(t, s.PutState("a", []byte("value a")))
+	require.NoError(t, s.PutState("b", []byte("value b")))
+	reads, writes = rwsetKeys(rwset)
+	assert.Equal(t, []string{SKVSKey}, reads)
+	assert.Equal(t, []string{SKVSKey}, writes)
+	ledger.commit(t, rwset)
+
+	rwset = newTestRwSet()
+	s = NewSkvsStubInterface(newTestStub(ledger), &pb.ChaincodeInput{}, rwset, keys)
+	value, err := s.GetState("a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value a"), value)
+	require.NoError(t, s.DelState("b"))
+	ledger.commit(t, rwset)
+
+	s = NewSkvsStubInterface(newTestStub(ledger), &pb.ChaincodeInput{}, newTestRwSet(), keys)
+	value, err = s.GetState("b")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+func TestSkvsShardedBuckets(t *testing.T) {
+	const shards = 4
+	ledger := testLedger{}
+	keys := newTestKeys(t)
+	bucketKey, err := keys.BucketKey()
+	require.NoError(t, err)
+
+	newStub := func(rwset *readWriteSet) *SkvsStubInterface {
+		return NewShardedSkvsStubInterface(newTestStub(ledger), &pb.ChaincodeInput{}, rwset, keys, crypto.GetDefaultCSP(), bucketKey, shards)
+	}
+
+	// nothing is read before a key is accessed
+	rwset := newTestRwSet()
+	s := newStub(rwset)
+	reads, writes := rwsetKeys(rwset)
+	assert.Empty(t, reads)
+	assert.Empty(t, writes)
+
+	// a write reads and writes exactly the bucket of the key
+	require.NoError(t, s.PutState("a", []byte("value a")))
+	b, err := s.bucket("a")
+	require.NoError(t, err)
+	reads, writes = rwsetKeys(rwset)
+	assert.Equal(t, []string{b.key}, reads)
+	assert.Equal(t, []string{b.key}, writes)
+	ledger.commit(t, rwset)
+	assert.Contains(t, ledger, b.key)
+
+	// a read only reads the bucket of the key
+	rwset = newTestRwSet()
+	s = newStub(rwset)
+	value, err := s.GetState("a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value a"), value)
+	reads, writes = rwsetKeys(rwset)
+	assert.Equal(t, []string{b.key}, reads)
+	assert.Empty(t, writes)
+
+	// keys are spread over the buckets
+	rwset = newTestRwSet()
+	s = newStub(rwset)
+	for i := 0; i < 32; i++ {
+		require.NoError(t, s.PutState(fmt.Sprintf("key%d", i), []byte("value")))
+	}
+	reads, writes = rwsetKeys(rwset)
+	assert.Len(t, reads, shards)
+	assert.Len(t, writes, shards)
+	ledger.commit(t, rwset)
+
+	rwset = newTestRwSet()
+	s = newStub(rwset)
+	for i := 0; i < 32; i++ {
+		value, err := s.GetState(fmt.Sprintf("key%d", i))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("value"), value)
+	}
+	require.NoError(t, s.DelState("key0"))
+	ledger.commit(t, rwset)
+
+	// as with Fabric, deletions become visible once committed
+	s = newStub(newTestRwSet())
+	value, err = s.GetState("key0")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+	value, err = s.GetState("key1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+}
+
+func TestSkvsBucketAssignment(t *testing.T) {
+	const shards = 16
+	keys := newTestKeys(t)
+	bucketKey, err := keys.BucketKey()
+	require.NoError(t, err)
+
+	bucketOf := func(bucketKey []byte, key string) string {
+		s := NewShardedSkvsStubInterface(newTestStub(testLedger{}), &pb.ChaincodeInput{}, newTestRwSet(), keys, crypto.GetDefaultCSP(), bucketKey, shards)
+		b, err := s.bucket(key)
+		require.NoError(t, err)
+		return b.key
+	}
+
+	// the assignment depends on the bucket key, i.e., the peer cannot compute it
+	otherKeys := newTestKeys(t)
+	otherBucketKey, err := otherKeys.BucketKey()
+	require.NoError(t, err)
+	differs := false
+	for i := 0; i < 16 && !differs; i++ {
+		key := fmt.Sprintf("key%d", i)
+		assert.Equal(t, bucketOf(bucketKey, key), bucketOf(bucketKey, key))
+		differs = bucketOf(bucketKey, key) != bucketOf(otherBucketKey, key)
+	}
+	assert.True(t, differs)
+
+	// the bucket key is required with more than one bucket
+	s := NewShardedSkvsStubInterface(newTestStub(testLedger{}), &pb.ChaincodeInput{}, newTestRwSet(), keys, crypto.GetDefaultCSP(), nil, shards)
+	_, err = s.GetState("a")
+	assert.EqualError(t, err, "SKVS bucket key is missing")
+}
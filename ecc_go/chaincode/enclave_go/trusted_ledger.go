@@ -0,0 +1,199 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/session"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// erccNamespace is the namespace of the enclave registry on the ledger
+const erccNamespace = "ercc"
+
+// trustedLedger sends a serialized trusted ledger request (see trusted_ledger.proto) to TLCC and returns the
+// serialized response
+type trustedLedger interface {
+	Request(request []byte) (response []byte, err error)
+}
+
+// trustedLedgerSession sends the requests of the enclave through a session with TLCC (see internal/session). The
+// session authenticates TLCC by its attested credentials and protects the requests and responses, which are not
+// signed, against the peer. The session is established with the first request and re-established if a request
+// fails, e.g., as TLCC expired the session.
+type trustedLedgerSession struct {
+	mutex     sync.Mutex
+	csp       crypto.CSP
+	identity  *session.Identity
+	verify    session.CredentialsVerifier
+	transport session.Transport
+	ccParams  *protos.CCParameters
+	enclaveId string
+	client    *session.Client
+}
+
+func (t *trustedLedgerSession) Request(request []byte) ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.client != nil {
+		response, err := t.client.Request(request)
+		if err == nil {
+			return response, nil
+		}
+		logger.Debugf("trusted ledger request failed, re-establishing the session: %s", err)
+		t.client = nil
+	}
+
+	client, err := t.dial()
+	if err != nil {
+		return nil, err
+	}
+	t.client = client
+	return client.Request(request)
+}
+
+func (t *trustedLedgerSession) dial() (*session.Client, error) {
+	client, err := session.Dial(t.csp, t.identity, t.verify, t.transport, t.ccParams.GetChannelId(), t.ccParams.GetChaincodeId(), t.enclaveId)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot establish trusted ledger session")
+	}
+
+	// TLCC maintains the ledger of a single channel
+	if channelId := client.Session().Peer().GetCcParams().GetChannelId(); channelId != t.ccParams.GetChannelId() {
+		_ = client.Close()
+		return nil, fmt.Errorf("trusted ledger is attested for channel %s", channelId)
+	}
+	return client, nil
+}
+
+// trustedLedgerStub checks every value returned by the peer against the value hash of the trusted ledger,
+// so that the peer can neither forge state nor present stale state to the enclave
+type trustedLedgerStub struct {
+	shim.ChaincodeStubInterface
+	csp       crypto.CSP
+	tl        trustedLedger
+	namespace string
+	txContext []byte
+}
+
+func newTrustedLedgerStub(stub shim.ChaincodeStubInterface, csp crypto.CSP, tl trustedLedger, namespace string) (*trustedLedgerStub, error) {
+	// all requests of an invocation share the tx context, thus, TLCC answers them from the same ledger view
+	txContext := make([]byte, 16)
+	if _, err := rand.Read(txContext); err != nil {
+		return nil, err
+	}
+
+	return &trustedLedgerStub{
+		ChaincodeStubInterface: stub,
+		csp:                    csp,
+		tl:                     tl,
+		namespace:              namespace,
+		txContext:              txContext,
+	}, nil
+}
+
+func (t *trustedLedgerStub) GetState(key string) ([]byte, error) {
+	value, err := t.ChaincodeStubInterface.GetState(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.verify(key, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetStateByPartialCompositeKey checks every returned value; note that TLCC cannot tell whether the peer
+// omits keys from the result
+func (t *trustedLedgerStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	iterator, err := t.ChaincodeStubInterface.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &trustedLedgerIterator{StateQueryIteratorInterface: iterator, stub: t}, nil
+}
+
+func (t *trustedLedgerStub) verify(key string, value []byte) error {
+	return verifyState(t.csp, t.tl, t.txContext, t.namespace, key, value)
+}
+
+// verifyState checks a value returned by the peer against the value hash of the trusted ledger
+func verifyState(csp crypto.CSP, tl trustedLedger, txContext []byte, namespace, key string, value []byte) error {
+	response, err := requestTrustedLedger(tl, &protos.Request{
+		TxContext: txContext,
+		Request: &protos.Request_Metadata{Metadata: &protos.GetMetadataRequest{
+			Namespace: namespace,
+			Key:       key,
+		}},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "trusted ledger request for key %s failed", key)
+	}
+	metadata := response.GetMetadata()
+	if metadata == nil {
+		return fmt.Errorf("invalid trusted ledger response")
+	}
+
+	// the trusted ledger reports an all-zero hash for absent keys
+	expected := make([]byte, len(metadata.GetHash()))
+	if len(value) > 0 {
+		if expected, err = csp.Hash(crypto.DefaultHashAlgorithm, value); err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(expected, metadata.GetHash()) {
+		return fmt.Errorf("state of key %s does not match the trusted ledger", key)
+	}
+	return nil
+}
+
+// requestTrustedLedger sends a request to the trusted ledger and returns its response
+func requestTrustedLedger(tl trustedLedger, request *protos.Request) (*protos.Response, error) {
+	requestBytes, err := proto.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBytes, err := tl.Request(requestBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &protos.Response{}
+	if err := proto.Unmarshal(responseBytes, response); err != nil {
+		return nil, errors.Wrap(err, "invalid trusted ledger response")
+	}
+	return response, nil
+}
+
+type trustedLedgerIterator struct {
+	shim.StateQueryIteratorInterface
+	stub *trustedLedgerStub
+}
+
+func (i *trustedLedgerIterator) Next() (*queryresult.KV, error) {
+	kv, err := i.StateQueryIteratorInterface.Next()
+	if err != nil || kv == nil {
+		return kv, err
+	}
+
+	if err := i.stub.verify(kv.GetKey(), kv.GetValue()); err != nil {
+		return nil, err
+	}
+	return kv, nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/session"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const testChannelId = "mychannel"
+
+// newTestSessionIdentity returns a session identity for an enclave of a chaincode, without attestation evidence
+func newTestSessionIdentity(t *testing.T, csp crypto.CSP, chaincodeId string) (*session.Identity, *EnclaveIdentity) {
+	identity, err := NewEnclaveIdentity(csp)
+	require.NoError(t, err)
+
+	serializedAttestedData, err := anypb.New(&protos.AttestedData{
+		CcParams:  &protos.CCParameters{ChannelId: testChannelId, ChaincodeId: chaincodeId},
+		EnclaveVk: identity.GetPublicKey(),
+	})
+	require.NoError(t, err)
+
+	return &session.Identity{
+		Credentials: &protos.Credentials{SerializedAttestedData: serializedAttestedData},
+		Signer:      identity,
+	}, identity
+}
+
+// acceptAll accepts any credentials without checking attestation evidence
+func acceptAll(credentials *protos.Credentials) (*protos.AttestedData, error) {
+	return utils.UnmarshalAttestedData(credentials.GetSerializedAttestedData())
+}
+
+// newTestTLCC returns a responder which answers metadata requests with the value hashes of the given ledger
+func newTestTLCC(t *testing.T, csp crypto.CSP, ledger testLedger) *session.Responder {
+	identity, _ := newTestSessionIdentity(t, csp, "tlcc")
+	return session.NewResponder(csp, identity, acceptAll, func(s *session.Session, requestBytes []byte) ([]byte, error) {
+		request := &protos.Request{}
+		if err := proto.Unmarshal(requestBytes, request); err != nil {
+			return nil, err
+		}
+		hash := make([]byte, 32)
+		if value, ok := ledger[request.GetMetadata().GetKey()]; ok {
+			var err error
+			if hash, err = csp.Hash(crypto.DefaultHashAlgorithm, value); err != nil {
+				return nil, err
+			}
+		}
+		return proto.Marshal(&protos.Response{Response: &protos.Response_Metadata{Metadata: &protos.GetMetadataResponse{Hash: hash}}})
+	})
+}
+
+func newTestTrustedLedgerSession(t *testing.T, csp crypto.CSP, transport session.Transport) *trustedLedgerSession {
+	identity, enclave := newTestSessionIdentity(t, csp, "cc")
+	return &trustedLedgerSession{
+		csp:       csp,
+		identity:  identity,
+		verify:    acceptAll,
+		transport: transport,
+		ccParams:  &protos.CCParameters{ChannelId: testChannelId, ChaincodeId: "cc"},
+		enclaveId: enclave.GetEnclaveId(),
+	}
+}
+
+func TestTrustedLedgerStub(t *testing.T) {
+	csp := crypto.GetDefaultCSP()
+	committed := testLedger{"alice": []byte("100")}
+	tlcc := newTestTLCC(t, csp, committed)
+	tl := newTestTrustedLedgerSession(t, csp, func(msg []byte) ([]byte, error) {
+		return tlcc.HandleMessage(msg)
+	})
+
+	// the peer returns the committed state
+	ledger := testLedger{"alice": []byte("100")}
+	stub, err := newTrustedLedgerStub(newTestStub(ledger), csp, tl, "cc")
+	require.NoError(t, err)
+
+	value, err := stub.GetState("alice")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("100"), value)
+
+	value, err = stub.GetState("bob")
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	// the peer forges, hides or invents state
+	ledger["alice"] = []byte("1000")
+	_, err = stub.GetState("alice")
+	assert.EqualError(t, err, "state of key alice does not match the trusted ledger")
+
+	delete(ledger, "alice")
+	_, err = stub.GetState("alice")
+	assert.Error(t, err)
+
+	ledger["bob"] = []byte("50")
+	_, err = stub.GetState("bob")
+	assert.Error(t, err)
+}
+
+func TestTrustedLedgerSession(t *testing.T) {
+	csp := crypto.GetDefaultCSP()
+	request, err := proto.Marshal(&protos.Request{
+		Request: &protos.Request_Metadata{Metadata: &protos.GetMetadataRequest{Namespace: "cc", Key: "alice"}},
+	})
+	require.NoError(t, err)
+
+	tlcc := newTestTLCC(t, csp, testLedger{})
+	var sent int
+	tl := newTestTrustedLedgerSession(t, csp, func(msg []byte) ([]byte, error) {
+		sent++
+		return tlcc.HandleMessage(msg)
+	})
+
+	// the session is established with the first request and then reused
+	_, err = tl.Request(request)
+	require.NoError(t, err)
+	assert.Equal(t, 3, sent)
+	_, err = tl.Request(request)
+	require.NoError(t, err)
+	assert.Equal(t, 4, sent)
+
+	// the session is re-established if TLCC lost it, e.g., after a restart; the failed request is sent again
+	tlcc = newTestTLCC(t, csp, testLedger{})
+	_, err = tl.Request(request)
+	require.NoError(t, err)
+	assert.Equal(t, 8, sent)
+
+	// TLCC must pass the verifier
+	tl = newTestTrustedLedgerSession(t, csp, tlcc.HandleMessage)
+	tl.verify = func(credentials *protos.Credentials) (*protos.AttestedData, error) {
+		return nil, fmt.Errorf("unexpected mrenclave")
+	}
+	_, err = tl.Request(request)
+	assert.ErrorContains(t, err, "cannot establish trusted ledger session")
+
+	// TLCC must be attested for the channel of the chaincode
+	tl = newTestTrustedLedgerSession(t, csp, tlcc.HandleMessage)
+	tl.verify = func(credentials *protos.Credentials) (*protos.AttestedData, error) {
+		attestedData, err := acceptAll(credentials)
+		if err != nil {
+			return nil, err
+		}
+		if attestedData.GetCcParams().GetChaincodeId() == "tlcc" {
+			attestedData.CcParams.ChannelId = "otherchannel"
+		}
+		return attestedData, nil
+	}
+	_, err = tl.Request(request)
+	assert.EqualError(t, err, "trusted ledger is attested for channel otherchannel")
+}
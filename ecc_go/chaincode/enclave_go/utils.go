@@ -7,26 +7,22 @@ SPDX-License-Identifier: Apache-2.0
 package enclave_go
 
 import (
-	"crypto/sha256"
-
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 )
 
-func hash(value []byte) []byte {
-	h := sha256.New()
-	h.Write(value)
-	return h.Sum(nil)
-}
+// decryptEntryFunction decrypts a state entry and returns the key exposed to the chaincode, which differs
+// from the ledger key if keys are blinded
+type decryptEntryFunction func(ledgerKey string, ciphertext []byte) (key string, plaintext []byte, err error)
 
 type fpcIterator struct {
 	iterator        shim.StateQueryIteratorInterface
-	addReadFunction func(key string, hash []byte)
-	decryptFunction func(ciphertext []byte) (plaintext []byte, err error)
+	addReadFunction func(key string, value []byte) error
+	decryptFunction decryptEntryFunction
 }
 
-func newFpcIterator(iterator shim.StateQueryIteratorInterface, addReadFunction func(key string, hash []byte), decryptFunction func(ciphertext []byte) (plaintext []byte, err error)) *fpcIterator {
+func newFpcIterator(iterator shim.StateQueryIteratorInterface, addReadFunction func(key string, value []byte) error, decryptFunction decryptEntryFunction) *fpcIterator {
 	return &fpcIterator{
 		iterator:        iterator,
 		addReadFunction: addReadFunction,
@@ -53,21 +49,24 @@ func (i *fpcIterator) Next() (*queryresult.KV, error) {
 	}
 
 	// add to rwset
-	i.addReadFunction(utils.TransformToFPCKey(q.Key), hash(q.Value))
+	ledgerKey := utils.TransformToFPCKey(q.Key)
+	if err := i.addReadFunction(ledgerKey, q.Value); err != nil {
+		return nil, err
+	}
 
 	if i.decryptFunction == nil {
 		return q, nil
 	}
 
 	// decrypt if state decryption function set
-	decValue, err := i.decryptFunction(q.Value)
+	key, decValue, err := i.decryptFunction(ledgerKey, q.Value)
 	if err != nil {
 		return nil, err
 	}
 
 	return &queryresult.KV{
 		Namespace: q.Namespace,
-		Key:       utils.TransformToFPCKey(q.Key),
+		Key:       key,
 		Value:     decValue,
 	}, nil
 }
@@ -0,0 +1,43 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"crypto/x509"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/cvm"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
+)
+
+// trustRoots are the roots of the attestation reports the enclave accepts, i.e., of the credentials of TLCC
+type trustRoots struct {
+	ias []*x509.Certificate
+	cvm *x509.CertPool
+}
+
+// hardwareVerifiers returns the verifiers of the hardware attestations, which only trust the given roots; unlike the
+// verifiers of ERCC, they never load the roots from the environment, which is controlled by the peer
+func hardwareVerifiers(roots trustRoots) []*types.Verifier {
+	cvmRoots := roots.cvm
+	if cvmRoots == nil {
+		cvmRoots = x509.NewCertPool()
+	}
+	return []*types.Verifier{
+		epid.NewEpidLinkableVerifier(epid.WithReportSigningRoot(roots.ias...)),
+		epid.NewEpidUnlinkableVerifier(epid.WithReportSigningRoot(roots.ias...)),
+		cvm.NewTDXVerifier(cvm.WithRootCertificates(cvmRoots)),
+		cvm.NewSEVSNPVerifier(cvm.WithRootCertificates(cvmRoots)),
+	}
+}
+
+// SetAttestationTrustRoots sets the trusted roots of the IAS report signing certificates and of the report signing
+// certificate chains of confidential VMs, which the enclave uses to verify the credentials of TLCC. As the roots are
+// part of the enclave, they are covered by its measurement. It must be called before Init.
+func (e *EnclaveStub) SetAttestationTrustRoots(iasRoots []*x509.Certificate, cvmRoots *x509.CertPool) {
+	e.verifier = newVerifier(&trustRoots{ias: iasRoots, cvm: cvmRoots})
+}
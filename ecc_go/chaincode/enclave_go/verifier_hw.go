@@ -0,0 +1,23 @@
+//go:build sgx_hw_mode
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	fpcattestation "github.com/hyperledger/fabric-private-chaincode/internal/attestation"
+)
+
+// newVerifier returns the verifier of the credentials of TLCC, which only accepts hardware attestations signed by the
+// trust roots of the enclave (see SetAttestationTrustRoots); without trust roots, no credentials are accepted.
+// Simulated attestations and the roots in the environment are not trusted, as both are controlled by the peer.
+func newVerifier(roots *trustRoots) fpcattestation.Verifier {
+	if roots == nil {
+		roots = &trustRoots{}
+	}
+	return fpcattestation.NewCredentialVerifier(hardwareVerifiers(*roots)...)
+}
@@ -0,0 +1,43 @@
+//go:build sgx_hw_mode
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid/iastest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHardwareModeVerifier(t *testing.T) {
+	ca, err := iastest.NewCA()
+	require.NoError(t, err)
+	credentials := newTestEPIDCredentials(t, ca)
+	simulated := newTestSimulatedCredentials(t)
+
+	// without trust roots, no credentials are accepted, not even with roots in the environment
+	rootsFile := filepath.Join(t.TempDir(), "roots.pem")
+	require.NoError(t, os.WriteFile(rootsFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Root.Raw}), 0o600))
+	t.Setenv(epid.ReportSigningRootEnv, rootsFile)
+
+	e := NewEnclaveStub(nil)
+	assert.Error(t, e.verifier.VerifyCredentials(credentials, testMrenclave))
+	assert.Error(t, e.verifier.VerifyCredentials(simulated, testMrenclave))
+
+	// simulated attestations are never accepted
+	e.SetAttestationTrustRoots([]*x509.Certificate{ca.Root}, nil)
+	assert.NoError(t, e.verifier.VerifyCredentials(credentials, testMrenclave))
+	assert.Error(t, e.verifier.VerifyCredentials(simulated, testMrenclave))
+}
@@ -0,0 +1,25 @@
+//go:build !sgx_hw_mode
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	ercc "github.com/hyperledger/fabric-private-chaincode/ercc/attestation"
+	fpcattestation "github.com/hyperledger/fabric-private-chaincode/internal/attestation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/simulation"
+)
+
+// newVerifier returns the verifier of the credentials of TLCC, which also accepts simulated attestations. Without
+// trust roots, the roots of the hardware attestations are loaded from the environment as by ERCC.
+// Enclaves for the hardware mode are built with the sgx_hw_mode build tag (see verifier_hw.go).
+func newVerifier(roots *trustRoots) fpcattestation.Verifier {
+	if roots == nil {
+		return ercc.GetAvailableVerifier()
+	}
+	return fpcattestation.NewCredentialVerifier(append(hardwareVerifiers(*roots), simulation.NewSimulationVerifier())...)
+}
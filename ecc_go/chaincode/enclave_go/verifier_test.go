@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package enclave_go
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid/iastest"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/simulation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const testMrenclave = "98aed61c91f258a5ae8a4e4b5ab0b0a3d5c2b6b8ab3ee0c3a3d5a4d9c6d8e9f0"
+
+// newTestTLCCCredentials returns TLCC credentials with the evidence converted from the given attestation
+func newTestTLCCCredentials(t *testing.T, converter *types.Converter, attest func(statement []byte) ([]byte, error)) *protos.Credentials {
+	serializedAttestedData, err := anypb.New(&protos.AttestedData{
+		CcParams: &protos.CCParameters{ChannelId: testChannelId, ChaincodeId: "tlcc", Version: testMrenclave},
+	})
+	require.NoError(t, err)
+	att, err := attest(serializedAttestedData.GetValue())
+	require.NoError(t, err)
+
+	credentialsBase64, err := attestation.NewCredentialConverter(converter).ConvertCredentials(utils.MarshallProtoBase64(&protos.Credentials{
+		Attestation:            att,
+		SerializedAttestedData: serializedAttestedData,
+	}))
+	require.NoError(t, err)
+	credentials, err := utils.UnmarshalCredentials(credentialsBase64)
+	require.NoError(t, err)
+	return credentials
+}
+
+// newTestEPIDCredentials returns TLCC credentials with a report of a local IAS stand-in signed by the given CA
+func newTestEPIDCredentials(t *testing.T, ca *iastest.CA) *protos.Credentials {
+	ias := httptest.NewServer(iastest.NewServer(ca))
+	defer ias.Close()
+	t.Setenv("IAS_API_KEY", "some_key")
+
+	converter := epid.NewEpidLinkableConverter(epid.WithUrl(ias.URL + iastest.ReportPath))
+	return newTestTLCCCredentials(t, converter, func(statement []byte) ([]byte, error) {
+		return iastest.NewAttestation(&iastest.Quote{Linkable: true, Mrenclave: testMrenclave, Statement: statement})
+	})
+}
+
+func newTestSimulatedCredentials(t *testing.T) *protos.Credentials {
+	return newTestTLCCCredentials(t, simulation.NewSimulationConverter(), simulation.NewSimulationIssuer().Issue)
+}
+
+func TestAttestationTrustRoots(t *testing.T) {
+	ca, err := iastest.NewCA()
+	require.NoError(t, err)
+	otherCA, err := iastest.NewCA()
+	require.NoError(t, err)
+	credentials := newTestEPIDCredentials(t, ca)
+
+	// the roots in the environment are not trusted once the enclave has its own roots
+	rootsFile := filepath.Join(t.TempDir(), "roots.pem")
+	require.NoError(t, os.WriteFile(rootsFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Root.Raw}), 0o600))
+	t.Setenv(epid.ReportSigningRootEnv, rootsFile)
+
+	e := &EnclaveStub{}
+	e.SetAttestationTrustRoots([]*x509.Certificate{otherCA.Root}, nil)
+	assert.Error(t, e.verifier.VerifyCredentials(credentials, testMrenclave))
+
+	e.SetAttestationTrustRoots([]*x509.Certificate{ca.Root}, nil)
+	assert.NoError(t, e.verifier.VerifyCredentials(credentials, testMrenclave))
+	assert.Error(t, e.verifier.VerifyCredentials(credentials, "0000000000000000000000000000000000000000000000000000000000000000"))
+}
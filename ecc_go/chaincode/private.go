@@ -7,16 +7,22 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode
 
 import (
+	"crypto/x509"
+
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-private-chaincode/ecc/chaincode"
 	"github.com/hyperledger/fabric-private-chaincode/ecc/chaincode/ercc"
 	"github.com/hyperledger/fabric-private-chaincode/ecc_go/chaincode/enclave_go"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
 	"github.com/hyperledger/fabric-private-chaincode/internal/endorsement"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric/common/metrics"
 )
 
 type BuildOption func(*chaincode.EnclaveChaincode, shim.Chaincode)
 
 // NewPrivateChaincode creates a new chaincode! This is for go support only!!!
+// The options configure the go enclave of the chaincode and can be given in any order.
 func NewPrivateChaincode(cc shim.Chaincode, options ...BuildOption) *chaincode.EnclaveChaincode {
 	ecc := &chaincode.EnclaveChaincode{
 		Enclave:   enclave_go.NewEnclaveStub(cc),
@@ -30,8 +36,161 @@ func NewPrivateChaincode(cc shim.Chaincode, options ...BuildOption) *chaincode.E
 	return ecc
 }
 
+// WithSKVS keeps the chaincode state in a single encrypted bucket to hide the keys accessed by a transaction.
+// This hides the access pattern entirely but serializes all transactions.
 func WithSKVS() BuildOption {
 	return func(ecc *chaincode.EnclaveChaincode, cc shim.Chaincode) {
-		ecc.Enclave = enclave_go.NewSkvsStub(cc)
+		stub, ok := ecc.Enclave.(*enclave_go.EnclaveStub)
+		if !ok {
+			panic("SKVS requires a go enclave")
+		}
+		stub.EnableSkvs()
+	}
+}
+
+// WithShardedSKVS keeps the chaincode state in the given number of encrypted buckets. Keys are assigned to buckets
+// by their HMAC under a chaincode key, thus, the access pattern is only hidden within a bucket, but transactions
+// accessing different buckets do not conflict. The number of shards must not change once the chaincode is deployed.
+func WithShardedSKVS(shards int) BuildOption {
+	if shards < 1 {
+		panic("SKVS requires at least one shard")
+	}
+	return func(ecc *chaincode.EnclaveChaincode, cc shim.Chaincode) {
+		stub, ok := ecc.Enclave.(*enclave_go.EnclaveStub)
+		if !ok {
+			panic("SKVS requires a go enclave")
+		}
+		stub.EnableShardedSkvs(shards)
+	}
+}
+
+// WithKeyBlinding replaces the keys of the chaincode state on the ledger with HMACs under a chaincode key.
+// Composite keys are blinded per attribute so that partial composite key queries keep working; range queries are
+// not supported. Key blinding must not be enabled or disabled once the chaincode is deployed.
+func WithKeyBlinding() BuildOption {
+	return func(ecc *chaincode.EnclaveChaincode, cc shim.Chaincode) {
+		stub, ok := ecc.Enclave.(*enclave_go.EnclaveStub)
+		if !ok {
+			panic("key blinding requires a go enclave")
+		}
+		stub.EnableKeyBlinding()
+	}
+}
+
+// WithORAM keeps the chaincode state in a Path ORAM to hide the access pattern of the chaincode from the peer.
+// The tree has 2^height leaves and holds up to 2^height entries, each with a key and value of at most blockSize
+// bytes; the height is at most 16, as every transaction rewrites the position map padded to 2^height entries.
+// Note that all transactions conflict with each other as every access rewrites the root.
+// The height and block size must not change once the chaincode is deployed.
+func WithORAM(height, blockSize int) BuildOption {
+	return func(ecc *chaincode.EnclaveChaincode, cc shim.Chaincode) {
+		stub, ok := ecc.Enclave.(*enclave_go.EnclaveStub)
+		if !ok {
+			panic("ORAM requires a go enclave")
+		}
+		if err := stub.EnableOram(height, blockSize); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// WithAttestationIssuer sets the issuer used to attest the enclave, for instance, a cvm issuer when the
+// chaincode runs inside a confidential VM.
+func WithAttestationIssuer(issuer *types.Issuer) BuildOption {
+	return func(ecc *chaincode.EnclaveChaincode, cc shim.Chaincode) {
+		stub, ok := ecc.Enclave.(*enclave_go.EnclaveStub)
+		if !ok {
+			panic("attestation issuer requires a go enclave")
+		}
+		stub.SetAttestationIssuer(issuer)
+	}
+}
+
+// WithMetrics lets the enclave report the metrics of every invocation, i.e., the invoked function, the number of reads
+// and writes, the encrypted and decrypted bytes, and the execution time, which ECC exports with the given provider,
+// e.g., the provider of a fabric operations system. Only the given functions are reported by name, all others as
+// enclave_go.OtherFunction, which bounds the label values; note that these names are revealed to the peer. The metrics
+// are not part of the signed response and never committed to the ledger.
+func WithMetrics(provider metrics.Provider, functions ...string) BuildOption {
+	return func(ecc *chaincode.EnclaveChaincode, cc shim.Chaincode) {
+		stub, ok := ecc.Enclave.(*enclave_go.EnclaveStub)
+		if !ok {
+			panic("metrics require a go enclave")
+		}
+		stub.EnableMetrics(functions...)
+		ecc.Metrics = chaincode.NewMetrics(provider)
+	}
+}
+
+// WithTrustedLedger lets the enclave check the integrity and freshness of all state read from the peer against
+// the trusted ledger, i.e., TLCC, which follows the committed blocks of the channel. The enclave establishes a session
+// with TLCC over the given transport, e.g., a chaincode-to-chaincode call through the peer, which does not need to be
+// trusted; TLCC is only accepted if its credentials are attested for the given mrenclave and the channel of the chaincode.
+func WithTrustedLedger(transport func(msg []byte) (reply []byte, err error), tlccMrenclave string) BuildOption {
+	return func(ecc *chaincode.EnclaveChaincode, cc shim.Chaincode) {
+		stub, ok := ecc.Enclave.(*enclave_go.EnclaveStub)
+		if !ok {
+			panic("trusted ledger requires a go enclave")
+		}
+		if transport == nil || tlccMrenclave == "" {
+			panic("trusted ledger requires a transport and the mrenclave of TLCC")
+		}
+		stub.SetTrustedLedger(transport, tlccMrenclave)
+	}
+}
+
+// WithAttestationTrustRoots sets the trusted roots of the attestation reports of TLCC (see WithTrustedLedger), i.e.,
+// of the IAS report signing certificates and of the report signing certificate chains of confidential VMs. Enclaves
+// built with the sgx_hw_mode build tag only accept hardware attestations signed by these roots; otherwise, the roots
+// are loaded from the environment, which the peer controls. At least one root is required.
+func WithAttestationTrustRoots(iasRoots []*x509.Certificate, cvmRoots *x509.CertPool) BuildOption {
+	if len(iasRoots) == 0 && (cvmRoots == nil || cvmRoots.Equal(x509.NewCertPool())) {
+		panic("attestation trust roots require at least one root certificate")
+	}
+	return func(ecc *chaincode.EnclaveChaincode, cc shim.Chaincode) {
+		stub, ok := ecc.Enclave.(*enclave_go.EnclaveStub)
+		if !ok {
+			panic("attestation trust roots require a go enclave")
+		}
+		stub.SetAttestationTrustRoots(iasRoots, cvmRoots)
+	}
+}
+
+// WithLegacyStateMigration lets the enclave read state which was encrypted by an earlier version of the enclave without
+// binding the ciphertext to its key. Such state is re-encrypted with binding when it is written again. Note that while
+// this option is set, the peer can move legacy ciphertexts to other keys, also to keys which have been rewritten with
+// binding; thus, it should only be set until all legacy state has been rewritten.
+func WithLegacyStateMigration() BuildOption {
+	return func(ecc *chaincode.EnclaveChaincode, cc shim.Chaincode) {
+		stub, ok := ecc.Enclave.(*enclave_go.EnclaveStub)
+		if !ok {
+			panic("legacy state migration requires a go enclave")
+		}
+		stub.EnableLegacyStateMigration()
+	}
+}
+
+// WithHashAlgorithm sets the hash function of the request hashes, read value hashes and rwset digests in the
+// responses of the enclave, e.g., SHA384; the default is SHA256, as used by the C++ enclave. As the algorithm is
+// recorded in the responses, enclaves with different algorithms can serve the same chaincode.
+func WithHashAlgorithm(algorithm protos.HashAlgorithm) BuildOption {
+	return func(ecc *chaincode.EnclaveChaincode, cc shim.Chaincode) {
+		stub, ok := ecc.Enclave.(*enclave_go.EnclaveStub)
+		if !ok {
+			panic("hash algorithm requires a go enclave")
+		}
+		if err := stub.SetHashAlgorithm(algorithm); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// WithCommand adds an ECC system function, e.g., for extensions which are not part of the FPC client protocol.
+// Commands which need access to the enclave can be registered by a custom BuildOption using RegisterCommand.
+func WithCommand(name string, command chaincode.Command) BuildOption {
+	return func(ecc *chaincode.EnclaveChaincode, cc shim.Chaincode) {
+		if err := ecc.RegisterCommand(name, command); err != nil {
+			panic(err)
+		}
 	}
 }
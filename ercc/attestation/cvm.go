@@ -0,0 +1,15 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package attestation
+
+import "github.com/hyperledger/fabric-private-chaincode/internal/attestation/cvm"
+
+func init() {
+	// note that the trusted report signing roots are loaded via $FPC_CVM_ROOT_CERTS
+	registry.add(cvm.NewTDXVerifier())
+	registry.add(cvm.NewSEVSNPVerifier())
+}
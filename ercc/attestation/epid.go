@@ -0,0 +1,19 @@
+//go:build !WITH_PDO_CRYPTO
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package attestation
+
+import (
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+)
+
+func init() {
+	// note that the trusted IAS report signing roots are loaded via $FPC_IAS_ROOT_CERTS
+	registry.add(epid.NewEpidLinkableVerifier())
+	registry.add(epid.NewEpidUnlinkableVerifier())
+}
@@ -28,6 +28,7 @@ func main() {
 	c := &registry.Contract{}
 	c.Verifier = attestation.GetAvailableVerifier()
 	c.IEvaluator = &utils.IdentityEvaluator{}
+	c.LocalMSPID = shim.GetMSPID
 	c.BeforeTransaction = registry.MyBeforeTransaction
 
 	ercc, err := contractapi.NewChaincode(c)
@@ -13,12 +13,14 @@ import (
 	"encoding/base64"
 	"fmt"
 
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-private-chaincode/internal/attestation"
 	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
 	"github.com/hyperledger/fabric/common/flogging"
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
 )
 
 var logger = flogging.MustGetLogger("ercc")
@@ -28,6 +30,8 @@ type Contract struct {
 
 	Verifier   attestation.Verifier
 	IEvaluator utils.IdentityEvaluatorInterface
+	// LocalMSPID returns the msp id of the peer executing ERCC, e.g., shim.GetMSPID
+	LocalMSPID func() (string, error)
 }
 
 func MyBeforeTransaction(ctx contractapi.TransactionContextInterface) error {
@@ -330,7 +334,8 @@ func checkAttestedData(ctx contractapi.TransactionContextInterface, v attestatio
 		return fmt.Errorf("sequence does not match chaincode definition")
 	}
 
-	// check that attestation evidence contains expectedMrEnclave as defined in chaincode definition
+	// check that attestation evidence contains expectedMrEnclave as defined in chaincode definition;
+	// note that the version may also carry measurement sets, e.g., RTMR values, which are checked as well
 	if err := v.VerifyCredentials(credentials, expectedMrEnclave); err != nil {
 		return fmt.Errorf("evidence verification failed: %s", err)
 	}
@@ -377,9 +382,296 @@ func (rs *Contract) PutKeyExport(ctx contractapi.TransactionContextInterface, ex
 }
 
 // GetKeyExport retrieve key export (Post-MVP feature)
+// Note that the chaincode keys of an enclave upgrade are handed over through TLCC (see UpgradeEnclave).
 func (rs *Contract) GetKeyExport(ctx contractapi.TransactionContextInterface, chaincodeId, enclaveId string) (string, error) {
 	//input chaincodeId string, enclaveId string
 	//return *ExportMessage or  error
 	// TODO implement me (Post-MVP)
 	return "", fmt.Errorf("not implemented yet")
 }
+
+// ApproveUpgradePolicy records the approval of an upgrade policy by the organization of the transaction creator.
+// The creator must be an admin of its organization, identified by the admin OU of the NodeOUs of its msp.
+// An approval is replaced by a later approval of the same organization and only counts for the exact policy.
+func (rs *Contract) ApproveUpgradePolicy(ctx contractapi.TransactionContextInterface, upgradePolicyBase64 string) error {
+	upgradePolicy, err := unmarshalUpgradePolicy(upgradePolicyBase64)
+	if err != nil {
+		return err
+	}
+
+	creatorIdentityBytes, err := ctx.GetStub().GetCreator()
+	if err != nil {
+		return err
+	}
+
+	mspId, err := utils.ExtractMSPID(creatorIdentityBytes)
+	if err != nil {
+		return fmt.Errorf("error while deserialzing creator identity, err: %s", err)
+	}
+
+	isAdmin, err := cid.HasOUValue(ctx.GetStub(), "admin")
+	if err != nil {
+		return errors.Wrap(err, "cannot get organizational units of the creator")
+	}
+	if !isAdmin {
+		return fmt.Errorf("creator is not an admin of %s", mspId)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("namespaces/upgrade_approvals", []string{upgradePolicy.ChaincodeId, mspId})
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(key, []byte(upgradePolicyBase64)); err != nil {
+		return fmt.Errorf("cannot store upgrade policy approval: %s", err)
+	}
+
+	return nil
+}
+
+// SetUpgradePolicy sets the upgrade policy for a chaincode. The policy defines to which chaincode versions (mrenclave)
+// the enclave of the current version may export its chaincode keys. As the policy controls who can obtain the keys of
+// the chaincode, it is subject to channel governance: every endorsing peer only endorses the transaction if its own
+// organization approved the policy (see ApproveUpgradePolicy). Hence, the endorsement policy of ERCC, by default a
+// majority of the channel members, defines which organizations must approve the policy. The approvals are consumed.
+// All peers read the approvals of all organizations, so that their endorsements have the same read set.
+func (rs *Contract) SetUpgradePolicy(ctx contractapi.TransactionContextInterface, upgradePolicyBase64 string) error {
+	upgradePolicy, err := unmarshalUpgradePolicy(upgradePolicyBase64)
+	if err != nil {
+		return err
+	}
+
+	if rs.LocalMSPID == nil {
+		return errors.New("msp id of the peer is not available")
+	}
+
+	mspId, err := rs.LocalMSPID()
+	if err != nil {
+		return errors.Wrap(err, "cannot get msp id of the peer")
+	}
+
+	approvalKey, err := ctx.GetStub().CreateCompositeKey("namespaces/upgrade_approvals", []string{upgradePolicy.ChaincodeId, mspId})
+	if err != nil {
+		return err
+	}
+
+	iter, err := ctx.GetStub().GetStateByPartialCompositeKey("namespaces/upgrade_approvals", []string{upgradePolicy.ChaincodeId})
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	var approvalKeys []string
+	approved := false
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		approvalKeys = append(approvalKeys, kv.GetKey())
+		if kv.GetKey() == approvalKey && string(kv.GetValue()) == upgradePolicyBase64 {
+			approved = true
+		}
+	}
+
+	if !approved {
+		return fmt.Errorf("upgrade policy is not approved by %s", mspId)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("namespaces/upgrade_policy", []string{upgradePolicy.ChaincodeId})
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(key, []byte(upgradePolicyBase64)); err != nil {
+		return fmt.Errorf("cannot store upgrade policy: %s", err)
+	}
+
+	// remove all approvals so that they cannot be used to set the policy again, e.g., after it has been changed
+	for _, approvalKey := range approvalKeys {
+		if err := ctx.GetStub().DelState(approvalKey); err != nil {
+			return fmt.Errorf("cannot delete upgrade policy approval: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func unmarshalUpgradePolicy(upgradePolicyBase64 string) (*protos.UpgradePolicy, error) {
+	upgradePolicyBytes, err := base64.StdEncoding.DecodeString(upgradePolicyBase64)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid upgrade policy bytes")
+	}
+
+	upgradePolicy := &protos.UpgradePolicy{}
+	if err := proto.Unmarshal(upgradePolicyBytes, upgradePolicy); err != nil {
+		return nil, errors.Wrap(err, "invalid upgrade policy message")
+	}
+
+	if upgradePolicy.ChaincodeId == "" {
+		return nil, errors.New("chaincode id is empty")
+	}
+
+	if len(upgradePolicy.AllowedVersions) == 0 {
+		return nil, errors.New("no allowed versions")
+	}
+
+	return upgradePolicy, nil
+}
+
+// QueryUpgradePolicy returns the (base64-encoded) upgrade policy for a given chaincode id or an empty string if no policy is set
+func (rs *Contract) QueryUpgradePolicy(ctx contractapi.TransactionContextInterface, chaincodeId string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("namespaces/upgrade_policy", []string{chaincodeId})
+	if err != nil {
+		return "", err
+	}
+
+	upgradePolicyBase64, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return "", err
+	}
+
+	return string(upgradePolicyBase64), nil
+}
+
+// UpgradeEnclave registers the enclave of an upgraded chaincode and replaces the enclave of the previous chaincode version.
+// In addition to the checks performed by RegisterEnclave, the new chaincode version must be allowed by the upgrade policy.
+// The previous enclave is recorded for the new enclave, which only accepts the chaincode keys handed over from the
+// previous enclave through TLCC (see `__exportCCKeys` and `__importCCKeys` of ECC and QueryPreviousEnclaveId).
+func (rs *Contract) UpgradeEnclave(ctx contractapi.TransactionContextInterface, credentialsBase64 string) error {
+	logger.Debugf("UpgradeEnclave")
+
+	credentials, err := utils.UnmarshalCredentials(credentialsBase64)
+	if err != nil {
+		return errors.Wrap(err, "invalid credential bytes")
+	}
+
+	if len(credentials.Evidence) == 0 {
+		return errors.New("evidence is empty")
+	}
+
+	attestedData, err := utils.UnmarshalAttestedData(credentials.SerializedAttestedData)
+	if err != nil {
+		return errors.Wrap(err, "invalid attested data message")
+	}
+
+	if err := checkAttestedData(ctx, rs.Verifier, rs.IEvaluator, attestedData, credentials); err != nil {
+		return err
+	}
+
+	chaincodeId := attestedData.CcParams.ChaincodeId
+	enclaveId := utils.GetEnclaveId(attestedData)
+
+	previousAttestedData, err := rs.getRegisteredAttestedData(ctx, chaincodeId)
+	if err != nil {
+		return err
+	}
+	previousEnclaveId := utils.GetEnclaveId(previousAttestedData)
+
+	if previousAttestedData.CcParams.GetSequence() >= attestedData.CcParams.Sequence {
+		return fmt.Errorf("enclave is not for a newer chaincode sequence")
+	}
+
+	if err := rs.checkUpgradePolicy(ctx, chaincodeId, attestedData.CcParams.Version); err != nil {
+		return err
+	}
+
+	// All check passed, now replace the previous enclave
+	logger.Debugf("Replacing enclave %s with %s", previousEnclaveId, enclaveId)
+
+	for _, namespace := range []string{"namespaces/credentials", "namespaces/provisioned", "namespaces/upgraded"} {
+		key, err := ctx.GetStub().CreateCompositeKey(namespace, []string{chaincodeId, previousEnclaveId})
+		if err != nil {
+			return err
+		}
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return fmt.Errorf("cannot delete previous enclave state: %s", err)
+		}
+	}
+
+	for namespace, value := range map[string]string{
+		"namespaces/credentials": credentialsBase64,
+		// see RegisterEnclave, the new enclave is provisioned once it imported the chaincode keys
+		"namespaces/provisioned": "a SignedCCKeyRegistrationMessage",
+		"namespaces/upgraded":    previousEnclaveId,
+	} {
+		key, err := ctx.GetStub().CreateCompositeKey(namespace, []string{chaincodeId, enclaveId})
+		if err != nil {
+			return err
+		}
+		if err := ctx.GetStub().PutState(key, []byte(value)); err != nil {
+			return fmt.Errorf("cannot store %s: %s", namespace, err)
+		}
+	}
+
+	logger.Debugf("UpgradeEnclave successful")
+
+	return nil
+}
+
+// QueryPreviousEnclaveId returns the id of the enclave which was replaced by the given enclave with UpgradeEnclave
+func (rs *Contract) QueryPreviousEnclaveId(ctx contractapi.TransactionContextInterface, chaincodeId, enclaveId string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("namespaces/upgraded", []string{chaincodeId, enclaveId})
+	if err != nil {
+		return "", err
+	}
+
+	previousEnclaveId, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return "", err
+	}
+
+	if previousEnclaveId == nil {
+		return "", fmt.Errorf("enclave %s did not replace a previous enclave", enclaveId)
+	}
+
+	return string(previousEnclaveId), nil
+}
+
+// getRegisteredAttestedData returns the attested data of the (single) enclave registered for a chaincode
+func (rs *Contract) getRegisteredAttestedData(ctx contractapi.TransactionContextInterface, chaincodeId string) (*protos.AttestedData, error) {
+	registeredCredentialsList, err := rs.QueryListEnclaveCredentials(ctx, chaincodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(registeredCredentialsList) != 1 {
+		return nil, fmt.Errorf("expected one registered enclave for chaincode %s, found %d", chaincodeId, len(registeredCredentialsList))
+	}
+
+	credentials, err := utils.UnmarshalCredentials(registeredCredentialsList[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.UnmarshalAttestedData(credentials.SerializedAttestedData)
+}
+
+func (rs *Contract) checkUpgradePolicy(ctx contractapi.TransactionContextInterface, chaincodeId, version string) error {
+	upgradePolicyBase64, err := rs.QueryUpgradePolicy(ctx, chaincodeId)
+	if err != nil {
+		return err
+	}
+
+	if upgradePolicyBase64 == "" {
+		return fmt.Errorf("no upgrade policy set for chaincode %s", chaincodeId)
+	}
+
+	upgradePolicyBytes, err := base64.StdEncoding.DecodeString(upgradePolicyBase64)
+	if err != nil {
+		return err
+	}
+
+	upgradePolicy := &protos.UpgradePolicy{}
+	if err := proto.Unmarshal(upgradePolicyBytes, upgradePolicy); err != nil {
+		return err
+	}
+
+	for _, v := range upgradePolicy.AllowedVersions {
+		if v == version {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("chaincode version %s is not allowed by upgrade policy", version)
+}
@@ -8,18 +8,31 @@ SPDX-License-Identifier: Apache-2.0
 package registry_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-private-chaincode/ercc/registry"
 	"github.com/hyperledger/fabric-private-chaincode/ercc/registry/fakes"
 	"github.com/hyperledger/fabric-private-chaincode/internal/attestation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid/iastest"
 	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
 	"github.com/hyperledger/fabric-protos-go/peer/lifecycle"
 	"github.com/hyperledger/fabric/protoutil"
 	"github.com/stretchr/testify/require"
@@ -260,6 +273,70 @@ func TestRegisterEnclave(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// TestRegisterEnclaveEPID runs a hardware-mode registration end-to-end using a local IAS stand-in
+func TestRegisterEnclaveEPID(t *testing.T) {
+	ca, err := iastest.NewCA()
+	require.NoError(t, err)
+	ias := httptest.NewServer(iastest.NewServer(ca))
+	defer ias.Close()
+	t.Setenv("IAS_API_KEY", "some_key")
+
+	chaincodeStub := &fakes.ChaincodeStub{}
+	transactionContext := &fakes.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	chaincodeStub.GetChannelIDReturns(channelId)
+	chaincodeStub.GetCreatorReturns([]byte("fake creator"), nil)
+	chaincodeStub.CreateCompositeKeyReturns("someKey", nil)
+	chaincodeStub.InvokeChaincodeReturns(shim.Success(protoutil.MarshalOrPanic(
+		&lifecycle.QueryChaincodeDefinitionResult{
+			Version:  mrenclave,
+			Sequence: 1,
+		})))
+
+	ercc := registry.Contract{}
+	ercc.Verifier = attestation.NewCredentialVerifier(epid.NewEpidLinkableVerifier(epid.WithReportSigningRoot(ca.Root)))
+	ercc.IEvaluator = &fakes.IdentityEvaluator{}
+
+	converter := attestation.NewCredentialConverter(epid.NewEpidLinkableConverter(epid.WithUrl(ias.URL + iastest.ReportPath)))
+
+	newCredentials := func(quotedMrenclave string) string {
+		serializedAttestedData, _ := anypb.New(
+			&protos.AttestedData{
+				EnclaveVk: []byte("enclaveVKString"),
+				CcParams: &protos.CCParameters{
+					ChaincodeId: chaincodeId,
+					Version:     mrenclave,
+					ChannelId:   channelId,
+					Sequence:    1,
+				},
+				HostParams: &protos.HostParameters{
+					PeerMspId: someMspId,
+				},
+			})
+
+		att, err := iastest.NewAttestation(&iastest.Quote{
+			Linkable:  true,
+			Mrenclave: quotedMrenclave,
+			Statement: serializedAttestedData.Value,
+		})
+		require.NoError(t, err)
+
+		credentials, err := converter.ConvertCredentials(toBase64(&protos.Credentials{
+			Attestation:            att,
+			SerializedAttestedData: serializedAttestedData,
+		}))
+		require.NoError(t, err)
+		return credentials
+	}
+
+	// enclave running other code than defined in the chaincode definition
+	err = ercc.RegisterEnclave(transactionContext, newCredentials("0000000000000000000000000000000000000000000000000000000000000000"))
+	require.Contains(t, err.Error(), "evidence verification failed: expected code id mismatch")
+
+	err = ercc.RegisterEnclave(transactionContext, newCredentials(mrenclave))
+	require.NoError(t, err)
+}
+
 func TestQueryListEnclaveCredentials(t *testing.T) {
 	chaincodeStub := &fakes.ChaincodeStub{}
 	transactionContext := &fakes.TransactionContext{}
@@ -347,3 +424,248 @@ func TestQueryEnclaveCredentials(t *testing.T) {
 	require.Empty(t, resp)
 	require.NoError(t, err)
 }
+
+// newRegisteredEnclave sets up the fake stub so that the given credentials are the only registered enclave
+func newRegisteredEnclave(chaincodeStub *fakes.ChaincodeStub, credentialsBase64 string) {
+	chaincodeStub.GetStateByPartialCompositeKeyCalls(func(string, []string) (shim.StateQueryIteratorInterface, error) {
+		stateQueryIterator := &fakes.StateQueryIterator{}
+		stateQueryIterator.HasNextReturnsOnCall(0, true)
+		stateQueryIterator.HasNextReturnsOnCall(1, false)
+		stateQueryIterator.NextReturns(&queryresult.KV{Value: []byte(credentialsBase64)}, nil)
+		return stateQueryIterator, nil
+	})
+}
+
+// newCreator returns a serialized identity of the given msp with a certificate of the given organizational unit
+func newCreator(t *testing.T, mspId, ou string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "creator", OrganizationalUnit: []string{ou}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return protoutil.MarshalOrPanic(&msp.SerializedIdentity{
+		Mspid:   mspId,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+}
+
+func TestApproveUpgradePolicy(t *testing.T) {
+	chaincodeStub := &fakes.ChaincodeStub{}
+	transactionContext := &fakes.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	chaincodeStub.CreateCompositeKeyReturns("someKey", nil)
+
+	ercc := registry.Contract{}
+
+	err := ercc.ApproveUpgradePolicy(transactionContext, "some bytes")
+	require.Contains(t, err.Error(), "invalid upgrade policy bytes")
+
+	err = ercc.ApproveUpgradePolicy(transactionContext, utils.MarshallProtoBase64(&protos.UpgradePolicy{AllowedVersions: []string{mrenclave}}))
+	require.EqualError(t, err, "chaincode id is empty")
+
+	err = ercc.ApproveUpgradePolicy(transactionContext, utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: chaincodeId}))
+	require.EqualError(t, err, "no allowed versions")
+
+	policyBase64 := utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: chaincodeId, AllowedVersions: []string{mrenclave}})
+
+	chaincodeStub.GetCreatorReturns([]byte("fake creator"), nil)
+	err = ercc.ApproveUpgradePolicy(transactionContext, policyBase64)
+	require.Contains(t, err.Error(), "error while deserialzing creator identity")
+
+	// the creator must be an admin
+	chaincodeStub.GetCreatorReturns(protoutil.MarshalOrPanic(&msp.SerializedIdentity{Mspid: someMspId}), nil)
+	err = ercc.ApproveUpgradePolicy(transactionContext, policyBase64)
+	require.Contains(t, err.Error(), "cannot get organizational units of the creator")
+
+	chaincodeStub.GetCreatorReturns(newCreator(t, someMspId, "client"), nil)
+	err = ercc.ApproveUpgradePolicy(transactionContext, policyBase64)
+	require.EqualError(t, err, "creator is not an admin of some org")
+	require.Equal(t, 0, chaincodeStub.PutStateCallCount())
+
+	// the approval is recorded for the organization of the creator
+	chaincodeStub.GetCreatorReturns(newCreator(t, someMspId, "admin"), nil)
+	err = ercc.ApproveUpgradePolicy(transactionContext, policyBase64)
+	require.NoError(t, err)
+	objectType, attributes := chaincodeStub.CreateCompositeKeyArgsForCall(0)
+	require.Equal(t, "namespaces/upgrade_approvals", objectType)
+	require.Equal(t, []string{chaincodeId, someMspId}, attributes)
+	key, value := chaincodeStub.PutStateArgsForCall(0)
+	require.Equal(t, "someKey", key)
+	require.Equal(t, policyBase64, string(value))
+}
+
+func TestSetUpgradePolicy(t *testing.T) {
+	chaincodeStub := &fakes.ChaincodeStub{}
+	transactionContext := &fakes.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	chaincodeStub.CreateCompositeKeyCalls(func(objectType string, attributes []string) (string, error) {
+		return objectType + "/" + strings.Join(attributes, "/"), nil
+	})
+
+	ercc := registry.Contract{}
+
+	policyBase64 := utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: chaincodeId, AllowedVersions: []string{mrenclave}})
+	otherPolicyBase64 := utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: chaincodeId, AllowedVersions: []string{"other mrenclave"}})
+
+	err := ercc.SetUpgradePolicy(transactionContext, policyBase64)
+	require.EqualError(t, err, "msp id of the peer is not available")
+
+	ercc.LocalMSPID = func() (string, error) { return someMspId, nil }
+
+	err = ercc.SetUpgradePolicy(transactionContext, "some bytes")
+	require.Contains(t, err.Error(), "invalid upgrade policy bytes")
+
+	err = ercc.SetUpgradePolicy(transactionContext, utils.MarshallProtoBase64(&protos.UpgradePolicy{AllowedVersions: []string{mrenclave}}))
+	require.EqualError(t, err, "chaincode id is empty")
+
+	err = ercc.SetUpgradePolicy(transactionContext, utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: chaincodeId}))
+	require.EqualError(t, err, "no allowed versions")
+
+	// approvals returns the given approvals by msp id
+	approvals := func(approvals map[string]string) {
+		chaincodeStub.GetStateByPartialCompositeKeyCalls(func(string, []string) (shim.StateQueryIteratorInterface, error) {
+			stateQueryIterator := &fakes.StateQueryIterator{}
+			i := 0
+			for mspId, approval := range approvals {
+				stateQueryIterator.HasNextReturnsOnCall(i, true)
+				stateQueryIterator.NextReturnsOnCall(i, &queryresult.KV{
+					Key:   "namespaces/upgrade_approvals/" + chaincodeId + "/" + mspId,
+					Value: []byte(approval),
+				}, nil)
+				i++
+			}
+			stateQueryIterator.HasNextReturnsOnCall(i, false)
+			return stateQueryIterator, nil
+		})
+	}
+
+	// the organization of the peer has not approved the policy
+	approvals(map[string]string{"other org": policyBase64})
+	err = ercc.SetUpgradePolicy(transactionContext, policyBase64)
+	require.EqualError(t, err, "upgrade policy is not approved by some org")
+
+	approvals(map[string]string{someMspId: otherPolicyBase64, "other org": policyBase64})
+	err = ercc.SetUpgradePolicy(transactionContext, policyBase64)
+	require.EqualError(t, err, "upgrade policy is not approved by some org")
+
+	require.Equal(t, 0, chaincodeStub.PutStateCallCount())
+	require.Equal(t, 0, chaincodeStub.GetStateCallCount())
+
+	// the policy is set and all approvals are removed; every peer reads the approvals of all organizations
+	approvals(map[string]string{someMspId: policyBase64, "other org": otherPolicyBase64})
+	err = ercc.SetUpgradePolicy(transactionContext, policyBase64)
+	require.NoError(t, err)
+	key, value := chaincodeStub.PutStateArgsForCall(0)
+	require.Equal(t, "namespaces/upgrade_policy/"+chaincodeId, key)
+	require.Equal(t, policyBase64, string(value))
+	objectType, attributes := chaincodeStub.GetStateByPartialCompositeKeyArgsForCall(0)
+	require.Equal(t, "namespaces/upgrade_approvals", objectType)
+	require.Equal(t, []string{chaincodeId}, attributes)
+	require.Equal(t, 0, chaincodeStub.GetStateCallCount())
+	require.Equal(t, 2, chaincodeStub.DelStateCallCount())
+	require.ElementsMatch(t, []string{
+		"namespaces/upgrade_approvals/" + chaincodeId + "/" + someMspId,
+		"namespaces/upgrade_approvals/" + chaincodeId + "/other org",
+	}, []string{chaincodeStub.DelStateArgsForCall(0), chaincodeStub.DelStateArgsForCall(1)})
+
+	chaincodeStub.GetStateReturns([]byte(policyBase64), nil)
+	resp, err := ercc.QueryUpgradePolicy(transactionContext, chaincodeId)
+	require.NoError(t, err)
+	require.Equal(t, policyBase64, resp)
+
+	ercc.LocalMSPID = func() (string, error) { return "", fmt.Errorf("CORE_PEER_LOCALMSPID is unset") }
+	err = ercc.SetUpgradePolicy(transactionContext, policyBase64)
+	require.EqualError(t, err, "cannot get msp id of the peer: CORE_PEER_LOCALMSPID is unset")
+}
+
+func TestUpgradeEnclave(t *testing.T) {
+	const newMrenclave = "a1aed61c91f258a37c68ed4943297695647ec7bbe6008cc111b0a12650ebeb91"
+
+	chaincodeStub := &fakes.ChaincodeStub{}
+	transactionContext := &fakes.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	chaincodeStub.GetChannelIDReturns(channelId)
+	chaincodeStub.GetCreatorReturns([]byte("fake creator"), nil)
+	chaincodeStub.CreateCompositeKeyCalls(func(objectType string, attributes []string) (string, error) {
+		return objectType + "/" + strings.Join(attributes, "/"), nil
+	})
+	chaincodeStub.InvokeChaincodeReturns(shim.Success(protoutil.MarshalOrPanic(
+		&lifecycle.QueryChaincodeDefinitionResult{
+			Version:  newMrenclave,
+			Sequence: 2,
+		})))
+
+	ercc := registry.Contract{}
+	ercc.Verifier = &fakes.CredentialVerifier{}
+	ercc.IEvaluator = &fakes.IdentityEvaluator{}
+
+	previousAttestedData := &protos.AttestedData{
+		EnclaveVk:   []byte("previous enclave vk"),
+		ChaincodeEk: []byte("previous chaincode ek"),
+		CcParams:    &protos.CCParameters{ChaincodeId: chaincodeId, Version: mrenclave, ChannelId: channelId, Sequence: 1},
+		HostParams:  &protos.HostParameters{PeerMspId: someMspId},
+	}
+	serializedPreviousAttestedData, _ := anypb.New(previousAttestedData)
+	newRegisteredEnclave(chaincodeStub, toBase64(&protos.Credentials{SerializedAttestedData: serializedPreviousAttestedData}))
+	previousEnclaveId := utils.GetEnclaveId(previousAttestedData)
+
+	newAttestedData := &protos.AttestedData{
+		EnclaveVk:  []byte("new enclave vk"),
+		CcParams:   &protos.CCParameters{ChaincodeId: chaincodeId, Version: newMrenclave, ChannelId: channelId, Sequence: 2},
+		HostParams: &protos.HostParameters{PeerMspId: someMspId},
+	}
+	newEnclaveId := utils.GetEnclaveId(newAttestedData)
+	newCredentials := func(sequence int64) string {
+		newAttestedData.CcParams.Sequence = sequence
+		serializedAttestedData, _ := anypb.New(newAttestedData)
+		return toBase64(&protos.Credentials{Evidence: []byte("some mock evidence"), SerializedAttestedData: serializedAttestedData})
+	}
+
+	// the new enclave must be for the current chaincode definition
+	err := ercc.UpgradeEnclave(transactionContext, newCredentials(3))
+	require.EqualError(t, err, "sequence does not match chaincode definition")
+
+	// no upgrade policy
+	err = ercc.UpgradeEnclave(transactionContext, newCredentials(2))
+	require.EqualError(t, err, "no upgrade policy set for chaincode SOME_CHAINCODE_PKG_ID")
+
+	chaincodeStub.GetStateReturns([]byte(utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: chaincodeId, AllowedVersions: []string{mrenclave}})), nil)
+	err = ercc.UpgradeEnclave(transactionContext, newCredentials(2))
+	require.EqualError(t, err, "chaincode version "+newMrenclave+" is not allowed by upgrade policy")
+
+	require.Equal(t, 0, chaincodeStub.PutStateCallCount())
+
+	chaincodeStub.GetStateReturns([]byte(utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: chaincodeId, AllowedVersions: []string{newMrenclave}})), nil)
+	err = ercc.UpgradeEnclave(transactionContext, newCredentials(2))
+	require.NoError(t, err)
+
+	// the previous enclave is replaced and recorded for the new enclave
+	require.Equal(t, 3, chaincodeStub.DelStateCallCount())
+	for i := 0; i < chaincodeStub.DelStateCallCount(); i++ {
+		require.Contains(t, chaincodeStub.DelStateArgsForCall(i), previousEnclaveId)
+	}
+	written := make(map[string]string)
+	for i := 0; i < chaincodeStub.PutStateCallCount(); i++ {
+		key, value := chaincodeStub.PutStateArgsForCall(i)
+		written[key] = string(value)
+	}
+	require.Len(t, written, 3)
+	require.Equal(t, newCredentials(2), written["namespaces/credentials/"+chaincodeId+"/"+newEnclaveId])
+	require.Equal(t, previousEnclaveId, written["namespaces/upgraded/"+chaincodeId+"/"+newEnclaveId])
+
+	chaincodeStub.GetStateReturns([]byte(previousEnclaveId), nil)
+	resp, err := ercc.QueryPreviousEnclaveId(transactionContext, chaincodeId, newEnclaveId)
+	require.NoError(t, err)
+	require.Equal(t, previousEnclaveId, resp)
+	key := chaincodeStub.GetStateArgsForCall(chaincodeStub.GetStateCallCount() - 1)
+	require.Equal(t, "namespaces/upgraded/"+chaincodeId+"/"+newEnclaveId, key)
+
+	chaincodeStub.GetStateReturns(nil, nil)
+	_, err = ercc.QueryPreviousEnclaveId(transactionContext, chaincodeId, enclaveId)
+	require.EqualError(t, err, "enclave some enclave id did not replace a previous enclave")
+}
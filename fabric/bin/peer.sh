@@ -472,9 +472,12 @@ EOF
         SPID_TYPE_FILE_PATH="${SGX_CREDENTIALS_PATH}/spid_type.txt"
         [ -f "${SPID_FILE_PATH}" ] || die "no spid file ${SPID_FILE_PATH}"
         [ -f "${SPID_TYPE_FILE_PATH}" ] || die "no spid type file ${SPID_TYPE_FILE_PATH}"
+        # the (base64 encoded) sig_rl is optional and assumed to be empty if not present
+        SIG_RL_FILE_PATH="${SGX_CREDENTIALS_PATH}/sig_rl.txt"
+        SIG_RL=""
+        [ -f "${SIG_RL_FILE_PATH}" ] && SIG_RL=$(tr -d '[:space:]' < "${SIG_RL_FILE_PATH}")
         # set hw-mode attestation params
-        # it is assumed that sig_rl is empty
-        ATTESTATION_PARAMS=$(jq -c -n --arg atype "$(cat ${SPID_TYPE_FILE_PATH})" --arg spid "$(cat ${SPID_FILE_PATH})" --arg sig_rl "" '{attestation_type: $atype, hex_spid: $spid, sig_rl: $sig_rl}' | base64 --wrap=0)
+        ATTESTATION_PARAMS=$(jq -c -n --arg atype "$(cat ${SPID_TYPE_FILE_PATH})" --arg spid "$(cat ${SPID_FILE_PATH})" --arg sig_rl "${SIG_RL}" '{attestation_type: $atype, hex_spid: $spid, sig_rl: $sig_rl}' | base64 --wrap=0)
     else
 	die "illegal sgx mode '${SGX_MODE}', should be either 'SIM' or 'HW'"
     fi
@@ -0,0 +1,20 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// This is the FPC endorsement plugin, to be built with -buildmode=plugin and loaded by the peer
+package main
+
+import (
+	"github.com/hyperledger/fabric-private-chaincode/fabric/plugins/endorsement"
+	api "github.com/hyperledger/fabric/core/handlers/endorsement/api"
+)
+
+// NewPluginFactory is the function the peer looks up when loading the plugin
+func NewPluginFactory() api.PluginFactory {
+	return &endorsement.PluginFactory{}
+}
+
+func main() {}
@@ -0,0 +1,204 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package endorsement implements the FPC endorsement plugin of the peer. For __invoke proposals, it replaces the
+// rwset of the chaincode in the proposal response by the rwset produced by the enclave, so that the transaction
+// can be committed directly, i.e., without a second __endorse transaction. The enclave signature is checked at
+// commit time by the FPC validation plugin.
+package endorsement
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/endorsement"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/common/flogging"
+	api "github.com/hyperledger/fabric/core/handlers/endorsement/api"
+	identities "github.com/hyperledger/fabric/core/handlers/endorsement/api/identities"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("fpc-escc")
+
+const invokeFunction = "__invoke"
+
+// PluginFactory creates FPC endorsement plugins
+type PluginFactory struct{}
+
+func (*PluginFactory) New() api.Plugin {
+	return &Plugin{}
+}
+
+// Plugin endorses FPC proposals; proposals of other functions, e.g., __init, are endorsed as by the default
+// endorsement plugin
+type Plugin struct {
+	identities.SigningIdentityFetcher
+}
+
+// Endorse signs the proposal response payload, after replacing the rwset of __invoke proposals by the enclave rwset
+func (p *Plugin) Endorse(prpBytes []byte, sp *peer.SignedProposal) (*peer.Endorsement, []byte, error) {
+	function, err := functionName(sp)
+	if err != nil {
+		return nil, nil, err
+	}
+	if function == invokeFunction {
+		if prpBytes, err = replaceRwSet(prpBytes); err != nil {
+			return nil, nil, errors.Wrap(err, "cannot endorse enclave response")
+		}
+	}
+
+	signer, err := p.SigningIdentityForRequest(sp)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed fetching signing identity")
+	}
+	identityBytes, err := signer.Serialize()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not serialize the signing identity")
+	}
+
+	// sign the concatenation of the proposal response and the serialized endorser identity with this endorser's key
+	signature, err := signer.Sign(append(prpBytes, identityBytes...))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not sign the proposal response payload")
+	}
+
+	return &peer.Endorsement{Signature: signature, Endorser: identityBytes}, prpBytes, nil
+}
+
+// Init injects dependencies into the instance of the Plugin
+func (p *Plugin) Init(dependencies ...api.Dependency) error {
+	for _, dep := range dependencies {
+		if fetcher, ok := dep.(identities.SigningIdentityFetcher); ok {
+			p.SigningIdentityFetcher = fetcher
+			return nil
+		}
+	}
+	return errors.New("could not find SigningIdentityFetcher in dependencies")
+}
+
+// replaceRwSet returns the proposal response payload with the enclave rwset as rwset of the chaincode namespace.
+// The read versions are taken from the simulation, as the enclave reads the state through the peer.
+// Responses reporting an error are left unchanged, the validation plugin rejects them.
+func replaceRwSet(prpBytes []byte) ([]byte, error) {
+	prp, err := protoutil.UnmarshalProposalResponsePayload(prpBytes)
+	if err != nil {
+		return nil, err
+	}
+	ccAction, err := protoutil.UnmarshalChaincodeAction(prp.GetExtension())
+	if err != nil {
+		return nil, err
+	}
+
+	_, responseMsg, err := utils.UnmarshalChaincodeResponseMessages(string(ccAction.GetResponse().GetPayload()))
+	if err != nil {
+		return nil, err
+	}
+	if responseMsg.GetErrorCode() != protos.ErrorCode_ERROR_CODE_OK {
+		logger.Debugf("enclave response reports an error (%s), rwset not replaced", responseMsg.GetErrorCode())
+		return prpBytes, nil
+	}
+
+	txRWSet, err := protoutil.UnmarshalTxReadWriteSet(ccAction.GetResults())
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := ccAction.GetChaincodeId().GetName()
+	var nsRWSet *rwset.NsReadWriteSet
+	for _, ns := range txRWSet.GetNsRwset() {
+		if ns.GetNamespace() == namespace {
+			nsRWSet = ns
+		}
+	}
+	if nsRWSet == nil {
+		nsRWSet = &rwset.NsReadWriteSet{Namespace: namespace}
+		txRWSet.NsRwset = append(txRWSet.NsRwset, nsRWSet)
+	}
+
+	simulated := &kvrwset.KVRWSet{}
+	if len(nsRWSet.GetRwset()) > 0 {
+		if simulated, err = protoutil.UnmarshalKVRWSet(nsRWSet.GetRwset()); err != nil {
+			return nil, err
+		}
+	}
+
+	kvRWSet, err := enclaveRwSet(responseMsg.GetFpcRwSet(), simulated)
+	if err != nil {
+		return nil, err
+	}
+	if nsRWSet.Rwset, err = protoutil.Marshal(kvRWSet); err != nil {
+		return nil, err
+	}
+	if ccAction.Results, err = protoutil.Marshal(txRWSet); err != nil {
+		return nil, err
+	}
+	if prp.Extension, err = protoutil.Marshal(ccAction); err != nil {
+		return nil, err
+	}
+	return protoutil.Marshal(prp)
+}
+
+// enclaveRwSet converts the enclave rwset into a Fabric rwset, using the read versions and range queries of
+// the simulated rwset
+func enclaveRwSet(fpcRwSet *protos.FPCKVSet, simulated *kvrwset.KVRWSet) (*kvrwset.KVRWSet, error) {
+	versions := make(map[string]*kvrwset.Version)
+	for _, r := range simulated.GetReads() {
+		versions[r.GetKey()] = r.GetVersion()
+	}
+	for _, rqi := range simulated.GetRangeQueriesInfo() {
+		for _, r := range rqi.GetRawReads().GetKvReads() {
+			versions[r.GetKey()] = r.GetVersion()
+		}
+	}
+
+	kvRWSet := &kvrwset.KVRWSet{RangeQueriesInfo: simulated.GetRangeQueriesInfo()}
+	for _, r := range fpcRwSet.GetRwSet().GetReads() {
+		key, err := endorsement.FabricKey(r.GetKey())
+		if err != nil {
+			return nil, err
+		}
+		version, ok := versions[key]
+		if !ok {
+			return nil, fmt.Errorf("enclave read of key %s was not observed by the peer", key)
+		}
+		kvRWSet.Reads = append(kvRWSet.Reads, &kvrwset.KVRead{Key: key, Version: version})
+	}
+	for _, w := range fpcRwSet.GetRwSet().GetWrites() {
+		key, err := endorsement.FabricKey(w.GetKey())
+		if err != nil {
+			return nil, err
+		}
+		kvRWSet.Writes = append(kvRWSet.Writes, &kvrwset.KVWrite{Key: key, IsDelete: w.GetIsDelete(), Value: w.GetValue()})
+	}
+	return kvRWSet, nil
+}
+
+// functionName returns the chaincode function invoked by a proposal
+func functionName(sp *peer.SignedProposal) (string, error) {
+	proposal, err := protoutil.UnmarshalProposal(sp.GetProposalBytes())
+	if err != nil {
+		return "", err
+	}
+	payload, err := protoutil.UnmarshalChaincodeProposalPayload(proposal.GetPayload())
+	if err != nil {
+		return "", err
+	}
+	cis, err := protoutil.UnmarshalChaincodeInvocationSpec(payload.GetInput())
+	if err != nil {
+		return "", err
+	}
+
+	args := cis.GetChaincodeSpec().GetInput().GetArgs()
+	if len(args) == 0 {
+		return "", nil
+	}
+	return string(args[0]), nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package endorsement
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	identities "github.com/hyperledger/fabric/core/handlers/endorsement/api/identities"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSigner struct{}
+
+func (testSigner) Serialize() ([]byte, error) {
+	return []byte("peer identity"), nil
+}
+
+func (testSigner) Sign(msg []byte) ([]byte, error) {
+	return append([]byte("signature over "), msg...), nil
+}
+
+type testFetcher struct{}
+
+func (testFetcher) SigningIdentityForRequest(*peer.SignedProposal) (identities.SigningIdentity, error) {
+	return testSigner{}, nil
+}
+
+func newSignedProposal(t *testing.T, function string) *peer.SignedProposal {
+	cis := &peer.ChaincodeInvocationSpec{ChaincodeSpec: &peer.ChaincodeSpec{
+		ChaincodeId: &peer.ChaincodeID{Name: "mycc"},
+		Input:       &peer.ChaincodeInput{Args: [][]byte{[]byte(function), []byte("request")}},
+	}}
+	proposal, _, err := protoutil.CreateProposalFromCIS(common.HeaderType_ENDORSER_TRANSACTION, "mychannel", cis, []byte("client"))
+	require.NoError(t, err)
+	return &peer.SignedProposal{ProposalBytes: protoutil.MarshalOrPanic(proposal)}
+}
+
+func newProposalResponsePayload(t *testing.T, responseMsg *protos.ChaincodeResponseMessage, simulated *kvrwset.KVRWSet) []byte {
+	signedResponseMsg := &protos.SignedChaincodeResponseMessage{
+		ChaincodeResponseMessage: utils.MarshalOrPanic(responseMsg),
+		Signature:                []byte("enclave signature"),
+	}
+	txRWSet := &rwset.TxReadWriteSet{NsRwset: []*rwset.NsReadWriteSet{
+		{Namespace: "ercc", Rwset: protoutil.MarshalOrPanic(&kvrwset.KVRWSet{Reads: []*kvrwset.KVRead{{Key: "erccKey"}}})},
+		{Namespace: "mycc", Rwset: protoutil.MarshalOrPanic(simulated)},
+	}}
+	ccAction := &peer.ChaincodeAction{
+		Results: protoutil.MarshalOrPanic(txRWSet),
+		Response: &peer.Response{
+			Status:  shim.OK,
+			Payload: []byte(base64.StdEncoding.EncodeToString(utils.MarshalOrPanic(signedResponseMsg))),
+		},
+		ChaincodeId: &peer.ChaincodeID{Name: "mycc"},
+	}
+	return protoutil.MarshalOrPanic(&peer.ProposalResponsePayload{
+		ProposalHash: []byte("proposal hash"),
+		Extension:    protoutil.MarshalOrPanic(ccAction),
+	})
+}
+
+func newResponseMessage() *protos.ChaincodeResponseMessage {
+	return &protos.ChaincodeResponseMessage{
+		FpcRwSet: &protos.FPCKVSet{
+			RwSet: &kvrwset.KVRWSet{
+				Reads: []*kvrwset.KVRead{{Key: "key1"}, {Key: ".asset.a."}, {Key: "absent"}},
+				Writes: []*kvrwset.KVWrite{
+					{Key: "key1", Value: []byte("value1")},
+					{Key: ".asset.b.", Value: []byte("valueB")},
+					{Key: "key2", IsDelete: true},
+				},
+			},
+			ReadValueHashes: [][]byte{[]byte("h1"), []byte("h2"), []byte("h3")},
+		},
+		EnclaveId: "someEnclaveId",
+	}
+}
+
+func namespaceRwSet(t *testing.T, prpBytes []byte, namespace string) *kvrwset.KVRWSet {
+	prp, err := protoutil.UnmarshalProposalResponsePayload(prpBytes)
+	require.NoError(t, err)
+	ccAction, err := protoutil.UnmarshalChaincodeAction(prp.GetExtension())
+	require.NoError(t, err)
+	txRWSet, err := protoutil.UnmarshalTxReadWriteSet(ccAction.GetResults())
+	require.NoError(t, err)
+	for _, ns := range txRWSet.GetNsRwset() {
+		if ns.GetNamespace() == namespace {
+			kvRWSet, err := protoutil.UnmarshalKVRWSet(ns.GetRwset())
+			require.NoError(t, err)
+			return kvRWSet
+		}
+	}
+	t.Fatalf("no rwset for namespace %s", namespace)
+	return nil
+}
+
+func TestEndorse(t *testing.T) {
+	p := (&PluginFactory{}).New()
+	assert.Error(t, p.Init())
+	require.NoError(t, p.Init(testFetcher{}))
+
+	compositeA, _ := shim.CreateCompositeKey("asset", []string{"a"})
+	compositeB, _ := shim.CreateCompositeKey("asset", []string{"b"})
+	simulated := &kvrwset.KVRWSet{
+		Reads: []*kvrwset.KVRead{
+			{Key: "key1", Version: &kvrwset.Version{BlockNum: 3, TxNum: 1}},
+			{Key: "absent"},
+		},
+		RangeQueriesInfo: []*kvrwset.RangeQueryInfo{{
+			StartKey:     compositeA,
+			ReadsInfo:    &kvrwset.RangeQueryInfo_RawReads{RawReads: &kvrwset.QueryReads{KvReads: []*kvrwset.KVRead{{Key: compositeA, Version: &kvrwset.Version{BlockNum: 2}}}}},
+			ItrExhausted: true,
+		}},
+	}
+
+	// the rwset of __invoke is replaced by the enclave rwset
+	prpBytes := newProposalResponsePayload(t, newResponseMessage(), simulated)
+	e, endorsedPrpBytes, err := p.Endorse(prpBytes, newSignedProposal(t, "__invoke"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("peer identity"), e.GetEndorser())
+	assert.Equal(t, append(append([]byte("signature over "), endorsedPrpBytes...), []byte("peer identity")...), e.GetSignature())
+
+	kvRWSet := namespaceRwSet(t, endorsedPrpBytes, "mycc")
+	assert.Equal(t, "key1", kvRWSet.GetReads()[0].GetKey())
+	assert.Equal(t, uint64(3), kvRWSet.GetReads()[0].GetVersion().GetBlockNum())
+	assert.Equal(t, compositeA, kvRWSet.GetReads()[1].GetKey())
+	assert.Equal(t, uint64(2), kvRWSet.GetReads()[1].GetVersion().GetBlockNum())
+	assert.Equal(t, "absent", kvRWSet.GetReads()[2].GetKey())
+	assert.Nil(t, kvRWSet.GetReads()[2].GetVersion())
+	assert.Len(t, kvRWSet.GetRangeQueriesInfo(), 1)
+	require.Len(t, kvRWSet.GetWrites(), 3)
+	assert.Equal(t, "key1", kvRWSet.GetWrites()[0].GetKey())
+	assert.Equal(t, []byte("value1"), kvRWSet.GetWrites()[0].GetValue())
+	assert.Equal(t, compositeB, kvRWSet.GetWrites()[1].GetKey())
+	assert.True(t, kvRWSet.GetWrites()[2].GetIsDelete())
+
+	// other namespaces are unchanged
+	assert.Equal(t, "erccKey", namespaceRwSet(t, endorsedPrpBytes, "ercc").GetReads()[0].GetKey())
+
+	// error when the peer did not observe an enclave read
+	responseMsg := newResponseMessage()
+	responseMsg.FpcRwSet.RwSet.Reads = append(responseMsg.FpcRwSet.RwSet.Reads, &kvrwset.KVRead{Key: "unobserved"})
+	_, _, err = p.Endorse(newProposalResponsePayload(t, responseMsg, simulated), newSignedProposal(t, "__invoke"))
+	assert.ErrorContains(t, err, "enclave read of key unobserved was not observed by the peer")
+
+	// responses reporting an error are not changed
+	responseMsg = newResponseMessage()
+	responseMsg.ErrorCode = protos.ErrorCode_ERROR_CODE_CHAINCODE_ERROR
+	prpBytes = newProposalResponsePayload(t, responseMsg, simulated)
+	_, endorsedPrpBytes, err = p.Endorse(prpBytes, newSignedProposal(t, "__invoke"))
+	assert.NoError(t, err)
+	assert.Equal(t, prpBytes, endorsedPrpBytes)
+
+	// other functions are endorsed as by the default endorsement plugin
+	prpBytes = []byte("some payload")
+	_, endorsedPrpBytes, err = p.Endorse(prpBytes, newSignedProposal(t, "__init"))
+	assert.NoError(t, err)
+	assert.Equal(t, prpBytes, endorsedPrpBytes)
+}
@@ -0,0 +1,483 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package validation implements the FPC validation plugin of the peer. It validates transactions created from
+// __invoke proposal responses which were endorsed by the FPC endorsement plugin: besides the endorsement policy,
+// it checks the enclave signature against the enclave credentials registered in ERCC and that the rwset of the
+// transaction is the rwset produced by the enclave. All other transactions of the chaincode, e.g., __endorse
+// transactions of the FPC Lite flow, are validated by the default validation plugin of the peer.
+package validation
+
+import (
+	"bytes"
+	"fmt"
+
+	protoV1 "github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/endorsement"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	lb "github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+	"github.com/hyperledger/fabric/bccsp/factory"
+	commonerrors "github.com/hyperledger/fabric/common/errors"
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/core/common/validation/statebased"
+	api "github.com/hyperledger/fabric/core/handlers/validation/api"
+	capabilities "github.com/hyperledger/fabric/core/handlers/validation/api/capabilities"
+	identities "github.com/hyperledger/fabric/core/handlers/validation/api/identities"
+	policies "github.com/hyperledger/fabric/core/handlers/validation/api/policies"
+	state "github.com/hyperledger/fabric/core/handlers/validation/api/state"
+	v20 "github.com/hyperledger/fabric/core/handlers/validation/builtin/v20"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("fpc-vscc")
+
+const (
+	// ErccNamespace is the namespace of the enclave registry
+	ErccNamespace = "ercc"
+
+	credentialsObjectType = "namespaces/credentials"
+
+	invokeFunction = "__invoke"
+
+	// lifecycleNamespace is the namespace of the chaincode definitions committed with the peer lifecycle
+	lifecycleNamespace = "_lifecycle"
+)
+
+// PluginFactory creates FPC validation plugins
+type PluginFactory struct{}
+
+// New returns a plugin using the BCCSP of the peer, thus, hashes and signatures are verified as configured in the
+// BCCSP section of core.yaml
+func (*PluginFactory) New() api.Plugin {
+	csp := crypto.NewBCCSPCrypto(factory.GetDefault())
+	return &Plugin{csp: csp, validator: endorsement.NewValidator(endorsement.WithCSP(csp))}
+}
+
+// TransactionValidator validates the transactions which are not created from __invoke proposals; it is the
+// validator of the default validation plugin of the peer for channels with V2_0 application capabilities
+type TransactionValidator interface {
+	Validate(block *common.Block, namespace string, txPosition int, actionPosition int, policy []byte) commonerrors.TxValidationError
+}
+
+// Plugin validates FPC transactions
+type Plugin struct {
+	csp              crypto.CSP
+	stateFetcher     state.StateFetcher
+	policyEvaluator  policies.PolicyEvaluator
+	validator        endorsement.Validation
+	defaultValidator TransactionValidator
+}
+
+// Init injects dependencies into the instance of the Plugin and creates the default validator, which requires
+// the same dependencies as the default validation plugin of the peer
+func (p *Plugin) Init(dependencies ...api.Dependency) error {
+	var (
+		deserializer        identities.IdentityDeserializer
+		caps                capabilities.Capabilities
+		collectionResources statebased.CollectionResources
+	)
+	for _, dep := range dependencies {
+		if stateFetcher, ok := dep.(state.StateFetcher); ok {
+			p.stateFetcher = stateFetcher
+		}
+		if policyEvaluator, ok := dep.(policies.PolicyEvaluator); ok {
+			p.policyEvaluator = policyEvaluator
+		}
+		if d, ok := dep.(identities.IdentityDeserializer); ok {
+			deserializer = d
+		}
+		if c, ok := dep.(capabilities.Capabilities); ok {
+			caps = c
+		}
+		if r, ok := dep.(statebased.CollectionResources); ok {
+			collectionResources = r
+		}
+	}
+	if p.stateFetcher == nil {
+		return errors.New("could not find StateFetcher in dependencies")
+	}
+	if p.policyEvaluator == nil {
+		return errors.New("could not find PolicyEvaluator in dependencies")
+	}
+	if p.defaultValidator != nil {
+		return nil
+	}
+
+	if deserializer == nil {
+		return errors.New("could not find IdentityDeserializer in dependencies")
+	}
+	if caps == nil {
+		return errors.New("could not find Capabilities in dependencies")
+	}
+	if collectionResources == nil {
+		return errors.New("could not find CollectionResources in dependencies")
+	}
+	p.defaultValidator = v20.New(caps, p.stateFetcher, deserializer, p.policyEvaluator, collectionResources)
+	return nil
+}
+
+// Validate returns nil if the action at the given position is a valid FPC transaction. An ExecutionFailureError
+// is returned if the state cannot be read, any other error marks the transaction as invalid. Actions of functions
+// other than __invoke are validated by the default validation plugin; in particular, __endorse transactions are
+// validated by ECC itself during endorsement, as without the plugins.
+func (p *Plugin) Validate(block *common.Block, namespace string, txPosition int, actionPosition int, contextData ...api.ContextDatum) error {
+	if len(contextData) == 0 {
+		return errors.New("no endorsement policy in context data")
+	}
+	policy, ok := contextData[0].(policies.SerializedPolicy)
+	if !ok {
+		return errors.New("no serialized endorsement policy in context data")
+	}
+
+	a, err := parseAction(block, txPosition, actionPosition)
+	if err != nil {
+		return errors.Wrapf(err, "invalid transaction %d in block %d", txPosition, block.GetHeader().GetNumber())
+	}
+	if a.function != invokeFunction {
+		return p.validateDefault(block, namespace, txPosition, actionPosition, policy.Bytes())
+	}
+	if a.ccAction.GetChaincodeId().GetName() != namespace {
+		return fmt.Errorf("action belongs to chaincode %s", a.ccAction.GetChaincodeId().GetName())
+	}
+
+	if err := p.checkEndorsementPolicy(policy.Bytes(), a); err != nil {
+		return err
+	}
+
+	s, err := p.stateFetcher.FetchState()
+	if err != nil {
+		return &api.ExecutionFailureError{Reason: fmt.Sprintf("cannot fetch state: %s", err)}
+	}
+	defer s.Done()
+
+	return p.checkEnclaveResponse(s, namespace, a)
+}
+
+// validateDefault validates a transaction as the default validation plugin of the peer
+func (p *Plugin) validateDefault(block *common.Block, namespace string, txPosition int, actionPosition int, policy []byte) error {
+	err := p.defaultValidator.Validate(block, namespace, txPosition, actionPosition, policy)
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*commonerrors.VSCCExecutionFailureError); ok {
+		return &api.ExecutionFailureError{Reason: err.Error()}
+	}
+	return err
+}
+
+type action struct {
+	channelHeader *common.ChannelHeader
+	function      string
+	prpBytes      []byte
+	endorsements  []*peer.Endorsement
+	ccAction      *peer.ChaincodeAction
+}
+
+func parseAction(block *common.Block, txPosition int, actionPosition int) (*action, error) {
+	if txPosition >= len(block.GetData().GetData()) {
+		return nil, fmt.Errorf("no transaction at position %d", txPosition)
+	}
+	env, err := protoutil.UnmarshalEnvelope(block.GetData().GetData()[txPosition])
+	if err != nil {
+		return nil, err
+	}
+	payload, err := protoutil.UnmarshalPayload(env.GetPayload())
+	if err != nil {
+		return nil, err
+	}
+	channelHeader, err := protoutil.UnmarshalChannelHeader(payload.GetHeader().GetChannelHeader())
+	if err != nil {
+		return nil, err
+	}
+	tx, err := protoutil.UnmarshalTransaction(payload.GetData())
+	if err != nil {
+		return nil, err
+	}
+	if actionPosition >= len(tx.GetActions()) {
+		return nil, fmt.Errorf("no action at position %d", actionPosition)
+	}
+	actionPayload, err := protoutil.UnmarshalChaincodeActionPayload(tx.GetActions()[actionPosition].GetPayload())
+	if err != nil {
+		return nil, err
+	}
+	function, err := functionName(actionPayload.GetChaincodeProposalPayload())
+	if err != nil {
+		return nil, err
+	}
+	prpBytes := actionPayload.GetAction().GetProposalResponsePayload()
+	prp, err := protoutil.UnmarshalProposalResponsePayload(prpBytes)
+	if err != nil {
+		return nil, err
+	}
+	ccAction, err := protoutil.UnmarshalChaincodeAction(prp.GetExtension())
+	if err != nil {
+		return nil, err
+	}
+
+	return &action{
+		channelHeader: channelHeader,
+		function:      function,
+		prpBytes:      prpBytes,
+		endorsements:  actionPayload.GetAction().GetEndorsements(),
+		ccAction:      ccAction,
+	}, nil
+}
+
+// functionName returns the chaincode function invoked by the proposal payload of a transaction
+func functionName(proposalPayload []byte) (string, error) {
+	payload, err := protoutil.UnmarshalChaincodeProposalPayload(proposalPayload)
+	if err != nil {
+		return "", err
+	}
+	cis, err := protoutil.UnmarshalChaincodeInvocationSpec(payload.GetInput())
+	if err != nil {
+		return "", err
+	}
+
+	args := cis.GetChaincodeSpec().GetInput().GetArgs()
+	if len(args) == 0 {
+		return "", nil
+	}
+	return string(args[0]), nil
+}
+
+func (p *Plugin) checkEndorsementPolicy(policyBytes []byte, a *action) error {
+	signatureSet := make([]*protoutil.SignedData, 0, len(a.endorsements))
+	for _, e := range a.endorsements {
+		data := make([]byte, 0, len(a.prpBytes)+len(e.GetEndorser()))
+		data = append(data, a.prpBytes...)
+		data = append(data, e.GetEndorser()...)
+		signatureSet = append(signatureSet, &protoutil.SignedData{
+			Data:      data,
+			Identity:  e.GetEndorser(),
+			Signature: e.GetSignature(),
+		})
+	}
+
+	if err := p.policyEvaluator.Evaluate(policyBytes, signatureSet); err != nil {
+		return errors.Wrap(err, "endorsement policy not satisfied")
+	}
+	return nil
+}
+
+// checkEnclaveResponse checks the enclave response of an action against the enclave credentials registered in ERCC
+// and against the rwset of the action
+func (p *Plugin) checkEnclaveResponse(s state.State, namespace string, a *action) error {
+	signedResponseMsg, responseMsg, err := utils.UnmarshalChaincodeResponseMessages(string(a.ccAction.GetResponse().GetPayload()))
+	if err != nil {
+		return errors.Wrap(err, "cannot extract chaincode response message")
+	}
+	if responseMsg.GetErrorCode() != protos.ErrorCode_ERROR_CODE_OK {
+		return fmt.Errorf("enclave response reports an error (%s)", responseMsg.GetErrorCode())
+	}
+
+	// get the enclave credentials from ercc
+	credentialsKey, err := shim.CreateCompositeKey(credentialsObjectType, []string{namespace, responseMsg.GetEnclaveId()})
+	if err != nil {
+		return err
+	}
+	values, err := s.GetStateMultipleKeys(ErccNamespace, []string{credentialsKey})
+	if err != nil {
+		return &api.ExecutionFailureError{Reason: fmt.Sprintf("cannot read enclave credentials: %s", err)}
+	}
+	if len(values) != 1 || len(values[0]) == 0 {
+		return fmt.Errorf("no credentials found for enclaveId = %s", responseMsg.GetEnclaveId())
+	}
+	credentials, err := utils.UnmarshalCredentials(string(values[0]))
+	if err != nil {
+		return err
+	}
+	attestedData, err := utils.UnmarshalAttestedData(credentials.GetSerializedAttestedData())
+	if err != nil {
+		return err
+	}
+	version, sequence, err := chaincodeDefinition(s, namespace)
+	if err != nil {
+		return err
+	}
+	ccParams := attestedData.GetCcParams()
+	if ccParams.GetChannelId() != a.channelHeader.GetChannelId() || ccParams.GetChaincodeId() != namespace ||
+		ccParams.GetVersion() != version || ccParams.GetSequence() != sequence {
+		return fmt.Errorf("ccParams don't match")
+	}
+
+	// validate enclave signature and request hash
+	if err := p.validator.Validate(signedResponseMsg, attestedData); err != nil {
+		return err
+	}
+
+	// the enclave must have processed the proposal of this transaction; as the tx ID is unique, the response
+	// cannot be committed twice
+	txId, err := proposalTxId(responseMsg.GetProposal())
+	if err != nil {
+		return err
+	}
+	if txId != a.channelHeader.GetTxId() {
+		return fmt.Errorf("enclave response belongs to transaction %s", txId)
+	}
+
+	// the rwset must match the signed digest, if any
+	if responseMsg.GetRwSetDigest() != nil {
+		digest, err := utils.GetRwSetDigest(responseMsg.GetFpcRwSet(), crypto.Hasher(p.csp, responseMsg.GetHashAlgorithm()))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(digest, responseMsg.GetRwSetDigest()) {
+			return fmt.Errorf("rwset does not match rwset digest")
+		}
+	}
+
+	kvRWSet, err := namespaceRwSet(a.ccAction.GetResults(), namespace)
+	if err != nil {
+		return err
+	}
+	if err := matchRwSet(responseMsg.GetFpcRwSet(), kvRWSet); err != nil {
+		return err
+	}
+	return p.checkReadValues(s, namespace, responseMsg.GetFpcRwSet(), kvRWSet)
+}
+
+// chaincodeDefinition returns the version and the sequence of the committed definition of the chaincode, as stored
+// by the peer lifecycle (see the Serializer of the lifecycle package of Fabric)
+func chaincodeDefinition(s state.State, namespace string) (string, int64, error) {
+	keys := []string{
+		fmt.Sprintf("namespaces/fields/%s/Sequence", namespace),
+		fmt.Sprintf("namespaces/fields/%s/EndorsementInfo", namespace),
+	}
+	values, err := s.GetStateMultipleKeys(lifecycleNamespace, keys)
+	if err != nil {
+		return "", 0, &api.ExecutionFailureError{Reason: fmt.Sprintf("cannot read chaincode definition: %s", err)}
+	}
+	if len(values) != 2 || len(values[0]) == 0 || len(values[1]) == 0 {
+		return "", 0, fmt.Errorf("no chaincode definition found for %s", namespace)
+	}
+
+	sequence := &lb.StateData{}
+	if err := protoV1.Unmarshal(values[0], sequence); err != nil {
+		return "", 0, errors.Wrap(err, "invalid chaincode definition sequence")
+	}
+	endorsementInfoBytes := &lb.StateData{}
+	if err := protoV1.Unmarshal(values[1], endorsementInfoBytes); err != nil {
+		return "", 0, errors.Wrap(err, "invalid chaincode definition endorsement info")
+	}
+	endorsementInfo := &lb.ChaincodeEndorsementInfo{}
+	if err := protoV1.Unmarshal(endorsementInfoBytes.GetBytes(), endorsementInfo); err != nil {
+		return "", 0, errors.Wrap(err, "invalid chaincode definition endorsement info")
+	}
+
+	return endorsementInfo.GetVersion(), sequence.GetInt64(), nil
+}
+
+func proposalTxId(signedProposal *peer.SignedProposal) (string, error) {
+	proposal, err := protoutil.UnmarshalProposal(signedProposal.GetProposalBytes())
+	if err != nil {
+		return "", errors.Wrap(err, "invalid proposal")
+	}
+	header, err := protoutil.UnmarshalHeader(proposal.GetHeader())
+	if err != nil {
+		return "", errors.Wrap(err, "invalid proposal header")
+	}
+	channelHeader, err := protoutil.UnmarshalChannelHeader(header.GetChannelHeader())
+	if err != nil {
+		return "", errors.Wrap(err, "invalid proposal header")
+	}
+	return channelHeader.GetTxId(), nil
+}
+
+func namespaceRwSet(results []byte, namespace string) (*kvrwset.KVRWSet, error) {
+	txRWSet, err := protoutil.UnmarshalTxReadWriteSet(results)
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range txRWSet.GetNsRwset() {
+		if ns.GetNamespace() == namespace {
+			return protoutil.UnmarshalKVRWSet(ns.GetRwset())
+		}
+	}
+	return &kvrwset.KVRWSet{}, nil
+}
+
+// matchRwSet checks that the rwset of the transaction is the enclave rwset, as produced by the endorsement plugin
+func matchRwSet(fpcRwSet *protos.FPCKVSet, kvRWSet *kvrwset.KVRWSet) error {
+	enclaveRwSet := fpcRwSet.GetRwSet()
+	if enclaveRwSet.GetRangeQueriesInfo() != nil {
+		return fmt.Errorf("RangeQuery support not implemented, missing hash check")
+	}
+	if len(kvRWSet.GetMetadataWrites()) > 0 {
+		return fmt.Errorf("metadata writes are not supported")
+	}
+
+	if len(enclaveRwSet.GetReads()) != len(kvRWSet.GetReads()) {
+		return fmt.Errorf("%d reads in the transaction but %d reads by the enclave", len(kvRWSet.GetReads()), len(enclaveRwSet.GetReads()))
+	}
+	for i, r := range enclaveRwSet.GetReads() {
+		key, err := endorsement.FabricKey(r.GetKey())
+		if err != nil {
+			return err
+		}
+		if key != kvRWSet.GetReads()[i].GetKey() {
+			return fmt.Errorf("read of key %s does not match the enclave rwset", kvRWSet.GetReads()[i].GetKey())
+		}
+	}
+
+	if len(enclaveRwSet.GetWrites()) != len(kvRWSet.GetWrites()) {
+		return fmt.Errorf("%d writes in the transaction but %d writes by the enclave", len(kvRWSet.GetWrites()), len(enclaveRwSet.GetWrites()))
+	}
+	for i, w := range enclaveRwSet.GetWrites() {
+		key, err := endorsement.FabricKey(w.GetKey())
+		if err != nil {
+			return err
+		}
+		txWrite := kvRWSet.GetWrites()[i]
+		if key != txWrite.GetKey() || w.GetIsDelete() != txWrite.GetIsDelete() || !bytes.Equal(w.GetValue(), txWrite.GetValue()) {
+			return fmt.Errorf("write of key %s does not match the enclave rwset", txWrite.GetKey())
+		}
+	}
+	return nil
+}
+
+// checkReadValues checks that the enclave has read the committed values. The peer checks afterwards that the
+// read versions are still current, i.e., that the values did not change since.
+func (p *Plugin) checkReadValues(s state.State, namespace string, fpcRwSet *protos.FPCKVSet, kvRWSet *kvrwset.KVRWSet) error {
+	reads := kvRWSet.GetReads()
+	if len(reads) == 0 {
+		return nil
+	}
+	if len(fpcRwSet.GetReadValueHashes()) != len(reads) {
+		return fmt.Errorf("%d read value hashes but %d reads", len(fpcRwSet.GetReadValueHashes()), len(reads))
+	}
+
+	keys := make([]string, len(reads))
+	for i, r := range reads {
+		keys[i] = r.GetKey()
+	}
+	values, err := s.GetStateMultipleKeys(namespace, keys)
+	if err != nil {
+		return &api.ExecutionFailureError{Reason: fmt.Sprintf("cannot read state: %s", err)}
+	}
+	if len(values) != len(keys) {
+		return &api.ExecutionFailureError{Reason: fmt.Sprintf("%d values for %d keys", len(values), len(keys))}
+	}
+
+	for i, v := range values {
+		h, err := p.csp.Hash(fpcRwSet.GetHashAlgorithm(), v)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(h, fpcRwSet.GetReadValueHashes()[i]) {
+			logger.Debugf("value hash mismatch for key %s", keys[i])
+			return fmt.Errorf("value hash mismatch for key %s", keys[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package validation
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-private-chaincode/fabric/plugins/endorsement"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	lb "github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+	commonerrors "github.com/hyperledger/fabric/common/errors"
+	identities "github.com/hyperledger/fabric/core/handlers/endorsement/api/identities"
+	api "github.com/hyperledger/fabric/core/handlers/validation/api"
+	state "github.com/hyperledger/fabric/core/handlers/validation/api/state"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	channelId   = "mychannel"
+	chaincodeId = "mycc"
+	mrenclave   = "7ca3f2e4d5b3bde1b1e3b3d1e3b3c5d2f1e3b3d1e3b3c5d2f1e3b3d1e3b3c5d2"
+)
+
+type testSigner struct {
+	identity []byte
+}
+
+func (s *testSigner) Serialize() ([]byte, error) {
+	return s.identity, nil
+}
+
+func (s *testSigner) Sign(msg []byte) ([]byte, error) {
+	return []byte("signature"), nil
+}
+
+func (s *testSigner) SigningIdentityForRequest(*peer.SignedProposal) (identities.SigningIdentity, error) {
+	return s, nil
+}
+
+type testState struct {
+	values map[string]map[string][]byte
+}
+
+func (s *testState) GetStateMultipleKeys(namespace string, keys []string) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		values[i] = s.values[namespace][k]
+	}
+	return values, nil
+}
+
+func (s *testState) GetStateRangeScanIterator(namespace string, startKey string, endKey string) (state.ResultsIterator, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (s *testState) GetStateMetadata(namespace, key string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func (s *testState) GetPrivateDataMetadataByHash(namespace, collection string, keyhash []byte) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func (s *testState) Done() {}
+
+type testStateFetcher struct {
+	state *testState
+	err   error
+}
+
+func (f *testStateFetcher) FetchState() (state.State, error) {
+	return f.state, f.err
+}
+
+type testPolicyEvaluator struct {
+	signatureSet []*protoutil.SignedData
+	err          error
+}
+
+func (e *testPolicyEvaluator) Evaluate(policyBytes []byte, signatureSet []*protoutil.SignedData) error {
+	e.signatureSet = signatureSet
+	return e.err
+}
+
+// testDefaultValidator records the transactions passed to the default validation
+type testDefaultValidator struct {
+	validated []int
+	err       commonerrors.TxValidationError
+}
+
+func (v *testDefaultValidator) Validate(block *common.Block, namespace string, txPosition int, actionPosition int, policy []byte) commonerrors.TxValidationError {
+	v.validated = append(v.validated, txPosition)
+	return v.err
+}
+
+type serializedPolicy []byte
+
+func (p serializedPolicy) Bytes() []byte {
+	return p
+}
+
+// fixture contains an enclave registered in ERCC and the committed state of the chaincode
+type fixture struct {
+	csp        crypto.CSP
+	enclaveSk  []byte
+	enclaveId  string
+	client     *testSigner
+	endorser   *testSigner
+	state      *testState
+	evaluator  *testPolicyEvaluator
+	fetcher    *testStateFetcher
+	defaultVal *testDefaultValidator
+	plugin     api.Plugin
+	credsKey   string
+	oldValue   []byte
+	newValue   []byte
+	requestMsg []byte
+}
+
+func newFixture(t *testing.T) *fixture {
+	f := &fixture{
+		csp:        crypto.GetDefaultCSP(),
+		client:     &testSigner{identity: []byte("client")},
+		endorser:   &testSigner{identity: []byte("peer")},
+		evaluator:  &testPolicyEvaluator{},
+		defaultVal: &testDefaultValidator{},
+		oldValue:   []byte("old value"),
+		newValue:   []byte("new value"),
+		requestMsg: []byte("request"),
+	}
+
+	enclaveVk, enclaveSk, err := f.csp.NewECDSAKeys()
+	require.NoError(t, err)
+	f.enclaveSk = enclaveSk
+	attestedData := &protos.AttestedData{
+		CcParams:  &protos.CCParameters{ChannelId: channelId, ChaincodeId: chaincodeId, Version: mrenclave, Sequence: 1},
+		EnclaveVk: enclaveVk,
+	}
+	serializedAttestedData, err := anypb.New(attestedData)
+	require.NoError(t, err)
+	f.enclaveId = utils.GetEnclaveId(attestedData)
+
+	f.credsKey, err = shim.CreateCompositeKey(credentialsObjectType, []string{chaincodeId, f.enclaveId})
+	require.NoError(t, err)
+	f.state = &testState{values: map[string]map[string][]byte{
+		ErccNamespace: {f.credsKey: []byte(utils.MarshallProtoBase64(&protos.Credentials{SerializedAttestedData: serializedAttestedData}))},
+		chaincodeId:   {"key1": f.oldValue},
+	}}
+	f.commitDefinition(mrenclave, 1)
+	f.fetcher = &testStateFetcher{state: f.state}
+
+	plugin := (&PluginFactory{}).New().(*Plugin)
+	plugin.defaultValidator = f.defaultVal
+	require.NoError(t, plugin.Init(f.fetcher, f.evaluator))
+	f.plugin = plugin
+	return f
+}
+
+// commitDefinition stores a chaincode definition in the lifecycle namespace as the peer lifecycle does
+func (f *fixture) commitDefinition(version string, sequence int64) {
+	f.state.values[lifecycleNamespace] = map[string][]byte{
+		"namespaces/fields/" + chaincodeId + "/Sequence": protoutil.MarshalOrPanic(&lb.StateData{Type: &lb.StateData_Int64{Int64: sequence}}),
+		"namespaces/fields/" + chaincodeId + "/EndorsementInfo": protoutil.MarshalOrPanic(&lb.StateData{Type: &lb.StateData_Bytes{
+			Bytes: protoutil.MarshalOrPanic(&lb.ChaincodeEndorsementInfo{Version: version}),
+		}}),
+	}
+}
+
+func (f *fixture) newProposal(t *testing.T) *peer.Proposal {
+	return f.newProposalWithFunction(t, invokeFunction)
+}
+
+func (f *fixture) newProposalWithFunction(t *testing.T, function string) *peer.Proposal {
+	cis := &peer.ChaincodeInvocationSpec{ChaincodeSpec: &peer.ChaincodeSpec{
+		ChaincodeId: &peer.ChaincodeID{Name: chaincodeId},
+		Input:       &peer.ChaincodeInput{Args: [][]byte{[]byte(function), []byte(base64.StdEncoding.EncodeToString(f.requestMsg))}},
+	}}
+	proposal, _, err := protoutil.CreateProposalFromCIS(common.HeaderType_ENDORSER_TRANSACTION, channelId, cis, f.client.identity)
+	require.NoError(t, err)
+	return proposal
+}
+
+// endorse runs the enclave and the endorsement plugin for a proposal and returns the endorsed proposal response
+func (f *fixture) endorse(t *testing.T, proposal *peer.Proposal) *peer.ProposalResponse {
+	signedProposal := &peer.SignedProposal{ProposalBytes: protoutil.MarshalOrPanic(proposal)}
+
+	requestHash := sha256.Sum256(f.requestMsg)
+	oldHash := sha256.Sum256(f.oldValue)
+	responseMsg := &protos.ChaincodeResponseMessage{
+		FpcRwSet: &protos.FPCKVSet{
+			RwSet: &kvrwset.KVRWSet{
+				Reads:  []*kvrwset.KVRead{{Key: "key1"}},
+				Writes: []*kvrwset.KVWrite{{Key: "key1", Value: f.newValue}},
+			},
+			ReadValueHashes: [][]byte{oldHash[:]},
+		},
+		Proposal:                    signedProposal,
+		ChaincodeRequestMessageHash: requestHash[:],
+		EnclaveId:                   f.enclaveId,
+	}
+	responseMsgBytes := utils.MarshalOrPanic(responseMsg)
+	signature, err := f.csp.SignMessage(f.enclaveSk, responseMsgBytes)
+	require.NoError(t, err)
+	signedResponseMsg := &protos.SignedChaincodeResponseMessage{ChaincodeResponseMessage: responseMsgBytes, Signature: signature}
+
+	simulated := &kvrwset.KVRWSet{Reads: []*kvrwset.KVRead{{Key: "key1", Version: &kvrwset.Version{BlockNum: 1}}}}
+	ccAction := &peer.ChaincodeAction{
+		Results: protoutil.MarshalOrPanic(&rwset.TxReadWriteSet{NsRwset: []*rwset.NsReadWriteSet{
+			{Namespace: chaincodeId, Rwset: protoutil.MarshalOrPanic(simulated)},
+		}}),
+		Response: &peer.Response{
+			Status:  shim.OK,
+			Payload: []byte(base64.StdEncoding.EncodeToString(utils.MarshalOrPanic(signedResponseMsg))),
+		},
+		ChaincodeId: &peer.ChaincodeID{Name: chaincodeId},
+	}
+	prpBytes := protoutil.MarshalOrPanic(&peer.ProposalResponsePayload{
+		ProposalHash: []byte("proposal hash"),
+		Extension:    protoutil.MarshalOrPanic(ccAction),
+	})
+
+	escc := (&endorsement.PluginFactory{}).New()
+	require.NoError(t, escc.Init(f.endorser))
+	e, prpBytes, err := escc.Endorse(prpBytes, signedProposal)
+	require.NoError(t, err)
+
+	return &peer.ProposalResponse{Version: 1, Response: &peer.Response{Status: shim.OK}, Payload: prpBytes, Endorsement: e}
+}
+
+func (f *fixture) newBlock(t *testing.T, proposal *peer.Proposal, response *peer.ProposalResponse) *common.Block {
+	env, err := protoutil.CreateSignedTx(proposal, f.client, response)
+	require.NoError(t, err)
+	return &common.Block{
+		Header: &common.BlockHeader{Number: 5},
+		Data:   &common.BlockData{Data: [][]byte{protoutil.MarshalOrPanic(env)}},
+	}
+}
+
+// tamperWrite changes the value written by the transaction
+func tamperWrite(t *testing.T, response *peer.ProposalResponse) {
+	prp, err := protoutil.UnmarshalProposalResponsePayload(response.Payload)
+	require.NoError(t, err)
+	ccAction, err := protoutil.UnmarshalChaincodeAction(prp.GetExtension())
+	require.NoError(t, err)
+	kvRWSet, err := namespaceRwSet(ccAction.GetResults(), chaincodeId)
+	require.NoError(t, err)
+	kvRWSet.Writes[0].Value = []byte("forged value")
+	ccAction.Results = protoutil.MarshalOrPanic(&rwset.TxReadWriteSet{NsRwset: []*rwset.NsReadWriteSet{
+		{Namespace: chaincodeId, Rwset: protoutil.MarshalOrPanic(kvRWSet)},
+	}})
+	prp.Extension = protoutil.MarshalOrPanic(ccAction)
+	response.Payload = protoutil.MarshalOrPanic(prp)
+}
+
+func TestValidate(t *testing.T) {
+	policy := serializedPolicy("policy")
+
+	t.Run("valid", func(t *testing.T) {
+		f := newFixture(t)
+		proposal := f.newProposal(t)
+		block := f.newBlock(t, proposal, f.endorse(t, proposal))
+
+		assert.NoError(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy))
+		require.Len(t, f.evaluator.signatureSet, 1)
+		assert.Equal(t, []byte("peer"), f.evaluator.signatureSet[0].Identity)
+		assert.Empty(t, f.defaultVal.validated)
+
+		// the action must belong to the validated chaincode
+		assert.EqualError(t, f.plugin.Validate(block, "other", 0, 0, policy), "action belongs to chaincode mycc")
+		// the endorsement policy is required
+		assert.Error(t, f.plugin.Validate(block, chaincodeId, 0, 0))
+	})
+
+	t.Run("endorsement policy not satisfied", func(t *testing.T) {
+		f := newFixture(t)
+		proposal := f.newProposal(t)
+		block := f.newBlock(t, proposal, f.endorse(t, proposal))
+
+		f.evaluator.err = fmt.Errorf("some error")
+		assert.ErrorContains(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy), "endorsement policy not satisfied")
+	})
+
+	t.Run("state not available", func(t *testing.T) {
+		f := newFixture(t)
+		proposal := f.newProposal(t)
+		block := f.newBlock(t, proposal, f.endorse(t, proposal))
+
+		f.fetcher.err = fmt.Errorf("some error")
+		err := f.plugin.Validate(block, chaincodeId, 0, 0, policy)
+		assert.IsType(t, &api.ExecutionFailureError{}, err)
+	})
+
+	t.Run("enclave not registered", func(t *testing.T) {
+		f := newFixture(t)
+		proposal := f.newProposal(t)
+		block := f.newBlock(t, proposal, f.endorse(t, proposal))
+
+		delete(f.state.values[ErccNamespace], f.credsKey)
+		assert.ErrorContains(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy), "no credentials found")
+	})
+
+	t.Run("enclave of another chaincode definition", func(t *testing.T) {
+		f := newFixture(t)
+		proposal := f.newProposal(t)
+		block := f.newBlock(t, proposal, f.endorse(t, proposal))
+
+		// the chaincode was upgraded after the endorsement
+		f.commitDefinition(mrenclave, 2)
+		assert.EqualError(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy), "ccParams don't match")
+
+		f.commitDefinition("other mrenclave", 1)
+		assert.EqualError(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy), "ccParams don't match")
+
+		delete(f.state.values, lifecycleNamespace)
+		assert.EqualError(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy), "no chaincode definition found for mycc")
+	})
+
+	t.Run("invalid enclave signature", func(t *testing.T) {
+		f := newFixture(t)
+		// the response is signed by another enclave
+		_, otherSk, err := f.csp.NewECDSAKeys()
+		require.NoError(t, err)
+		f.enclaveSk = otherSk
+
+		proposal := f.newProposal(t)
+		block := f.newBlock(t, proposal, f.endorse(t, proposal))
+		assert.EqualError(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy), "enclave signature verification failed")
+	})
+
+	t.Run("response of another proposal", func(t *testing.T) {
+		f := newFixture(t)
+		proposal := f.newProposal(t)
+		response := f.endorse(t, proposal)
+
+		// the client submits the response in a transaction of another proposal, e.g., to replay it
+		otherProposal := f.newProposal(t)
+		block := f.newBlock(t, otherProposal, response)
+		assert.ErrorContains(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy), "enclave response belongs to transaction")
+	})
+
+	t.Run("forged write", func(t *testing.T) {
+		f := newFixture(t)
+		proposal := f.newProposal(t)
+		response := f.endorse(t, proposal)
+
+		tamperWrite(t, response)
+		block := f.newBlock(t, proposal, response)
+		assert.EqualError(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy), "write of key key1 does not match the enclave rwset")
+	})
+
+	t.Run("stale read", func(t *testing.T) {
+		f := newFixture(t)
+		proposal := f.newProposal(t)
+		block := f.newBlock(t, proposal, f.endorse(t, proposal))
+
+		f.state.values[chaincodeId]["key1"] = []byte("other value")
+		assert.EqualError(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy), "value hash mismatch for key key1")
+	})
+
+	t.Run("other functions", func(t *testing.T) {
+		f := newFixture(t)
+		// e.g., an __endorse transaction of the FPC Lite flow, whose rwset is validated by ECC during endorsement
+		proposal := f.newProposalWithFunction(t, "__endorse")
+		block := f.newBlock(t, proposal, f.endorse(t, proposal))
+
+		assert.NoError(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy))
+		assert.Equal(t, []int{0}, f.defaultVal.validated)
+		assert.Empty(t, f.evaluator.signatureSet)
+
+		f.defaultVal.err = &commonerrors.VSCCEndorsementPolicyError{Err: fmt.Errorf("policy error")}
+		assert.EqualError(t, f.plugin.Validate(block, chaincodeId, 0, 0, policy), "policy error")
+
+		f.defaultVal.err = &commonerrors.VSCCExecutionFailureError{Err: fmt.Errorf("execution error")}
+		err := f.plugin.Validate(block, chaincodeId, 0, 0, policy)
+		assert.IsType(t, &api.ExecutionFailureError{}, err)
+		assert.EqualError(t, err, "execution error")
+	})
+
+	t.Run("missing dependencies", func(t *testing.T) {
+		f := newFixture(t)
+		assert.Error(t, (&PluginFactory{}).New().Init(f.fetcher))
+		assert.Error(t, (&PluginFactory{}).New().Init(f.evaluator))
+		// the default validation requires the dependencies of the default validation plugin
+		assert.EqualError(t, (&PluginFactory{}).New().Init(f.fetcher, f.evaluator), "could not find IdentityDeserializer in dependencies")
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/cvm"
 	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
 	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/simulation"
 	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
@@ -71,11 +72,15 @@ type CredentialConverter struct {
 	dispatcher *converterDispatcher
 }
 
-func NewDefaultCredentialConverter() *CredentialConverter {
+// NewDefaultCredentialConverter returns a CredentialConverter supporting all available attestation types.
+// Optionally, IASClientOption can be provided to configure the IAS client used for EPID attestations.
+func NewDefaultCredentialConverter(opts ...epid.IASClientOption) *CredentialConverter {
 	return NewCredentialConverter(
 		simulation.NewSimulationConverter(),
-		epid.NewEpidLinkableConverter(),
-		epid.NewEpidUnlinkableConverter(),
+		epid.NewEpidLinkableConverter(opts...),
+		epid.NewEpidUnlinkableConverter(opts...),
+		cvm.NewTDXConverter(),
+		cvm.NewSEVSNPConverter(),
 	)
 }
 
@@ -0,0 +1,35 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cvm
+
+import (
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
+)
+
+// NewTDXConverter creates a new attestation converter for TDX-style confidential VMs
+func NewTDXConverter() *types.Converter {
+	return &types.Converter{
+		Type:      TDXType,
+		Converter: convert,
+	}
+}
+
+// NewSEVSNPConverter creates a new attestation converter for SEV-SNP-style confidential VMs
+func NewSEVSNPConverter() *types.Converter {
+	return &types.Converter{
+		Type:      SEVSNPType,
+		Converter: convert,
+	}
+}
+
+func convert(attestationBytes []byte) (evidenceBytes []byte, err error) {
+	// a signed report is already verifiable by itself, thus, the attestation is used as evidence
+	if _, _, err := UnmarshalSignedReport(attestationBytes); err != nil {
+		return nil, err
+	}
+	return attestationBytes, nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cvm
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
+	"github.com/pkg/errors"
+)
+
+// ReportProvider abstracts the platform-specific interface (e.g., the guest attestation device of the
+// confidential VM) that produces a signed report for the given report data.
+type ReportProvider interface {
+	GetSignedReport(reportData []byte) (*SignedReport, error)
+}
+
+// NewTDXIssuer creates a new attestation issuer for TDX-style confidential VMs
+func NewTDXIssuer(provider ReportProvider) *types.Issuer {
+	return &types.Issuer{
+		Type:  TDXType,
+		Issue: newIssueFunction(TDXType, provider),
+	}
+}
+
+// NewSEVSNPIssuer creates a new attestation issuer for SEV-SNP-style confidential VMs
+func NewSEVSNPIssuer(provider ReportProvider) *types.Issuer {
+	return &types.Issuer{
+		Type:  SEVSNPType,
+		Issue: newIssueFunction(SEVSNPType, provider),
+	}
+}
+
+func newIssueFunction(attestationType string, provider ReportProvider) types.IssueFunction {
+	return func(customData []byte) ([]byte, error) {
+		signed, err := provider.GetSignedReport(ReportData(customData))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot get signed report")
+		}
+
+		signedBytes, err := json.Marshal(signed)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot marshal signed report")
+		}
+
+		return json.Marshal(&types.Attestation{
+			Type: attestationType,
+			Data: string(signedBytes),
+		})
+	}
+}
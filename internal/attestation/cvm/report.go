@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cvm
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	TDXType    = "cvm-tdx"
+	SEVSNPType = "cvm-sev-snp"
+
+	// reportDataSize is the size of the user data field covered by a confidential VM report
+	reportDataSize = 64
+)
+
+// Report is the measurement report produced by the confidential VM platform.
+// LaunchMeasurement identifies the initial image of the confidential VM (e.g., MRTD for TDX or the launch digest
+// for SEV-SNP); RuntimeMeasurements contains the runtime extendable measurement registers (e.g., rtmr0 .. rtmr3).
+// All measurements and the report data are hex encoded.
+type Report struct {
+	Platform            string            `json:"platform"`
+	LaunchMeasurement   string            `json:"launch_measurement"`
+	RuntimeMeasurements map[string]string `json:"runtime_measurements,omitempty"`
+	ReportData          string            `json:"report_data"`
+}
+
+// SignedReport is the attestation (and evidence) format of a confidential VM.
+// Report contains the base64 encoded serialized Report, and Signature the base64 encoded signature over it,
+// created with the key of the first (leaf) certificate in Certificates. Certificates are PEM encoded and
+// ordered from leaf to (excluding) the root.
+type SignedReport struct {
+	Report       string   `json:"report"`
+	Signature    string   `json:"signature"`
+	Certificates []string `json:"certificates"`
+}
+
+// ReportData returns the report data binding a statement to a confidential VM report.
+// Same as with SGX, the report data is the sha256 hash of the statement padded with zeros to 64 bytes.
+func ReportData(statement []byte) []byte {
+	h := sha256.Sum256(statement)
+	reportData := make([]byte, reportDataSize)
+	copy(reportData, h[:])
+	return reportData
+}
+
+// UnmarshalSignedReport parses a json encoded SignedReport and returns the SignedReport together with the decoded Report
+func UnmarshalSignedReport(serializedSignedReport []byte) (*SignedReport, *Report, error) {
+	signed := &SignedReport{}
+	if err := json.Unmarshal(serializedSignedReport, signed); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot unmarshal signed report")
+	}
+
+	reportBytes, err := base64.StdEncoding.DecodeString(signed.Report)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot decode report")
+	}
+
+	report := &Report{}
+	if err := json.Unmarshal(reportBytes, report); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot unmarshal report")
+	}
+
+	return signed, report, nil
+}
@@ -0,0 +1,208 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cvm
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
+	"github.com/pkg/errors"
+)
+
+// RootCertificatesEnv is the environment variable pointing to a PEM file with the trusted report signing roots.
+// It is used by verifiers created without WithRootCertificates.
+const RootCertificatesEnv = "FPC_CVM_ROOT_CERTS"
+
+const (
+	tdxPlatform    = "tdx"
+	sevSNPPlatform = "sev-snp"
+)
+
+type verifier struct {
+	platform string
+	roots    *x509.CertPool
+}
+
+type VerifierOption func(*verifier)
+
+// WithRootCertificates option sets the trusted roots for the report signing certificate chain.
+// If not set, the roots are loaded from the file referenced by RootCertificatesEnv when verifying.
+func WithRootCertificates(roots *x509.CertPool) VerifierOption {
+	return func(v *verifier) {
+		v.roots = roots
+	}
+}
+
+// NewTDXVerifier creates a new attestation verifier for TDX-style confidential VMs
+func NewTDXVerifier(opts ...VerifierOption) *types.Verifier {
+	return &types.Verifier{
+		Type:   TDXType,
+		Verify: newVerifier(tdxPlatform, opts...).verify,
+	}
+}
+
+// NewSEVSNPVerifier creates a new attestation verifier for SEV-SNP-style confidential VMs
+func NewSEVSNPVerifier(opts ...VerifierOption) *types.Verifier {
+	return &types.Verifier{
+		Type:   SEVSNPType,
+		Verify: newVerifier(sevSNPPlatform, opts...).verify,
+	}
+}
+
+func newVerifier(platform string, opts ...VerifierOption) *verifier {
+	v := &verifier{platform: platform}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+func (v *verifier) verify(evidence *types.Evidence, expectedValidationValues *types.ValidationValues) error {
+	// an empty report value would otherwise match an empty reference value
+	if len(expectedValidationValues.Mrenclave) == 0 {
+		return fmt.Errorf("no expected launch measurement")
+	}
+	for name, expected := range expectedValidationValues.Measurements {
+		if len(expected) == 0 {
+			return fmt.Errorf("no expected value for measurement '%s'", name)
+		}
+	}
+
+	signed, report, err := UnmarshalSignedReport([]byte(evidence.Data))
+	if err != nil {
+		return err
+	}
+
+	if report.Platform != v.platform {
+		return fmt.Errorf("wrong platform! expected=%s, actual=%s", v.platform, report.Platform)
+	}
+
+	// check that the report is signed by a trusted platform key
+	if err := v.verifySignature(signed); err != nil {
+		return errors.Wrap(err, "invalid report signature")
+	}
+
+	// check that the report is bound to the statement
+	expectedReportData := hex.EncodeToString(ReportData(expectedValidationValues.Statement))
+	if !strings.EqualFold(report.ReportData, expectedReportData) {
+		return fmt.Errorf("report data does not match statement")
+	}
+
+	// check code identity
+	if !strings.EqualFold(report.LaunchMeasurement, expectedValidationValues.Mrenclave) {
+		return fmt.Errorf("launch measurement does not match! expected=%s, actual=%s", expectedValidationValues.Mrenclave, report.LaunchMeasurement)
+	}
+
+	for name, expected := range expectedValidationValues.Measurements {
+		actual, ok := report.RuntimeMeasurements[name]
+		if !ok {
+			return fmt.Errorf("measurement '%s' not found in report", name)
+		}
+		if !strings.EqualFold(actual, expected) {
+			return fmt.Errorf("measurement '%s' does not match! expected=%s, actual=%s", name, expected, actual)
+		}
+	}
+
+	return nil
+}
+
+func (v *verifier) verifySignature(signed *SignedReport) error {
+	roots, err := v.rootCertificates()
+	if err != nil {
+		return err
+	}
+
+	if len(signed.Certificates) == 0 {
+		return fmt.Errorf("no certificates provided")
+	}
+
+	certs := make([]*x509.Certificate, len(signed.Certificates))
+	for i, c := range signed.Certificates {
+		certs[i], err = parseCertificate(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	leaf := certs[0]
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return errors.Wrap(err, "cannot verify certificate chain")
+	}
+
+	reportBytes, err := base64.StdEncoding.DecodeString(signed.Report)
+	if err != nil {
+		return errors.Wrap(err, "cannot decode report")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil {
+		return errors.Wrap(err, "cannot decode signature")
+	}
+
+	return leaf.CheckSignature(signatureAlgorithm(leaf), reportBytes, signature)
+}
+
+func (v *verifier) rootCertificates() (*x509.CertPool, error) {
+	if v.roots != nil {
+		return v.roots, nil
+	}
+
+	path := os.Getenv(RootCertificatesEnv)
+	if len(path) == 0 {
+		return nil, fmt.Errorf("no trusted root certificates configured, $%s not set", RootCertificatesEnv)
+	}
+
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read %s", path)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pemBytes) {
+		return nil, errors.Errorf("no certificates found in %s", path)
+	}
+
+	return roots, nil
+}
+
+func parseCertificate(pemCert string) (*x509.Certificate, error) {
+	block, _ := pem.Decode(bytes.TrimSpace([]byte(pemCert)))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("cannot decode pem certificate")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// signatureAlgorithm returns the algorithm used to sign reports with the key of the given certificate.
+// Reports are signed with SHA-384, as used by both TDX and SEV-SNP.
+func signatureAlgorithm(cert *x509.Certificate) x509.SignatureAlgorithm {
+	switch cert.PublicKeyAlgorithm {
+	case x509.RSA:
+		return x509.SHA384WithRSAPSS
+	case x509.Ed25519:
+		return x509.PureEd25519
+	default:
+		return x509.ECDSAWithSHA384
+	}
+}
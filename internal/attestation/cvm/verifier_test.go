@@ -0,0 +1,194 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cvm
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixture contains recorded evidence together with the values it was issued for
+type fixture struct {
+	Statement    string            `json:"statement"`
+	Mrenclave    string            `json:"mrenclave"`
+	Measurements map[string]string `json:"measurements"`
+	Evidence     *types.Evidence   `json:"evidence"`
+}
+
+func loadFixture(t *testing.T, name string) (*types.Evidence, *types.ValidationValues) {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	f := &fixture{}
+	require.NoError(t, json.Unmarshal(data, f))
+
+	statement, err := base64.StdEncoding.DecodeString(f.Statement)
+	require.NoError(t, err)
+
+	return f.Evidence, &types.ValidationValues{
+		Statement:    statement,
+		Mrenclave:    f.Mrenclave,
+		Measurements: f.Measurements,
+	}
+}
+
+func loadRoots(t *testing.T) *x509.CertPool {
+	data, err := os.ReadFile(filepath.Join("testdata", "root_ca.pem"))
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(data))
+	return roots
+}
+
+func TestTDXVerifier(t *testing.T) {
+	evidence, expected := loadFixture(t, "tdx_evidence.json")
+	v := NewTDXVerifier(WithRootCertificates(loadRoots(t)))
+	assert.Equal(t, TDXType, v.Type)
+
+	// valid evidence with all measurements
+	err := v.Verify(evidence, expected)
+	assert.NoError(t, err)
+
+	// measurements are optional
+	err = v.Verify(evidence, &types.ValidationValues{Statement: expected.Statement, Mrenclave: expected.Mrenclave})
+	assert.NoError(t, err)
+
+	// wrong launch measurement
+	err = v.Verify(evidence, &types.ValidationValues{Statement: expected.Statement, Mrenclave: "00"})
+	assert.EqualError(t, err, "launch measurement does not match! expected=00, actual="+expected.Mrenclave)
+
+	// wrong statement
+	err = v.Verify(evidence, &types.ValidationValues{Statement: []byte("other statement"), Mrenclave: expected.Mrenclave})
+	assert.EqualError(t, err, "report data does not match statement")
+
+	// wrong runtime measurement
+	err = v.Verify(evidence, &types.ValidationValues{Statement: expected.Statement, Mrenclave: expected.Mrenclave, Measurements: map[string]string{"rtmr2": "00"}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "measurement 'rtmr2' does not match!")
+
+	// empty reference values are rejected
+	err = v.Verify(evidence, &types.ValidationValues{Statement: expected.Statement, Mrenclave: ""})
+	assert.EqualError(t, err, "no expected launch measurement")
+	err = v.Verify(evidence, &types.ValidationValues{Statement: expected.Statement, Mrenclave: expected.Mrenclave, Measurements: map[string]string{"rtmr2": ""}})
+	assert.EqualError(t, err, "no expected value for measurement 'rtmr2'")
+
+	// unknown runtime measurement
+	err = v.Verify(evidence, &types.ValidationValues{Statement: expected.Statement, Mrenclave: expected.Mrenclave, Measurements: map[string]string{"rtmr7": "00"}})
+	assert.EqualError(t, err, "measurement 'rtmr7' not found in report")
+
+	// evidence of another platform
+	sevEvidence, sevExpected := loadFixture(t, "sev_snp_evidence.json")
+	err = v.Verify(sevEvidence, sevExpected)
+	assert.EqualError(t, err, "wrong platform! expected=tdx, actual=sev-snp")
+}
+
+func TestSEVSNPVerifier(t *testing.T) {
+	evidence, expected := loadFixture(t, "sev_snp_evidence.json")
+	v := NewSEVSNPVerifier(WithRootCertificates(loadRoots(t)))
+	assert.Equal(t, SEVSNPType, v.Type)
+
+	err := v.Verify(evidence, expected)
+	assert.NoError(t, err)
+}
+
+func TestVerifierTamperedReport(t *testing.T) {
+	evidence, expected := loadFixture(t, "tdx_evidence.json")
+	v := NewTDXVerifier(WithRootCertificates(loadRoots(t)))
+
+	signed, report, err := UnmarshalSignedReport([]byte(evidence.Data))
+	require.NoError(t, err)
+
+	// replace the launch measurement but keep the original signature
+	report.LaunchMeasurement = "00"
+	reportBytes, err := json.Marshal(report)
+	require.NoError(t, err)
+	signed.Report = base64.StdEncoding.EncodeToString(reportBytes)
+	signedBytes, err := json.Marshal(signed)
+	require.NoError(t, err)
+
+	tampered := &types.Evidence{Type: evidence.Type, Data: string(signedBytes)}
+	err = v.Verify(tampered, &types.ValidationValues{Statement: expected.Statement, Mrenclave: "00"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid report signature")
+}
+
+func TestVerifierRoots(t *testing.T) {
+	evidence, expected := loadFixture(t, "tdx_evidence.json")
+
+	// untrusted roots
+	v := NewTDXVerifier(WithRootCertificates(x509.NewCertPool()))
+	err := v.Verify(evidence, expected)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot verify certificate chain")
+
+	// no roots configured
+	t.Setenv(RootCertificatesEnv, "")
+	v = NewTDXVerifier()
+	err = v.Verify(evidence, expected)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no trusted root certificates configured")
+
+	// roots loaded from env
+	t.Setenv(RootCertificatesEnv, filepath.Join("testdata", "root_ca.pem"))
+	err = v.Verify(evidence, expected)
+	assert.NoError(t, err)
+}
+
+func TestConverter(t *testing.T) {
+	evidence, _ := loadFixture(t, "tdx_evidence.json")
+
+	c := NewTDXConverter()
+	evidenceBytes, err := c.Converter([]byte(evidence.Data))
+	assert.NoError(t, err)
+	assert.Equal(t, evidence.Data, string(evidenceBytes))
+
+	_, err = c.Converter([]byte("not a report"))
+	assert.Error(t, err)
+}
+
+type staticReportProvider struct {
+	signed     *SignedReport
+	reportData []byte
+}
+
+func (p *staticReportProvider) GetSignedReport(reportData []byte) (*SignedReport, error) {
+	p.reportData = reportData
+	return p.signed, nil
+}
+
+func TestIssuer(t *testing.T) {
+	evidence, expected := loadFixture(t, "tdx_evidence.json")
+	signed, _, err := UnmarshalSignedReport([]byte(evidence.Data))
+	require.NoError(t, err)
+
+	provider := &staticReportProvider{signed: signed}
+	issuer := NewTDXIssuer(provider)
+
+	attestationBytes, err := issuer.Issue(expected.Statement)
+	assert.NoError(t, err)
+	assert.Equal(t, ReportData(expected.Statement), provider.reportData)
+
+	// the issued attestation converts to verifiable evidence
+	att := &types.Attestation{}
+	require.NoError(t, json.Unmarshal(attestationBytes, att))
+	assert.Equal(t, TDXType, att.Type)
+
+	evidenceBytes, err := NewTDXConverter().Converter([]byte(att.Data))
+	require.NoError(t, err)
+
+	err = NewTDXVerifier(WithRootCertificates(loadRoots(t))).Verify(&types.Evidence{Type: att.Type, Data: string(evidenceBytes)}, expected)
+	assert.NoError(t, err)
+}
@@ -8,6 +8,8 @@ SPDX-License-Identifier: Apache-2.0
 package epid
 
 import (
+	"os"
+
 	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
 	"github.com/pkg/errors"
 )
@@ -17,23 +19,29 @@ const (
 	LinkableType   = "epid-linkable"
 )
 
-// NewEpidUnlinkableConverter creates a new attestation converter for Intel SGX EPID (unlinkable) attestation
-func NewEpidUnlinkableConverter() *types.Converter {
+// IASUrlEnv is the environment variable that allows to override the default IAS endpoint used by the converters,
+// for instance, to use a local IAS stand-in (see iastest)
+const IASUrlEnv = "IAS_URL"
+
+// NewEpidUnlinkableConverter creates a new attestation converter for Intel SGX EPID (unlinkable) attestation.
+// Optionally, IASClientOption can be provided to change the behavior of the IASClient used for the conversion.
+func NewEpidUnlinkableConverter(opts ...IASClientOption) *types.Converter {
 	return &types.Converter{
 		Type:      UnlinkableType,
-		Converter: newEpidConverter(),
+		Converter: newEpidConverter(opts...),
 	}
 }
 
-// NewEpidLinkableConverter creates a new attestation converter for Intel SGX EPID (linkable) attestation
-func NewEpidLinkableConverter() *types.Converter {
+// NewEpidLinkableConverter creates a new attestation converter for Intel SGX EPID (linkable) attestation.
+// Optionally, IASClientOption can be provided to change the behavior of the IASClient used for the conversion.
+func NewEpidLinkableConverter(opts ...IASClientOption) *types.Converter {
 	return &types.Converter{
 		Type:      LinkableType,
-		Converter: newEpidConverter(),
+		Converter: newEpidConverter(opts...),
 	}
 }
 
-func newEpidConverter() types.ConvertFunction {
+func newEpidConverter(opts ...IASClientOption) types.ConvertFunction {
 	return func(attestationBytes []byte) (evidenceBytes []byte, err error) {
 
 		apiKey, err := loadApiKey()
@@ -41,7 +49,13 @@ func newEpidConverter() types.ConvertFunction {
 			return nil, errors.Wrap(err, "cannot load IAS API key")
 		}
 
-		ias := NewIASClient(apiKey)
+		// the IAS url from env is applied first, so it can still be overridden by the options
+		clientOpts := opts
+		if url := os.Getenv(IASUrlEnv); len(url) != 0 {
+			clientOpts = append([]IASClientOption{WithUrl(url)}, opts...)
+		}
+
+		ias := NewIASClient(apiKey, clientOpts...)
 		evidence, err := ias.RequestAttestationReport(string(attestationBytes))
 		if err != nil {
 			return nil, errors.Wrap(err, "cannot convert epid attestation")
@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -28,20 +29,21 @@ type IASRequest struct {
 	Nonce    string `json:"nonce,omitempty"`
 }
 
+// IASResponseBody is the attestation verification report as defined by the IAS API specification
 type IASResponseBody struct {
 	Id                    string   `json:"id"`
 	Timestamp             string   `json:"timestamp"`
 	Version               int      `json:"version"`
-	IsvEnclaveQuoteStatus string   `json:"ISVEnclaveQuoteStatus"`
-	IsvEnclaveQuoteBody   string   `json:"ISVEnclaveQuoteBody"`
-	RevocationReason      string   `json:"revocationReason"`
-	PseManifestStatus     string   `json:"pseManifestStatus"`
-	PseManifestHash       string   `json:"pseManifestHash"`
-	PlatformInfoBlob      string   `json:"platformInfoBlob"`
-	Nonce                 string   `json:"nonce"`
-	EpidPseudonym         string   `json:"epidPseudonym"`
-	AdvisoryURL           string   `json:"advisoryURL"`
-	AdvisoryIDs           []string `json:"advisoryIDs"`
+	IsvEnclaveQuoteStatus string   `json:"isvEnclaveQuoteStatus"`
+	IsvEnclaveQuoteBody   string   `json:"isvEnclaveQuoteBody"`
+	RevocationReason      string   `json:"revocationReason,omitempty"`
+	PseManifestStatus     string   `json:"pseManifestStatus,omitempty"`
+	PseManifestHash       string   `json:"pseManifestHash,omitempty"`
+	PlatformInfoBlob      string   `json:"platformInfoBlob,omitempty"`
+	Nonce                 string   `json:"nonce,omitempty"`
+	EpidPseudonym         string   `json:"epidPseudonym,omitempty"`
+	AdvisoryURL           string   `json:"advisoryURL,omitempty"`
+	AdvisoryIDs           []string `json:"advisoryIDs,omitempty"`
 }
 
 type IASReport struct {
@@ -58,6 +60,7 @@ type IASClient struct {
 	url        string
 	apiKey     string
 	httpClient HTTPClient
+	timeout    time.Duration
 }
 
 type IASClientOption func(*IASClient)
@@ -76,6 +79,13 @@ func WithHttpClient(client HTTPClient) IASClientOption {
 	}
 }
 
+// WithTimeout option sets a time limit for requests to IAS. It has no effect if a custom http client is used
+func WithTimeout(timeout time.Duration) IASClientOption {
+	return func(c *IASClient) {
+		c.timeout = timeout
+	}
+}
+
 // NewIASClient returns a new IASClient instance using DefaultIASUrl as IAS endpoint
 // This method requires an API Key as input in order to authenticate with the IAS.
 // Optionally, IASClientOption can be provided to change the behavior of the IASClient.
@@ -92,7 +102,7 @@ func NewIASClient(apiKey string, opts ...IASClientOption) *IASClient {
 
 	// create default http client if not provided via options
 	if client.httpClient == nil {
-		client.httpClient = &http.Client{}
+		client.httpClient = &http.Client{Timeout: client.timeout}
 	}
 
 	return client
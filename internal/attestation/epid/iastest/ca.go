@@ -0,0 +1,108 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package iastest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const rsaKeySize = 2048
+
+// CA is a test certificate authority mimicking the IAS report signing PKI.
+// It consists of a self-signed root and a report signing certificate issued by the root.
+type CA struct {
+	Root        *x509.Certificate
+	SigningCert *x509.Certificate
+	signingKey  *rsa.PrivateKey
+}
+
+// NewCA creates a new test CA with freshly generated keys
+func NewCA() (*CA, error) {
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := notBefore.AddDate(10, 0, 0)
+
+	rootKey, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot generate root key")
+	}
+
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "FPC Test IAS Report Signing CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	root, err := createCertificate(rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create root certificate")
+	}
+
+	signingKey, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot generate signing key")
+	}
+
+	signingTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "FPC Test IAS Report Signing"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+	signingCert, err := createCertificate(signingTemplate, root, &signingKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create signing certificate")
+	}
+
+	return &CA{
+		Root:        root,
+		SigningCert: signingCert,
+		signingKey:  signingKey,
+	}, nil
+}
+
+// RootPEM returns the PEM encoded root certificate
+func (ca *CA) RootPEM() []byte {
+	return toPEM(ca.Root)
+}
+
+// CertificateChainPEM returns the PEM encoded signing certificate followed by the root certificate,
+// as included by IAS in the X-IASReport-Signing-Certificate header
+func (ca *CA) CertificateChainPEM() []byte {
+	return append(toPEM(ca.SigningCert), toPEM(ca.Root)...)
+}
+
+// Sign returns a RSA-SHA256 signature over the report
+func (ca *CA) Sign(report []byte) ([]byte, error) {
+	h := sha256.Sum256(report)
+	return rsa.SignPKCS1v15(rand.Reader, ca.signingKey, crypto.SHA256, h[:])
+}
+
+func createCertificate(template, parent *x509.Certificate, pub *rsa.PublicKey, priv *rsa.PrivateKey) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func toPEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
@@ -0,0 +1,84 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package iastest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
+	"github.com/pkg/errors"
+)
+
+// offsets within the sgx_quote_t structure
+const (
+	quoteVersion          = 2
+	quoteSignTypeOffset   = 2
+	quoteHeaderSize       = 48
+	attributesOffset      = quoteHeaderSize + 48
+	mrenclaveOffset       = quoteHeaderSize + 64
+	reportDataOffset      = quoteHeaderSize + 320
+	attributesDebugFlag   = 0x2
+	quoteSignatureSize    = 680
+	quoteSignatureLenSize = 4
+)
+
+// Quote describes the enclave quote to be created by NewQuote
+type Quote struct {
+	Linkable  bool
+	Debug     bool
+	Mrenclave string
+	Statement []byte
+}
+
+// NewQuote creates a base64 encoded (unsigned) EPID quote as produced by the quoting enclave, with the report data
+// binding the statement. The EPID signature is filled with zeros, as it is only checked by the IAS stand-in for presence.
+func NewQuote(q *Quote) (string, error) {
+	mrenclave, err := hex.DecodeString(q.Mrenclave)
+	if err != nil || len(mrenclave) != 32 {
+		return "", errors.Errorf("invalid mrenclave '%s'", q.Mrenclave)
+	}
+
+	quote := make([]byte, epid.QuoteBodySize+quoteSignatureLenSize+quoteSignatureSize)
+	binary.LittleEndian.PutUint16(quote, quoteVersion)
+	if q.Linkable {
+		binary.LittleEndian.PutUint16(quote[quoteSignTypeOffset:], 1)
+	}
+	if q.Debug {
+		binary.LittleEndian.PutUint64(quote[attributesOffset:], attributesDebugFlag)
+	}
+	copy(quote[mrenclaveOffset:], mrenclave)
+
+	h := sha256.Sum256(q.Statement)
+	copy(quote[reportDataOffset:], h[:])
+
+	binary.LittleEndian.PutUint32(quote[epid.QuoteBodySize:], quoteSignatureSize)
+
+	return base64.StdEncoding.EncodeToString(quote), nil
+}
+
+// NewAttestation returns a serialized EPID attestation as issued by an enclave for the given quote
+func NewAttestation(q *Quote) ([]byte, error) {
+	quote, err := NewQuote(q)
+	if err != nil {
+		return nil, err
+	}
+
+	attestationType := epid.UnlinkableType
+	if q.Linkable {
+		attestationType = epid.LinkableType
+	}
+
+	return json.Marshal(&types.Attestation{
+		Type: attestationType,
+		Data: quote,
+	})
+}
@@ -0,0 +1,169 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package iastest provides a local stand-in for the Intel Attestation Service (IAS).
+// The stand-in implements the report endpoint of the IAS API (v4) and signs reports with a test CA;
+// combined with an epid.ReportVerifier configured with the root of that CA, it allows to test
+// hardware-mode attestation flows without network access and without SGX hardware.
+package iastest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+	"github.com/hyperledger/fabric/common/flogging"
+)
+
+var logger = flogging.MustGetLogger("fpc.attestation.iastest")
+
+const (
+	ReportPath = "/sgx/dev/attestation/v4/report"
+	SigRLPath  = "/sgx/dev/attestation/v4/sigrl/"
+
+	iasReportVersion = 4
+	timestampFormat  = "2006-01-02T15:04:05.000000"
+)
+
+// Server is an IAS compatible http handler
+type Server struct {
+	ca          *CA
+	apiKey      string
+	quoteStatus string
+}
+
+type ServerOption func(*Server)
+
+// WithAPIKey option requires requests to carry the given API key. By default, any API key is accepted.
+func WithAPIKey(apiKey string) ServerOption {
+	return func(s *Server) {
+		s.apiKey = apiKey
+	}
+}
+
+// WithQuoteStatus option sets the quote status reported for all quotes. Default is OK.
+func WithQuoteStatus(status string) ServerOption {
+	return func(s *Server) {
+		s.quoteStatus = status
+	}
+}
+
+// NewServer returns a new IAS stand-in signing reports with the given CA
+func NewServer(ca *CA, opts ...ServerOption) *Server {
+	s := &Server{
+		ca:          ca,
+		quoteStatus: "OK",
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestId := newRequestId()
+	w.Header().Set("Request-ID", requestId)
+
+	if len(s.apiKey) != 0 && r.Header.Get("Ocp-Apim-Subscription-Key") != s.apiKey {
+		http.Error(w, "invalid subscription key", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == ReportPath && r.Method == http.MethodPost:
+		s.handleReport(w, r, requestId)
+	case strings.HasPrefix(r.URL.Path, SigRLPath) && r.Method == http.MethodGet:
+		// the stand-in has no revoked platforms, thus, the SigRL is always empty
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request, requestId string) {
+	request := &epid.IASRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	quoteBody, err := parseQuote(request.Quote)
+	if err != nil {
+		logger.Debugf("invalid quote: %s", err)
+		http.Error(w, "invalid quote", http.StatusBadRequest)
+		return
+	}
+
+	body, err := json.Marshal(&epid.IASResponseBody{
+		Id:                    requestId,
+		Timestamp:             time.Now().UTC().Format(timestampFormat),
+		Version:               iasReportVersion,
+		IsvEnclaveQuoteStatus: s.quoteStatus,
+		IsvEnclaveQuoteBody:   base64.StdEncoding.EncodeToString(quoteBody),
+		Nonce:                 request.Nonce,
+	})
+	if err != nil {
+		http.Error(w, "cannot create report", http.StatusInternalServerError)
+		return
+	}
+
+	signature, err := s.ca.Sign(body)
+	if err != nil {
+		http.Error(w, "cannot sign report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-IASReport-Signature", base64.StdEncoding.EncodeToString(signature))
+	w.Header().Set("X-IASReport-Signing-Certificate", urlEncode(s.ca.CertificateChainPEM()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// parseQuote checks the structure of the quote and returns the quote body
+func parseQuote(quoteBase64 string) ([]byte, error) {
+	quote, err := base64.StdEncoding.DecodeString(quoteBase64)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(quote) < epid.QuoteBodySize+quoteSignatureLenSize {
+		return nil, fmt.Errorf("quote too short")
+	}
+
+	signatureLen := binary.LittleEndian.Uint32(quote[epid.QuoteBodySize:])
+	if int(signatureLen) != len(quote)-epid.QuoteBodySize-quoteSignatureLenSize {
+		return nil, fmt.Errorf("invalid signature length")
+	}
+
+	return quote[:epid.QuoteBodySize], nil
+}
+
+// urlEncode percent-encodes all but unreserved characters, as IAS does for the certificate header
+func urlEncode(data []byte) string {
+	var sb strings.Builder
+	for _, c := range data {
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("-_.~", c) >= 0 {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,189 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package iastest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/epid"
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testApiKey    = "some_key"
+	testMrenclave = "98aed61c91f258a37c68ed4943297695647ec7bbe6008cc111b0a12650ebeb91"
+)
+
+var testStatement = []byte("some attested data")
+
+func newTestServer(t *testing.T, opts ...ServerOption) (*CA, *httptest.Server) {
+	ca, err := NewCA()
+	require.NoError(t, err)
+
+	return ca, newTestServerWithCA(t, ca, opts...)
+}
+
+func newTestServerWithCA(t *testing.T, ca *CA, opts ...ServerOption) *httptest.Server {
+	server := httptest.NewServer(NewServer(ca, append([]ServerOption{WithAPIKey(testApiKey)}, opts...)...))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func requestReport(t *testing.T, url string, q *Quote) *epid.IASReport {
+	quote, err := NewQuote(q)
+	require.NoError(t, err)
+
+	reportJson, err := epid.NewIASClient(testApiKey, epid.WithUrl(url+ReportPath)).RequestAttestationReport(quote)
+	require.NoError(t, err)
+
+	report := &epid.IASReport{}
+	require.NoError(t, json.Unmarshal([]byte(reportJson), report))
+	return report
+}
+
+func TestServer(t *testing.T) {
+	ca, server := newTestServer(t)
+
+	report := requestReport(t, server.URL, &Quote{Linkable: true, Mrenclave: testMrenclave, Statement: testStatement})
+
+	v := epid.NewReportVerifier(epid.WithReportSigningRoot(ca.Root))
+	body, err := v.VerifyReport(report)
+	require.NoError(t, err)
+	assert.Equal(t, "OK", body.IsvEnclaveQuoteStatus)
+	assert.Equal(t, 4, body.Version)
+
+	quoteBody, err := v.VerifyEvidence(report, testStatement, testMrenclave)
+	require.NoError(t, err)
+	assert.True(t, quoteBody.Linkable)
+	assert.False(t, quoteBody.Debug)
+	assert.Equal(t, testMrenclave, quoteBody.Mrenclave)
+
+	// wrong expected values
+	_, err = v.VerifyEvidence(report, testStatement, "0000")
+	assert.Contains(t, err.Error(), "expected code id mismatch")
+	_, err = v.VerifyEvidence(report, []byte("other statement"), testMrenclave)
+	assert.EqualError(t, err, "expected statement mismatch")
+
+	// report signed by an untrusted CA
+	otherCA, err := NewCA()
+	require.NoError(t, err)
+	_, err = epid.NewReportVerifier(epid.WithReportSigningRoot(otherCA.Root)).VerifyReport(report)
+	assert.Contains(t, err.Error(), "invalid signing certificate")
+
+	// tampered report
+	tampered := *report
+	tampered.Body = report.Body[:len(report.Body)-1] + " }"
+	_, err = v.VerifyReport(&tampered)
+	assert.Contains(t, err.Error(), "invalid report signature")
+
+	// archived reports are verified against the validity of the signing certificate at the given time
+	_, err = epid.NewReportVerifier(epid.WithReportSigningRoot(ca.Root), epid.WithVerificationTime(ca.SigningCert.NotAfter.Add(time.Hour))).VerifyReport(report)
+	assert.Contains(t, err.Error(), "invalid signing certificate")
+	_, err = epid.NewReportVerifier(epid.WithReportSigningRoot(ca.Root), epid.WithVerificationTime(ca.SigningCert.NotAfter.Add(-time.Hour))).VerifyReport(report)
+	assert.NoError(t, err)
+}
+
+func TestServerRequests(t *testing.T) {
+	_, server := newTestServer(t)
+
+	quote, err := NewQuote(&Quote{Mrenclave: testMrenclave, Statement: testStatement})
+	require.NoError(t, err)
+
+	// wrong api key
+	_, err = epid.NewIASClient("wrong_key", epid.WithUrl(server.URL+ReportPath)).RequestAttestationReport(quote)
+	assert.Contains(t, err.Error(), "401")
+
+	// malformed quote
+	_, err = epid.NewIASClient(testApiKey, epid.WithUrl(server.URL+ReportPath)).RequestAttestationReport("bm90IGEgcXVvdGU=")
+	assert.Contains(t, err.Error(), "400")
+
+	// wrong endpoint
+	_, err = epid.NewIASClient(testApiKey, epid.WithUrl(server.URL+"/some/path")).RequestAttestationReport(quote)
+	assert.Contains(t, err.Error(), "404")
+
+	_, err = NewQuote(&Quote{Mrenclave: "not hex"})
+	assert.Error(t, err)
+}
+
+func TestQuoteStatus(t *testing.T) {
+	ca, err := NewCA()
+	require.NoError(t, err)
+
+	for status, valid := range map[string]bool{
+		"OK":                             true,
+		"GROUP_OUT_OF_DATE":              true,
+		"SW_HARDENING_NEEDED":            true,
+		"SIGNATURE_INVALID":              false,
+		"GROUP_REVOKED":                  false,
+		"KEY_REVOKED":                    false,
+		"SIGRL_VERSION_MISMATCH":         false,
+		"CONFIGURATION_NEEDED":           true,
+		"some unknown status":            false,
+		"SIGNATURE_REVOKED_OR_WITHDRAWN": false,
+	} {
+		server := newTestServerWithCA(t, ca, WithQuoteStatus(status))
+		report := requestReport(t, server.URL, &Quote{Mrenclave: testMrenclave, Statement: testStatement})
+
+		_, err := epid.NewReportVerifier(epid.WithReportSigningRoot(ca.Root)).VerifyReport(report)
+		if valid {
+			assert.NoError(t, err, status)
+		} else {
+			assert.EqualError(t, err, "invalid quote status: "+status)
+		}
+	}
+}
+
+func TestEpidConverterAndVerifier(t *testing.T) {
+	ca, server := newTestServer(t)
+	t.Setenv("IAS_API_KEY", testApiKey)
+
+	for _, linkable := range []bool{true, false} {
+		attestationBytes, err := NewAttestation(&Quote{Linkable: linkable, Mrenclave: testMrenclave, Statement: testStatement})
+		require.NoError(t, err)
+
+		att := &types.Attestation{}
+		require.NoError(t, json.Unmarshal(attestationBytes, att))
+
+		converter := epid.NewEpidUnlinkableConverter(epid.WithUrl(server.URL + ReportPath))
+		verifier := epid.NewEpidUnlinkableVerifier(epid.WithReportSigningRoot(ca.Root))
+		otherVerifier := epid.NewEpidLinkableVerifier(epid.WithReportSigningRoot(ca.Root))
+		if linkable {
+			converter = epid.NewEpidLinkableConverter(epid.WithUrl(server.URL + ReportPath))
+			verifier, otherVerifier = otherVerifier, verifier
+		}
+		assert.Equal(t, att.Type, converter.Type)
+
+		evidenceBytes, err := converter.Converter([]byte(att.Data))
+		require.NoError(t, err)
+
+		evidence := &types.Evidence{Type: att.Type, Data: string(evidenceBytes)}
+		expected := &types.ValidationValues{Statement: testStatement, Mrenclave: testMrenclave}
+		assert.NoError(t, verifier.Verify(evidence, expected))
+
+		// the quote signature type must match the attestation type
+		assert.Error(t, otherVerifier.Verify(evidence, expected))
+	}
+
+	// the IAS url can be set via env
+	t.Setenv(epid.IASUrlEnv, server.URL+ReportPath)
+	quote, err := NewQuote(&Quote{Mrenclave: testMrenclave, Statement: testStatement})
+	require.NoError(t, err)
+	_, err = epid.NewEpidUnlinkableConverter().Converter([]byte(quote))
+	assert.NoError(t, err)
+
+	// no root configured
+	t.Setenv(epid.ReportSigningRootEnv, "")
+	evidenceBytes, err := epid.NewEpidUnlinkableConverter().Converter([]byte(quote))
+	require.NoError(t, err)
+	err = epid.NewEpidUnlinkableVerifier().Verify(&types.Evidence{Type: epid.UnlinkableType, Data: string(evidenceBytes)}, &types.ValidationValues{Statement: testStatement, Mrenclave: testMrenclave})
+	assert.Contains(t, err.Error(), "no trusted report signing root configured")
+}
@@ -0,0 +1,89 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package epid
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// layout of the sgx_quote_t structure (up to the signature) as returned by IAS in the isvEnclaveQuoteBody
+const (
+	QuoteBodySize = 432
+
+	quoteHeaderSize       = 48
+	attributesOffset      = quoteHeaderSize + 48
+	mrenclaveOffset       = quoteHeaderSize + 64
+	mrsignerOffset        = quoteHeaderSize + 128
+	isvProdIdOffset       = quoteHeaderSize + 256
+	isvSvnOffset          = quoteHeaderSize + 258
+	reportDataOffset      = quoteHeaderSize + 320
+	measurementSize       = 32
+	reportDataSize        = 64
+	attributesDebugFlag   = 0x2
+	attributesFlagsSize   = 8
+	quoteSignTypeOffset   = 2
+	quoteSignTypeSize     = 2
+	quoteSignTypeLinkable = 1
+)
+
+// QuoteBody contains the fields of an enclave quote body relevant for verification
+type QuoteBody struct {
+	Linkable   bool
+	Debug      bool
+	Mrenclave  string
+	Mrsigner   string
+	IsvProdId  uint16
+	IsvSvn     uint16
+	ReportData []byte
+}
+
+// ParseQuoteBody parses a base64 encoded quote body as contained in an IAS report
+func ParseQuoteBody(quoteBodyBase64 string) (*QuoteBody, error) {
+	quote, err := base64.StdEncoding.DecodeString(quoteBodyBase64)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode quote body")
+	}
+
+	if len(quote) != QuoteBodySize {
+		return nil, fmt.Errorf("unexpected quote size! expected=%d, actual=%d", QuoteBodySize, len(quote))
+	}
+
+	return parseQuoteBody(quote), nil
+}
+
+// ParseQuote parses the body of a base64 encoded quote as issued by an enclave, that is, including the EPID signature
+func ParseQuote(quoteBase64 string) (*QuoteBody, error) {
+	quote, err := base64.StdEncoding.DecodeString(quoteBase64)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode quote")
+	}
+
+	if len(quote) < QuoteBodySize {
+		return nil, fmt.Errorf("quote too short! expected at least %d bytes, actual=%d", QuoteBodySize, len(quote))
+	}
+
+	return parseQuoteBody(quote[:QuoteBodySize]), nil
+}
+
+func parseQuoteBody(quote []byte) *QuoteBody {
+	flags := binary.LittleEndian.Uint64(quote[attributesOffset : attributesOffset+attributesFlagsSize])
+
+	return &QuoteBody{
+		Linkable:   binary.LittleEndian.Uint16(quote[quoteSignTypeOffset:quoteSignTypeOffset+quoteSignTypeSize]) == quoteSignTypeLinkable,
+		Debug:      flags&attributesDebugFlag != 0,
+		Mrenclave:  hex.EncodeToString(quote[mrenclaveOffset : mrenclaveOffset+measurementSize]),
+		Mrsigner:   hex.EncodeToString(quote[mrsignerOffset : mrsignerOffset+measurementSize]),
+		IsvProdId:  binary.LittleEndian.Uint16(quote[isvProdIdOffset:]),
+		IsvSvn:     binary.LittleEndian.Uint16(quote[isvSvnOffset:]),
+		ReportData: quote[reportDataOffset : reportDataOffset+reportDataSize],
+	}
+}
@@ -0,0 +1,251 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package epid
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
+	"github.com/pkg/errors"
+)
+
+// ReportSigningRootEnv is the environment variable pointing to a PEM file with the trusted IAS report signing roots.
+// It is used by report verifiers created without WithReportSigningRoot.
+const ReportSigningRootEnv = "FPC_IAS_ROOT_CERTS"
+
+// acceptedQuoteStatus lists the quote status values accepted as valid. This follows the verification of the
+// attestation-api (see common/crypto/attestation-api/evidence/verify-evidence.cpp).
+var acceptedQuoteStatus = map[string]bool{
+	"OK":                                    true,
+	"GROUP_OUT_OF_DATE":                     true,
+	"CONFIGURATION_NEEDED":                  true,
+	"SW_HARDENING_NEEDED":                   true,
+	"CONFIGURATION_AND_SW_HARDENING_NEEDED": true,
+}
+
+// ReportVerifier verifies attestation reports issued by the Intel Attestation Service (IAS) or by a compatible service.
+type ReportVerifier struct {
+	roots            *x509.CertPool
+	verificationTime time.Time
+}
+
+type ReportVerifierOption func(*ReportVerifier)
+
+// WithReportSigningRoot option sets the trusted root certificates of the report signing certificate.
+// If not set, the roots are loaded from the file referenced by ReportSigningRootEnv when verifying.
+func WithReportSigningRoot(roots ...*x509.Certificate) ReportVerifierOption {
+	return func(v *ReportVerifier) {
+		v.roots = x509.NewCertPool()
+		for _, r := range roots {
+			v.roots.AddCert(r)
+		}
+	}
+}
+
+// WithVerificationTime option sets the time at which the report signing certificate must be valid.
+// This allows to verify archived reports signed with a certificate that has expired since. Default is the current time.
+func WithVerificationTime(t time.Time) ReportVerifierOption {
+	return func(v *ReportVerifier) {
+		v.verificationTime = t
+	}
+}
+
+// NewReportVerifier returns a new ReportVerifier.
+// Optionally, ReportVerifierOption can be provided to change the behavior of the ReportVerifier.
+func NewReportVerifier(opts ...ReportVerifierOption) *ReportVerifier {
+	v := &ReportVerifier{}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// NewEpidLinkableVerifier creates a new attestation verifier for Intel SGX EPID (linkable) attestation
+func NewEpidLinkableVerifier(opts ...ReportVerifierOption) *types.Verifier {
+	return newEpidVerifier(LinkableType, NewReportVerifier(opts...))
+}
+
+// NewEpidUnlinkableVerifier creates a new attestation verifier for Intel SGX EPID (unlinkable) attestation
+func NewEpidUnlinkableVerifier(opts ...ReportVerifierOption) *types.Verifier {
+	return newEpidVerifier(UnlinkableType, NewReportVerifier(opts...))
+}
+
+func newEpidVerifier(attestationType string, v *ReportVerifier) *types.Verifier {
+	return &types.Verifier{
+		Type: attestationType,
+		Verify: func(evidence *types.Evidence, expectedValidationValues *types.ValidationValues) error {
+			if len(expectedValidationValues.Measurements) != 0 {
+				return fmt.Errorf("measurement sets are not supported by '%s' attestations", attestationType)
+			}
+
+			report := &IASReport{}
+			if err := json.Unmarshal([]byte(evidence.Data), report); err != nil {
+				return errors.Wrap(err, "cannot unmarshal ias report")
+			}
+
+			quoteBody, err := v.VerifyEvidence(report, expectedValidationValues.Statement, expectedValidationValues.Mrenclave)
+			if err != nil {
+				return err
+			}
+
+			if quoteBody.Linkable != (attestationType == LinkableType) {
+				return fmt.Errorf("quote signature type does not match attestation type '%s'", attestationType)
+			}
+
+			return nil
+		},
+	}
+}
+
+// VerifyEvidence verifies the IAS report and checks that the contained quote matches the expected mrenclave and
+// is bound to the expected statement. On success, the parsed quote body is returned.
+func (v *ReportVerifier) VerifyEvidence(report *IASReport, expectedStatement []byte, expectedMrenclave string) (*QuoteBody, error) {
+	body, err := v.VerifyReport(report)
+	if err != nil {
+		return nil, err
+	}
+
+	quoteBody, err := ParseQuoteBody(body.IsvEnclaveQuoteBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(quoteBody.Mrenclave, expectedMrenclave) {
+		return nil, fmt.Errorf("expected code id mismatch! expected=%s, actual=%s", expectedMrenclave, quoteBody.Mrenclave)
+	}
+
+	// the report data contains the sha256 hash of the statement padded with zeros
+	expectedReportData := make([]byte, reportDataSize)
+	h := sha256.Sum256(expectedStatement)
+	copy(expectedReportData, h[:])
+	if !bytes.Equal(quoteBody.ReportData, expectedReportData) {
+		return nil, fmt.Errorf("expected statement mismatch")
+	}
+
+	return quoteBody, nil
+}
+
+// VerifyReport checks the signature and the certificate chain of an IAS report as well as the quote status.
+// On success, the parsed report body is returned.
+func (v *ReportVerifier) VerifyReport(report *IASReport) (*IASResponseBody, error) {
+	if len(report.Signature) == 0 {
+		return nil, fmt.Errorf("no ias signature")
+	}
+	if len(report.Certificates) == 0 {
+		return nil, fmt.Errorf("no ias certificates")
+	}
+	if len(report.Body) == 0 {
+		return nil, fmt.Errorf("no ias report")
+	}
+
+	signingCert, err := v.verifyCertificates(report.Certificates)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid signing certificate")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(report.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode ias signature")
+	}
+
+	if err := signingCert.CheckSignature(x509.SHA256WithRSA, []byte(report.Body), signature); err != nil {
+		return nil, errors.Wrap(err, "invalid report signature")
+	}
+
+	body := &IASResponseBody{}
+	if err := json.Unmarshal([]byte(report.Body), body); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal ias report body")
+	}
+
+	if !acceptedQuoteStatus[body.IsvEnclaveQuoteStatus] {
+		return nil, fmt.Errorf("invalid quote status: %s", body.IsvEnclaveQuoteStatus)
+	}
+
+	return body, nil
+}
+
+// verifyCertificates parses the (url encoded) certificates of an IAS report and verifies that the first
+// certificate, the report signing certificate, chains to a trusted root.
+func (v *ReportVerifier) verifyCertificates(certificates string) (*x509.Certificate, error) {
+	roots, err := v.rootCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := url.PathUnescape(certificates)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode ias certificates")
+	}
+
+	var certs []*x509.Certificate
+	rest := []byte(decoded)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse ias certificate")
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no ias certificates found")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   v.verificationTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, err
+	}
+
+	return certs[0], nil
+}
+
+func (v *ReportVerifier) rootCertificates() (*x509.CertPool, error) {
+	if v.roots != nil {
+		return v.roots, nil
+	}
+
+	path := os.Getenv(ReportSigningRootEnv)
+	if len(path) == 0 {
+		return nil, fmt.Errorf("no trusted report signing root configured, $%s not set", ReportSigningRootEnv)
+	}
+
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read %s", path)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pemBytes) {
+		return nil, errors.Errorf("no certificates found in %s", path)
+	}
+
+	return roots, nil
+}
@@ -0,0 +1,50 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package attestation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	measurementSeparator = "+"
+	nameValueSeparator   = "."
+)
+
+var measurementNameRegExp = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+// ParseCodeIdentity parses the expected code identity of a chaincode as stored in the version of its chaincode
+// definition. Besides the mrenclave (or the launch measurement of a confidential VM), the version may carry a set of
+// named measurements using only characters allowed in chaincode versions, e.g.,
+// `<launch measurement>+rtmr0.<value>+rtmr1.<value>`.
+func ParseCodeIdentity(codeIdentity string) (mrenclave string, measurements map[string]string, err error) {
+	parts := strings.Split(codeIdentity, measurementSeparator)
+	mrenclave = parts[0]
+	if len(mrenclave) == 0 {
+		return "", nil, fmt.Errorf("empty mrenclave in code identity '%s'", codeIdentity)
+	}
+
+	if len(parts) == 1 {
+		return mrenclave, nil, nil
+	}
+
+	measurements = make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		name, value, ok := strings.Cut(p, nameValueSeparator)
+		if !ok || !measurementNameRegExp.MatchString(name) || len(value) == 0 {
+			return "", nil, fmt.Errorf("invalid measurement '%s' in code identity", p)
+		}
+		if _, ok := measurements[name]; ok {
+			return "", nil, fmt.Errorf("duplicate measurement '%s' in code identity", name)
+		}
+		measurements[name] = value
+	}
+
+	return mrenclave, measurements, nil
+}
@@ -7,6 +7,8 @@ SPDX-License-Identifier: Apache-2.0
 package simulation
 
 import (
+	"fmt"
+
 	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
 )
 
@@ -14,6 +16,9 @@ func NewSimulationVerifier() *types.Verifier {
 	return &types.Verifier{
 		Type: SimulationType,
 		Verify: func(evidence *types.Evidence, expectedValidationValues *types.ValidationValues) (err error) {
+			if len(expectedValidationValues.Measurements) != 0 {
+				return fmt.Errorf("measurement sets are not supported by '%s' attestations", SimulationType)
+			}
 			// NO-OP
 			return nil
 		},
@@ -38,7 +38,12 @@ type Evidence struct {
 	Data string `json:"evidence"`
 }
 
+// ValidationValues contains the reference values an evidence is checked against.
+// Mrenclave carries the primary code identity (e.g., SGX mrenclave or the launch measurement of a confidential VM).
+// Measurements optionally carries additional named measurements (e.g., rtmr0 .. rtmr3) that must be matched by
+// verifiers supporting them; verifiers without support for measurement sets fail if this field is set.
 type ValidationValues struct {
-	Statement []byte
-	Mrenclave string
+	Statement    []byte
+	Mrenclave    string
+	Measurements map[string]string
 }
@@ -66,7 +66,19 @@ func NewCredentialVerifier(verifier ...*types.Verifier) *CredentialVerifier {
 	return &CredentialVerifier{dispatcher: dispatcher}
 }
 
-func (c *CredentialVerifier) VerifyCredentials(credentials *protos.Credentials, expectedMrenclave string) error {
+// VerifyCredentials verifies the credentials against the expected code identity, that is, the version of the
+// chaincode definition. If the code identity carries measurements (see ParseCodeIdentity), these are checked too.
+func (c *CredentialVerifier) VerifyCredentials(credentials *protos.Credentials, expectedCodeIdentity string) error {
+	expectedMrenclave, expectedMeasurements, err := ParseCodeIdentity(expectedCodeIdentity)
+	if err != nil {
+		return err
+	}
+	return c.VerifyCredentialsWithMeasurements(credentials, expectedMrenclave, expectedMeasurements)
+}
+
+// VerifyCredentialsWithMeasurements verifies the credentials against the expected mrenclave and, in addition,
+// against a set of named measurements. Verifiers without support for measurement sets reject expected measurements.
+func (c *CredentialVerifier) VerifyCredentialsWithMeasurements(credentials *protos.Credentials, expectedMrenclave string, expectedMeasurements map[string]string) error {
 
 	evidence, err := unmarshalEvidence(credentials.Evidence)
 	if err != nil {
@@ -74,8 +86,9 @@ func (c *CredentialVerifier) VerifyCredentials(credentials *protos.Credentials,
 	}
 
 	expectedValues := &types.ValidationValues{
-		Statement: credentials.SerializedAttestedData.Value,
-		Mrenclave: expectedMrenclave,
+		Statement:    credentials.SerializedAttestedData.Value,
+		Mrenclave:    expectedMrenclave,
+		Measurements: expectedMeasurements,
 	}
 
 	return c.dispatcher.Verify(evidence, expectedValues)
@@ -11,7 +11,9 @@ import (
 
 	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/simulation"
 	"github.com/hyperledger/fabric-private-chaincode/internal/attestation/types"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/anypb"
 )
 
 func NewDummyVerifier() *types.Verifier {
@@ -56,3 +58,62 @@ func TestVerifier(t *testing.T) {
 	err = d.Register(simulation.NewSimulationVerifier())
 	assert.NoError(t, err)
 }
+
+func TestSimulationVerifier(t *testing.T) {
+	v := simulation.NewSimulationVerifier()
+	ev := &types.Evidence{Type: simulation.SimulationType}
+
+	err := v.Verify(ev, &types.ValidationValues{Mrenclave: "abcd"})
+	assert.NoError(t, err)
+
+	// simulated enclaves have no measurement sets
+	err = v.Verify(ev, &types.ValidationValues{Mrenclave: "abcd", Measurements: map[string]string{"rtmr0": "0011"}})
+	assert.EqualError(t, err, "measurement sets are not supported by 'simulated' attestations")
+}
+
+func TestParseCodeIdentity(t *testing.T) {
+	mrenclave, measurements, err := ParseCodeIdentity("abcd")
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd", mrenclave)
+	assert.Nil(t, measurements)
+
+	mrenclave, measurements, err = ParseCodeIdentity("abcd+rtmr0.0011+rtmr_1.2233")
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd", mrenclave)
+	assert.Equal(t, map[string]string{"rtmr0": "0011", "rtmr_1": "2233"}, measurements)
+
+	for _, invalid := range []string{"", "+rtmr0.00", "abcd+", "abcd+rtmr0", "abcd+rtmr0.", "abcd+.00", "abcd+rtmr0.00+rtmr0.11"} {
+		_, _, err = ParseCodeIdentity(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestVerifyCredentialsWithMeasurements(t *testing.T) {
+	var expected *types.ValidationValues
+	v := NewCredentialVerifier(&types.Verifier{
+		Type: "dummy",
+		Verify: func(evidence *types.Evidence, expectedValidationValues *types.ValidationValues) error {
+			expected = expectedValidationValues
+			return nil
+		},
+	})
+	credentials := &protos.Credentials{
+		Evidence:               []byte(`{"attestation_type":"dummy","evidence":""}`),
+		SerializedAttestedData: &anypb.Any{Value: []byte("statement")},
+	}
+
+	// the measurements of the code identity are passed to the verifier
+	err := v.VerifyCredentials(credentials, "abcd+rtmr0.0011")
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ValidationValues{
+		Statement:    []byte("statement"),
+		Mrenclave:    "abcd",
+		Measurements: map[string]string{"rtmr0": "0011"},
+	}, expected)
+
+	// invalid code identity
+	expected = nil
+	err = v.VerifyCredentials(credentials, "abcd+rtmr0")
+	assert.Error(t, err)
+	assert.Nil(t, expected)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
 	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/protoutil"
 	"github.com/pkg/errors"
 )
 
@@ -97,6 +98,10 @@ func (e *EncryptionContextImpl) Reveal(signedResponseBytesB64 []byte) ([]byte, e
 		return nil, errors.Wrap(err, "failed to extract response message")
 	}
 
+	if response.ErrorCode != protos.ErrorCode_ERROR_CODE_OK {
+		return nil, e.revealError(response)
+	}
+
 	clearResponseBytes, err := e.csp.DecryptMessage(e.responseEncryptionKey, response.EncryptedResponse)
 	if err != nil {
 		return nil, errors.Wrap(err, "decryption of response failed")
@@ -105,6 +110,40 @@ func (e *EncryptionContextImpl) Reveal(signedResponseBytesB64 []byte) ([]byte, e
 	return clearResponseBytes, nil
 }
 
+// revealError returns the error reported by the enclave including the error message from the encrypted response, if any
+func (e *EncryptionContextImpl) revealError(response *protos.ChaincodeResponseMessage) error {
+	chaincodeErr := &ChaincodeError{Code: response.ErrorCode}
+	if response.EncryptedResponse == nil {
+		return chaincodeErr
+	}
+
+	clearResponseBytes, err := e.csp.DecryptMessage(e.responseEncryptionKey, response.EncryptedResponse)
+	if err != nil {
+		return errors.Wrap(err, "decryption of error response failed")
+	}
+
+	clearResponse, err := protoutil.UnmarshalResponse(clearResponseBytes)
+	if err != nil {
+		return errors.Wrap(err, "failed to unmarshal peer.Response message")
+	}
+	chaincodeErr.Message = clearResponse.Message
+
+	return chaincodeErr
+}
+
+// ChaincodeError is an error reported by a chaincode enclave in its signed response
+type ChaincodeError struct {
+	Code    protos.ErrorCode
+	Message string
+}
+
+func (e *ChaincodeError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("chaincode enclave reported %s", e.Code)
+	}
+	return e.Message
+}
+
 func (e *EncryptionContextImpl) Conceal(function string, args []string) (string, error) {
 	args = append([]string{function}, args...)
 	bytes := make([][]byte, len(args))
@@ -14,6 +14,7 @@ import (
 
 	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/protoutil"
 	"github.com/stretchr/testify/assert"
 )
@@ -158,3 +159,41 @@ func TestReveal(t *testing.T) {
 	assert.Equal(t, resp, msg)
 	assert.NoError(t, err)
 }
+
+func TestRevealError(t *testing.T) {
+	responseEncryptionKey, err := GetDefaultCSP().NewSymmetricKey()
+	assert.NoError(t, err)
+
+	ctx := &EncryptionContextImpl{
+		csp:                   GetDefaultCSP(),
+		responseEncryptionKey: responseEncryptionKey,
+	}
+
+	// error without message
+	response := &protos.ChaincodeResponseMessage{ErrorCode: protos.ErrorCode_ERROR_CODE_INVALID_PROPOSAL}
+	responseBytes := protoutil.MarshalOrPanic(response)
+	resp, err := ctx.Reveal([]byte(utils.MarshallProtoBase64(&protos.SignedChaincodeResponseMessage{ChaincodeResponseMessage: responseBytes})))
+	assert.Nil(t, resp)
+	var chaincodeErr *ChaincodeError
+	assert.ErrorAs(t, err, &chaincodeErr)
+	assert.Equal(t, protos.ErrorCode_ERROR_CODE_INVALID_PROPOSAL, chaincodeErr.Code)
+	assert.EqualError(t, err, "chaincode enclave reported ERROR_CODE_INVALID_PROPOSAL")
+
+	// error with encrypted message
+	encryptedMsg, err := GetDefaultCSP().EncryptMessage(responseEncryptionKey, protoutil.MarshalOrPanic(&peer.Response{Status: 500, Message: "some chaincode error"}))
+	assert.NoError(t, err)
+	response = &protos.ChaincodeResponseMessage{ErrorCode: protos.ErrorCode_ERROR_CODE_CHAINCODE_ERROR, EncryptedResponse: encryptedMsg}
+	responseBytes = protoutil.MarshalOrPanic(response)
+	resp, err = ctx.Reveal([]byte(utils.MarshallProtoBase64(&protos.SignedChaincodeResponseMessage{ChaincodeResponseMessage: responseBytes})))
+	assert.Nil(t, resp)
+	assert.ErrorAs(t, err, &chaincodeErr)
+	assert.Equal(t, &ChaincodeError{Code: protos.ErrorCode_ERROR_CODE_CHAINCODE_ERROR, Message: "some chaincode error"}, chaincodeErr)
+	assert.EqualError(t, err, "some chaincode error")
+
+	// message not encrypted
+	response = &protos.ChaincodeResponseMessage{ErrorCode: protos.ErrorCode_ERROR_CODE_CHAINCODE_ERROR, EncryptedResponse: []byte("some error")}
+	responseBytes = protoutil.MarshalOrPanic(response)
+	resp, err = ctx.Reveal([]byte(utils.MarshallProtoBase64(&protos.SignedChaincodeResponseMessage{ChaincodeResponseMessage: responseBytes})))
+	assert.Nil(t, resp)
+	assert.ErrorContains(t, err, "decryption of error response failed")
+}
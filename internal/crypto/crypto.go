@@ -7,7 +7,17 @@ SPDX-License-Identifier: Apache-2.0
 
 package crypto
 
-// CSP (Crypto Service Provider) offers a high-level abstract of cryptographic primitives used in FPC
+import (
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+)
+
+// DefaultHashAlgorithm is the hash function of the FPC protocol unless a message records another one
+const DefaultHashAlgorithm = protos.HashAlgorithm_HASH_ALGORITHM_SHA256
+
+// CSP (Crypto Service Provider) offers a high-level abstract of cryptographic primitives used in FPC.
+// The Go enclave, the session layer and the FPC plugins use the CSP, including HMAC and DeriveKey, e.g., for key
+// blinding, SKVS buckets and session keys; the C++ enclave implements its primitives in common/crypto instead.
 type CSP interface {
 	NewRSAKeys() (publicKey []byte, privateKey []byte, e error)
 	NewECDSAKeys() (publicKey []byte, privateKey []byte, e error)
@@ -18,8 +28,20 @@ type CSP interface {
 	PkEncryptMessage(publicKey []byte, message []byte) ([]byte, error)
 	DecryptMessage(key []byte, encryptedMessage []byte) ([]byte, error)
 	EncryptMessage(key []byte, message []byte) (encryptedMessage []byte, e error)
+	DecryptMessageWithAD(key []byte, encryptedMessage []byte, additionalData []byte) ([]byte, error)
+	EncryptMessageWithAD(key []byte, message []byte, additionalData []byte) (encryptedMessage []byte, e error)
+	Hash(algorithm protos.HashAlgorithm, message []byte) ([]byte, error)
+	HMAC(algorithm protos.HashAlgorithm, key []byte, message []byte) ([]byte, error)
+	DeriveKey(algorithm protos.HashAlgorithm, secret []byte, salt []byte, info string, length int) ([]byte, error)
 }
 
 func GetDefaultCSP() CSP {
 	return &GoCrypto{}
 }
+
+// Hasher returns a function hashing messages with the given algorithm of the CSP
+func Hasher(csp CSP, algorithm protos.HashAlgorithm) utils.HashFunction {
+	return func(message []byte) ([]byte, error) {
+		return csp.Hash(algorithm, message)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/bccsp/factory"
+	bccsputils "github.com/hyperledger/fabric/bccsp/utils"
+	"github.com/pkg/errors"
+)
+
+// KeyReferencePEMType is the PEM block type of the private keys returned by BCCSPCrypto.NewECDSAKeys. The block
+// contains the subject key identifier (SKI) of the key in the keystore of the BCCSP, e.g., a HSM, and not the key.
+const KeyReferencePEMType = "BCCSP KEY REFERENCE"
+
+// BCCSPCrypto implements CSP using a Fabric BCCSP, e.g., the SW or the PKCS#11 implementation. ECDSA keys, signatures
+// and hashes are handled by the BCCSP; thus, with the PKCS#11 implementation, the ECDSA keys created by NewECDSAKeys
+// never leave the HSM. As the BCCSP neither offers RSA-OAEP nor AES-GCM, HMAC or HKDF, and FPC transports symmetric
+// keys in the clear within encrypted messages, the remaining operations use the Go implementation.
+type BCCSPCrypto struct {
+	csp bccsp.BCCSP
+}
+
+// NewBCCSPCrypto returns a CSP using the given BCCSP. Note that the keys created by NewECDSAKeys are stored in the
+// keystore of the BCCSP, thus, the SW implementation must be configured with a keystore, e.g., a file keystore.
+func NewBCCSPCrypto(csp bccsp.BCCSP) *BCCSPCrypto {
+	return &BCCSPCrypto{csp: csp}
+}
+
+// NewBCCSPCryptoFromOpts returns a CSP using a BCCSP configured as in the BCCSP section of the Fabric configuration.
+// The PKCS#11 implementation is only available if built with the pkcs11 build tag.
+func NewBCCSPCryptoFromOpts(opts *factory.FactoryOpts) (*BCCSPCrypto, error) {
+	csp, err := factory.GetBCCSPFromOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewBCCSPCrypto(csp), nil
+}
+
+// NewECDSAKeys generates a new ECDSA key pair in the keystore of the BCCSP. The public key is PEM encoded, whereas
+// the private key is a PEM encoded reference to the key (see KeyReferencePEMType).
+func (c BCCSPCrypto) NewECDSAKeys() (publicKey []byte, privateKey []byte, e error) {
+	k, err := c.csp.KeyGen(&bccsp.ECDSAP256KeyGenOpts{Temporary: false})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot generate ecdsa key")
+	}
+
+	pub, err := k.PublicKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	x509encodedPub, err := pub.Bytes()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot serialize public key")
+	}
+
+	publicKey = pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: x509encodedPub,
+	})
+
+	privateKey = pem.EncodeToMemory(&pem.Block{
+		Type:  KeyReferencePEMType,
+		Bytes: k.SKI(),
+	})
+
+	return publicKey, privateKey, nil
+}
+
+// SignMessage signs a message with a key referenced by a private key of NewECDSAKeys or with a PEM encoded
+// ECDSA private key, e.g., of GoCrypto
+func (c BCCSPCrypto) SignMessage(privateKey []byte, message []byte) (signature []byte, e error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block containing private key")
+	}
+
+	var k bccsp.Key
+	var err error
+	switch block.Type {
+	case KeyReferencePEMType:
+		k, err = c.csp.GetKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		k, err = c.csp.KeyImport(block.Bytes, &bccsp.ECDSAPrivateKeyImportOpts{Temporary: true})
+	default:
+		return nil, fmt.Errorf("failed to decode PEM block containing private key, got %v", block.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get private key")
+	}
+
+	digest, err := c.Hash(protos.HashAlgorithm_HASH_ALGORITHM_SHA256, message)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.csp.Sign(k, digest, nil)
+}
+
+func (c BCCSPCrypto) VerifyMessage(publicKey []byte, message []byte, signature []byte) error {
+	block, _ := pem.Decode(publicKey)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return fmt.Errorf("failed to decode PEM block containing public key, got %v", block)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse public key")
+	}
+	ecdsaPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("public key is not an ecdsa key")
+	}
+
+	k, err := c.csp.KeyImport(ecdsaPub, &bccsp.ECDSAGoPublicKeyImportOpts{Temporary: true})
+	if err != nil {
+		return errors.Wrap(err, "cannot import public key")
+	}
+
+	// the BCCSP only accepts signatures with low S, which neither the enclaves nor GoCrypto produce
+	signature, err = bccsputils.SignatureToLowS(ecdsaPub, signature)
+	if err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+
+	digest, err := c.Hash(protos.HashAlgorithm_HASH_ALGORITHM_SHA256, message)
+	if err != nil {
+		return err
+	}
+
+	valid, err := c.csp.Verify(k, signature, digest, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to verify signature")
+	}
+	if !valid {
+		return fmt.Errorf("failed to verify signature")
+	}
+	return nil
+}
+
+func (c BCCSPCrypto) Hash(algorithm protos.HashAlgorithm, message []byte) ([]byte, error) {
+	var opts bccsp.HashOpts
+	switch algorithm {
+	case protos.HashAlgorithm_HASH_ALGORITHM_SHA256:
+		opts = &bccsp.SHA256Opts{}
+	case protos.HashAlgorithm_HASH_ALGORITHM_SHA384:
+		opts = &bccsp.SHA384Opts{}
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %s", algorithm)
+	}
+	return c.csp.Hash(message, opts)
+}
+
+// HMAC computes the MAC of a message using the Go implementation (see BCCSPCrypto)
+func (c BCCSPCrypto) HMAC(algorithm protos.HashAlgorithm, key []byte, message []byte) ([]byte, error) {
+	return GoCrypto{}.HMAC(algorithm, key, message)
+}
+
+// DeriveKey derives a key using the Go implementation (see BCCSPCrypto)
+func (c BCCSPCrypto) DeriveKey(algorithm protos.HashAlgorithm, secret []byte, salt []byte, info string, length int) ([]byte, error) {
+	return GoCrypto{}.DeriveKey(algorithm, secret, salt, info, length)
+}
+
+// NewRSAKeys generates a new RSA key pair using the Go implementation (see BCCSPCrypto)
+func (c BCCSPCrypto) NewRSAKeys() (publicKey []byte, privateKey []byte, e error) {
+	return GoCrypto{}.NewRSAKeys()
+}
+
+// PkEncryptMessage encrypts a message using the Go implementation (see BCCSPCrypto)
+func (c BCCSPCrypto) PkEncryptMessage(publicKey []byte, message []byte) ([]byte, error) {
+	return GoCrypto{}.PkEncryptMessage(publicKey, message)
+}
+
+// PkDecryptMessage decrypts a message using the Go implementation (see BCCSPCrypto)
+func (c BCCSPCrypto) PkDecryptMessage(privateKey []byte, encryptedMessage []byte) (message []byte, e error) {
+	return GoCrypto{}.PkDecryptMessage(privateKey, encryptedMessage)
+}
+
+// NewSymmetricKey generates a new symmetric key using the Go implementation (see BCCSPCrypto)
+func (c BCCSPCrypto) NewSymmetricKey() ([]byte, error) {
+	return GoCrypto{}.NewSymmetricKey()
+}
+
+// EncryptMessage encrypts a message using the Go implementation (see BCCSPCrypto)
+func (c BCCSPCrypto) EncryptMessage(key []byte, message []byte) (encryptedMessage []byte, e error) {
+	return GoCrypto{}.EncryptMessage(key, message)
+}
+
+// DecryptMessage decrypts a message using the Go implementation (see BCCSPCrypto)
+func (c BCCSPCrypto) DecryptMessage(key []byte, encryptedMessage []byte) ([]byte, error) {
+	return GoCrypto{}.DecryptMessage(key, encryptedMessage)
+}
+
+// EncryptMessageWithAD encrypts a message with additional data using the Go implementation (see BCCSPCrypto)
+func (c BCCSPCrypto) EncryptMessageWithAD(key []byte, message []byte, additionalData []byte) (encryptedMessage []byte, e error) {
+	return GoCrypto{}.EncryptMessageWithAD(key, message, additionalData)
+}
+
+// DecryptMessageWithAD decrypts a message with additional data using the Go implementation (see BCCSPCrypto)
+func (c BCCSPCrypto) DecryptMessageWithAD(key []byte, encryptedMessage []byte, additionalData []byte) ([]byte, error) {
+	return GoCrypto{}.DecryptMessageWithAD(key, encryptedMessage, additionalData)
+}
@@ -0,0 +1,81 @@
+//go:build pkcs11
+// +build pkcs11
+
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package crypto
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/bccsp/factory"
+	"github.com/hyperledger/fabric/bccsp/pkcs11"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPKCS11Crypto returns a CSP using the token configured by PKCS11_LIB, PKCS11_PIN and PKCS11_LABEL,
+// by default, a SoftHSM token labeled ForFabric with pin 98765432 (see `make test-pkcs11`)
+func newPKCS11Crypto(t *testing.T) *BCCSPCrypto {
+	lib, pin, label := pkcs11.FindPKCS11Lib()
+	if lib == "" {
+		t.Skip("no PKCS#11 library found")
+	}
+
+	c, err := NewBCCSPCryptoFromOpts(&factory.FactoryOpts{
+		Default: "PKCS11",
+		PKCS11: &pkcs11.PKCS11Opts{
+			Security: 256,
+			Hash:     "SHA2",
+			Library:  lib,
+			Pin:      pin,
+			Label:    label,
+		},
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func TestPKCS11Crypto(t *testing.T) {
+	c := newPKCS11Crypto(t)
+	g := NewGoCrypto()
+	msg := []byte("some message")
+
+	// the private key stays in the HSM
+	pubKey, privKey, err := c.NewECDSAKeys()
+	require.NoError(t, err)
+	sig, err := c.SignMessage(privKey, msg)
+	require.NoError(t, err)
+	assert.NoError(t, c.VerifyMessage(pubKey, msg, sig))
+	assert.NoError(t, g.VerifyMessage(pubKey, msg, sig))
+	assert.Error(t, c.VerifyMessage(pubKey, []byte("other message"), sig))
+
+	// signatures of the Go implementation are verified by the HSM CSP
+	pubKey, privKey, err = g.NewECDSAKeys()
+	require.NoError(t, err)
+	for i := 0; i < 16; i++ {
+		sig, err = g.SignMessage(privKey, msg)
+		require.NoError(t, err)
+		assert.NoError(t, c.VerifyMessage(pubKey, msg, sig))
+	}
+
+	// hashes are computed by the BCCSP
+	h, err := c.Hash(DefaultHashAlgorithm, msg)
+	assert.NoError(t, err)
+	expected, err := g.Hash(DefaultHashAlgorithm, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, h)
+
+	// client-side encryption works with the HSM CSP
+	key, err := c.NewSymmetricKey()
+	require.NoError(t, err)
+	cipher, err := c.EncryptMessage(key, msg)
+	require.NoError(t, err)
+	plain, err := g.DecryptMessage(key, cipher)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, plain)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package crypto
+
+import (
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric/bccsp"
+	"github.com/hyperledger/fabric/bccsp/sw"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryKeyStore keeps the keys of the SW BCCSP in memory
+type memoryKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]bccsp.Key
+}
+
+func (ks *memoryKeyStore) ReadOnly() bool {
+	return false
+}
+
+func (ks *memoryKeyStore) GetKey(ski []byte) (bccsp.Key, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k, ok := ks.keys[hex.EncodeToString(ski)]
+	if !ok {
+		return nil, fmt.Errorf("key not found")
+	}
+	return k, nil
+}
+
+func (ks *memoryKeyStore) StoreKey(k bccsp.Key) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[hex.EncodeToString(k.SKI())] = k
+	return nil
+}
+
+func newSWCrypto() *BCCSPCrypto {
+	csp, err := sw.NewDefaultSecurityLevelWithKeystore(&memoryKeyStore{keys: make(map[string]bccsp.Key)})
+	if err != nil {
+		panic(err)
+	}
+	return NewBCCSPCrypto(csp)
+}
+
+func TestBCCSPSignature(t *testing.T) {
+	c := newSWCrypto()
+	msg := []byte("some message")
+
+	pubKey, privKey, err := c.NewECDSAKeys()
+	require.NoError(t, err)
+	block, _ := pem.Decode(pubKey)
+	require.NotNil(t, block)
+	assert.Equal(t, "PUBLIC KEY", block.Type)
+	block, _ = pem.Decode(privKey)
+	require.NotNil(t, block)
+	assert.Equal(t, KeyReferencePEMType, block.Type)
+
+	sig, err := c.SignMessage(privKey, msg)
+	require.NoError(t, err)
+	assert.NoError(t, c.VerifyMessage(pubKey, msg, sig))
+
+	// should fail with other message
+	assert.Error(t, c.VerifyMessage(pubKey, []byte("other message"), sig))
+
+	// should fail with other key
+	otherPubKey, _, err := c.NewECDSAKeys()
+	require.NoError(t, err)
+	assert.Error(t, c.VerifyMessage(otherPubKey, msg, sig))
+
+	// should fail with keys of another keystore
+	sig, err = newSWCrypto().SignMessage(privKey, msg)
+	assert.Nil(t, sig)
+	assert.Error(t, err)
+}
+
+func TestBCCSPCompatibility(t *testing.T) {
+	c := newSWCrypto()
+	g := NewGoCrypto()
+	msg := []byte("some message")
+
+	// keys of the Go implementation can be used with the BCCSP
+	pubKey, privKey, err := g.NewECDSAKeys()
+	require.NoError(t, err)
+	sig, err := c.SignMessage(privKey, msg)
+	assert.NoError(t, err)
+	assert.NoError(t, g.VerifyMessage(pubKey, msg, sig))
+
+	// the BCCSP verifies signatures of the Go implementation, which may have a high S
+	for i := 0; i < 16; i++ {
+		sig, err = g.SignMessage(privKey, msg)
+		require.NoError(t, err)
+		assert.NoError(t, c.VerifyMessage(pubKey, msg, sig))
+	}
+
+	// the Go implementation verifies signatures of the BCCSP
+	pubKey, privKey, err = c.NewECDSAKeys()
+	require.NoError(t, err)
+	sig, err = c.SignMessage(privKey, msg)
+	require.NoError(t, err)
+	assert.NoError(t, g.VerifyMessage(pubKey, msg, sig))
+	assert.Error(t, g.VerifyMessage(pubKey, []byte("other message"), sig))
+}
+
+func TestBCCSPHash(t *testing.T) {
+	c := newSWCrypto()
+	g := NewGoCrypto()
+	msg := []byte("some message")
+
+	for _, algorithm := range []protos.HashAlgorithm{protos.HashAlgorithm_HASH_ALGORITHM_SHA256, protos.HashAlgorithm_HASH_ALGORITHM_SHA384} {
+		h, err := c.Hash(algorithm, msg)
+		assert.NoError(t, err)
+		expected, err := g.Hash(algorithm, msg)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, h)
+	}
+
+	// should fail with unknown algorithm
+	h, err := c.Hash(protos.HashAlgorithm(42), msg)
+	assert.Nil(t, h)
+	assert.Error(t, err)
+}
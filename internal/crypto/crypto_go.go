@@ -11,15 +11,20 @@ import (
 	"crypto/cipher"
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"hash"
 	"io"
 
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	"github.com/pkg/errors"
 )
 
@@ -198,6 +203,11 @@ func (g GoCrypto) PkEncryptMessage(publicKey []byte, message []byte) ([]byte, er
 }
 
 func (g GoCrypto) DecryptMessage(key []byte, encryptedMessage []byte) ([]byte, error) {
+	return g.DecryptMessageWithAD(key, encryptedMessage, nil)
+}
+
+// DecryptMessageWithAD decrypts a message and authenticates the additional data, which must match the data used for encryption
+func (g GoCrypto) DecryptMessageWithAD(key []byte, encryptedMessage []byte, additionalData []byte) ([]byte, error) {
 
 	if len(encryptedMessage) <= NonceLength+TagLength {
 		return nil, fmt.Errorf("encrypted message to small. expect len to be larger than %d, actual %d", NonceLength+TagLength, len(encryptedMessage))
@@ -223,7 +233,7 @@ func (g GoCrypto) DecryptMessage(key []byte, encryptedMessage []byte) ([]byte, e
 		return nil, err
 	}
 
-	plaintext, err := aesgcm.Open(nil, nonce, aesgcmCiphertext, nil)
+	plaintext, err := aesgcm.Open(nil, nonce, aesgcmCiphertext, additionalData)
 	if err != nil {
 		return nil, err
 	}
@@ -232,6 +242,11 @@ func (g GoCrypto) DecryptMessage(key []byte, encryptedMessage []byte) ([]byte, e
 }
 
 func (g GoCrypto) EncryptMessage(key []byte, message []byte) (encryptedMessage []byte, err error) {
+	return g.EncryptMessageWithAD(key, message, nil)
+}
+
+// EncryptMessageWithAD encrypts a message and authenticates, but does not include, the additional data
+func (g GoCrypto) EncryptMessageWithAD(key []byte, message []byte, additionalData []byte) (encryptedMessage []byte, err error) {
 
 	// generate nonce (IV)
 	nonce := make([]byte, NonceLength)
@@ -250,7 +265,7 @@ func (g GoCrypto) EncryptMessage(key []byte, message []byte) (encryptedMessage [
 		return nil, err
 	}
 
-	aesgcmCiphertext := aesgcm.Seal(nil, nonce, message, nil)
+	aesgcmCiphertext := aesgcm.Seal(nil, nonce, message, additionalData)
 
 	// Note that Seal appends the authentication tag to the cipertext, whereas PDO crypto prepends the tag
 	ciphertext, tag := aesgcmCiphertext[:len(aesgcmCiphertext)-TagLength], aesgcmCiphertext[len(aesgcmCiphertext)-TagLength:]
@@ -262,3 +277,44 @@ func (g GoCrypto) EncryptMessage(key []byte, message []byte) (encryptedMessage [
 	return encryptedMessage, nil
 
 }
+
+// hashFunction returns the Go implementation of a hash algorithm
+func hashFunction(algorithm protos.HashAlgorithm) (func() hash.Hash, error) {
+	switch algorithm {
+	case protos.HashAlgorithm_HASH_ALGORITHM_SHA256:
+		return sha256.New, nil
+	case protos.HashAlgorithm_HASH_ALGORITHM_SHA384:
+		return sha512.New384, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %s", algorithm)
+	}
+}
+
+func (g GoCrypto) Hash(algorithm protos.HashAlgorithm, message []byte) ([]byte, error) {
+	newHash, err := hashFunction(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	h := newHash()
+	h.Write(message)
+	return h.Sum(nil), nil
+}
+
+func (g GoCrypto) HMAC(algorithm protos.HashAlgorithm, key []byte, message []byte) ([]byte, error) {
+	newHash, err := hashFunction(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(newHash, key)
+	mac.Write(message)
+	return mac.Sum(nil), nil
+}
+
+// DeriveKey derives a key of the given length from a secret using HKDF (RFC 5869)
+func (g GoCrypto) DeriveKey(algorithm protos.HashAlgorithm, secret []byte, salt []byte, info string, length int) ([]byte, error) {
+	newHash, err := hashFunction(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Key(newHash, secret, salt, info, length)
+}
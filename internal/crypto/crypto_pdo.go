@@ -13,6 +13,8 @@ package crypto
 import (
 	"crypto/rand"
 	"fmt"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 )
 
 // #cgo CFLAGS: -I${SRCDIR}/../../common/crypto
@@ -290,3 +292,53 @@ func (c PDOCrypto) EncryptMessage(key []byte, message []byte) (encryptedMessage
 
 	return C.GoBytes(encryptedMessagePtr, C.int(encryptedMessageActualLen)), nil
 }
+
+// DecryptMessageWithAD decrypts a message with additional data. As the PDO crypto library does not support additional
+// data, we use the Go implementation, which uses the same message format.
+func (c PDOCrypto) DecryptMessageWithAD(key []byte, encryptedMessage []byte, additionalData []byte) ([]byte, error) {
+	return GoCrypto{}.DecryptMessageWithAD(key, encryptedMessage, additionalData)
+}
+
+// EncryptMessageWithAD encrypts a message with additional data using the Go implementation (see DecryptMessageWithAD)
+func (c PDOCrypto) EncryptMessageWithAD(key []byte, message []byte, additionalData []byte) (encryptedMessage []byte, e error) {
+	return GoCrypto{}.EncryptMessageWithAD(key, message, additionalData)
+}
+
+// Hash computes the hash of a message. The PDO crypto library only implements SHA256, for other algorithms we use
+// the Go implementation.
+func (c PDOCrypto) Hash(algorithm protos.HashAlgorithm, message []byte) ([]byte, error) {
+	if algorithm != protos.HashAlgorithm_HASH_ALGORITHM_SHA256 {
+		return GoCrypto{}.Hash(algorithm, message)
+	}
+
+	const hashLen = 32
+	hashPtr := C.malloc(hashLen)
+	defer C.free(hashPtr)
+	hashActualLen := C.uint32_t(0)
+
+	messagePtr := C.CBytes(message)
+	defer C.free(messagePtr)
+
+	ret := C.compute_hash(
+		(*C.uint8_t)(messagePtr),
+		(C.uint32_t)(len(message)),
+		(*C.uint8_t)(hashPtr),
+		hashLen,
+		&hashActualLen,
+	)
+	if !ret {
+		return nil, fmt.Errorf("hash computation failed")
+	}
+
+	return C.GoBytes(hashPtr, C.int(hashActualLen)), nil
+}
+
+// HMAC computes the MAC of a message using the Go implementation, as the PDO crypto library does not offer HMAC
+func (c PDOCrypto) HMAC(algorithm protos.HashAlgorithm, key []byte, message []byte) ([]byte, error) {
+	return GoCrypto{}.HMAC(algorithm, key, message)
+}
+
+// DeriveKey derives a key using the Go implementation (see HMAC)
+func (c PDOCrypto) DeriveKey(algorithm protos.HashAlgorithm, secret []byte, salt []byte, info string, length int) ([]byte, error) {
+	return GoCrypto{}.DeriveKey(algorithm, secret, salt, info, length)
+}
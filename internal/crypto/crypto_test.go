@@ -8,10 +8,13 @@ SPDX-License-Identifier: Apache-2.0
 package crypto
 
 import (
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"testing"
 
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -169,3 +172,103 @@ func TestSymEncryption(t *testing.T) {
 		assert.NoError(t, err)
 	}
 }
+
+func TestSymEncryptionWithAD(t *testing.T) {
+	msg := []byte("some message")
+	ad := []byte("some additional data")
+
+	for _, tc := range allTestCases {
+		key, err := tc.CSP.NewSymmetricKey()
+		assert.NoError(t, err)
+
+		cipher, err := tc.CSP.EncryptMessageWithAD(key, msg, ad)
+		assert.NotNil(t, cipher)
+		assert.NoError(t, err)
+
+		// should fail with other additional data
+		plain, err := tc.CSP.DecryptMessageWithAD(key, cipher, []byte("other additional data"))
+		assert.Nil(t, plain)
+		assert.Error(t, err)
+
+		// should fail without additional data
+		plain, err = tc.CSP.DecryptMessage(key, cipher)
+		assert.Nil(t, plain)
+		assert.Error(t, err)
+
+		// should succeed
+		plain, err = tc.CSP.DecryptMessageWithAD(key, cipher, ad)
+		assert.Equal(t, msg, plain)
+		assert.NoError(t, err)
+
+		// messages without additional data can be decrypted with empty additional data
+		cipher, err = tc.CSP.EncryptMessage(key, msg)
+		assert.NoError(t, err)
+		plain, err = tc.CSP.DecryptMessageWithAD(key, cipher, nil)
+		assert.Equal(t, msg, plain)
+		assert.NoError(t, err)
+	}
+}
+
+func TestHash(t *testing.T) {
+	msg := []byte("some message")
+	expected := sha256.Sum256(msg)
+
+	for _, tc := range allTestCases {
+		h, err := tc.CSP.Hash(DefaultHashAlgorithm, msg)
+		assert.NoError(t, err)
+		assert.Equal(t, expected[:], h)
+
+		h, err = tc.CSP.Hash(protos.HashAlgorithm_HASH_ALGORITHM_SHA384, msg)
+		assert.NoError(t, err)
+		assert.Len(t, h, 48)
+
+		h, err = Hasher(tc.CSP, protos.HashAlgorithm_HASH_ALGORITHM_SHA384)(msg)
+		assert.NoError(t, err)
+		assert.Len(t, h, 48)
+
+		// should fail with unknown algorithm
+		h, err = tc.CSP.Hash(protos.HashAlgorithm(42), msg)
+		assert.Nil(t, h)
+		assert.Error(t, err)
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	// test case 2 of RFC 4231
+	key := []byte("Jefe")
+	msg := []byte("what do ya want for nothing?")
+	expected, _ := hex.DecodeString("5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843")
+
+	for _, tc := range allTestCases {
+		mac, err := tc.CSP.HMAC(protos.HashAlgorithm_HASH_ALGORITHM_SHA256, key, msg)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, mac)
+
+		mac, err = tc.CSP.HMAC(protos.HashAlgorithm(42), key, msg)
+		assert.Nil(t, mac)
+		assert.Error(t, err)
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	secret := []byte("some secret")
+	salt := []byte("some salt")
+
+	for _, tc := range allTestCases {
+		key, err := tc.CSP.DeriveKey(DefaultHashAlgorithm, secret, salt, "some info", SymKeyLength)
+		assert.NoError(t, err)
+		assert.Len(t, key, SymKeyLength)
+
+		// derivation is deterministic but depends on the info
+		otherKey, err := tc.CSP.DeriveKey(DefaultHashAlgorithm, secret, salt, "some info", SymKeyLength)
+		assert.NoError(t, err)
+		assert.Equal(t, key, otherKey)
+		otherKey, err = tc.CSP.DeriveKey(DefaultHashAlgorithm, secret, salt, "other info", SymKeyLength)
+		assert.NoError(t, err)
+		assert.NotEqual(t, key, otherKey)
+
+		key, err = tc.CSP.DeriveKey(protos.HashAlgorithm(42), secret, salt, "some info", SymKeyLength)
+		assert.Nil(t, key)
+		assert.Error(t, err)
+	}
+}
@@ -3,6 +3,8 @@ package fakes
 
 import (
 	"sync"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 )
 
 type CryptoProvider struct {
@@ -20,6 +22,38 @@ type CryptoProvider struct {
 		result1 []byte
 		result2 error
 	}
+	DecryptMessageWithADStub        func([]byte, []byte, []byte) ([]byte, error)
+	decryptMessageWithADMutex       sync.RWMutex
+	decryptMessageWithADArgsForCall []struct {
+		arg1 []byte
+		arg2 []byte
+		arg3 []byte
+	}
+	decryptMessageWithADReturns struct {
+		result1 []byte
+		result2 error
+	}
+	decryptMessageWithADReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
+	DeriveKeyStub        func(protos.HashAlgorithm, []byte, []byte, string, int) ([]byte, error)
+	deriveKeyMutex       sync.RWMutex
+	deriveKeyArgsForCall []struct {
+		arg1 protos.HashAlgorithm
+		arg2 []byte
+		arg3 []byte
+		arg4 string
+		arg5 int
+	}
+	deriveKeyReturns struct {
+		result1 []byte
+		result2 error
+	}
+	deriveKeyReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
 	EncryptMessageStub        func([]byte, []byte) ([]byte, error)
 	encryptMessageMutex       sync.RWMutex
 	encryptMessageArgsForCall []struct {
@@ -34,6 +68,50 @@ type CryptoProvider struct {
 		result1 []byte
 		result2 error
 	}
+	EncryptMessageWithADStub        func([]byte, []byte, []byte) ([]byte, error)
+	encryptMessageWithADMutex       sync.RWMutex
+	encryptMessageWithADArgsForCall []struct {
+		arg1 []byte
+		arg2 []byte
+		arg3 []byte
+	}
+	encryptMessageWithADReturns struct {
+		result1 []byte
+		result2 error
+	}
+	encryptMessageWithADReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
+	HMACStub        func(protos.HashAlgorithm, []byte, []byte) ([]byte, error)
+	hMACMutex       sync.RWMutex
+	hMACArgsForCall []struct {
+		arg1 protos.HashAlgorithm
+		arg2 []byte
+		arg3 []byte
+	}
+	hMACReturns struct {
+		result1 []byte
+		result2 error
+	}
+	hMACReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
+	HashStub        func(protos.HashAlgorithm, []byte) ([]byte, error)
+	hashMutex       sync.RWMutex
+	hashArgsForCall []struct {
+		arg1 protos.HashAlgorithm
+		arg2 []byte
+	}
+	hashReturns struct {
+		result1 []byte
+		result2 error
+	}
+	hashReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
 	NewECDSAKeysStub        func() ([]byte, []byte, error)
 	newECDSAKeysMutex       sync.RWMutex
 	newECDSAKeysArgsForCall []struct {
@@ -208,6 +286,165 @@ func (fake *CryptoProvider) DecryptMessageReturnsOnCall(i int, result1 []byte, r
 	}{result1, result2}
 }
 
+func (fake *CryptoProvider) DecryptMessageWithAD(arg1 []byte, arg2 []byte, arg3 []byte) ([]byte, error) {
+	var arg1Copy []byte
+	if arg1 != nil {
+		arg1Copy = make([]byte, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	var arg2Copy []byte
+	if arg2 != nil {
+		arg2Copy = make([]byte, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	var arg3Copy []byte
+	if arg3 != nil {
+		arg3Copy = make([]byte, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.decryptMessageWithADMutex.Lock()
+	ret, specificReturn := fake.decryptMessageWithADReturnsOnCall[len(fake.decryptMessageWithADArgsForCall)]
+	fake.decryptMessageWithADArgsForCall = append(fake.decryptMessageWithADArgsForCall, struct {
+		arg1 []byte
+		arg2 []byte
+		arg3 []byte
+	}{arg1Copy, arg2Copy, arg3Copy})
+	stub := fake.DecryptMessageWithADStub
+	fakeReturns := fake.decryptMessageWithADReturns
+	fake.recordInvocation("DecryptMessageWithAD", []interface{}{arg1Copy, arg2Copy, arg3Copy})
+	fake.decryptMessageWithADMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *CryptoProvider) DecryptMessageWithADCallCount() int {
+	fake.decryptMessageWithADMutex.RLock()
+	defer fake.decryptMessageWithADMutex.RUnlock()
+	return len(fake.decryptMessageWithADArgsForCall)
+}
+
+func (fake *CryptoProvider) DecryptMessageWithADCalls(stub func([]byte, []byte, []byte) ([]byte, error)) {
+	fake.decryptMessageWithADMutex.Lock()
+	defer fake.decryptMessageWithADMutex.Unlock()
+	fake.DecryptMessageWithADStub = stub
+}
+
+func (fake *CryptoProvider) DecryptMessageWithADArgsForCall(i int) ([]byte, []byte, []byte) {
+	fake.decryptMessageWithADMutex.RLock()
+	defer fake.decryptMessageWithADMutex.RUnlock()
+	argsForCall := fake.decryptMessageWithADArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *CryptoProvider) DecryptMessageWithADReturns(result1 []byte, result2 error) {
+	fake.decryptMessageWithADMutex.Lock()
+	defer fake.decryptMessageWithADMutex.Unlock()
+	fake.DecryptMessageWithADStub = nil
+	fake.decryptMessageWithADReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CryptoProvider) DecryptMessageWithADReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.decryptMessageWithADMutex.Lock()
+	defer fake.decryptMessageWithADMutex.Unlock()
+	fake.DecryptMessageWithADStub = nil
+	if fake.decryptMessageWithADReturnsOnCall == nil {
+		fake.decryptMessageWithADReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.decryptMessageWithADReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CryptoProvider) DeriveKey(arg1 protos.HashAlgorithm, arg2 []byte, arg3 []byte, arg4 string, arg5 int) ([]byte, error) {
+	var arg2Copy []byte
+	if arg2 != nil {
+		arg2Copy = make([]byte, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	var arg3Copy []byte
+	if arg3 != nil {
+		arg3Copy = make([]byte, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.deriveKeyMutex.Lock()
+	ret, specificReturn := fake.deriveKeyReturnsOnCall[len(fake.deriveKeyArgsForCall)]
+	fake.deriveKeyArgsForCall = append(fake.deriveKeyArgsForCall, struct {
+		arg1 protos.HashAlgorithm
+		arg2 []byte
+		arg3 []byte
+		arg4 string
+		arg5 int
+	}{arg1, arg2Copy, arg3Copy, arg4, arg5})
+	stub := fake.DeriveKeyStub
+	fakeReturns := fake.deriveKeyReturns
+	fake.recordInvocation("DeriveKey", []interface{}{arg1, arg2Copy, arg3Copy, arg4, arg5})
+	fake.deriveKeyMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4, arg5)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *CryptoProvider) DeriveKeyCallCount() int {
+	fake.deriveKeyMutex.RLock()
+	defer fake.deriveKeyMutex.RUnlock()
+	return len(fake.deriveKeyArgsForCall)
+}
+
+func (fake *CryptoProvider) DeriveKeyCalls(stub func(protos.HashAlgorithm, []byte, []byte, string, int) ([]byte, error)) {
+	fake.deriveKeyMutex.Lock()
+	defer fake.deriveKeyMutex.Unlock()
+	fake.DeriveKeyStub = stub
+}
+
+func (fake *CryptoProvider) DeriveKeyArgsForCall(i int) (protos.HashAlgorithm, []byte, []byte, string, int) {
+	fake.deriveKeyMutex.RLock()
+	defer fake.deriveKeyMutex.RUnlock()
+	argsForCall := fake.deriveKeyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
+func (fake *CryptoProvider) DeriveKeyReturns(result1 []byte, result2 error) {
+	fake.deriveKeyMutex.Lock()
+	defer fake.deriveKeyMutex.Unlock()
+	fake.DeriveKeyStub = nil
+	fake.deriveKeyReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CryptoProvider) DeriveKeyReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.deriveKeyMutex.Lock()
+	defer fake.deriveKeyMutex.Unlock()
+	fake.DeriveKeyStub = nil
+	if fake.deriveKeyReturnsOnCall == nil {
+		fake.deriveKeyReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.deriveKeyReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *CryptoProvider) EncryptMessage(arg1 []byte, arg2 []byte) ([]byte, error) {
 	var arg1Copy []byte
 	if arg1 != nil {
@@ -283,6 +520,233 @@ func (fake *CryptoProvider) EncryptMessageReturnsOnCall(i int, result1 []byte, r
 	}{result1, result2}
 }
 
+func (fake *CryptoProvider) EncryptMessageWithAD(arg1 []byte, arg2 []byte, arg3 []byte) ([]byte, error) {
+	var arg1Copy []byte
+	if arg1 != nil {
+		arg1Copy = make([]byte, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	var arg2Copy []byte
+	if arg2 != nil {
+		arg2Copy = make([]byte, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	var arg3Copy []byte
+	if arg3 != nil {
+		arg3Copy = make([]byte, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.encryptMessageWithADMutex.Lock()
+	ret, specificReturn := fake.encryptMessageWithADReturnsOnCall[len(fake.encryptMessageWithADArgsForCall)]
+	fake.encryptMessageWithADArgsForCall = append(fake.encryptMessageWithADArgsForCall, struct {
+		arg1 []byte
+		arg2 []byte
+		arg3 []byte
+	}{arg1Copy, arg2Copy, arg3Copy})
+	stub := fake.EncryptMessageWithADStub
+	fakeReturns := fake.encryptMessageWithADReturns
+	fake.recordInvocation("EncryptMessageWithAD", []interface{}{arg1Copy, arg2Copy, arg3Copy})
+	fake.encryptMessageWithADMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *CryptoProvider) EncryptMessageWithADCallCount() int {
+	fake.encryptMessageWithADMutex.RLock()
+	defer fake.encryptMessageWithADMutex.RUnlock()
+	return len(fake.encryptMessageWithADArgsForCall)
+}
+
+func (fake *CryptoProvider) EncryptMessageWithADCalls(stub func([]byte, []byte, []byte) ([]byte, error)) {
+	fake.encryptMessageWithADMutex.Lock()
+	defer fake.encryptMessageWithADMutex.Unlock()
+	fake.EncryptMessageWithADStub = stub
+}
+
+func (fake *CryptoProvider) EncryptMessageWithADArgsForCall(i int) ([]byte, []byte, []byte) {
+	fake.encryptMessageWithADMutex.RLock()
+	defer fake.encryptMessageWithADMutex.RUnlock()
+	argsForCall := fake.encryptMessageWithADArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *CryptoProvider) EncryptMessageWithADReturns(result1 []byte, result2 error) {
+	fake.encryptMessageWithADMutex.Lock()
+	defer fake.encryptMessageWithADMutex.Unlock()
+	fake.EncryptMessageWithADStub = nil
+	fake.encryptMessageWithADReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CryptoProvider) EncryptMessageWithADReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.encryptMessageWithADMutex.Lock()
+	defer fake.encryptMessageWithADMutex.Unlock()
+	fake.EncryptMessageWithADStub = nil
+	if fake.encryptMessageWithADReturnsOnCall == nil {
+		fake.encryptMessageWithADReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.encryptMessageWithADReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CryptoProvider) HMAC(arg1 protos.HashAlgorithm, arg2 []byte, arg3 []byte) ([]byte, error) {
+	var arg2Copy []byte
+	if arg2 != nil {
+		arg2Copy = make([]byte, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	var arg3Copy []byte
+	if arg3 != nil {
+		arg3Copy = make([]byte, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.hMACMutex.Lock()
+	ret, specificReturn := fake.hMACReturnsOnCall[len(fake.hMACArgsForCall)]
+	fake.hMACArgsForCall = append(fake.hMACArgsForCall, struct {
+		arg1 protos.HashAlgorithm
+		arg2 []byte
+		arg3 []byte
+	}{arg1, arg2Copy, arg3Copy})
+	stub := fake.HMACStub
+	fakeReturns := fake.hMACReturns
+	fake.recordInvocation("HMAC", []interface{}{arg1, arg2Copy, arg3Copy})
+	fake.hMACMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *CryptoProvider) HMACCallCount() int {
+	fake.hMACMutex.RLock()
+	defer fake.hMACMutex.RUnlock()
+	return len(fake.hMACArgsForCall)
+}
+
+func (fake *CryptoProvider) HMACCalls(stub func(protos.HashAlgorithm, []byte, []byte) ([]byte, error)) {
+	fake.hMACMutex.Lock()
+	defer fake.hMACMutex.Unlock()
+	fake.HMACStub = stub
+}
+
+func (fake *CryptoProvider) HMACArgsForCall(i int) (protos.HashAlgorithm, []byte, []byte) {
+	fake.hMACMutex.RLock()
+	defer fake.hMACMutex.RUnlock()
+	argsForCall := fake.hMACArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *CryptoProvider) HMACReturns(result1 []byte, result2 error) {
+	fake.hMACMutex.Lock()
+	defer fake.hMACMutex.Unlock()
+	fake.HMACStub = nil
+	fake.hMACReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CryptoProvider) HMACReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.hMACMutex.Lock()
+	defer fake.hMACMutex.Unlock()
+	fake.HMACStub = nil
+	if fake.hMACReturnsOnCall == nil {
+		fake.hMACReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.hMACReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CryptoProvider) Hash(arg1 protos.HashAlgorithm, arg2 []byte) ([]byte, error) {
+	var arg2Copy []byte
+	if arg2 != nil {
+		arg2Copy = make([]byte, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.hashMutex.Lock()
+	ret, specificReturn := fake.hashReturnsOnCall[len(fake.hashArgsForCall)]
+	fake.hashArgsForCall = append(fake.hashArgsForCall, struct {
+		arg1 protos.HashAlgorithm
+		arg2 []byte
+	}{arg1, arg2Copy})
+	stub := fake.HashStub
+	fakeReturns := fake.hashReturns
+	fake.recordInvocation("Hash", []interface{}{arg1, arg2Copy})
+	fake.hashMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *CryptoProvider) HashCallCount() int {
+	fake.hashMutex.RLock()
+	defer fake.hashMutex.RUnlock()
+	return len(fake.hashArgsForCall)
+}
+
+func (fake *CryptoProvider) HashCalls(stub func(protos.HashAlgorithm, []byte) ([]byte, error)) {
+	fake.hashMutex.Lock()
+	defer fake.hashMutex.Unlock()
+	fake.HashStub = stub
+}
+
+func (fake *CryptoProvider) HashArgsForCall(i int) (protos.HashAlgorithm, []byte) {
+	fake.hashMutex.RLock()
+	defer fake.hashMutex.RUnlock()
+	argsForCall := fake.hashArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *CryptoProvider) HashReturns(result1 []byte, result2 error) {
+	fake.hashMutex.Lock()
+	defer fake.hashMutex.Unlock()
+	fake.HashStub = nil
+	fake.hashReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CryptoProvider) HashReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.hashMutex.Lock()
+	defer fake.hashMutex.Unlock()
+	fake.HashStub = nil
+	if fake.hashReturnsOnCall == nil {
+		fake.hashReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.hashReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *CryptoProvider) NewECDSAKeys() ([]byte, []byte, error) {
 	fake.newECDSAKeysMutex.Lock()
 	ret, specificReturn := fake.newECDSAKeysReturnsOnCall[len(fake.newECDSAKeysArgsForCall)]
@@ -765,8 +1229,18 @@ func (fake *CryptoProvider) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.decryptMessageMutex.RLock()
 	defer fake.decryptMessageMutex.RUnlock()
+	fake.decryptMessageWithADMutex.RLock()
+	defer fake.decryptMessageWithADMutex.RUnlock()
+	fake.deriveKeyMutex.RLock()
+	defer fake.deriveKeyMutex.RUnlock()
 	fake.encryptMessageMutex.RLock()
 	defer fake.encryptMessageMutex.RUnlock()
+	fake.encryptMessageWithADMutex.RLock()
+	defer fake.encryptMessageWithADMutex.RUnlock()
+	fake.hMACMutex.RLock()
+	defer fake.hMACMutex.RUnlock()
+	fake.hashMutex.RLock()
+	defer fake.hashMutex.RUnlock()
 	fake.newECDSAKeysMutex.RLock()
 	defer fake.newECDSAKeysMutex.RUnlock()
 	fake.newRSAKeysMutex.RLock()
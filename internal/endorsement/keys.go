@@ -0,0 +1,24 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package endorsement
+
+import (
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+)
+
+// FabricKey returns the ledger key of a key in an FPC rwset, i.e., FPC composite keys are converted into
+// Fabric composite keys
+func FabricKey(key string) (string, error) {
+	k := utils.TransformToFPCKey(key)
+	if !utils.IsFPCCompositeKey(k) {
+		return k, nil
+	}
+
+	comp := utils.SplitFPCCompositeKey(k)
+	return shim.CreateCompositeKey(comp[0], comp[1:])
+}
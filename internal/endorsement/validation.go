@@ -9,32 +9,71 @@ package endorsement
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
 	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
 	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
 	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/protoutil"
 	"github.com/pkg/errors"
 )
 
 var logger = flogging.MustGetLogger("validate")
 
+const (
+	// consumedRequestObjectType is the object type of the composite keys which record the chaincode requests
+	// consumed by an endorsement transaction. It contains the FPC composite key separator, thus, it cannot
+	// collide with the keys written by the chaincode.
+	consumedRequestObjectType = "fpc.consumed"
+
+	defaultMaxProposalAge = 10 * time.Minute
+)
+
 type Validation interface {
 	ReplayReadWrites(stub shim.ChaincodeStubInterface, fpcrwset *protos.FPCKVSet) error
 	Validate(signedResponseMessage *protos.SignedChaincodeResponseMessage, attestedData *protos.AttestedData) error
+	ValidateProposal(stub shim.ChaincodeStubInterface, responseMessage *protos.ChaincodeResponseMessage) error
 }
 
-func NewValidator() *ValidatorImpl {
-	return &ValidatorImpl{csp: crypto.GetDefaultCSP()}
+type Option func(v *ValidatorImpl)
+
+// WithMaxProposalAge sets how much the timestamp of the proposal processed by the enclave may differ from the
+// timestamp of the endorsement transaction
+func WithMaxProposalAge(maxAge time.Duration) Option {
+	return func(v *ValidatorImpl) {
+		if maxAge <= 0 {
+			panic(fmt.Sprintf("invalid max proposal age %s", maxAge))
+		}
+		v.maxProposalAge = maxAge
+	}
+}
+
+// WithCSP sets the crypto provider used to verify the signatures and digests of the enclave, e.g., a BCCSPCrypto
+func WithCSP(csp crypto.CSP) Option {
+	return func(v *ValidatorImpl) {
+		if csp == nil {
+			panic("invalid csp")
+		}
+		v.csp = csp
+	}
+}
+
+func NewValidator(options ...Option) *ValidatorImpl {
+	v := &ValidatorImpl{csp: crypto.GetDefaultCSP(), maxProposalAge: defaultMaxProposalAge}
+	for _, o := range options {
+		o(v)
+	}
+	return v
 }
 
 type ValidatorImpl struct {
-	csp crypto.CSP
+	csp            crypto.CSP
+	maxProposalAge time.Duration
 }
 
 func (v *ValidatorImpl) ReplayReadWrites(stub shim.ChaincodeStubInterface, fpcrwset *protos.FPCKVSet) (err error) {
@@ -67,22 +106,22 @@ func (v *ValidatorImpl) ReplayReadWrites(stub shim.ChaincodeStubInterface, fpcrw
 				k, _ = stub.CreateCompositeKey(comp[0], comp[1:])
 			}
 
-			v, err := stub.GetState(k)
+			value, err := stub.GetState(k)
 			if err != nil {
 				return fmt.Errorf("error (%s) reading key %s", err, k)
 			}
 
-			logger.Debugf("read key='%s' value(hex)='%s'", k, hex.EncodeToString(v))
+			logger.Debugf("read key='%s' value(hex)='%s'", k, hex.EncodeToString(value))
 
 			// compute value hash
-			// TODO: use CSP hash for consistency
-			h := sha256.New()
-			h.Write(v)
-			valueHash := h.Sum(nil)
+			valueHash, err := v.csp.Hash(fpcrwset.GetHashAlgorithm(), value)
+			if err != nil {
+				return errors.Wrap(err, "cannot compute read value hash")
+			}
 
 			// check hashes
 			if !bytes.Equal(valueHash, fpcrwset.ReadValueHashes[i]) {
-				logger.Debugf("value(hex): %s", hex.EncodeToString(v))
+				logger.Debugf("value(hex): %s", hex.EncodeToString(value))
 				logger.Debugf("computed hash(hex): %s", hex.EncodeToString(valueHash))
 				logger.Debugf("received hash(hex): %s", hex.EncodeToString(fpcrwset.ReadValueHashes[i]))
 				return fmt.Errorf("value hash mismatch for key %s", k)
@@ -175,16 +214,98 @@ func (v *ValidatorImpl) Validate(signedResponseMessage *protos.SignedChaincodeRe
 	if err != nil {
 		return errors.Wrap(err, "failed to extract chaincode request message")
 	}
-	expectedChaincodeRequestMessageHash := sha256.Sum256(chaincodeRequestMessageBytes)
+	expectedChaincodeRequestMessageHash, err := v.csp.Hash(chaincodeResponseMessage.GetHashAlgorithm(), chaincodeRequestMessageBytes)
+	if err != nil {
+		return errors.Wrap(err, "cannot compute chaincode request message hash")
+	}
 	chaincodeRequestMessageHash := chaincodeResponseMessage.GetChaincodeRequestMessageHash()
 	if chaincodeRequestMessageHash == nil {
 		return fmt.Errorf("cannot get the chaincode request message hash")
 	}
-	if !bytes.Equal(expectedChaincodeRequestMessageHash[:], chaincodeRequestMessageHash) {
-		logger.Debugf("expected chaincode request message hash: %s", strings.ToUpper(hex.EncodeToString(expectedChaincodeRequestMessageHash[:])))
+	if !bytes.Equal(expectedChaincodeRequestMessageHash, chaincodeRequestMessageHash) {
+		logger.Debugf("expected chaincode request message hash: %s", strings.ToUpper(hex.EncodeToString(expectedChaincodeRequestMessageHash)))
 		logger.Debugf("received chaincode request message hash: %s", strings.ToUpper(hex.EncodeToString(chaincodeRequestMessageHash[:])))
 		return fmt.Errorf("chaincode request message hash mismatch")
 	}
 
 	return nil
 }
+
+// ValidateProposal checks that the proposal processed by the enclave belongs to the endorsement transaction, i.e.,
+// it was created by the submitter of the transaction on the same channel, its tx ID is well-formed and its timestamp
+// is within the max proposal age of the transaction timestamp. Moreover, it records the chaincode request as
+// consumed on the ledger, so that a response cannot be committed twice; as the record is read before it is
+// written, concurrent endorsements of the same request are invalidated by the MVCC check of the peer.
+// It must be called after Validate, which ensures that the response message is signed by the enclave.
+func (v *ValidatorImpl) ValidateProposal(stub shim.ChaincodeStubInterface, responseMessage *protos.ChaincodeResponseMessage) error {
+	signedProposal := responseMessage.GetProposal()
+	if signedProposal == nil {
+		return fmt.Errorf("cannot get the signed proposal that the enclave received")
+	}
+	proposal, err := protoutil.UnmarshalProposal(signedProposal.GetProposalBytes())
+	if err != nil {
+		return errors.Wrap(err, "invalid proposal")
+	}
+	header, err := protoutil.UnmarshalHeader(proposal.GetHeader())
+	if err != nil {
+		return errors.Wrap(err, "invalid proposal header")
+	}
+	channelHeader, err := protoutil.UnmarshalChannelHeader(header.GetChannelHeader())
+	if err != nil {
+		return errors.Wrap(err, "invalid proposal header")
+	}
+	signatureHeader, err := protoutil.UnmarshalSignatureHeader(header.GetSignatureHeader())
+	if err != nil {
+		return errors.Wrap(err, "invalid proposal header")
+	}
+
+	// binding
+	if channelHeader.GetChannelId() != stub.GetChannelID() {
+		return fmt.Errorf("proposal belongs to channel %s", channelHeader.GetChannelId())
+	}
+	creator, err := stub.GetCreator()
+	if err != nil {
+		return errors.Wrap(err, "cannot get transaction creator")
+	}
+	if !bytes.Equal(signatureHeader.GetCreator(), creator) {
+		return fmt.Errorf("proposal was not created by the transaction creator")
+	}
+	if err := protoutil.CheckTxID(channelHeader.GetTxId(), signatureHeader.GetNonce(), signatureHeader.GetCreator()); err != nil {
+		return errors.Wrap(err, "invalid proposal tx ID")
+	}
+
+	// freshness
+	if channelHeader.GetTimestamp() == nil {
+		return fmt.Errorf("proposal has no timestamp")
+	}
+	txTimestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return errors.Wrap(err, "cannot get transaction timestamp")
+	}
+	age := txTimestamp.AsTime().Sub(channelHeader.GetTimestamp().AsTime())
+	if age > v.maxProposalAge || age < -v.maxProposalAge {
+		return fmt.Errorf("proposal timestamp differs from the transaction timestamp by %s, max %s", age, v.maxProposalAge)
+	}
+
+	// replay protection
+	requestHash := responseMessage.GetChaincodeRequestMessageHash()
+	if requestHash == nil {
+		return fmt.Errorf("cannot get the chaincode request message hash")
+	}
+	key, err := stub.CreateCompositeKey(consumedRequestObjectType, []string{strings.ToUpper(hex.EncodeToString(requestHash))})
+	if err != nil {
+		return err
+	}
+	consumed, err := stub.GetState(key)
+	if err != nil {
+		return errors.Wrap(err, "cannot read consumed requests")
+	}
+	if consumed != nil {
+		return fmt.Errorf("chaincode request was already endorsed")
+	}
+	if err := stub.PutState(key, []byte(channelHeader.GetTxId())); err != nil {
+		return errors.Wrap(err, "cannot record consumed request")
+	}
+
+	return nil
+}
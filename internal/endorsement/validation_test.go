@@ -2,9 +2,11 @@ package endorsement
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
@@ -16,6 +18,7 @@ import (
 	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/protoutil"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -generate
@@ -33,7 +36,7 @@ type cryptoProvider interface {
 }
 
 func TestReplayReadWrites(t *testing.T) {
-	v := &ValidatorImpl{}
+	v := &ValidatorImpl{csp: crypto.GetDefaultCSP()}
 	stub := &fakes.ChaincodeStub{}
 
 	// do nothing if no fpcrwset supplied
@@ -116,6 +119,21 @@ func TestReplayReadWrites(t *testing.T) {
 		assert.EqualValues(t, utils.TransformToFPCKey(r.Key), k)
 	}
 
+	// no errors (reads) with the hash algorithm recorded in the rwset
+	sha384Hash := sha512.Sum384(value)
+	fpcrwset = &protos.FPCKVSet{
+		RwSet:           someRWSet,
+		ReadValueHashes: [][]byte{sha384Hash[:]},
+		HashAlgorithm:   protos.HashAlgorithm_HASH_ALGORITHM_SHA384,
+	}
+	err = v.ReplayReadWrites(stub, fpcrwset)
+	assert.NoError(t, err)
+
+	// error when hash algorithm not supported
+	fpcrwset.HashAlgorithm = protos.HashAlgorithm(42)
+	err = v.ReplayReadWrites(stub, fpcrwset)
+	assert.ErrorContains(t, err, "unsupported hash algorithm")
+
 	// error when checking writeset and putstate returns error
 	stub = &fakes.ChaincodeStub{}
 	stub.PutStateReturns(fmt.Errorf("some error"))
@@ -162,6 +180,28 @@ func TestReplayReadWrites(t *testing.T) {
 	assert.EqualValues(t, expectedFabricCompKey, k)
 	assert.EqualValues(t, writeCompKey.Value, val)
 
+	// no error (reads) with comp keys, e.g., blinded keys whose attributes are hex encoded HMACs
+	expectedFabricCompKey = "\x00a362\x0082a2\x00"
+	readCompKey := &kvrwset.KVRead{
+		Key: ".a362.82a2.",
+	}
+	someRWSet = &kvrwset.KVRWSet{
+		Reads: []*kvrwset.KVRead{readCompKey},
+	}
+	fpcrwset = &protos.FPCKVSet{
+		RwSet:           someRWSet,
+		ReadValueHashes: [][]byte{hash(value)},
+	}
+	stub = &fakes.ChaincodeStub{}
+	stub.CreateCompositeKeyReturns(expectedFabricCompKey, nil)
+	stub.GetStateReturns(value, nil)
+	err = v.ReplayReadWrites(stub, fpcrwset)
+	assert.NoError(t, err)
+	objectType, attributes := stub.CreateCompositeKeyArgsForCall(0)
+	assert.Equal(t, "a362", objectType)
+	assert.Equal(t, []string{"82a2"}, attributes)
+	assert.EqualValues(t, expectedFabricCompKey, stub.GetStateArgsForCall(0))
+
 	// error when rangequery
 	someRWSet = &kvrwset.KVRWSet{
 		RangeQueriesInfo: []*kvrwset.RangeQueryInfo{{
@@ -179,6 +219,7 @@ func TestReplayReadWrites(t *testing.T) {
 func TestValidate(t *testing.T) {
 	// TODO
 	c := &fakes.CryptoProvider{}
+	c.HashStub = crypto.GetDefaultCSP().Hash
 	v := &ValidatorImpl{csp: c}
 
 	scr := &protos.SignedChaincodeResponseMessage{}
@@ -235,6 +276,114 @@ func TestValidate(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestValidateProposal(t *testing.T) {
+	creator := []byte("someCreator")
+	nonce := []byte("someNonce")
+	now := time.Now()
+
+	newResponse := func(chdr *common.ChannelHeader, shdr *common.SignatureHeader) *protos.ChaincodeResponseMessage {
+		header := &common.Header{
+			ChannelHeader:   protoutil.MarshalOrPanic(chdr),
+			SignatureHeader: protoutil.MarshalOrPanic(shdr),
+		}
+		proposal := &peer.Proposal{Header: protoutil.MarshalOrPanic(header)}
+		return &protos.ChaincodeResponseMessage{
+			Proposal:                    &peer.SignedProposal{ProposalBytes: protoutil.MarshalOrPanic(proposal)},
+			ChaincodeRequestMessageHash: []byte("someHash"),
+		}
+	}
+	validHeaders := func() (*common.ChannelHeader, *common.SignatureHeader) {
+		chdr := &common.ChannelHeader{
+			Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+			ChannelId: "someChannelId",
+			TxId:      protoutil.ComputeTxID(nonce, creator),
+			Timestamp: timestamppb.New(now.Add(-time.Minute)),
+		}
+		shdr := &common.SignatureHeader{
+			Creator: creator,
+			Nonce:   nonce,
+		}
+		return chdr, shdr
+	}
+	newStub := func() *fakes.ChaincodeStub {
+		stub := &fakes.ChaincodeStub{}
+		stub.GetChannelIDReturns("someChannelId")
+		stub.GetCreatorReturns(creator, nil)
+		stub.GetTxTimestampReturns(timestamppb.New(now), nil)
+		stub.CreateCompositeKeyStub = func(objectType string, attributes []string) (string, error) {
+			return shim.CreateCompositeKey(objectType, attributes)
+		}
+		return stub
+	}
+
+	v := NewValidator(WithMaxProposalAge(5 * time.Minute))
+
+	// no errors; the request is recorded as consumed
+	stub := newStub()
+	err := v.ValidateProposal(stub, newResponse(validHeaders()))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stub.PutStateCallCount())
+	key, _ := stub.PutStateArgsForCall(0)
+	readKey := stub.GetStateArgsForCall(0)
+	assert.Equal(t, readKey, key)
+	expectedKey, _ := shim.CreateCompositeKey(consumedRequestObjectType, []string{"736F6D6548617368"})
+	assert.Equal(t, expectedKey, key)
+
+	// error when the request was consumed before
+	stub = newStub()
+	stub.GetStateReturns([]byte("someTxId"), nil)
+	err = v.ValidateProposal(stub, newResponse(validHeaders()))
+	assert.EqualError(t, err, "chaincode request was already endorsed")
+	assert.Equal(t, 0, stub.PutStateCallCount())
+
+	// error when no proposal
+	err = v.ValidateProposal(newStub(), &protos.ChaincodeResponseMessage{})
+	assert.Error(t, err)
+
+	// error when the proposal belongs to another channel
+	chdr, shdr := validHeaders()
+	chdr.ChannelId = "otherChannelId"
+	err = v.ValidateProposal(newStub(), newResponse(chdr, shdr))
+	assert.EqualError(t, err, "proposal belongs to channel otherChannelId")
+
+	// error when the proposal was created by another client
+	stub = newStub()
+	stub.GetCreatorReturns([]byte("otherCreator"), nil)
+	err = v.ValidateProposal(stub, newResponse(validHeaders()))
+	assert.EqualError(t, err, "proposal was not created by the transaction creator")
+
+	// error when the tx ID does not match nonce and creator
+	chdr, shdr = validHeaders()
+	chdr.TxId = "someTxId"
+	err = v.ValidateProposal(newStub(), newResponse(chdr, shdr))
+	assert.ErrorContains(t, err, "invalid proposal tx ID")
+
+	// error when the proposal is too old, or too far in the future
+	for _, offset := range []time.Duration{-6 * time.Minute, 6 * time.Minute} {
+		chdr, shdr = validHeaders()
+		chdr.Timestamp = timestamppb.New(now.Add(offset))
+		err = v.ValidateProposal(newStub(), newResponse(chdr, shdr))
+		assert.ErrorContains(t, err, "proposal timestamp differs from the transaction timestamp")
+	}
+
+	// error when no timestamp
+	chdr, shdr = validHeaders()
+	chdr.Timestamp = nil
+	err = v.ValidateProposal(newStub(), newResponse(chdr, shdr))
+	assert.EqualError(t, err, "proposal has no timestamp")
+
+	// invalid max age
+	assert.Panics(t, func() { NewValidator(WithMaxProposalAge(0)) })
+}
+
+func TestWithCSP(t *testing.T) {
+	c := &fakes.CryptoProvider{}
+	v := NewValidator(WithCSP(c))
+	assert.Equal(t, c, v.csp)
+
+	assert.Panics(t, func() { NewValidator(WithCSP(nil)) })
+}
+
 func createChaincodeResponseMessage(chaincodeRequest []byte, chaincodeRequestHash []byte) *protos.ChaincodeResponseMessage {
 	chdr := &common.ChannelHeader{
 		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
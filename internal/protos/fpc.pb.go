@@ -29,6 +29,116 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// HashAlgorithm identifies the hash function used for the hashes of a message.
+// The default, HASH_ALGORITHM_SHA256, is the hash function of the C++ enclave, which does not set the algorithm.
+type HashAlgorithm int32
+
+const (
+	HashAlgorithm_HASH_ALGORITHM_SHA256 HashAlgorithm = 0
+	HashAlgorithm_HASH_ALGORITHM_SHA384 HashAlgorithm = 1
+)
+
+// Enum value maps for HashAlgorithm.
+var (
+	HashAlgorithm_name = map[int32]string{
+		0: "HASH_ALGORITHM_SHA256",
+		1: "HASH_ALGORITHM_SHA384",
+	}
+	HashAlgorithm_value = map[string]int32{
+		"HASH_ALGORITHM_SHA256": 0,
+		"HASH_ALGORITHM_SHA384": 1,
+	}
+)
+
+func (x HashAlgorithm) Enum() *HashAlgorithm {
+	p := new(HashAlgorithm)
+	*p = x
+	return p
+}
+
+func (x HashAlgorithm) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (HashAlgorithm) Descriptor() protoreflect.EnumDescriptor {
+	return file_fpc_fpc_proto_enumTypes[0].Descriptor()
+}
+
+func (HashAlgorithm) Type() protoreflect.EnumType {
+	return &file_fpc_fpc_proto_enumTypes[0]
+}
+
+func (x HashAlgorithm) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use HashAlgorithm.Descriptor instead.
+func (HashAlgorithm) EnumDescriptor() ([]byte, []int) {
+	return file_fpc_fpc_proto_rawDescGZIP(), []int{0}
+}
+
+// ErrorCode classifies the errors reported by an enclave in a ChaincodeResponseMessage.
+// A response with an error code must not be endorsed.
+type ErrorCode int32
+
+const (
+	// no error
+	ErrorCode_ERROR_CODE_OK ErrorCode = 0
+	// the chaincode returned an error response
+	ErrorCode_ERROR_CODE_CHAINCODE_ERROR ErrorCode = 1
+	// the signed proposal does not match the request or channel of the enclave
+	ErrorCode_ERROR_CODE_INVALID_PROPOSAL ErrorCode = 2
+	// the request cannot be decrypted or decoded
+	ErrorCode_ERROR_CODE_INVALID_REQUEST ErrorCode = 3
+	// the enclave failed to process the request
+	ErrorCode_ERROR_CODE_INTERNAL_ERROR ErrorCode = 4
+)
+
+// Enum value maps for ErrorCode.
+var (
+	ErrorCode_name = map[int32]string{
+		0: "ERROR_CODE_OK",
+		1: "ERROR_CODE_CHAINCODE_ERROR",
+		2: "ERROR_CODE_INVALID_PROPOSAL",
+		3: "ERROR_CODE_INVALID_REQUEST",
+		4: "ERROR_CODE_INTERNAL_ERROR",
+	}
+	ErrorCode_value = map[string]int32{
+		"ERROR_CODE_OK":               0,
+		"ERROR_CODE_CHAINCODE_ERROR":  1,
+		"ERROR_CODE_INVALID_PROPOSAL": 2,
+		"ERROR_CODE_INVALID_REQUEST":  3,
+		"ERROR_CODE_INTERNAL_ERROR":   4,
+	}
+)
+
+func (x ErrorCode) Enum() *ErrorCode {
+	p := new(ErrorCode)
+	*p = x
+	return p
+}
+
+func (x ErrorCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ErrorCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_fpc_fpc_proto_enumTypes[1].Descriptor()
+}
+
+func (ErrorCode) Type() protoreflect.EnumType {
+	return &file_fpc_fpc_proto_enumTypes[1]
+}
+
+func (x ErrorCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ErrorCode.Descriptor instead.
+func (ErrorCode) EnumDescriptor() ([]byte, []int) {
+	return file_fpc_fpc_proto_rawDescGZIP(), []int{1}
+}
+
 type CCParameters struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// name of the chaincode
@@ -584,8 +694,10 @@ type FPCKVSet struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
 	RwSet           *kvrwset.KVRWSet       `protobuf:"bytes,1,opt,name=rw_set,json=rwSet,proto3" json:"rw_set,omitempty"`
 	ReadValueHashes [][]byte               `protobuf:"bytes,2,rep,name=read_value_hashes,json=readValueHashes,proto3" json:"read_value_hashes,omitempty"`
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+	// hash function of read_value_hashes
+	HashAlgorithm HashAlgorithm `protobuf:"varint,3,opt,name=hash_algorithm,json=hashAlgorithm,proto3,enum=fpc.HashAlgorithm" json:"hash_algorithm,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *FPCKVSet) Reset() {
@@ -632,12 +744,20 @@ func (x *FPCKVSet) GetReadValueHashes() [][]byte {
 	return nil
 }
 
+func (x *FPCKVSet) GetHashAlgorithm() HashAlgorithm {
+	if x != nil {
+		return x.HashAlgorithm
+	}
+	return HashAlgorithm_HASH_ALGORITHM_SHA256
+}
+
 type ChaincodeResponseMessage struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// an encryption (symmetric) of the serialization of CleartextChaincodeRequest with KeyTransportMessage.response_encryption_key
 	EncryptedResponse []byte `protobuf:"bytes,1,opt,name=encrypted_response,json=encryptedResponse,proto3" json:"encrypted_response,omitempty"`
 	// R/W set (of cleartext keys but encrypted values)
-	// This field is only valid for the FPC Lite variant but absent from the full version with in-peer FPC validation
+	// With the FPC endorsement and validation plugins of the peer (see fabric/plugins), the endorsement plugin
+	// uses this R/W set as R/W set of the proposal response and the validation plugin checks that they match
 	FpcRwSet *FPCKVSet `protobuf:"bytes,2,opt,name=fpc_rw_set,json=fpcRwSet,proto3" json:"fpc_rw_set,omitempty"`
 	// signed proposal for this request
 	Proposal *peer.SignedProposal `protobuf:"bytes,3,opt,name=proposal,proto3" json:"proposal,omitempty"`
@@ -646,7 +766,14 @@ type ChaincodeResponseMessage struct {
 	// and not extracted from it; validation chaincode will check for consistency
 	ChaincodeRequestMessageHash []byte `protobuf:"bytes,4,opt,name=chaincode_request_message_hash,json=chaincodeRequestMessageHash,proto3" json:"chaincode_request_message_hash,omitempty"`
 	// identity for public key used to sign
-	EnclaveId     string `protobuf:"bytes,5,opt,name=enclave_id,json=enclaveId,proto3" json:"enclave_id,omitempty"`
+	EnclaveId string `protobuf:"bytes,5,opt,name=enclave_id,json=enclaveId,proto3" json:"enclave_id,omitempty"`
+	// error reported by the enclave; details of the error are only included in the encrypted response
+	ErrorCode ErrorCode `protobuf:"varint,6,opt,name=error_code,json=errorCode,proto3,enum=fpc.ErrorCode" json:"error_code,omitempty"`
+	// hash over the canonical encoding of fpc_rw_set, i.e., its length-prefixed reads (key, read value hash) and
+	// writes (key, deletion flag, value) sorted by key, see EncodeRwSet in internal/utils
+	RwSetDigest []byte `protobuf:"bytes,8,opt,name=rw_set_digest,json=rwSetDigest,proto3" json:"rw_set_digest,omitempty"`
+	// hash function of chaincode_request_message_hash and rw_set_digest
+	HashAlgorithm HashAlgorithm `protobuf:"varint,9,opt,name=hash_algorithm,json=hashAlgorithm,proto3,enum=fpc.HashAlgorithm" json:"hash_algorithm,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -716,19 +843,134 @@ func (x *ChaincodeResponseMessage) GetEnclaveId() string {
 	return ""
 }
 
+func (x *ChaincodeResponseMessage) GetErrorCode() ErrorCode {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ErrorCode_ERROR_CODE_OK
+}
+
+func (x *ChaincodeResponseMessage) GetRwSetDigest() []byte {
+	if x != nil {
+		return x.RwSetDigest
+	}
+	return nil
+}
+
+func (x *ChaincodeResponseMessage) GetHashAlgorithm() HashAlgorithm {
+	if x != nil {
+		return x.HashAlgorithm
+	}
+	return HashAlgorithm_HASH_ALGORITHM_SHA256
+}
+
+// InvocationMetrics let the peer monitor the invocations of a FPC chaincode.
+// Note that the metrics reveal the name of the invoked function to the peer but neither keys nor values.
+type InvocationMetrics struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// the invoked function, i.e., the first argument of the request, if it is one of the functions the chaincode
+	// reports metrics for, and `other` otherwise; thus, the peer only sees a bounded set of function names
+	Function string `protobuf:"bytes,1,opt,name=function,proto3" json:"function,omitempty"`
+	// number of keys read and written
+	Reads  uint32 `protobuf:"varint,2,opt,name=reads,proto3" json:"reads,omitempty"`
+	Writes uint32 `protobuf:"varint,3,opt,name=writes,proto3" json:"writes,omitempty"`
+	// number of bytes encrypted and decrypted, i.e., the plaintext size of the request, response and state values
+	BytesEncrypted uint64 `protobuf:"varint,4,opt,name=bytes_encrypted,json=bytesEncrypted,proto3" json:"bytes_encrypted,omitempty"`
+	BytesDecrypted uint64 `protobuf:"varint,5,opt,name=bytes_decrypted,json=bytesDecrypted,proto3" json:"bytes_decrypted,omitempty"`
+	// time to process the invocation inside the enclave in microseconds
+	DurationMicros uint64 `protobuf:"varint,6,opt,name=duration_micros,json=durationMicros,proto3" json:"duration_micros,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *InvocationMetrics) Reset() {
+	*x = InvocationMetrics{}
+	mi := &file_fpc_fpc_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvocationMetrics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvocationMetrics) ProtoMessage() {}
+
+func (x *InvocationMetrics) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_fpc_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvocationMetrics.ProtoReflect.Descriptor instead.
+func (*InvocationMetrics) Descriptor() ([]byte, []int) {
+	return file_fpc_fpc_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *InvocationMetrics) GetFunction() string {
+	if x != nil {
+		return x.Function
+	}
+	return ""
+}
+
+func (x *InvocationMetrics) GetReads() uint32 {
+	if x != nil {
+		return x.Reads
+	}
+	return 0
+}
+
+func (x *InvocationMetrics) GetWrites() uint32 {
+	if x != nil {
+		return x.Writes
+	}
+	return 0
+}
+
+func (x *InvocationMetrics) GetBytesEncrypted() uint64 {
+	if x != nil {
+		return x.BytesEncrypted
+	}
+	return 0
+}
+
+func (x *InvocationMetrics) GetBytesDecrypted() uint64 {
+	if x != nil {
+		return x.BytesDecrypted
+	}
+	return 0
+}
+
+func (x *InvocationMetrics) GetDurationMicros() uint64 {
+	if x != nil {
+		return x.DurationMicros
+	}
+	return 0
+}
+
 type SignedChaincodeResponseMessage struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// binary encoding of a ChaincodeResponseMessage protobuf
 	ChaincodeResponseMessage []byte `protobuf:"bytes,1,opt,name=chaincode_response_message,json=chaincodeResponseMessage,proto3" json:"chaincode_response_message,omitempty"`
 	// signature over the chaincode response message
-	Signature     []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	// optional metrics of the invocation for the hosting peer; they are not covered by the signature and
+	// ECC removes them before returning the response, i.e., they are never committed to the ledger
+	Metrics       *InvocationMetrics `protobuf:"bytes,3,opt,name=metrics,proto3" json:"metrics,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *SignedChaincodeResponseMessage) Reset() {
 	*x = SignedChaincodeResponseMessage{}
-	mi := &file_fpc_fpc_proto_msgTypes[11]
+	mi := &file_fpc_fpc_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -740,7 +982,7 @@ func (x *SignedChaincodeResponseMessage) String() string {
 func (*SignedChaincodeResponseMessage) ProtoMessage() {}
 
 func (x *SignedChaincodeResponseMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_fpc_fpc_proto_msgTypes[11]
+	mi := &file_fpc_fpc_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -753,7 +995,7 @@ func (x *SignedChaincodeResponseMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SignedChaincodeResponseMessage.ProtoReflect.Descriptor instead.
 func (*SignedChaincodeResponseMessage) Descriptor() ([]byte, []int) {
-	return file_fpc_fpc_proto_rawDescGZIP(), []int{11}
+	return file_fpc_fpc_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *SignedChaincodeResponseMessage) GetChaincodeResponseMessage() []byte {
@@ -770,6 +1012,150 @@ func (x *SignedChaincodeResponseMessage) GetSignature() []byte {
 	return nil
 }
 
+func (x *SignedChaincodeResponseMessage) GetMetrics() *InvocationMetrics {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+// EnclaveInfo describes a running enclave; it is returned by the read-only `__enclaveInfo` ECC function
+type EnclaveInfo struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// identity of the enclave, i.e., the hash of its public key
+	EnclaveId string `protobuf:"bytes,1,opt,name=enclave_id,json=enclaveId,proto3" json:"enclave_id,omitempty"`
+	// version of the chaincode definition the enclave is initialized with as provided by the host;
+	// it is not measured by the enclave, i.e., the mrenclave of the enclave is only attested by its credentials at ERCC
+	ChaincodeVersion string `protobuf:"bytes,2,opt,name=chaincode_version,json=chaincodeVersion,proto3" json:"chaincode_version,omitempty"`
+	// the type of attestation issued by the enclave, e.g., `simulated` or `epid-linkable`
+	AttestationType string `protobuf:"bytes,3,opt,name=attestation_type,json=attestationType,proto3" json:"attestation_type,omitempty"`
+	// seconds since the enclave has been initialized as measured by the (untrusted) clock of the host
+	UptimeSeconds uint64 `protobuf:"varint,4,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	// nonce provided by the caller to ensure freshness of the response
+	Nonce         []byte `protobuf:"bytes,5,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EnclaveInfo) Reset() {
+	*x = EnclaveInfo{}
+	mi := &file_fpc_fpc_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EnclaveInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EnclaveInfo) ProtoMessage() {}
+
+func (x *EnclaveInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_fpc_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EnclaveInfo.ProtoReflect.Descriptor instead.
+func (*EnclaveInfo) Descriptor() ([]byte, []int) {
+	return file_fpc_fpc_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *EnclaveInfo) GetEnclaveId() string {
+	if x != nil {
+		return x.EnclaveId
+	}
+	return ""
+}
+
+func (x *EnclaveInfo) GetChaincodeVersion() string {
+	if x != nil {
+		return x.ChaincodeVersion
+	}
+	return ""
+}
+
+func (x *EnclaveInfo) GetAttestationType() string {
+	if x != nil {
+		return x.AttestationType
+	}
+	return ""
+}
+
+func (x *EnclaveInfo) GetUptimeSeconds() uint64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+func (x *EnclaveInfo) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+type SignedEnclaveInfo struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// binary encoding of an EnclaveInfo protobuf
+	EnclaveInfo []byte `protobuf:"bytes,1,opt,name=enclave_info,json=enclaveInfo,proto3" json:"enclave_info,omitempty"`
+	// signature over the enclave info with the enclave signing key
+	Signature     []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SignedEnclaveInfo) Reset() {
+	*x = SignedEnclaveInfo{}
+	mi := &file_fpc_fpc_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignedEnclaveInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignedEnclaveInfo) ProtoMessage() {}
+
+func (x *SignedEnclaveInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_fpc_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignedEnclaveInfo.ProtoReflect.Descriptor instead.
+func (*SignedEnclaveInfo) Descriptor() ([]byte, []int) {
+	return file_fpc_fpc_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *SignedEnclaveInfo) GetEnclaveInfo() []byte {
+	if x != nil {
+		return x.EnclaveInfo
+	}
+	return nil
+}
+
+func (x *SignedEnclaveInfo) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
 var File_fpc_fpc_proto protoreflect.FileDescriptor
 
 const file_fpc_fpc_proto_rawDesc = "" +
@@ -810,10 +1196,11 @@ const file_fpc_fpc_proto_rawDesc = "" +
 	"\x16request_encryption_key\x18\x01 \x01(\fR\x14requestEncryptionKey\x126\n" +
 	"\x17response_encryption_key\x18\x02 \x01(\fR\x15responseEncryptionKey\"J\n" +
 	"\x1aCleartextChaincodeResponse\x12,\n" +
-	"\bresponse\x18\x01 \x01(\v2\x10.protos.ResponseR\bresponse\"_\n" +
+	"\bresponse\x18\x01 \x01(\v2\x10.protos.ResponseR\bresponse\"\x9a\x01\n" +
 	"\bFPCKVSet\x12'\n" +
 	"\x06rw_set\x18\x01 \x01(\v2\x10.kvrwset.KVRWSetR\x05rwSet\x12*\n" +
-	"\x11read_value_hashes\x18\x02 \x03(\fR\x0freadValueHashes\"\x8e\x02\n" +
+	"\x11read_value_hashes\x18\x02 \x03(\fR\x0freadValueHashes\x129\n" +
+	"\x0ehash_algorithm\x18\x03 \x01(\x0e2\x12.fpc.HashAlgorithmR\rhashAlgorithm\"\x9c\x03\n" +
 	"\x18ChaincodeResponseMessage\x12-\n" +
 	"\x12encrypted_response\x18\x01 \x01(\fR\x11encryptedResponse\x12+\n" +
 	"\n" +
@@ -821,10 +1208,41 @@ const file_fpc_fpc_proto_rawDesc = "" +
 	"\bproposal\x18\x03 \x01(\v2\x16.protos.SignedProposalR\bproposal\x12C\n" +
 	"\x1echaincode_request_message_hash\x18\x04 \x01(\fR\x1bchaincodeRequestMessageHash\x12\x1d\n" +
 	"\n" +
-	"enclave_id\x18\x05 \x01(\tR\tenclaveId\"|\n" +
+	"enclave_id\x18\x05 \x01(\tR\tenclaveId\x12-\n" +
+	"\n" +
+	"error_code\x18\x06 \x01(\x0e2\x0e.fpc.ErrorCodeR\terrorCode\x12\"\n" +
+	"\rrw_set_digest\x18\b \x01(\fR\vrwSetDigest\x129\n" +
+	"\x0ehash_algorithm\x18\t \x01(\x0e2\x12.fpc.HashAlgorithmR\rhashAlgorithm\"\xd8\x01\n" +
+	"\x11InvocationMetrics\x12\x1a\n" +
+	"\bfunction\x18\x01 \x01(\tR\bfunction\x12\x14\n" +
+	"\x05reads\x18\x02 \x01(\rR\x05reads\x12\x16\n" +
+	"\x06writes\x18\x03 \x01(\rR\x06writes\x12'\n" +
+	"\x0fbytes_encrypted\x18\x04 \x01(\x04R\x0ebytesEncrypted\x12'\n" +
+	"\x0fbytes_decrypted\x18\x05 \x01(\x04R\x0ebytesDecrypted\x12'\n" +
+	"\x0fduration_micros\x18\x06 \x01(\x04R\x0edurationMicros\"\xae\x01\n" +
 	"\x1eSignedChaincodeResponseMessage\x12<\n" +
 	"\x1achaincode_response_message\x18\x01 \x01(\fR\x18chaincodeResponseMessage\x12\x1c\n" +
-	"\tsignature\x18\x02 \x01(\fR\tsignatureBAZ?github.com/hyperledger/fabric-private-chaincode/internal/protosb\x06proto3"
+	"\tsignature\x18\x02 \x01(\fR\tsignature\x120\n" +
+	"\ametrics\x18\x03 \x01(\v2\x16.fpc.InvocationMetricsR\ametrics\"\xc1\x01\n" +
+	"\vEnclaveInfo\x12\x1d\n" +
+	"\n" +
+	"enclave_id\x18\x01 \x01(\tR\tenclaveId\x12+\n" +
+	"\x11chaincode_version\x18\x02 \x01(\tR\x10chaincodeVersion\x12)\n" +
+	"\x10attestation_type\x18\x03 \x01(\tR\x0fattestationType\x12%\n" +
+	"\x0euptime_seconds\x18\x04 \x01(\x04R\ruptimeSeconds\x12\x14\n" +
+	"\x05nonce\x18\x05 \x01(\fR\x05nonce\"T\n" +
+	"\x11SignedEnclaveInfo\x12!\n" +
+	"\fenclave_info\x18\x01 \x01(\fR\venclaveInfo\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\fR\tsignature*E\n" +
+	"\rHashAlgorithm\x12\x19\n" +
+	"\x15HASH_ALGORITHM_SHA256\x10\x00\x12\x19\n" +
+	"\x15HASH_ALGORITHM_SHA384\x10\x01*\x9e\x01\n" +
+	"\tErrorCode\x12\x11\n" +
+	"\rERROR_CODE_OK\x10\x00\x12\x1e\n" +
+	"\x1aERROR_CODE_CHAINCODE_ERROR\x10\x01\x12\x1f\n" +
+	"\x1bERROR_CODE_INVALID_PROPOSAL\x10\x02\x12\x1e\n" +
+	"\x1aERROR_CODE_INVALID_REQUEST\x10\x03\x12\x1d\n" +
+	"\x19ERROR_CODE_INTERNAL_ERROR\x10\x04BAZ?github.com/hyperledger/fabric-private-chaincode/internal/protosb\x06proto3"
 
 var (
 	file_fpc_fpc_proto_rawDescOnce sync.Once
@@ -838,40 +1256,50 @@ func file_fpc_fpc_proto_rawDescGZIP() []byte {
 	return file_fpc_fpc_proto_rawDescData
 }
 
-var file_fpc_fpc_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_fpc_fpc_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_fpc_fpc_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_fpc_fpc_proto_goTypes = []any{
-	(*CCParameters)(nil),                   // 0: fpc.CCParameters
-	(*HostParameters)(nil),                 // 1: fpc.HostParameters
-	(*AttestedData)(nil),                   // 2: fpc.AttestedData
-	(*Credentials)(nil),                    // 3: fpc.Credentials
-	(*InitEnclaveMessage)(nil),             // 4: fpc.InitEnclaveMessage
-	(*CleartextChaincodeRequest)(nil),      // 5: fpc.CleartextChaincodeRequest
-	(*ChaincodeRequestMessage)(nil),        // 6: fpc.ChaincodeRequestMessage
-	(*KeyTransportMessage)(nil),            // 7: fpc.KeyTransportMessage
-	(*CleartextChaincodeResponse)(nil),     // 8: fpc.CleartextChaincodeResponse
-	(*FPCKVSet)(nil),                       // 9: fpc.FPCKVSet
-	(*ChaincodeResponseMessage)(nil),       // 10: fpc.ChaincodeResponseMessage
-	(*SignedChaincodeResponseMessage)(nil), // 11: fpc.SignedChaincodeResponseMessage
-	(*anypb.Any)(nil),                      // 12: google.protobuf.Any
-	(*peer.ChaincodeInput)(nil),            // 13: protos.ChaincodeInput
-	(*peer.Response)(nil),                  // 14: protos.Response
-	(*kvrwset.KVRWSet)(nil),                // 15: kvrwset.KVRWSet
-	(*peer.SignedProposal)(nil),            // 16: protos.SignedProposal
+	(HashAlgorithm)(0),                     // 0: fpc.HashAlgorithm
+	(ErrorCode)(0),                         // 1: fpc.ErrorCode
+	(*CCParameters)(nil),                   // 2: fpc.CCParameters
+	(*HostParameters)(nil),                 // 3: fpc.HostParameters
+	(*AttestedData)(nil),                   // 4: fpc.AttestedData
+	(*Credentials)(nil),                    // 5: fpc.Credentials
+	(*InitEnclaveMessage)(nil),             // 6: fpc.InitEnclaveMessage
+	(*CleartextChaincodeRequest)(nil),      // 7: fpc.CleartextChaincodeRequest
+	(*ChaincodeRequestMessage)(nil),        // 8: fpc.ChaincodeRequestMessage
+	(*KeyTransportMessage)(nil),            // 9: fpc.KeyTransportMessage
+	(*CleartextChaincodeResponse)(nil),     // 10: fpc.CleartextChaincodeResponse
+	(*FPCKVSet)(nil),                       // 11: fpc.FPCKVSet
+	(*ChaincodeResponseMessage)(nil),       // 12: fpc.ChaincodeResponseMessage
+	(*InvocationMetrics)(nil),              // 13: fpc.InvocationMetrics
+	(*SignedChaincodeResponseMessage)(nil), // 14: fpc.SignedChaincodeResponseMessage
+	(*EnclaveInfo)(nil),                    // 15: fpc.EnclaveInfo
+	(*SignedEnclaveInfo)(nil),              // 16: fpc.SignedEnclaveInfo
+	(*anypb.Any)(nil),                      // 17: google.protobuf.Any
+	(*peer.ChaincodeInput)(nil),            // 18: protos.ChaincodeInput
+	(*peer.Response)(nil),                  // 19: protos.Response
+	(*kvrwset.KVRWSet)(nil),                // 20: kvrwset.KVRWSet
+	(*peer.SignedProposal)(nil),            // 21: protos.SignedProposal
 }
 var file_fpc_fpc_proto_depIdxs = []int32{
-	0,  // 0: fpc.AttestedData.cc_params:type_name -> fpc.CCParameters
-	1,  // 1: fpc.AttestedData.host_params:type_name -> fpc.HostParameters
-	12, // 2: fpc.Credentials.serialized_attested_data:type_name -> google.protobuf.Any
-	13, // 3: fpc.CleartextChaincodeRequest.input:type_name -> protos.ChaincodeInput
-	14, // 4: fpc.CleartextChaincodeResponse.response:type_name -> protos.Response
-	15, // 5: fpc.FPCKVSet.rw_set:type_name -> kvrwset.KVRWSet
-	9,  // 6: fpc.ChaincodeResponseMessage.fpc_rw_set:type_name -> fpc.FPCKVSet
-	16, // 7: fpc.ChaincodeResponseMessage.proposal:type_name -> protos.SignedProposal
-	8,  // [8:8] is the sub-list for method output_type
-	8,  // [8:8] is the sub-list for method input_type
-	8,  // [8:8] is the sub-list for extension type_name
-	8,  // [8:8] is the sub-list for extension extendee
-	0,  // [0:8] is the sub-list for field type_name
+	2,  // 0: fpc.AttestedData.cc_params:type_name -> fpc.CCParameters
+	3,  // 1: fpc.AttestedData.host_params:type_name -> fpc.HostParameters
+	17, // 2: fpc.Credentials.serialized_attested_data:type_name -> google.protobuf.Any
+	18, // 3: fpc.CleartextChaincodeRequest.input:type_name -> protos.ChaincodeInput
+	19, // 4: fpc.CleartextChaincodeResponse.response:type_name -> protos.Response
+	20, // 5: fpc.FPCKVSet.rw_set:type_name -> kvrwset.KVRWSet
+	0,  // 6: fpc.FPCKVSet.hash_algorithm:type_name -> fpc.HashAlgorithm
+	11, // 7: fpc.ChaincodeResponseMessage.fpc_rw_set:type_name -> fpc.FPCKVSet
+	21, // 8: fpc.ChaincodeResponseMessage.proposal:type_name -> protos.SignedProposal
+	1,  // 9: fpc.ChaincodeResponseMessage.error_code:type_name -> fpc.ErrorCode
+	0,  // 10: fpc.ChaincodeResponseMessage.hash_algorithm:type_name -> fpc.HashAlgorithm
+	13, // 11: fpc.SignedChaincodeResponseMessage.metrics:type_name -> fpc.InvocationMetrics
+	12, // [12:12] is the sub-list for method output_type
+	12, // [12:12] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
 }
 
 func init() { file_fpc_fpc_proto_init() }
@@ -884,13 +1312,14 @@ func file_fpc_fpc_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fpc_fpc_proto_rawDesc), len(file_fpc_fpc_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   12,
+			NumEnums:      2,
+			NumMessages:   15,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_fpc_fpc_proto_goTypes,
 		DependencyIndexes: file_fpc_fpc_proto_depIdxs,
+		EnumInfos:         file_fpc_fpc_proto_enumTypes,
 		MessageInfos:      file_fpc_fpc_proto_msgTypes,
 	}.Build()
 	File_fpc_fpc_proto = out.File
@@ -280,6 +280,61 @@ func (x *SignedExportMessage) GetSignature() []byte {
 	return nil
 }
 
+type UpgradePolicy struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// chaincode this policy applies to
+	ChaincodeId string `protobuf:"bytes,1,opt,name=chaincode_id,json=chaincodeId,proto3" json:"chaincode_id,omitempty"`
+	// chaincode versions (mrenclave) the enclave of the current chaincode version
+	// is allowed to export its chaincode keys to
+	AllowedVersions []string `protobuf:"bytes,2,rep,name=allowed_versions,json=allowedVersions,proto3" json:"allowed_versions,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *UpgradePolicy) Reset() {
+	*x = UpgradePolicy{}
+	mi := &file_fpc_key_dist_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpgradePolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpgradePolicy) ProtoMessage() {}
+
+func (x *UpgradePolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_key_dist_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpgradePolicy.ProtoReflect.Descriptor instead.
+func (*UpgradePolicy) Descriptor() ([]byte, []int) {
+	return file_fpc_key_dist_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UpgradePolicy) GetChaincodeId() string {
+	if x != nil {
+		return x.ChaincodeId
+	}
+	return ""
+}
+
+func (x *UpgradePolicy) GetAllowedVersions() []string {
+	if x != nil {
+		return x.AllowedVersions
+	}
+	return nil
+}
+
 var File_fpc_key_dist_proto protoreflect.FileDescriptor
 
 const file_fpc_key_dist_proto_rawDesc = "" +
@@ -302,7 +357,10 @@ const file_fpc_key_dist_proto_rawDesc = "" +
 	"\x11sender_enclave_vk\x18\x05 \x01(\fR\x0fsenderEnclaveVk\"\x88\x01\n" +
 	"\x13SignedExportMessage\x12S\n" +
 	"\x1bserialized_export_msg_bytes\x18\x01 \x01(\v2\x14.google.protobuf.AnyR\x18serializedExportMsgBytes\x12\x1c\n" +
-	"\tsignature\x18\x02 \x01(\fR\tsignatureBAZ?github.com/hyperledger/fabric-private-chaincode/internal/protosb\x06proto3"
+	"\tsignature\x18\x02 \x01(\fR\tsignature\"]\n" +
+	"\rUpgradePolicy\x12!\n" +
+	"\fchaincode_id\x18\x01 \x01(\tR\vchaincodeId\x12)\n" +
+	"\x10allowed_versions\x18\x02 \x03(\tR\x0fallowedVersionsBAZ?github.com/hyperledger/fabric-private-chaincode/internal/protosb\x06proto3"
 
 var (
 	file_fpc_key_dist_proto_rawDescOnce sync.Once
@@ -316,17 +374,18 @@ func file_fpc_key_dist_proto_rawDescGZIP() []byte {
 	return file_fpc_key_dist_proto_rawDescData
 }
 
-var file_fpc_key_dist_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_fpc_key_dist_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_fpc_key_dist_proto_goTypes = []any{
 	(*CCKeyRegistrationMessage)(nil),       // 0: key_distribution.CCKeyRegistrationMessage
 	(*SignedCCKeyRegistrationMessage)(nil), // 1: key_distribution.SignedCCKeyRegistrationMessage
 	(*ExportMessage)(nil),                  // 2: key_distribution.ExportMessage
 	(*SignedExportMessage)(nil),            // 3: key_distribution.SignedExportMessage
-	(*anypb.Any)(nil),                      // 4: google.protobuf.Any
+	(*UpgradePolicy)(nil),                  // 4: key_distribution.UpgradePolicy
+	(*anypb.Any)(nil),                      // 5: google.protobuf.Any
 }
 var file_fpc_key_dist_proto_depIdxs = []int32{
-	4, // 0: key_distribution.SignedCCKeyRegistrationMessage.serialized_cckey_reg_msg:type_name -> google.protobuf.Any
-	4, // 1: key_distribution.SignedExportMessage.serialized_export_msg_bytes:type_name -> google.protobuf.Any
+	5, // 0: key_distribution.SignedCCKeyRegistrationMessage.serialized_cckey_reg_msg:type_name -> google.protobuf.Any
+	5, // 1: key_distribution.SignedExportMessage.serialized_export_msg_bytes:type_name -> google.protobuf.Any
 	2, // [2:2] is the sub-list for method output_type
 	2, // [2:2] is the sub-list for method input_type
 	2, // [2:2] is the sub-list for extension type_name
@@ -345,7 +404,7 @@ func file_fpc_key_dist_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fpc_key_dist_proto_rawDesc), len(file_fpc_key_dist_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
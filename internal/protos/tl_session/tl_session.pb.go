@@ -793,6 +793,171 @@ func (x *SessionMsg) GetMac() []byte {
 	return nil
 }
 
+// msg1 of SessionSetupInitResponse
+type SessionHandshakeMsg1 struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ephemeral ECDH P-256 public key of the responder
+	EphemeralKey  []byte `protobuf:"bytes,1,opt,name=ephemeral_key,json=ephemeralKey,proto3" json:"ephemeral_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionHandshakeMsg1) Reset() {
+	*x = SessionHandshakeMsg1{}
+	mi := &file_fpc_tl_session_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionHandshakeMsg1) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionHandshakeMsg1) ProtoMessage() {}
+
+func (x *SessionHandshakeMsg1) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_tl_session_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionHandshakeMsg1.ProtoReflect.Descriptor instead.
+func (*SessionHandshakeMsg1) Descriptor() ([]byte, []int) {
+	return file_fpc_tl_session_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SessionHandshakeMsg1) GetEphemeralKey() []byte {
+	if x != nil {
+		return x.EphemeralKey
+	}
+	return nil
+}
+
+// msg2 of SessionSetupCompleteRequest
+type SessionHandshakeMsg2 struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ephemeral ECDH P-256 public key of the initiator
+	EphemeralKey []byte `protobuf:"bytes,1,opt,name=ephemeral_key,json=ephemeralKey,proto3" json:"ephemeral_key,omitempty"`
+	// serialized fpc.Credentials of the initiator
+	Credentials []byte `protobuf:"bytes,2,opt,name=credentials,proto3" json:"credentials,omitempty"`
+	// signature over the handshake transcript with the attested enclave key of the initiator
+	Signature     []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionHandshakeMsg2) Reset() {
+	*x = SessionHandshakeMsg2{}
+	mi := &file_fpc_tl_session_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionHandshakeMsg2) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionHandshakeMsg2) ProtoMessage() {}
+
+func (x *SessionHandshakeMsg2) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_tl_session_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionHandshakeMsg2.ProtoReflect.Descriptor instead.
+func (*SessionHandshakeMsg2) Descriptor() ([]byte, []int) {
+	return file_fpc_tl_session_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *SessionHandshakeMsg2) GetEphemeralKey() []byte {
+	if x != nil {
+		return x.EphemeralKey
+	}
+	return nil
+}
+
+func (x *SessionHandshakeMsg2) GetCredentials() []byte {
+	if x != nil {
+		return x.Credentials
+	}
+	return nil
+}
+
+func (x *SessionHandshakeMsg2) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+// msg3 of SessionSetupCompleteResponse
+type SessionHandshakeMsg3 struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// serialized fpc.Credentials of the responder
+	Credentials []byte `protobuf:"bytes,1,opt,name=credentials,proto3" json:"credentials,omitempty"`
+	// signature over the handshake transcript with the attested enclave key of the responder
+	Signature     []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionHandshakeMsg3) Reset() {
+	*x = SessionHandshakeMsg3{}
+	mi := &file_fpc_tl_session_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionHandshakeMsg3) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionHandshakeMsg3) ProtoMessage() {}
+
+func (x *SessionHandshakeMsg3) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_tl_session_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionHandshakeMsg3.ProtoReflect.Descriptor instead.
+func (*SessionHandshakeMsg3) Descriptor() ([]byte, []int) {
+	return file_fpc_tl_session_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SessionHandshakeMsg3) GetCredentials() []byte {
+	if x != nil {
+		return x.Credentials
+	}
+	return nil
+}
+
+func (x *SessionHandshakeMsg3) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
 var File_fpc_tl_session_proto protoreflect.FileDescriptor
 
 const file_fpc_tl_session_proto_rawDesc = "" +
@@ -859,7 +1024,16 @@ const file_fpc_tl_session_proto_rawDesc = "" +
 	"\n" +
 	"SessionMsg\x12C\n" +
 	"\x12serialized_payload\x18\x01 \x01(\v2\x14.google.protobuf.AnyR\x11serializedPayload\x12\x10\n" +
-	"\x03mac\x18\x02 \x01(\fR\x03macBLZJgithub.com/hyperledger/fabric-private-chaincode/internal/protos/tl_sessionb\x06proto3"
+	"\x03mac\x18\x02 \x01(\fR\x03mac\";\n" +
+	"\x14SessionHandshakeMsg1\x12#\n" +
+	"\rephemeral_key\x18\x01 \x01(\fR\fephemeralKey\"{\n" +
+	"\x14SessionHandshakeMsg2\x12#\n" +
+	"\rephemeral_key\x18\x01 \x01(\fR\fephemeralKey\x12 \n" +
+	"\vcredentials\x18\x02 \x01(\fR\vcredentials\x12\x1c\n" +
+	"\tsignature\x18\x03 \x01(\fR\tsignature\"V\n" +
+	"\x14SessionHandshakeMsg3\x12 \n" +
+	"\vcredentials\x18\x01 \x01(\fR\vcredentials\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\fR\tsignatureBLZJgithub.com/hyperledger/fabric-private-chaincode/internal/protos/tl_sessionb\x06proto3"
 
 var (
 	file_fpc_tl_session_proto_rawDescOnce sync.Once
@@ -873,7 +1047,7 @@ func file_fpc_tl_session_proto_rawDescGZIP() []byte {
 	return file_fpc_tl_session_proto_rawDescData
 }
 
-var file_fpc_tl_session_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_fpc_tl_session_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_fpc_tl_session_proto_goTypes = []any{
 	(*SessionSetupInitRequest)(nil),      // 0: tl_session.SessionSetupInitRequest
 	(*SessionSetupInitResponse)(nil),     // 1: tl_session.SessionSetupInitResponse
@@ -886,7 +1060,10 @@ var file_fpc_tl_session_proto_goTypes = []any{
 	(*SessionError)(nil),                 // 8: tl_session.SessionError
 	(*SessionMsgPayload)(nil),            // 9: tl_session.SessionMsgPayload
 	(*SessionMsg)(nil),                   // 10: tl_session.SessionMsg
-	(*anypb.Any)(nil),                    // 11: google.protobuf.Any
+	(*SessionHandshakeMsg1)(nil),         // 11: tl_session.SessionHandshakeMsg1
+	(*SessionHandshakeMsg2)(nil),         // 12: tl_session.SessionHandshakeMsg2
+	(*SessionHandshakeMsg3)(nil),         // 13: tl_session.SessionHandshakeMsg3
+	(*anypb.Any)(nil),                    // 14: google.protobuf.Any
 }
 var file_fpc_tl_session_proto_depIdxs = []int32{
 	0,  // 0: tl_session.SessionMsgPayload.stp_int_req:type_name -> tl_session.SessionSetupInitRequest
@@ -898,7 +1075,7 @@ var file_fpc_tl_session_proto_depIdxs = []int32{
 	6,  // 6: tl_session.SessionMsgPayload.tx_req:type_name -> tl_session.SessionTXRequest
 	7,  // 7: tl_session.SessionMsgPayload.tx_rsp:type_name -> tl_session.SessionTXResponse
 	8,  // 8: tl_session.SessionMsgPayload.error:type_name -> tl_session.SessionError
-	11, // 9: tl_session.SessionMsg.serialized_payload:type_name -> google.protobuf.Any
+	14, // 9: tl_session.SessionMsg.serialized_payload:type_name -> google.protobuf.Any
 	10, // [10:10] is the sub-list for method output_type
 	10, // [10:10] is the sub-list for method input_type
 	10, // [10:10] is the sub-list for extension type_name
@@ -928,7 +1105,7 @@ func file_fpc_tl_session_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fpc_tl_session_proto_rawDesc), len(file_fpc_tl_session_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   11,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
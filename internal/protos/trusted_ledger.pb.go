@@ -463,6 +463,8 @@ type Request struct {
 	//	*Request_MultiMetadata
 	//	*Request_ValidateIdentity
 	//	*Request_CanEndorse
+	//	*Request_EscrowKeys
+	//	*Request_ReleaseKeys
 	Request       isRequest_Request `protobuf_oneof:"request"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -548,6 +550,24 @@ func (x *Request) GetCanEndorse() *CanEndorseRequest {
 	return nil
 }
 
+func (x *Request) GetEscrowKeys() *EscrowKeysRequest {
+	if x != nil {
+		if x, ok := x.Request.(*Request_EscrowKeys); ok {
+			return x.EscrowKeys
+		}
+	}
+	return nil
+}
+
+func (x *Request) GetReleaseKeys() *ReleaseKeysRequest {
+	if x != nil {
+		if x, ok := x.Request.(*Request_ReleaseKeys); ok {
+			return x.ReleaseKeys
+		}
+	}
+	return nil
+}
+
 type isRequest_Request interface {
 	isRequest_Request()
 }
@@ -568,6 +588,14 @@ type Request_CanEndorse struct {
 	CanEndorse *CanEndorseRequest `protobuf:"bytes,5,opt,name=can_endorse,json=canEndorse,proto3,oneof"`
 }
 
+type Request_EscrowKeys struct {
+	EscrowKeys *EscrowKeysRequest `protobuf:"bytes,6,opt,name=escrow_keys,json=escrowKeys,proto3,oneof"`
+}
+
+type Request_ReleaseKeys struct {
+	ReleaseKeys *ReleaseKeysRequest `protobuf:"bytes,7,opt,name=release_keys,json=releaseKeys,proto3,oneof"`
+}
+
 func (*Request_Metadata) isRequest_Request() {}
 
 func (*Request_MultiMetadata) isRequest_Request() {}
@@ -576,6 +604,10 @@ func (*Request_ValidateIdentity) isRequest_Request() {}
 
 func (*Request_CanEndorse) isRequest_Request() {}
 
+func (*Request_EscrowKeys) isRequest_Request() {}
+
+func (*Request_ReleaseKeys) isRequest_Request() {}
+
 type Response struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Response:
@@ -584,6 +616,8 @@ type Response struct {
 	//	*Response_MultiMetadata
 	//	*Response_ValidateIdentity
 	//	*Response_CanEndorse
+	//	*Response_EscrowKeys
+	//	*Response_ReleaseKeys
 	Response      isResponse_Response `protobuf_oneof:"response"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -662,6 +696,24 @@ func (x *Response) GetCanEndorse() *CanEndorseResponse {
 	return nil
 }
 
+func (x *Response) GetEscrowKeys() *EscrowKeysResponse {
+	if x != nil {
+		if x, ok := x.Response.(*Response_EscrowKeys); ok {
+			return x.EscrowKeys
+		}
+	}
+	return nil
+}
+
+func (x *Response) GetReleaseKeys() *ReleaseKeysResponse {
+	if x != nil {
+		if x, ok := x.Response.(*Response_ReleaseKeys); ok {
+			return x.ReleaseKeys
+		}
+	}
+	return nil
+}
+
 type isResponse_Response interface {
 	isResponse_Response()
 }
@@ -682,6 +734,14 @@ type Response_CanEndorse struct {
 	CanEndorse *CanEndorseResponse `protobuf:"bytes,4,opt,name=can_endorse,json=canEndorse,proto3,oneof"`
 }
 
+type Response_EscrowKeys struct {
+	EscrowKeys *EscrowKeysResponse `protobuf:"bytes,5,opt,name=escrow_keys,json=escrowKeys,proto3,oneof"`
+}
+
+type Response_ReleaseKeys struct {
+	ReleaseKeys *ReleaseKeysResponse `protobuf:"bytes,6,opt,name=release_keys,json=releaseKeys,proto3,oneof"`
+}
+
 func (*Response_Metadata) isResponse_Response() {}
 
 func (*Response_MultiMetadata) isResponse_Response() {}
@@ -690,6 +750,285 @@ func (*Response_ValidateIdentity) isResponse_Response() {}
 
 func (*Response_CanEndorse) isResponse_Response() {}
 
+func (*Response_EscrowKeys) isResponse_Response() {}
+
+func (*Response_ReleaseKeys) isResponse_Response() {}
+
+// escrows the state key of the chaincode of the session peer
+type EscrowKeysRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ChaincodeId string                 `protobuf:"bytes,1,opt,name=chaincode_id,json=chaincodeId,proto3" json:"chaincode_id,omitempty"`
+	// upgrade policy as stored by ERCC, i.e., the base64-encoded UpgradePolicy
+	UpgradePolicy []byte `protobuf:"bytes,2,opt,name=upgrade_policy,json=upgradePolicy,proto3" json:"upgrade_policy,omitempty"`
+	StateKey      []byte `protobuf:"bytes,3,opt,name=state_key,json=stateKey,proto3" json:"state_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EscrowKeysRequest) Reset() {
+	*x = EscrowKeysRequest{}
+	mi := &file_fpc_trusted_ledger_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EscrowKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EscrowKeysRequest) ProtoMessage() {}
+
+func (x *EscrowKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_trusted_ledger_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EscrowKeysRequest.ProtoReflect.Descriptor instead.
+func (*EscrowKeysRequest) Descriptor() ([]byte, []int) {
+	return file_fpc_trusted_ledger_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *EscrowKeysRequest) GetChaincodeId() string {
+	if x != nil {
+		return x.ChaincodeId
+	}
+	return ""
+}
+
+func (x *EscrowKeysRequest) GetUpgradePolicy() []byte {
+	if x != nil {
+		return x.UpgradePolicy
+	}
+	return nil
+}
+
+func (x *EscrowKeysRequest) GetStateKey() []byte {
+	if x != nil {
+		return x.StateKey
+	}
+	return nil
+}
+
+type EscrowKeysResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EscrowKeysResponse) Reset() {
+	*x = EscrowKeysResponse{}
+	mi := &file_fpc_trusted_ledger_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EscrowKeysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EscrowKeysResponse) ProtoMessage() {}
+
+func (x *EscrowKeysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_trusted_ledger_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EscrowKeysResponse.ProtoReflect.Descriptor instead.
+func (*EscrowKeysResponse) Descriptor() ([]byte, []int) {
+	return file_fpc_trusted_ledger_proto_rawDescGZIP(), []int{11}
+}
+
+// releases the escrowed state key to the session peer
+type ReleaseKeysRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ChaincodeId   string                 `protobuf:"bytes,1,opt,name=chaincode_id,json=chaincodeId,proto3" json:"chaincode_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReleaseKeysRequest) Reset() {
+	*x = ReleaseKeysRequest{}
+	mi := &file_fpc_trusted_ledger_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReleaseKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReleaseKeysRequest) ProtoMessage() {}
+
+func (x *ReleaseKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_trusted_ledger_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReleaseKeysRequest.ProtoReflect.Descriptor instead.
+func (*ReleaseKeysRequest) Descriptor() ([]byte, []int) {
+	return file_fpc_trusted_ledger_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ReleaseKeysRequest) GetChaincodeId() string {
+	if x != nil {
+		return x.ChaincodeId
+	}
+	return ""
+}
+
+type ReleaseKeysResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	StateKey []byte                 `protobuf:"bytes,1,opt,name=state_key,json=stateKey,proto3" json:"state_key,omitempty"`
+	// enclave id of the enclave which escrowed the key
+	SenderEnclaveId string `protobuf:"bytes,2,opt,name=sender_enclave_id,json=senderEnclaveId,proto3" json:"sender_enclave_id,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ReleaseKeysResponse) Reset() {
+	*x = ReleaseKeysResponse{}
+	mi := &file_fpc_trusted_ledger_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReleaseKeysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReleaseKeysResponse) ProtoMessage() {}
+
+func (x *ReleaseKeysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_trusted_ledger_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReleaseKeysResponse.ProtoReflect.Descriptor instead.
+func (*ReleaseKeysResponse) Descriptor() ([]byte, []int) {
+	return file_fpc_trusted_ledger_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ReleaseKeysResponse) GetStateKey() []byte {
+	if x != nil {
+		return x.StateKey
+	}
+	return nil
+}
+
+func (x *ReleaseKeysResponse) GetSenderEnclaveId() string {
+	if x != nil {
+		return x.SenderEnclaveId
+	}
+	return ""
+}
+
+// escrowed state key as persisted by TLCC, sealed with its sealing key, so that it survives a restart of TLCC
+type KeyEscrow struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ChannelId   string                 `protobuf:"bytes,1,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	ChaincodeId string                 `protobuf:"bytes,2,opt,name=chaincode_id,json=chaincodeId,proto3" json:"chaincode_id,omitempty"`
+	// upgrade policy as stored by ERCC, i.e., the base64-encoded UpgradePolicy
+	UpgradePolicy []byte `protobuf:"bytes,3,opt,name=upgrade_policy,json=upgradePolicy,proto3" json:"upgrade_policy,omitempty"`
+	StateKey      []byte `protobuf:"bytes,4,opt,name=state_key,json=stateKey,proto3" json:"state_key,omitempty"`
+	// enclave id of the enclave which escrowed the key
+	SenderEnclaveId string `protobuf:"bytes,5,opt,name=sender_enclave_id,json=senderEnclaveId,proto3" json:"sender_enclave_id,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *KeyEscrow) Reset() {
+	*x = KeyEscrow{}
+	mi := &file_fpc_trusted_ledger_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyEscrow) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyEscrow) ProtoMessage() {}
+
+func (x *KeyEscrow) ProtoReflect() protoreflect.Message {
+	mi := &file_fpc_trusted_ledger_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyEscrow.ProtoReflect.Descriptor instead.
+func (*KeyEscrow) Descriptor() ([]byte, []int) {
+	return file_fpc_trusted_ledger_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *KeyEscrow) GetChannelId() string {
+	if x != nil {
+		return x.ChannelId
+	}
+	return ""
+}
+
+func (x *KeyEscrow) GetChaincodeId() string {
+	if x != nil {
+		return x.ChaincodeId
+	}
+	return ""
+}
+
+func (x *KeyEscrow) GetUpgradePolicy() []byte {
+	if x != nil {
+		return x.UpgradePolicy
+	}
+	return nil
+}
+
+func (x *KeyEscrow) GetStateKey() []byte {
+	if x != nil {
+		return x.StateKey
+	}
+	return nil
+}
+
+func (x *KeyEscrow) GetSenderEnclaveId() string {
+	if x != nil {
+		return x.SenderEnclaveId
+	}
+	return ""
+}
+
 var File_fpc_trusted_ledger_proto protoreflect.FileDescriptor
 
 const file_fpc_trusted_ledger_proto_rawDesc = "" +
@@ -714,7 +1053,7 @@ const file_fpc_trusted_ledger_proto_rawDesc = "" +
 	"\n" +
 	"enclave_id\x18\x02 \x01(\tR\tenclaveId\"/\n" +
 	"\x12CanEndorseResponse\x12\x19\n" +
-	"\bis_valid\x18\x01 \x01(\bR\aisValid\"\xe5\x02\n" +
+	"\bis_valid\x18\x01 \x01(\bR\aisValid\"\xf4\x03\n" +
 	"\aRequest\x12\x1d\n" +
 	"\n" +
 	"tx_context\x18\x01 \x01(\fR\ttxContext\x12@\n" +
@@ -722,16 +1061,39 @@ const file_fpc_trusted_ledger_proto_rawDesc = "" +
 	"\x0emulti_metadata\x18\x03 \x01(\v2'.trusted_ledger.GetMultiMetadataRequestH\x00R\rmultiMetadata\x12V\n" +
 	"\x11validate_identity\x18\x04 \x01(\v2'.trusted_ledger.ValidateIdentityRequestH\x00R\x10validateIdentity\x12D\n" +
 	"\vcan_endorse\x18\x05 \x01(\v2!.trusted_ledger.CanEndorseRequestH\x00R\n" +
-	"canEndorseB\t\n" +
-	"\arequest\"\xcc\x02\n" +
+	"canEndorse\x12D\n" +
+	"\vescrow_keys\x18\x06 \x01(\v2!.trusted_ledger.EscrowKeysRequestH\x00R\n" +
+	"escrowKeys\x12G\n" +
+	"\frelease_keys\x18\a \x01(\v2\".trusted_ledger.ReleaseKeysRequestH\x00R\vreleaseKeysB\t\n" +
+	"\arequest\"\xdd\x03\n" +
 	"\bResponse\x12A\n" +
 	"\bmetadata\x18\x01 \x01(\v2#.trusted_ledger.GetMetadataResponseH\x00R\bmetadata\x12Q\n" +
 	"\x0emulti_metadata\x18\x02 \x01(\v2(.trusted_ledger.GetMultiMetadataResponseH\x00R\rmultiMetadata\x12W\n" +
 	"\x11validate_identity\x18\x03 \x01(\v2(.trusted_ledger.ValidateIdentityResponseH\x00R\x10validateIdentity\x12E\n" +
 	"\vcan_endorse\x18\x04 \x01(\v2\".trusted_ledger.CanEndorseResponseH\x00R\n" +
-	"canEndorseB\n" +
+	"canEndorse\x12E\n" +
+	"\vescrow_keys\x18\x05 \x01(\v2\".trusted_ledger.EscrowKeysResponseH\x00R\n" +
+	"escrowKeys\x12H\n" +
+	"\frelease_keys\x18\x06 \x01(\v2#.trusted_ledger.ReleaseKeysResponseH\x00R\vreleaseKeysB\n" +
+	"\n" +
+	"\bresponse\"z\n" +
+	"\x11EscrowKeysRequest\x12!\n" +
+	"\fchaincode_id\x18\x01 \x01(\tR\vchaincodeId\x12%\n" +
+	"\x0eupgrade_policy\x18\x02 \x01(\fR\rupgradePolicy\x12\x1b\n" +
+	"\tstate_key\x18\x03 \x01(\fR\bstateKey\"\x14\n" +
+	"\x12EscrowKeysResponse\"7\n" +
+	"\x12ReleaseKeysRequest\x12!\n" +
+	"\fchaincode_id\x18\x01 \x01(\tR\vchaincodeId\"^\n" +
+	"\x13ReleaseKeysResponse\x12\x1b\n" +
+	"\tstate_key\x18\x01 \x01(\fR\bstateKey\x12*\n" +
+	"\x11sender_enclave_id\x18\x02 \x01(\tR\x0fsenderEnclaveId\"\xbd\x01\n" +
+	"\tKeyEscrow\x12\x1d\n" +
 	"\n" +
-	"\bresponseBAZ?github.com/hyperledger/fabric-private-chaincode/internal/protosb\x06proto3"
+	"channel_id\x18\x01 \x01(\tR\tchannelId\x12!\n" +
+	"\fchaincode_id\x18\x02 \x01(\tR\vchaincodeId\x12%\n" +
+	"\x0eupgrade_policy\x18\x03 \x01(\fR\rupgradePolicy\x12\x1b\n" +
+	"\tstate_key\x18\x04 \x01(\fR\bstateKey\x12*\n" +
+	"\x11sender_enclave_id\x18\x05 \x01(\tR\x0fsenderEnclaveIdBAZ?github.com/hyperledger/fabric-private-chaincode/internal/protosb\x06proto3"
 
 var (
 	file_fpc_trusted_ledger_proto_rawDescOnce sync.Once
@@ -745,7 +1107,7 @@ func file_fpc_trusted_ledger_proto_rawDescGZIP() []byte {
 	return file_fpc_trusted_ledger_proto_rawDescData
 }
 
-var file_fpc_trusted_ledger_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_fpc_trusted_ledger_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_fpc_trusted_ledger_proto_goTypes = []any{
 	(*GetMetadataRequest)(nil),       // 0: trusted_ledger.GetMetadataRequest
 	(*GetMetadataResponse)(nil),      // 1: trusted_ledger.GetMetadataResponse
@@ -757,21 +1119,30 @@ var file_fpc_trusted_ledger_proto_goTypes = []any{
 	(*CanEndorseResponse)(nil),       // 7: trusted_ledger.CanEndorseResponse
 	(*Request)(nil),                  // 8: trusted_ledger.Request
 	(*Response)(nil),                 // 9: trusted_ledger.Response
+	(*EscrowKeysRequest)(nil),        // 10: trusted_ledger.EscrowKeysRequest
+	(*EscrowKeysResponse)(nil),       // 11: trusted_ledger.EscrowKeysResponse
+	(*ReleaseKeysRequest)(nil),       // 12: trusted_ledger.ReleaseKeysRequest
+	(*ReleaseKeysResponse)(nil),      // 13: trusted_ledger.ReleaseKeysResponse
+	(*KeyEscrow)(nil),                // 14: trusted_ledger.KeyEscrow
 }
 var file_fpc_trusted_ledger_proto_depIdxs = []int32{
-	0, // 0: trusted_ledger.Request.metadata:type_name -> trusted_ledger.GetMetadataRequest
-	2, // 1: trusted_ledger.Request.multi_metadata:type_name -> trusted_ledger.GetMultiMetadataRequest
-	4, // 2: trusted_ledger.Request.validate_identity:type_name -> trusted_ledger.ValidateIdentityRequest
-	6, // 3: trusted_ledger.Request.can_endorse:type_name -> trusted_ledger.CanEndorseRequest
-	1, // 4: trusted_ledger.Response.metadata:type_name -> trusted_ledger.GetMetadataResponse
-	3, // 5: trusted_ledger.Response.multi_metadata:type_name -> trusted_ledger.GetMultiMetadataResponse
-	5, // 6: trusted_ledger.Response.validate_identity:type_name -> trusted_ledger.ValidateIdentityResponse
-	7, // 7: trusted_ledger.Response.can_endorse:type_name -> trusted_ledger.CanEndorseResponse
-	8, // [8:8] is the sub-list for method output_type
-	8, // [8:8] is the sub-list for method input_type
-	8, // [8:8] is the sub-list for extension type_name
-	8, // [8:8] is the sub-list for extension extendee
-	0, // [0:8] is the sub-list for field type_name
+	0,  // 0: trusted_ledger.Request.metadata:type_name -> trusted_ledger.GetMetadataRequest
+	2,  // 1: trusted_ledger.Request.multi_metadata:type_name -> trusted_ledger.GetMultiMetadataRequest
+	4,  // 2: trusted_ledger.Request.validate_identity:type_name -> trusted_ledger.ValidateIdentityRequest
+	6,  // 3: trusted_ledger.Request.can_endorse:type_name -> trusted_ledger.CanEndorseRequest
+	10, // 4: trusted_ledger.Request.escrow_keys:type_name -> trusted_ledger.EscrowKeysRequest
+	12, // 5: trusted_ledger.Request.release_keys:type_name -> trusted_ledger.ReleaseKeysRequest
+	1,  // 6: trusted_ledger.Response.metadata:type_name -> trusted_ledger.GetMetadataResponse
+	3,  // 7: trusted_ledger.Response.multi_metadata:type_name -> trusted_ledger.GetMultiMetadataResponse
+	5,  // 8: trusted_ledger.Response.validate_identity:type_name -> trusted_ledger.ValidateIdentityResponse
+	7,  // 9: trusted_ledger.Response.can_endorse:type_name -> trusted_ledger.CanEndorseResponse
+	11, // 10: trusted_ledger.Response.escrow_keys:type_name -> trusted_ledger.EscrowKeysResponse
+	13, // 11: trusted_ledger.Response.release_keys:type_name -> trusted_ledger.ReleaseKeysResponse
+	12, // [12:12] is the sub-list for method output_type
+	12, // [12:12] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
 }
 
 func init() { file_fpc_trusted_ledger_proto_init() }
@@ -784,12 +1155,16 @@ func file_fpc_trusted_ledger_proto_init() {
 		(*Request_MultiMetadata)(nil),
 		(*Request_ValidateIdentity)(nil),
 		(*Request_CanEndorse)(nil),
+		(*Request_EscrowKeys)(nil),
+		(*Request_ReleaseKeys)(nil),
 	}
 	file_fpc_trusted_ledger_proto_msgTypes[9].OneofWrappers = []any{
 		(*Response_Metadata)(nil),
 		(*Response_MultiMetadata)(nil),
 		(*Response_ValidateIdentity)(nil),
 		(*Response_CanEndorse)(nil),
+		(*Response_EscrowKeys)(nil),
+		(*Response_ReleaseKeys)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -797,7 +1172,7 @@ func file_fpc_trusted_ledger_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fpc_trusted_ledger_proto_rawDesc), len(file_fpc_trusted_ledger_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   10,
+			NumMessages:   15,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,193 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package session
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos/tl_session"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// Transport sends a serialized session message to the responder and returns the serialized reply,
+// e.g., through the peer; the transport does not need to be trusted
+type Transport func(msg []byte) (reply []byte, err error)
+
+// Client is the initiator of a session. Its Request method can be used as the trusted ledger of an enclave.
+type Client struct {
+	mutex     sync.Mutex
+	session   *Session
+	transport Transport
+}
+
+// Dial runs the handshake with a responder and returns the client of the established session. The initiator
+// is identified by its enclave ID, chaincode ID and channel ID, which must match its credentials; the
+// responder is accepted if its credentials pass the verifier.
+func Dial(csp crypto.CSP, identity *Identity, verify CredentialsVerifier, transport Transport, channelId, chaincodeId, enclaveId string) (*Client, error) {
+	init := &tl_session.SessionSetupInitRequest{
+		ChannelId:   channelId,
+		ChaincodeId: chaincodeId,
+		EnclaveId:   enclaveId,
+	}
+	_, payload, err := roundTrip(transport, &tl_session.SessionMsgPayload{Payload: &tl_session.SessionMsgPayload_StpIntReq{StpIntReq: init}}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "session setup failed")
+	}
+	initRsp := payload.GetStpIntRsp()
+	if initRsp == nil {
+		return nil, fmt.Errorf("session setup failed: unexpected message %T", payload.GetPayload())
+	}
+	sessionId := initRsp.GetSessionId()
+
+	msg1 := &tl_session.SessionHandshakeMsg1{}
+	if err := proto.Unmarshal(initRsp.GetMsg1(), msg1); err != nil {
+		return nil, errors.Wrap(err, "invalid handshake message")
+	}
+	responderKey, err := ecdh.P256().NewPublicKey(msg1.GetEphemeralKey())
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid ephemeral key")
+	}
+
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	transcript := transcriptHash(init, sessionId, msg1.GetEphemeralKey(), ephemeral.PublicKey().Bytes())
+
+	credentials, err := proto.Marshal(identity.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := identity.Signer.Sign(signedData(initiatorRole, transcript, credentials))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot sign handshake")
+	}
+	msg2, err := proto.Marshal(&tl_session.SessionHandshakeMsg2{
+		EphemeralKey: ephemeral.PublicKey().Bytes(),
+		Credentials:  credentials,
+		Signature:    signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	msg, payload, err := roundTrip(transport, &tl_session.SessionMsgPayload{Payload: &tl_session.SessionMsgPayload_StpCmpReq{StpCmpReq: &tl_session.SessionSetupCompleteRequest{
+		SessionId: sessionId,
+		Msg2:      msg2,
+	}}}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "session setup failed")
+	}
+	completeRsp := payload.GetStpCmpRsp()
+	if completeRsp == nil {
+		return nil, fmt.Errorf("session setup failed: unexpected message %T", payload.GetPayload())
+	}
+
+	sharedSecret, err := ephemeral.ECDH(responderKey)
+	if err != nil {
+		return nil, err
+	}
+	i2r, r2i, err := deriveKeys(csp, sharedSecret, transcript)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMac(r2i.mac, msg); err != nil {
+		return nil, errors.Wrap(err, "session setup failed")
+	}
+
+	msg3 := &tl_session.SessionHandshakeMsg3{}
+	if err := proto.Unmarshal(completeRsp.GetMsg3(), msg3); err != nil {
+		return nil, errors.Wrap(err, "invalid handshake message")
+	}
+	peer, err := verifyParty(csp, verify, msg3.GetCredentials(), signedData(responderRole, transcript, credentials, msg3.GetCredentials()), msg3.GetSignature())
+	if err != nil {
+		return nil, errors.Wrap(err, "session setup failed")
+	}
+	if completeRsp.GetSessionId() != sessionId || completeRsp.GetChannelId() != channelId ||
+		completeRsp.GetChaincodeId() != chaincodeId || completeRsp.GetEnclaveId() != enclaveId {
+		return nil, fmt.Errorf("session setup failed: response does not match the request")
+	}
+
+	logger.Debugf("session %d: established", sessionId)
+	return &Client{
+		session: &Session{
+			csp:         csp,
+			id:          sessionId,
+			peer:        peer,
+			channelId:   channelId,
+			channelHash: completeRsp.GetChannelHash(),
+			chaincodeId: chaincodeId,
+			enclaveId:   enclaveId,
+			send:        i2r,
+			recv:        r2i,
+		},
+		transport: transport,
+	}, nil
+}
+
+func (c *Client) Session() *Session {
+	return c.session
+}
+
+// Request sends a request through the session and returns the response
+func (c *Client) Request(request []byte) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.session.closed {
+		return nil, fmt.Errorf("session %d is closed", c.session.id)
+	}
+
+	msg, nonce, err := c.session.sealRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.transport(msg)
+	if err != nil {
+		return nil, err
+	}
+	return c.session.openResponse(nonce, reply)
+}
+
+// Close closes the session at the responder
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.session.closed {
+		return nil
+	}
+	c.session.closed = true
+
+	msg, payload, err := roundTrip(c.transport, &tl_session.SessionMsgPayload{Payload: &tl_session.SessionMsgPayload_ClsReq{ClsReq: &tl_session.SessionCloseRequest{
+		SessionId: c.session.id,
+	}}}, c.session.send.mac)
+	if err != nil {
+		return err
+	}
+	if payload.GetClsRsp().GetSessionId() != c.session.id {
+		return fmt.Errorf("unexpected message %T", payload.GetPayload())
+	}
+	return checkMac(c.session.recv.mac, msg)
+}
+
+func roundTrip(transport Transport, payload *tl_session.SessionMsgPayload, macKey []byte) (*tl_session.SessionMsg, *tl_session.SessionMsgPayload, error) {
+	msg, err := marshalMsg(payload, macKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	reply, err := transport(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return expectMsg(reply)
+}
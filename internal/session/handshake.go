@@ -0,0 +1,119 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos/tl_session"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// The handshake is a signed ephemeral ECDH (P-256) key exchange:
+//
+//	initiator -> responder: SessionSetupInitRequest{channel_id, chaincode_id, enclave_id}
+//	responder -> initiator: SessionSetupInitResponse{session_id, msg1{ephemeral_key}}
+//	initiator -> responder: SessionSetupCompleteRequest{session_id, msg2{ephemeral_key, credentials, signature}}
+//	responder -> initiator: SessionSetupCompleteResponse{session_id, msg3{credentials, signature}, ...} (MACed)
+//
+// Both parties sign the transcript hash, which covers the init request, the session ID and both ephemeral keys,
+// together with their role and the credentials exchanged so far. The session keys are derived from the
+// shared secret with HKDF-SHA256 of the CSP, salted with the transcript hash.
+
+const (
+	protocolLabel = "fpc tl session v1"
+
+	initiatorRole = "initiator"
+	responderRole = "responder"
+
+	encKeyLength = 16
+	macKeyLength = 32
+)
+
+// transcriptHash returns the hash over the public values of a handshake
+func transcriptHash(init *tl_session.SessionSetupInitRequest, sessionId uint64, responderKey, initiatorKey []byte) []byte {
+	h := sha256.New()
+	writeField(h, []byte(protocolLabel))
+	writeField(h, []byte(init.GetChannelId()))
+	writeField(h, []byte(init.GetChaincodeId()))
+	writeField(h, []byte(init.GetEnclaveId()))
+	writeField(h, binary.BigEndian.AppendUint64(nil, sessionId))
+	writeField(h, responderKey)
+	writeField(h, initiatorKey)
+	return h.Sum(nil)
+}
+
+// signedData returns the data signed by a party of the handshake
+func signedData(role string, transcript []byte, credentials ...[]byte) []byte {
+	h := sha256.New()
+	writeField(h, []byte(role))
+	writeField(h, transcript)
+	for _, c := range credentials {
+		writeField(h, c)
+	}
+	return h.Sum(nil)
+}
+
+// writeField writes a length-prefixed field, so that the encoding of the fields is unambiguous
+func writeField(h hash.Hash, field []byte) {
+	h.Write(binary.AppendUvarint(nil, uint64(len(field))))
+	h.Write(field)
+}
+
+// deriveKeys returns the keys of the initiator-to-responder and the responder-to-initiator direction
+func deriveKeys(csp crypto.CSP, sharedSecret, transcript []byte) (directionKeys, directionKeys, error) {
+	keys, err := csp.DeriveKey(protos.HashAlgorithm_HASH_ALGORITHM_SHA256, sharedSecret, transcript, protocolLabel+" keys", 2*(encKeyLength+macKeyLength))
+	if err != nil {
+		return directionKeys{}, directionKeys{}, err
+	}
+
+	i2r := directionKeys{enc: keys[:encKeyLength], mac: keys[encKeyLength : encKeyLength+macKeyLength]}
+	keys = keys[encKeyLength+macKeyLength:]
+	r2i := directionKeys{enc: keys[:encKeyLength], mac: keys[encKeyLength:]}
+	return i2r, r2i, nil
+}
+
+// verifyParty checks the credentials and the handshake signature of the other party and returns its attested data
+func verifyParty(csp crypto.CSP, verify CredentialsVerifier, serializedCredentials, data, signature []byte) (*protos.AttestedData, error) {
+	credentials := &protos.Credentials{}
+	if err := proto.Unmarshal(serializedCredentials, credentials); err != nil {
+		return nil, errors.Wrap(err, "invalid credentials")
+	}
+
+	attestedData, err := verify(credentials)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid credentials")
+	}
+	if attestedData.GetEnclaveVk() == nil {
+		return nil, fmt.Errorf("credentials contain no enclave verification key")
+	}
+
+	if err := csp.VerifyMessage(attestedData.GetEnclaveVk(), data, signature); err != nil {
+		return nil, errors.Wrap(err, "invalid handshake signature")
+	}
+	return attestedData, nil
+}
+
+// checkInitiator checks that the attested data of the initiator matches its init request
+func checkInitiator(init *tl_session.SessionSetupInitRequest, attestedData *protos.AttestedData) error {
+	if !strings.EqualFold(utils.GetEnclaveId(attestedData), init.GetEnclaveId()) {
+		return fmt.Errorf("credentials do not belong to enclave %s", init.GetEnclaveId())
+	}
+	ccParams := attestedData.GetCcParams()
+	if ccParams.GetChannelId() != init.GetChannelId() || ccParams.GetChaincodeId() != init.GetChaincodeId() {
+		return fmt.Errorf("credentials do not belong to chaincode %s on channel %s", init.GetChaincodeId(), init.GetChannelId())
+	}
+	return nil
+}
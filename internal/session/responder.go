@@ -0,0 +1,391 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package session
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos/tl_session"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultMaxSessions      = 1024
+	defaultMaxHandshakes    = 64
+	defaultHandshakeTimeout = 30 * time.Second
+	defaultSessionTimeout   = 10 * time.Minute
+)
+
+// Handler processes the decrypted request of a session and returns the response,
+// e.g., the Request method of a trusted ledger
+type Handler func(session *Session, request []byte) (response []byte, err error)
+
+type ResponderOption func(r *Responder)
+
+// WithChannelHash sets the channel hash which the responder reports to the initiators
+func WithChannelHash(channelHash []byte) ResponderOption {
+	return func(r *Responder) {
+		r.channelHash = channelHash
+	}
+}
+
+// WithMaxSessions sets the number of established sessions which the responder keeps
+func WithMaxSessions(n int) ResponderOption {
+	return func(r *Responder) {
+		if n < 1 {
+			panic(fmt.Sprintf("invalid number of sessions %d", n))
+		}
+		r.maxSessions = n
+	}
+}
+
+// WithMaxHandshakes sets the number of pending handshakes which the responder keeps. As handshakes are not
+// authenticated until they complete, the oldest pending handshake is dropped when a new one exceeds the limit.
+func WithMaxHandshakes(n int) ResponderOption {
+	return func(r *Responder) {
+		if n < 1 {
+			panic(fmt.Sprintf("invalid number of handshakes %d", n))
+		}
+		r.maxHandshakes = n
+	}
+}
+
+// WithHandshakeTimeout sets the time within which an initiator must complete a handshake
+func WithHandshakeTimeout(timeout time.Duration) ResponderOption {
+	return func(r *Responder) {
+		if timeout <= 0 {
+			panic(fmt.Sprintf("invalid handshake timeout %s", timeout))
+		}
+		r.handshakeTimeout = timeout
+	}
+}
+
+// WithSessionTimeout sets the time after which an idle session is closed by the responder
+func WithSessionTimeout(timeout time.Duration) ResponderOption {
+	return func(r *Responder) {
+		if timeout <= 0 {
+			panic(fmt.Sprintf("invalid session timeout %s", timeout))
+		}
+		r.sessionTimeout = timeout
+	}
+}
+
+// Responder accepts sessions from initiators and serves their requests
+type Responder struct {
+	mutex sync.Mutex
+
+	csp         crypto.CSP
+	identity    *Identity
+	verify      CredentialsVerifier
+	handler     Handler
+	channelHash []byte
+
+	maxSessions      int
+	maxHandshakes    int
+	handshakeTimeout time.Duration
+	sessionTimeout   time.Duration
+	now              func() time.Time
+
+	handshakes map[uint64]*handshake
+	sessions   map[uint64]*Session
+	// lastUsed is the time of the last message of each established session
+	lastUsed map[uint64]time.Time
+}
+
+type handshake struct {
+	init      *tl_session.SessionSetupInitRequest
+	ephemeral *ecdh.PrivateKey
+	started   time.Time
+}
+
+func NewResponder(csp crypto.CSP, identity *Identity, verify CredentialsVerifier, handler Handler, options ...ResponderOption) *Responder {
+	r := &Responder{
+		csp:              csp,
+		identity:         identity,
+		verify:           verify,
+		handler:          handler,
+		maxSessions:      defaultMaxSessions,
+		maxHandshakes:    defaultMaxHandshakes,
+		handshakeTimeout: defaultHandshakeTimeout,
+		sessionTimeout:   defaultSessionTimeout,
+		now:              time.Now,
+		handshakes:       make(map[uint64]*handshake),
+		sessions:         make(map[uint64]*Session),
+		lastUsed:         make(map[uint64]time.Time),
+	}
+	for _, o := range options {
+		o(r)
+	}
+	return r
+}
+
+// HandleMessage processes a serialized session message and returns the serialized reply. Failures are reported
+// to the initiator as SessionError messages; an error is only returned if no reply can be created.
+func (r *Responder) HandleMessage(msgBytes []byte) ([]byte, error) {
+	msg, payload, err := unmarshalMsg(msgBytes)
+	if err != nil {
+		return errorMsg(0, ErrorInvalidMessage, err)
+	}
+
+	switch p := payload.GetPayload().(type) {
+	case *tl_session.SessionMsgPayload_StpIntReq:
+		return r.initHandshake(p.StpIntReq)
+	case *tl_session.SessionMsgPayload_StpCmpReq:
+		return r.completeHandshake(p.StpCmpReq)
+	case *tl_session.SessionMsgPayload_TxReq:
+		return r.handleRequest(msg, p.TxReq)
+	case *tl_session.SessionMsgPayload_ClsReq:
+		return r.closeSession(msg, p.ClsReq)
+	default:
+		return errorMsg(0, ErrorInvalidMessage, fmt.Errorf("unexpected message %T", p))
+	}
+}
+
+func (r *Responder) initHandshake(init *tl_session.SessionSetupInitRequest) ([]byte, error) {
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.now()
+	r.evictExpired(now)
+	if len(r.sessions) >= r.maxSessions {
+		return errorMsg(0, ErrorHandshakeFailed, fmt.Errorf("too many sessions"))
+	}
+	if len(r.handshakes) >= r.maxHandshakes {
+		r.evictOldestHandshake()
+	}
+	sessionId, err := r.newSessionId()
+	if err != nil {
+		return nil, err
+	}
+	r.handshakes[sessionId] = &handshake{init: init, ephemeral: ephemeral, started: now}
+
+	msg1, err := proto.Marshal(&tl_session.SessionHandshakeMsg1{EphemeralKey: ephemeral.PublicKey().Bytes()})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debugf("session %d: handshake started by enclave %s", sessionId, init.GetEnclaveId())
+	return marshalMsg(&tl_session.SessionMsgPayload{Payload: &tl_session.SessionMsgPayload_StpIntRsp{StpIntRsp: &tl_session.SessionSetupInitResponse{
+		SessionId: sessionId,
+		Msg1:      msg1,
+	}}}, nil)
+}
+
+func (r *Responder) completeHandshake(req *tl_session.SessionSetupCompleteRequest) ([]byte, error) {
+	sessionId := req.GetSessionId()
+
+	// a handshake can be completed only once, even if it fails
+	r.mutex.Lock()
+	r.evictExpired(r.now())
+	hs, ok := r.handshakes[sessionId]
+	delete(r.handshakes, sessionId)
+	r.mutex.Unlock()
+	if !ok {
+		return errorMsg(sessionId, ErrorUnknownSession, fmt.Errorf("unknown handshake"))
+	}
+
+	session, msg3, err := r.acceptInitiator(hs, sessionId, req.GetMsg2())
+	if err != nil {
+		return errorMsg(sessionId, ErrorHandshakeFailed, err)
+	}
+
+	r.mutex.Lock()
+	if len(r.sessions) >= r.maxSessions {
+		r.mutex.Unlock()
+		return errorMsg(sessionId, ErrorHandshakeFailed, fmt.Errorf("too many sessions"))
+	}
+	r.sessions[sessionId] = session
+	r.lastUsed[sessionId] = r.now()
+	r.mutex.Unlock()
+
+	logger.Debugf("session %d: established with enclave %s", sessionId, session.enclaveId)
+	return marshalMsg(&tl_session.SessionMsgPayload{Payload: &tl_session.SessionMsgPayload_StpCmpRsp{StpCmpRsp: &tl_session.SessionSetupCompleteResponse{
+		SessionId:   sessionId,
+		Msg3:        msg3,
+		ChannelId:   session.channelId,
+		ChannelHash: session.channelHash,
+		ChaincodeId: session.chaincodeId,
+		EnclaveId:   session.enclaveId,
+	}}}, session.send.mac)
+}
+
+// acceptInitiator verifies msg2 of a handshake and returns the new session and msg3
+func (r *Responder) acceptInitiator(hs *handshake, sessionId uint64, msg2Bytes []byte) (*Session, []byte, error) {
+	msg2 := &tl_session.SessionHandshakeMsg2{}
+	if err := proto.Unmarshal(msg2Bytes, msg2); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid handshake message")
+	}
+	initiatorKey, err := ecdh.P256().NewPublicKey(msg2.GetEphemeralKey())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid ephemeral key")
+	}
+
+	transcript := transcriptHash(hs.init, sessionId, hs.ephemeral.PublicKey().Bytes(), msg2.GetEphemeralKey())
+	peer, err := verifyParty(r.csp, r.verify, msg2.GetCredentials(), signedData(initiatorRole, transcript, msg2.GetCredentials()), msg2.GetSignature())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkInitiator(hs.init, peer); err != nil {
+		return nil, nil, err
+	}
+
+	sharedSecret, err := hs.ephemeral.ECDH(initiatorKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	i2r, r2i, err := deriveKeys(r.csp, sharedSecret, transcript)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	credentials, err := proto.Marshal(r.identity.Credentials)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, err := r.identity.Signer.Sign(signedData(responderRole, transcript, msg2.GetCredentials(), credentials))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot sign handshake")
+	}
+	msg3, err := proto.Marshal(&tl_session.SessionHandshakeMsg3{Credentials: credentials, Signature: signature})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Session{
+		csp:         r.csp,
+		id:          sessionId,
+		peer:        peer,
+		channelId:   hs.init.GetChannelId(),
+		channelHash: r.channelHash,
+		chaincodeId: hs.init.GetChaincodeId(),
+		enclaveId:   hs.init.GetEnclaveId(),
+		send:        r2i,
+		recv:        i2r,
+	}, msg3, nil
+}
+
+func (r *Responder) handleRequest(msg *tl_session.SessionMsg, req *tl_session.SessionTXRequest) ([]byte, error) {
+	session, ok := r.session(req.GetSessionId())
+	if !ok {
+		return errorMsg(req.GetSessionId(), ErrorUnknownSession, fmt.Errorf("unknown session"))
+	}
+
+	request, nonce, err := session.openRequest(msg, req)
+	if err != nil {
+		return errorMsg(session.id, ErrorInvalidMessage, err)
+	}
+
+	response, err := r.handler(session, request)
+	if err != nil {
+		return errorMsg(session.id, ErrorRequestFailed, err)
+	}
+	return session.sealResponse(nonce, response)
+}
+
+func (r *Responder) closeSession(msg *tl_session.SessionMsg, req *tl_session.SessionCloseRequest) ([]byte, error) {
+	session, ok := r.session(req.GetSessionId())
+	if !ok {
+		return errorMsg(req.GetSessionId(), ErrorUnknownSession, fmt.Errorf("unknown session"))
+	}
+	if err := checkMac(session.recv.mac, msg); err != nil {
+		return errorMsg(session.id, ErrorInvalidMessage, err)
+	}
+
+	r.mutex.Lock()
+	delete(r.sessions, session.id)
+	delete(r.lastUsed, session.id)
+	r.mutex.Unlock()
+
+	logger.Debugf("session %d: closed", session.id)
+	return marshalMsg(&tl_session.SessionMsgPayload{Payload: &tl_session.SessionMsgPayload_ClsRsp{ClsRsp: &tl_session.SessionCloseResponse{
+		SessionId: session.id,
+	}}}, session.send.mac)
+}
+
+// session returns an established session and marks it as used
+func (r *Responder) session(sessionId uint64) (*Session, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.now()
+	r.evictExpired(now)
+	session, ok := r.sessions[sessionId]
+	if ok {
+		r.lastUsed[sessionId] = now
+	}
+	return session, ok
+}
+
+// evictExpired drops the handshakes which were not completed in time and the idle sessions; the caller must hold
+// the mutex
+func (r *Responder) evictExpired(now time.Time) {
+	for id, hs := range r.handshakes {
+		if now.Sub(hs.started) >= r.handshakeTimeout {
+			logger.Debugf("session %d: handshake expired", id)
+			delete(r.handshakes, id)
+		}
+	}
+	for id, lastUsed := range r.lastUsed {
+		if now.Sub(lastUsed) >= r.sessionTimeout {
+			logger.Debugf("session %d: closed after being idle", id)
+			delete(r.sessions, id)
+			delete(r.lastUsed, id)
+		}
+	}
+}
+
+// evictOldestHandshake drops the oldest pending handshake; the caller must hold the mutex
+func (r *Responder) evictOldestHandshake() {
+	var oldestId uint64
+	var oldest *handshake
+	for id, hs := range r.handshakes {
+		if oldest == nil || hs.started.Before(oldest.started) {
+			oldestId, oldest = id, hs
+		}
+	}
+	if oldest != nil {
+		logger.Debugf("session %d: handshake dropped", oldestId)
+		delete(r.handshakes, oldestId)
+	}
+}
+
+// newSessionId returns a random unused session ID; the caller must hold the mutex
+func (r *Responder) newSessionId() (uint64, error) {
+	b := make([]byte, 8)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return 0, err
+		}
+		id := binary.BigEndian.Uint64(b)
+		_, pending := r.handshakes[id]
+		_, established := r.sessions[id]
+		if id != 0 && !pending && !established {
+			return id, nil
+		}
+	}
+}
+
+func errorMsg(sessionId uint64, code int32, err error) ([]byte, error) {
+	logger.Debugf("session %d: error %d: %s", sessionId, code, err)
+	return marshalMsg(&tl_session.SessionMsgPayload{Payload: &tl_session.SessionMsgPayload_Error{Error: &tl_session.SessionError{
+		SessionId: sessionId,
+		ErrorCode: code,
+		ErrorMsg:  err.Error(),
+	}}}, nil)
+}
@@ -0,0 +1,264 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package session implements the secure session protocol of tl_session.proto. A session is established by an
+// authenticated key exchange between two attested parties, e.g., an enclave (the initiator) and TLCC (the
+// responder), and protects the requests and responses exchanged over an untrusted transport, such as the peer.
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/attestation"
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos/tl_session"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+var logger = flogging.MustGetLogger("session")
+
+// Error codes of SessionError messages
+const (
+	ErrorInvalidMessage int32 = iota + 1
+	ErrorUnknownSession
+	ErrorHandshakeFailed
+	ErrorRequestFailed
+)
+
+const nonceLength = 8
+
+// Signer signs messages with the attested key of a party, i.e., the key whose hash is the enclave ID
+type Signer interface {
+	Sign(msg []byte) (signature []byte, err error)
+}
+
+// Identity is the attested identity of a session party
+type Identity struct {
+	Credentials *protos.Credentials
+	Signer      Signer
+}
+
+// CredentialsVerifier checks the credentials of the other party of a session, including its attestation
+// evidence, and returns the attested data
+type CredentialsVerifier func(credentials *protos.Credentials) (*protos.AttestedData, error)
+
+// AttestedCredentials returns a CredentialsVerifier which accepts credentials with valid attestation evidence
+// for the expected mrenclave
+func AttestedCredentials(verifier attestation.Verifier, expectedMrenclave string) CredentialsVerifier {
+	return func(credentials *protos.Credentials) (*protos.AttestedData, error) {
+		if err := verifier.VerifyCredentials(credentials, expectedMrenclave); err != nil {
+			return nil, errors.Wrap(err, "invalid attestation")
+		}
+		return utils.UnmarshalAttestedData(credentials.GetSerializedAttestedData())
+	}
+}
+
+// ChaincodeCredentials returns a CredentialsVerifier which accepts credentials with valid attestation evidence for
+// the chaincode version in their attested data, i.e., for the mrenclave of the chaincode of the other party. It lets a
+// responder, such as TLCC, serve the enclaves of any chaincode and tell them apart by their attested data.
+func ChaincodeCredentials(verifier attestation.Verifier) CredentialsVerifier {
+	return func(credentials *protos.Credentials) (*protos.AttestedData, error) {
+		attestedData, err := utils.UnmarshalAttestedData(credentials.GetSerializedAttestedData())
+		if err != nil {
+			return nil, err
+		}
+		if err := verifier.VerifyCredentials(credentials, attestedData.GetCcParams().GetVersion()); err != nil {
+			return nil, errors.Wrap(err, "invalid attestation")
+		}
+		return attestedData, nil
+	}
+}
+
+// directionKeys protect the messages sent in one direction of a session
+type directionKeys struct {
+	enc []byte
+	mac []byte
+}
+
+// Session is an established session. Requests are sent by the initiator in order, and the responder accepts
+// every request nonce at most once; responses are bound to the nonce of their request.
+type Session struct {
+	mutex sync.Mutex
+	csp   crypto.CSP
+
+	id          uint64
+	peer        *protos.AttestedData
+	channelId   string
+	channelHash []byte
+	chaincodeId string
+	enclaveId   string
+
+	send directionKeys
+	recv directionKeys
+
+	// nonce is the last request nonce sent by the initiator, or accepted by the responder
+	nonce  uint64
+	closed bool
+}
+
+func (s *Session) ID() uint64 {
+	return s.id
+}
+
+// Peer returns the attested data of the other party of the session
+func (s *Session) Peer() *protos.AttestedData {
+	return s.peer
+}
+
+// ChannelHash returns the channel hash reported by the responder during the handshake
+func (s *Session) ChannelHash() []byte {
+	return s.channelHash
+}
+
+// sealRequest returns a tx request message and its nonce
+func (s *Session) sealRequest(request []byte) ([]byte, []byte, error) {
+	s.nonce++
+	nonce := binary.BigEndian.AppendUint64(nil, s.nonce)
+
+	encryptedRequest, err := s.csp.EncryptMessageWithAD(s.send.enc, request, txBinding(s.id, nonce))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot encrypt request")
+	}
+
+	msg, err := marshalMsg(&tl_session.SessionMsgPayload{Payload: &tl_session.SessionMsgPayload_TxReq{TxReq: &tl_session.SessionTXRequest{
+		SessionId: s.id,
+		Nonce:     nonce,
+		Request:   encryptedRequest,
+	}}}, s.send.mac)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, nonce, nil
+}
+
+// openRequest checks and decrypts a tx request and returns the request and its nonce
+func (s *Session) openRequest(msg *tl_session.SessionMsg, req *tl_session.SessionTXRequest) ([]byte, []byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := checkMac(s.recv.mac, msg); err != nil {
+		return nil, nil, err
+	}
+	if len(req.GetNonce()) != nonceLength {
+		return nil, nil, fmt.Errorf("invalid nonce length %d", len(req.GetNonce()))
+	}
+	nonce := binary.BigEndian.Uint64(req.GetNonce())
+	if nonce <= s.nonce {
+		return nil, nil, fmt.Errorf("replayed request with nonce %d", nonce)
+	}
+
+	request, err := s.csp.DecryptMessageWithAD(s.recv.enc, req.GetRequest(), txBinding(s.id, req.GetNonce()))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot decrypt request")
+	}
+
+	s.nonce = nonce
+	return request, req.GetNonce(), nil
+}
+
+// sealResponse returns the tx response message for the request with the given nonce
+func (s *Session) sealResponse(nonce, response []byte) ([]byte, error) {
+	encryptedResponse, err := s.csp.EncryptMessageWithAD(s.send.enc, response, txBinding(s.id, nonce))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot encrypt response")
+	}
+
+	return marshalMsg(&tl_session.SessionMsgPayload{Payload: &tl_session.SessionMsgPayload_TxRsp{TxRsp: &tl_session.SessionTXResponse{
+		SessionId: s.id,
+		Nonce:     nonce,
+		Respoonse: encryptedResponse,
+	}}}, s.send.mac)
+}
+
+// openResponse checks and decrypts the tx response to the request with the given nonce
+func (s *Session) openResponse(nonce, msgBytes []byte) ([]byte, error) {
+	msg, payload, err := expectMsg(msgBytes)
+	if err != nil {
+		return nil, err
+	}
+	rsp := payload.GetTxRsp()
+	if rsp == nil {
+		return nil, fmt.Errorf("unexpected message %T", payload.GetPayload())
+	}
+	if err := checkMac(s.recv.mac, msg); err != nil {
+		return nil, err
+	}
+	if rsp.GetSessionId() != s.id || !hmac.Equal(rsp.GetNonce(), nonce) {
+		return nil, fmt.Errorf("response does not match the request")
+	}
+
+	response, err := s.csp.DecryptMessageWithAD(s.recv.enc, rsp.GetRespoonse(), txBinding(s.id, nonce))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decrypt response")
+	}
+	return response, nil
+}
+
+// txBinding returns the additional data which binds a request or response to its session and nonce
+func txBinding(sessionId uint64, nonce []byte) []byte {
+	return append(binary.BigEndian.AppendUint64(nil, sessionId), nonce...)
+}
+
+func marshalMsg(payload *tl_session.SessionMsgPayload, macKey []byte) ([]byte, error) {
+	serializedPayload, err := anypb.New(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &tl_session.SessionMsg{SerializedPayload: serializedPayload}
+	if macKey != nil {
+		msg.Mac = computeMac(macKey, serializedPayload.GetValue())
+	}
+	return proto.Marshal(msg)
+}
+
+func unmarshalMsg(msgBytes []byte) (*tl_session.SessionMsg, *tl_session.SessionMsgPayload, error) {
+	msg := &tl_session.SessionMsg{}
+	if err := proto.Unmarshal(msgBytes, msg); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid session message")
+	}
+
+	payload := &tl_session.SessionMsgPayload{}
+	if err := msg.GetSerializedPayload().UnmarshalTo(payload); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid session message payload")
+	}
+	return msg, payload, nil
+}
+
+// expectMsg unmarshals a message received by the initiator and turns a session error into an error.
+// Note that session errors are not authenticated, thus, they are only useful for diagnostics.
+func expectMsg(msgBytes []byte) (*tl_session.SessionMsg, *tl_session.SessionMsgPayload, error) {
+	msg, payload, err := unmarshalMsg(msgBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e := payload.GetError(); e != nil {
+		return nil, nil, fmt.Errorf("session error %d: %s", e.GetErrorCode(), e.GetErrorMsg())
+	}
+	return msg, payload, nil
+}
+
+func computeMac(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func checkMac(key []byte, msg *tl_session.SessionMsg) error {
+	if !hmac.Equal(computeMac(key, msg.GetSerializedPayload().GetValue()), msg.GetMac()) {
+		return fmt.Errorf("invalid message mac")
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package session
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos/tl_session"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	testChannelId   = "mychannel"
+	testChaincodeId = "echo"
+)
+
+type testSigner struct {
+	csp        crypto.CSP
+	privateKey []byte
+}
+
+func (s *testSigner) Sign(msg []byte) ([]byte, error) {
+	return s.csp.SignMessage(s.privateKey, msg)
+}
+
+func newTestIdentity(t *testing.T, csp crypto.CSP, chaincodeId string) (*Identity, *protos.AttestedData) {
+	publicKey, privateKey, err := csp.NewECDSAKeys()
+	require.NoError(t, err)
+
+	attestedData := &protos.AttestedData{
+		CcParams:  &protos.CCParameters{ChannelId: testChannelId, ChaincodeId: chaincodeId},
+		EnclaveVk: publicKey,
+	}
+	serializedAttestedData, err := anypb.New(attestedData)
+	require.NoError(t, err)
+
+	return &Identity{
+		Credentials: &protos.Credentials{SerializedAttestedData: serializedAttestedData},
+		Signer:      &testSigner{csp: csp, privateKey: privateKey},
+	}, attestedData
+}
+
+// acceptAll accepts any credentials without checking attestation evidence
+func acceptAll(credentials *protos.Credentials) (*protos.AttestedData, error) {
+	return utils.UnmarshalAttestedData(credentials.GetSerializedAttestedData())
+}
+
+type testSetup struct {
+	csp             crypto.CSP
+	client          *Identity
+	clientData      *protos.AttestedData
+	responder       *Responder
+	responderData   *protos.AttestedData
+	handledSessions []*Session
+}
+
+func newTestSetup(t *testing.T) *testSetup {
+	s := &testSetup{csp: crypto.GetDefaultCSP()}
+	s.client, s.clientData = newTestIdentity(t, s.csp, testChaincodeId)
+
+	var responderIdentity *Identity
+	responderIdentity, s.responderData = newTestIdentity(t, s.csp, "tlcc")
+	s.responder = NewResponder(s.csp, responderIdentity, acceptAll, func(session *Session, request []byte) ([]byte, error) {
+		s.handledSessions = append(s.handledSessions, session)
+		if string(request) == "fail" {
+			return nil, fmt.Errorf("request failed")
+		}
+		return append([]byte("echo "), request...), nil
+	}, WithChannelHash([]byte("channel hash")))
+	return s
+}
+
+func (s *testSetup) dial(transport Transport) (*Client, error) {
+	return Dial(s.csp, s.client, acceptAll, transport, testChannelId, testChaincodeId, utils.GetEnclaveId(s.clientData))
+}
+
+func TestSession(t *testing.T) {
+	s := newTestSetup(t)
+
+	client, err := s.dial(s.responder.HandleMessage)
+	require.NoError(t, err)
+	assert.Equal(t, s.responderData.GetEnclaveVk(), client.Session().Peer().GetEnclaveVk())
+	assert.Equal(t, []byte("channel hash"), client.Session().ChannelHash())
+
+	for _, request := range []string{"hello", "world", "!"} {
+		response, err := client.Request([]byte(request))
+		assert.NoError(t, err)
+		assert.Equal(t, "echo "+request, string(response))
+	}
+	require.Len(t, s.handledSessions, 3)
+	assert.Equal(t, s.clientData.GetEnclaveVk(), s.handledSessions[0].Peer().GetEnclaveVk())
+	assert.Equal(t, client.Session().ID(), s.handledSessions[0].ID())
+
+	// handler errors are reported to the client
+	_, err = client.Request([]byte("fail"))
+	assert.EqualError(t, err, fmt.Sprintf("session error %d: request failed", ErrorRequestFailed))
+
+	// the session can be used after a failed request
+	response, err := client.Request([]byte("again"))
+	assert.NoError(t, err)
+	assert.Equal(t, "echo again", string(response))
+
+	assert.NoError(t, client.Close())
+	_, err = client.Request([]byte("closed"))
+	assert.Error(t, err)
+	assert.Empty(t, s.responder.sessions)
+}
+
+func TestSessionReplay(t *testing.T) {
+	s := newTestSetup(t)
+
+	var sent, replies [][]byte
+	client, err := s.dial(func(msg []byte) ([]byte, error) {
+		sent = append(sent, msg)
+		reply, err := s.responder.HandleMessage(msg)
+		replies = append(replies, reply)
+		return reply, err
+	})
+	require.NoError(t, err)
+
+	_, err = client.Request([]byte("first"))
+	require.NoError(t, err)
+	_, err = client.Request([]byte("second"))
+	require.NoError(t, err)
+
+	// replayed requests are rejected
+	for _, msg := range sent[2:] {
+		reply, err := s.responder.HandleMessage(msg)
+		require.NoError(t, err)
+		assertSessionError(t, reply, ErrorInvalidMessage)
+	}
+	assert.Len(t, s.handledSessions, 2)
+
+	// a replayed handshake does not create a session
+	reply, err := s.responder.HandleMessage(sent[1])
+	require.NoError(t, err)
+	assertSessionError(t, reply, ErrorUnknownSession)
+
+	// the response to the first request is not accepted for the second request
+	_, err = client.session.openResponse(binary.BigEndian.AppendUint64(nil, 2), replies[2])
+	assert.EqualError(t, err, "response does not match the request")
+}
+
+func TestSessionTampering(t *testing.T) {
+	s := newTestSetup(t)
+
+	var tamper func(payload *tl_session.SessionMsgPayload)
+	client, err := s.dial(func(msgBytes []byte) ([]byte, error) {
+		if tamper != nil {
+			msg, payload, err := unmarshalMsg(msgBytes)
+			require.NoError(t, err)
+			tamper(payload)
+			msg.SerializedPayload, err = anypb.New(payload)
+			require.NoError(t, err)
+			msgBytes, err = proto.Marshal(msg)
+			require.NoError(t, err)
+		}
+		return s.responder.HandleMessage(msgBytes)
+	})
+	require.NoError(t, err)
+
+	tamper = func(payload *tl_session.SessionMsgPayload) {
+		payload.GetTxReq().Request[0] ^= 1
+	}
+	_, err = client.Request([]byte("hello"))
+	assert.EqualError(t, err, fmt.Sprintf("session error %d: invalid message mac", ErrorInvalidMessage))
+
+	tamper = func(payload *tl_session.SessionMsgPayload) {
+		payload.GetTxReq().SessionId++
+	}
+	_, err = client.Request([]byte("hello"))
+	assert.EqualError(t, err, fmt.Sprintf("session error %d: unknown session", ErrorUnknownSession))
+
+	assert.Empty(t, s.handledSessions)
+}
+
+func TestSessionHandshake(t *testing.T) {
+	s := newTestSetup(t)
+
+	t.Run("wrong enclave id", func(t *testing.T) {
+		_, err := Dial(s.csp, s.client, acceptAll, s.responder.HandleMessage, testChannelId, testChaincodeId, "ABCD")
+		assert.ErrorContains(t, err, "credentials do not belong to enclave ABCD")
+	})
+
+	t.Run("wrong chaincode", func(t *testing.T) {
+		_, err := Dial(s.csp, s.client, acceptAll, s.responder.HandleMessage, testChannelId, "other", utils.GetEnclaveId(s.clientData))
+		assert.ErrorContains(t, err, "credentials do not belong to chaincode other")
+	})
+
+	t.Run("responder rejected", func(t *testing.T) {
+		reject := func(credentials *protos.Credentials) (*protos.AttestedData, error) {
+			return nil, fmt.Errorf("untrusted")
+		}
+		_, err := Dial(s.csp, s.client, reject, s.responder.HandleMessage, testChannelId, testChaincodeId, utils.GetEnclaveId(s.clientData))
+		assert.ErrorContains(t, err, "untrusted")
+	})
+
+	t.Run("stolen credentials", func(t *testing.T) {
+		// an identity with the credentials of the client but a different signing key
+		thief, _ := newTestIdentity(t, s.csp, testChaincodeId)
+		thief.Credentials = s.client.Credentials
+		_, err := Dial(s.csp, thief, acceptAll, s.responder.HandleMessage, testChannelId, testChaincodeId, utils.GetEnclaveId(s.clientData))
+		assert.ErrorContains(t, err, "invalid handshake signature")
+	})
+
+	t.Run("too many sessions", func(t *testing.T) {
+		s.responder.maxSessions = len(s.responder.sessions)
+		_, err := s.dial(s.responder.HandleMessage)
+		assert.ErrorContains(t, err, "too many sessions")
+	})
+
+	assert.Empty(t, s.responder.handshakes)
+	// only the session which the initiator rejected is established at the responder
+	assert.Len(t, s.responder.sessions, 1)
+}
+
+func assertSessionError(t *testing.T, reply []byte, code int32) {
+	_, payload, err := unmarshalMsg(reply)
+	require.NoError(t, err)
+	assert.Equal(t, code, payload.GetError().GetErrorCode())
+}
+
+func TestSessionExpiry(t *testing.T) {
+	s := newTestSetup(t)
+	now := time.Now()
+	s.responder.now = func() time.Time { return now }
+
+	t.Run("handshake timeout", func(t *testing.T) {
+		_, err := s.dial(func(msg []byte) ([]byte, error) {
+			_, payload, err := unmarshalMsg(msg)
+			require.NoError(t, err)
+			if payload.GetStpCmpReq() != nil {
+				now = now.Add(defaultHandshakeTimeout)
+			}
+			return s.responder.HandleMessage(msg)
+		})
+		assert.ErrorContains(t, err, fmt.Sprintf("session error %d: unknown handshake", ErrorUnknownSession))
+		assert.Empty(t, s.responder.handshakes)
+		assert.Empty(t, s.responder.sessions)
+	})
+
+	t.Run("pending handshakes", func(t *testing.T) {
+		s.responder.maxHandshakes = 2
+		init, err := marshalMsg(&tl_session.SessionMsgPayload{Payload: &tl_session.SessionMsgPayload_StpIntReq{StpIntReq: &tl_session.SessionSetupInitRequest{}}}, nil)
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			now = now.Add(time.Second)
+			_, err := s.responder.HandleMessage(init)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(s.responder.handshakes), 2)
+		}
+
+		// abandoned handshakes do not prevent new sessions
+		client, err := s.dial(s.responder.HandleMessage)
+		require.NoError(t, err)
+		assert.Len(t, s.responder.handshakes, 1)
+		require.NoError(t, client.Close())
+
+		now = now.Add(defaultHandshakeTimeout)
+		client, err = s.dial(s.responder.HandleMessage)
+		require.NoError(t, err)
+		assert.Empty(t, s.responder.handshakes)
+		require.NoError(t, client.Close())
+	})
+
+	t.Run("idle session", func(t *testing.T) {
+		client, err := s.dial(s.responder.HandleMessage)
+		require.NoError(t, err)
+
+		// requests keep the session alive
+		for i := 0; i < 3; i++ {
+			now = now.Add(defaultSessionTimeout - time.Second)
+			_, err = client.Request([]byte("hello"))
+			require.NoError(t, err)
+		}
+
+		now = now.Add(defaultSessionTimeout)
+		_, err = client.Request([]byte("hello"))
+		assert.EqualError(t, err, fmt.Sprintf("session error %d: unknown session", ErrorUnknownSession))
+		assert.Empty(t, s.responder.sessions)
+		assert.Empty(t, s.responder.lastUsed)
+	})
+}
@@ -0,0 +1,127 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+)
+
+// rwSetDigestDomain prefixes the canonical encoding of an FPC rwset and identifies its version
+const rwSetDigestDomain = "FPC-RWSET-V1"
+
+// HashFunction computes the hash of a message, e.g., with a hash algorithm of a CSP
+type HashFunction func(message []byte) ([]byte, error)
+
+// GetRwSetDigest returns the hash over the canonical encoding of the FPC rwset (see EncodeRwSet).
+func GetRwSetDigest(fpcKVSet *protos.FPCKVSet, hash HashFunction) ([]byte, error) {
+	encodedRwSet, err := EncodeRwSet(fpcKVSet)
+	if err != nil {
+		return nil, err
+	}
+	return hash(encodedRwSet)
+}
+
+type encodedRead struct {
+	key, hash []byte
+}
+
+type encodedWrite struct {
+	key      []byte
+	isDelete bool
+	value    []byte
+}
+
+// EncodeRwSet returns the canonical encoding of an FPC rwset. Unlike the protobuf serialization, the encoding does
+// not depend on the order of the reads and writes or on the encoder; it is defined as follows, where all integers
+// are big-endian uint32 and each byte string is prefixed with its length:
+//
+//	"FPC-RWSET-V1" | hash_algorithm | #reads | (key | read value hash)* | #writes | (key | is_delete byte | value)*
+//
+// Reads are sorted by key and hash, writes by key, deletion flag and value. The read value hash of a read is the
+// element of read_value_hashes at the same position, or empty if the rwset has none. Range queries and metadata
+// writes are not used by FPC and rejected, as are read versions.
+func EncodeRwSet(fpcKVSet *protos.FPCKVSet) ([]byte, error) {
+	rwset := fpcKVSet.GetRwSet()
+	if len(rwset.GetRangeQueriesInfo()) > 0 || len(rwset.GetMetadataWrites()) > 0 {
+		return nil, fmt.Errorf("rwset contains range queries or metadata writes")
+	}
+
+	readValueHashes := fpcKVSet.GetReadValueHashes()
+	if len(readValueHashes) > 0 && len(readValueHashes) != len(rwset.GetReads()) {
+		return nil, fmt.Errorf("rwset contains %d reads but %d read value hashes", len(rwset.GetReads()), len(readValueHashes))
+	}
+
+	reads := make([]encodedRead, len(rwset.GetReads()))
+	for i, r := range rwset.GetReads() {
+		if r.GetVersion() != nil {
+			return nil, fmt.Errorf("read of key '%s' has a version", r.GetKey())
+		}
+		reads[i].key = []byte(r.GetKey())
+		if len(readValueHashes) > 0 {
+			reads[i].hash = readValueHashes[i]
+		}
+	}
+	sort.Slice(reads, func(i, j int) bool {
+		if c := bytes.Compare(reads[i].key, reads[j].key); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(reads[i].hash, reads[j].hash) < 0
+	})
+
+	writes := make([]encodedWrite, len(rwset.GetWrites()))
+	for i, w := range rwset.GetWrites() {
+		writes[i] = encodedWrite{key: []byte(w.GetKey()), isDelete: w.GetIsDelete(), value: w.GetValue()}
+	}
+	sort.Slice(writes, func(i, j int) bool {
+		if c := bytes.Compare(writes[i].key, writes[j].key); c != 0 {
+			return c < 0
+		}
+		if writes[i].isDelete != writes[j].isDelete {
+			return !writes[i].isDelete
+		}
+		return bytes.Compare(writes[i].value, writes[j].value) < 0
+	})
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(rwSetDigestDomain)
+	writeUint32(buf, uint32(fpcKVSet.GetHashAlgorithm()))
+
+	writeUint32(buf, uint32(len(reads)))
+	for _, r := range reads {
+		writeBytes(buf, r.key)
+		writeBytes(buf, r.hash)
+	}
+
+	writeUint32(buf, uint32(len(writes)))
+	for _, w := range writes {
+		writeBytes(buf, w.key)
+		if w.isDelete {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		writeBytes(buf, w.value)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeUint32(buf *bytes.Buffer, n uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], n)
+	buf.Write(b[:])
+}
+
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	writeUint32(buf, uint32(len(b)))
+	buf.Write(b)
+}
@@ -0,0 +1,133 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RwSet digest", func() {
+
+	sha256Hash := func(message []byte) ([]byte, error) {
+		h := sha256.Sum256(message)
+		return h[:], nil
+	}
+
+	newRwSet := func(value string) *protos.FPCKVSet {
+		return &protos.FPCKVSet{
+			RwSet: &kvrwset.KVRWSet{
+				Reads:  []*kvrwset.KVRead{{Key: "a"}, {Key: "b"}},
+				Writes: []*kvrwset.KVWrite{{Key: "c", Value: []byte(value)}, {Key: "d", IsDelete: true}},
+			},
+			ReadValueHashes: [][]byte{[]byte("hash a"), []byte("hash b")},
+		}
+	}
+
+	Context("GetRwSetDigest", func() {
+
+		It("should return the same digest for the same rwset", func() {
+			digest, err := GetRwSetDigest(newRwSet("value"), sha256Hash)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(digest).To(HaveLen(32))
+
+			otherDigest, err := GetRwSetDigest(newRwSet("value"), sha256Hash)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(otherDigest).To(Equal(digest))
+		})
+
+		It("should return different digests for different rwsets", func() {
+			digest, err := GetRwSetDigest(newRwSet("value"), sha256Hash)
+			Expect(err).NotTo(HaveOccurred())
+
+			otherDigest, err := GetRwSetDigest(newRwSet("other value"), sha256Hash)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(otherDigest).NotTo(Equal(digest))
+
+			rwset := newRwSet("value")
+			rwset.HashAlgorithm = protos.HashAlgorithm_HASH_ALGORITHM_SHA384
+			otherDigest, err = GetRwSetDigest(rwset, sha256Hash)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(otherDigest).NotTo(Equal(digest))
+		})
+	})
+
+	Context("EncodeRwSet", func() {
+
+		It("should follow the canonical encoding", func() {
+			encoded, err := EncodeRwSet(newRwSet("v"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hex.EncodeToString(encoded)).To(Equal(hex.EncodeToString([]byte("FPC-RWSET-V1")) +
+				"00000000" + // hash algorithm
+				"00000002" + // reads
+				"00000001" + "61" + "00000006" + hex.EncodeToString([]byte("hash a")) +
+				"00000001" + "62" + "00000006" + hex.EncodeToString([]byte("hash b")) +
+				"00000002" + // writes
+				"00000001" + "63" + "00" + "00000001" + "76" +
+				"00000001" + "64" + "01" + "00000000"))
+		})
+
+		It("should not depend on the order of reads and writes", func() {
+			encoded, err := EncodeRwSet(newRwSet("value"))
+			Expect(err).NotTo(HaveOccurred())
+
+			reordered := newRwSet("value")
+			rw := reordered.RwSet
+			rw.Reads[0], rw.Reads[1] = rw.Reads[1], rw.Reads[0]
+			reordered.ReadValueHashes[0], reordered.ReadValueHashes[1] = reordered.ReadValueHashes[1], reordered.ReadValueHashes[0]
+			rw.Writes[0], rw.Writes[1] = rw.Writes[1], rw.Writes[0]
+
+			otherEncoded, err := EncodeRwSet(reordered)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(otherEncoded).To(Equal(encoded))
+		})
+
+		It("should bind read value hashes to their keys", func() {
+			encoded, err := EncodeRwSet(newRwSet("value"))
+			Expect(err).NotTo(HaveOccurred())
+
+			swapped := newRwSet("value")
+			swapped.ReadValueHashes[0], swapped.ReadValueHashes[1] = swapped.ReadValueHashes[1], swapped.ReadValueHashes[0]
+			otherEncoded, err := EncodeRwSet(swapped)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(otherEncoded).NotTo(Equal(encoded))
+		})
+
+		It("should not be ambiguous about field boundaries", func() {
+			rwset := &protos.FPCKVSet{RwSet: &kvrwset.KVRWSet{Writes: []*kvrwset.KVWrite{{Key: "ab", Value: []byte("c")}}}}
+			otherRwSet := &protos.FPCKVSet{RwSet: &kvrwset.KVRWSet{Writes: []*kvrwset.KVWrite{{Key: "a", Value: []byte("bc")}}}}
+
+			encoded, err := EncodeRwSet(rwset)
+			Expect(err).NotTo(HaveOccurred())
+			otherEncoded, err := EncodeRwSet(otherRwSet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(otherEncoded).NotTo(Equal(encoded))
+		})
+
+		It("should reject rwsets without a canonical encoding", func() {
+			rwset := newRwSet("value")
+			rwset.ReadValueHashes = rwset.ReadValueHashes[:1]
+			_, err := EncodeRwSet(rwset)
+			Expect(err).To(MatchError("rwset contains 2 reads but 1 read value hashes"))
+
+			rwset = newRwSet("value")
+			rwset.RwSet.Reads[0].Version = &kvrwset.Version{BlockNum: 1}
+			_, err = EncodeRwSet(rwset)
+			Expect(err).To(MatchError("read of key 'a' has a version"))
+
+			rwset = newRwSet("value")
+			rwset.RwSet.MetadataWrites = []*kvrwset.KVMetadataWrite{{Key: "a"}}
+			_, err = EncodeRwSet(rwset)
+			Expect(err).To(MatchError("rwset contains range queries or metadata writes"))
+		})
+	})
+})
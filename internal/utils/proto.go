@@ -127,6 +127,27 @@ func UnmarshalChaincodeResponseMessage(data []byte) (*protos.ChaincodeResponseMe
 	return msg, nil
 }
 
+// UnmarshalChaincodeResponseMessages decodes the base64-encoded signed chaincode response message returned by
+// __invoke and returns it together with the enclosed chaincode response message
+func UnmarshalChaincodeResponseMessages(responseBase64 string) (*protos.SignedChaincodeResponseMessage, *protos.ChaincodeResponseMessage, error) {
+	serializedSignedResponseMsg, err := base64.StdEncoding.DecodeString(responseBase64)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signedResponseMsg, err := UnmarshalSignedChaincodeResponseMessage(serializedSignedResponseMsg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	responseMsg, err := UnmarshalChaincodeResponseMessage(signedResponseMsg.GetChaincodeResponseMessage())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return signedResponseMsg, responseMsg, nil
+}
+
 // GetEnclaveId returns enclave_id as hex-encoded string of SHA256 hash over enclave_vk.
 func GetEnclaveId(attestedData *protos.AttestedData) string {
 	// hash enclave vk
@@ -135,6 +156,17 @@ func GetEnclaveId(attestedData *protos.AttestedData) string {
 	return strings.ToUpper(hex.EncodeToString(h[:]))
 }
 
+// GetCCParamsHash returns the SHA256 hash over the (deterministically) serialized chaincode parameters.
+// The hash defines the context of key registration and export messages.
+func GetCCParamsHash(ccParams *protos.CCParameters) ([]byte, error) {
+	serializedCCParams, err := proto.MarshalOptions{Deterministic: true}.Marshal(ccParams)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(serializedCCParams)
+	return h[:], nil
+}
+
 func ExtractEndpoint(credentials *protos.Credentials) (string, error) {
 	attestedData := &protos.AttestedData{}
 	err := credentials.SerializedAttestedData.UnmarshalTo(attestedData)
@@ -0,0 +1,334 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tlcc
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	//lint:ignore SA1019 the fabric protos are generated with the v1 API
+	protoV1 "github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/bccsp/factory"
+	fabricmsp "github.com/hyperledger/fabric/msp"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	ordererGroupKey     = "Orderer"
+	applicationGroupKey = "Application"
+	mspKey              = "MSP"
+)
+
+// channelConfig contains the parts of a channel config which TLCC uses to verify blocks and transactions
+type channelConfig struct {
+	orderers *ordererPolicy
+	// msps are the MSPs of the application organizations, which issue the identities of the endorsers
+	msps fabricmsp.MSPManager
+}
+
+// ordererPolicy accepts a block if it is signed by at least minSignatures distinct orderers,
+// i.e., valid identities with the orderer OU of the MSP of an orderer organization of the channel
+type ordererPolicy struct {
+	// msps are the MSPs of the orderer organizations by MSP ID
+	msps          map[string]fabricmsp.MSP
+	minSignatures int
+}
+
+// EvaluateSignedData implements the policy interface of protoutil.BlockSignatureVerifier
+func (p *ordererPolicy) EvaluateSignedData(signatureSet []*protoutil.SignedData) error {
+	signers := make(map[string]bool)
+	for _, sd := range signatureSet {
+		id, err := p.verify(sd)
+		if err != nil {
+			logger.Debugf("ignoring invalid orderer signature: %s", err)
+			continue
+		}
+		signers[id] = true
+	}
+
+	if len(signers) < p.minSignatures {
+		return fmt.Errorf("block is signed by %d orderers, %d required", len(signers), p.minSignatures)
+	}
+	return nil
+}
+
+// verify checks a signature and returns the orderer who signed it. An orderer may sign with several
+// certificates, e.g., after a renewal, thus, it is identified by the subject of its certificate.
+func (p *ordererPolicy) verify(sd *protoutil.SignedData) (string, error) {
+	sId, err := protoutil.UnmarshalSerializedIdentity(sd.Identity)
+	if err != nil {
+		return "", err
+	}
+
+	m, ok := p.msps[sId.GetMspid()]
+	if !ok {
+		return "", fmt.Errorf("%s is not an orderer organization", sId.GetMspid())
+	}
+	id, err := m.DeserializeIdentity(sd.Identity)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid identity of %s", sId.GetMspid())
+	}
+
+	// the MSP checks that the identity is issued by its CAs, not revoked by its CRLs, and has the orderer OU
+	principal := &msp.MSPPrincipal{
+		PrincipalClassification: msp.MSPPrincipal_ROLE,
+		Principal:               protoutil.MarshalOrPanic(&msp.MSPRole{MspIdentifier: sId.GetMspid(), Role: msp.MSPRole_ORDERER}),
+	}
+	if err := id.SatisfiesPrincipal(principal); err != nil {
+		return "", errors.Wrapf(err, "identity is not an orderer of %s", sId.GetMspid())
+	}
+	if err := id.Verify(sd.Data, sd.Signature); err != nil {
+		return "", errors.Wrapf(err, "invalid signature of %s", sId.GetMspid())
+	}
+
+	block, _ := pem.Decode(sId.GetIdBytes())
+	if block == nil {
+		return "", fmt.Errorf("invalid identity of %s", sId.GetMspid())
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	return sId.GetMspid() + "/" + cert.Subject.String(), nil
+}
+
+// parseConfigBlock returns the channel ID and the channel config of a config block
+func parseConfigBlock(block *common.Block) (string, *channelConfig, error) {
+	if len(block.GetData().GetData()) != 1 {
+		return "", nil, fmt.Errorf("config block must contain a single transaction")
+	}
+
+	env, err := protoutil.UnmarshalEnvelope(block.GetData().GetData()[0])
+	if err != nil {
+		return "", nil, err
+	}
+	payload, err := protoutil.UnmarshalPayload(env.GetPayload())
+	if err != nil {
+		return "", nil, err
+	}
+	channelHeader, err := protoutil.UnmarshalChannelHeader(payload.GetHeader().GetChannelHeader())
+	if err != nil {
+		return "", nil, err
+	}
+	if common.HeaderType(channelHeader.GetType()) != common.HeaderType_CONFIG {
+		return "", nil, fmt.Errorf("block is not a config block")
+	}
+
+	config, err := parseConfig(payload.GetData())
+	if err != nil {
+		return "", nil, err
+	}
+	return channelHeader.GetChannelId(), config, nil
+}
+
+// parseConfig returns the channel config of a serialized config envelope
+func parseConfig(configEnvelopeBytes []byte) (*channelConfig, error) {
+	configEnvelope, err := protoutil.UnmarshalConfigEnvelope(configEnvelopeBytes)
+	if err != nil {
+		return nil, err
+	}
+	groups := configEnvelope.GetConfig().GetChannelGroup().GetGroups()
+
+	orderers, err := parseOrderers(groups)
+	if err != nil {
+		return nil, err
+	}
+	msps, err := parseApplicationMSPs(groups)
+	if err != nil {
+		return nil, err
+	}
+	return &channelConfig{orderers: orderers, msps: msps}, nil
+}
+
+// parseOrderers returns the orderer organizations of the groups of a channel config
+func parseOrderers(groups map[string]*common.ConfigGroup) (*ordererPolicy, error) {
+	ordererGroup, ok := groups[ordererGroupKey]
+	if !ok {
+		return nil, fmt.Errorf("no orderer group in config")
+	}
+
+	policy := &ordererPolicy{msps: make(map[string]fabricmsp.MSP), minSignatures: 1}
+	for name, orgGroup := range ordererGroup.GetGroups() {
+		mspValue, ok := orgGroup.GetValues()[mspKey]
+		if !ok {
+			return nil, fmt.Errorf("no msp for orderer organization %s", name)
+		}
+
+		mspConfig := &msp.MSPConfig{}
+		if err := protoV1.Unmarshal(mspValue.GetValue(), mspConfig); err != nil {
+			return nil, errors.Wrapf(err, "invalid msp of orderer organization %s", name)
+		}
+		fabricMSPConfig := &msp.FabricMSPConfig{}
+		if err := protoV1.Unmarshal(mspConfig.GetConfig(), fabricMSPConfig); err != nil {
+			return nil, errors.Wrapf(err, "invalid msp of orderer organization %s", name)
+		}
+		// without NodeOUs, the MSP cannot tell the orderers apart from the other members of the organization
+		nodeOUs := fabricMSPConfig.GetFabricNodeOus()
+		if !nodeOUs.GetEnable() || nodeOUs.GetOrdererOuIdentifier() == nil {
+			return nil, fmt.Errorf("msp of orderer organization %s does not define an orderer OU", name)
+		}
+
+		m, err := newMSP(mspConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid msp of orderer organization %s", name)
+		}
+		policy.msps[fabricMSPConfig.GetName()] = m
+	}
+
+	if len(policy.msps) == 0 {
+		return nil, fmt.Errorf("no orderer organizations in config")
+	}
+	return policy, nil
+}
+
+// parseApplicationMSPs returns the MSPs of the application organizations of the groups of a channel config.
+// The config of a system channel has no application group, thus, the MSP manager is empty.
+func parseApplicationMSPs(groups map[string]*common.ConfigGroup) (fabricmsp.MSPManager, error) {
+	var msps []fabricmsp.MSP
+	for name, orgGroup := range groups[applicationGroupKey].GetGroups() {
+		mspValue, ok := orgGroup.GetValues()[mspKey]
+		if !ok {
+			return nil, fmt.Errorf("no msp for application organization %s", name)
+		}
+
+		mspConfig := &msp.MSPConfig{}
+		if err := protoV1.Unmarshal(mspValue.GetValue(), mspConfig); err != nil {
+			return nil, errors.Wrapf(err, "invalid msp of application organization %s", name)
+		}
+		m, err := newMSP(mspConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid msp of application organization %s", name)
+		}
+		msps = append(msps, m)
+	}
+
+	manager := fabricmsp.NewMSPManager()
+	if err := manager.Setup(msps); err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+// newMSP returns the fabric MSP of an MSP config
+func newMSP(mspConfig *msp.MSPConfig) (fabricmsp.MSP, error) {
+	m, err := fabricmsp.New(&fabricmsp.BCCSPNewOpts{NewBaseOpts: fabricmsp.NewBaseOpts{Version: fabricmsp.MSPv1_4_3}}, factory.GetDefault())
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Setup(mspConfig); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// transaction contains the actions of an endorser transaction
+type transaction struct {
+	actions []*txAction
+}
+
+type txAction struct {
+	prpBytes     []byte
+	endorsements []*peer.Endorsement
+	rwsets       []*namespaceRwSet
+}
+
+type namespaceRwSet struct {
+	namespace string
+	rwset     *kvrwset.KVRWSet
+}
+
+// parseTransaction returns the actions of an endorser transaction with their public rwsets
+func parseTransaction(txBytes []byte) (*transaction, error) {
+	tx, err := protoutil.UnmarshalTransaction(txBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &transaction{}
+	for _, action := range tx.GetActions() {
+		actionPayload, err := protoutil.UnmarshalChaincodeActionPayload(action.GetPayload())
+		if err != nil {
+			return nil, err
+		}
+		prpBytes := actionPayload.GetAction().GetProposalResponsePayload()
+		prp, err := protoutil.UnmarshalProposalResponsePayload(prpBytes)
+		if err != nil {
+			return nil, err
+		}
+		ccAction, err := protoutil.UnmarshalChaincodeAction(prp.GetExtension())
+		if err != nil {
+			return nil, err
+		}
+		txRWSet, err := protoutil.UnmarshalTxReadWriteSet(ccAction.GetResults())
+		if err != nil {
+			return nil, err
+		}
+
+		a := &txAction{prpBytes: prpBytes, endorsements: actionPayload.GetAction().GetEndorsements()}
+		for _, nsRWSet := range txRWSet.GetNsRwset() {
+			kvRWSet, err := protoutil.UnmarshalKVRWSet(nsRWSet.GetRwset())
+			if err != nil {
+				return nil, err
+			}
+			a.rwsets = append(a.rwsets, &namespaceRwSet{namespace: nsRWSet.GetNamespace(), rwset: kvRWSet})
+		}
+		t.actions = append(t.actions, a)
+	}
+	return t, nil
+}
+
+// signedData returns the endorsements of an action as signed data for the evaluation of an endorsement policy
+func (a *txAction) signedData() []*protoutil.SignedData {
+	signatureSet := make([]*protoutil.SignedData, 0, len(a.endorsements))
+	for _, e := range a.endorsements {
+		data := make([]byte, 0, len(a.prpBytes)+len(e.GetEndorser()))
+		data = append(data, a.prpBytes...)
+		data = append(data, e.GetEndorser()...)
+		signatureSet = append(signatureSet, &protoutil.SignedData{
+			Data:      data,
+			Identity:  e.GetEndorser(),
+			Signature: e.GetSignature(),
+		})
+	}
+	return signatureSet
+}
+
+type namespaceWrites struct {
+	namespace string
+	writes    []*kvrwset.KVWrite
+	// valueHashes[i] is the hash of the value of writes[i], or nil if writes[i] is a delete
+	valueHashes [][]byte
+}
+
+// writes returns the public writes of a transaction and the hashes of the written values
+func (t *transaction) writes(hash utils.HashFunction) ([]*namespaceWrites, error) {
+	var writes []*namespaceWrites
+	for _, a := range t.actions {
+		for _, ns := range a.rwsets {
+			w := &namespaceWrites{namespace: ns.namespace, writes: ns.rwset.GetWrites()}
+			for _, kv := range ns.rwset.GetWrites() {
+				var h []byte
+				if !kv.GetIsDelete() {
+					var err error
+					if h, err = hash(kv.GetValue()); err != nil {
+						return nil, err
+					}
+				}
+				w.valueHashes = append(w.valueHashes, h)
+			}
+			writes = append(writes, w)
+		}
+	}
+	return writes, nil
+}
@@ -0,0 +1,272 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tlcc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/session"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// upgradePolicyObjectType is the object type of the composite keys of the upgrade policies in ERCC
+const upgradePolicyObjectType = "namespaces/upgrade_policy"
+
+// escrow is the state key of a chaincode, which the enclave of the current chaincode version handed over for the
+// enclave of an upgraded version
+type escrow struct {
+	stateKey        []byte
+	upgradePolicy   []byte
+	allowedVersions []string
+	senderEnclaveId string
+}
+
+// Handle answers a serialized trusted ledger request received through a session with an enclave, i.e., it is the
+// session.Handler of TLCC. In addition to the requests answered by Request, it handles the key escrow of enclave
+// upgrades, which relies on the attested data of the enclave as verified by the session, e.g., with
+// session.ChaincodeCredentials. Note that escrowed keys are kept in memory; unless they are persisted with an escrow
+// store (see WithEscrowStore), they are lost if TLCC restarts.
+func (l *TrustedLedger) Handle(s *session.Session, requestBytes []byte) ([]byte, error) {
+	request := &protos.Request{}
+	if err := proto.Unmarshal(requestBytes, request); err != nil {
+		return nil, errors.Wrap(err, "invalid trusted ledger request")
+	}
+
+	response := &protos.Response{}
+	switch r := request.GetRequest().(type) {
+	case *protos.Request_EscrowKeys:
+		if err := l.escrowKeys(s.Peer(), r.EscrowKeys); err != nil {
+			return nil, errors.Wrap(err, "key escrow failed")
+		}
+		response.Response = &protos.Response_EscrowKeys{EscrowKeys: &protos.EscrowKeysResponse{}}
+
+	case *protos.Request_ReleaseKeys:
+		released, err := l.releaseKeys(s.Peer(), r.ReleaseKeys)
+		if err != nil {
+			return nil, errors.Wrap(err, "key release failed")
+		}
+		response.Response = &protos.Response_ReleaseKeys{ReleaseKeys: released}
+
+	default:
+		return l.Request(requestBytes)
+	}
+
+	return proto.Marshal(response)
+}
+
+// escrowKeys keeps the state key of the chaincode of the sender, which must be the registered enclave of the
+// chaincode. The upgrade policy must be the policy set in ERCC; the key is only released to the versions it allows.
+func (l *TrustedLedger) escrowKeys(sender *protos.AttestedData, request *protos.EscrowKeysRequest) error {
+	if err := l.checkChaincode(sender, request.GetChaincodeId()); err != nil {
+		return err
+	}
+	if len(request.GetStateKey()) == 0 {
+		return fmt.Errorf("state key is empty")
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	senderEnclaveId := utils.GetEnclaveId(sender)
+	if !l.registered(request.GetChaincodeId(), senderEnclaveId) {
+		return fmt.Errorf("enclave %s is not registered", senderEnclaveId)
+	}
+
+	upgradePolicy, err := l.upgradePolicy(request.GetChaincodeId(), request.GetUpgradePolicy())
+	if err != nil {
+		return err
+	}
+
+	e := &escrow{
+		stateKey:        request.GetStateKey(),
+		upgradePolicy:   request.GetUpgradePolicy(),
+		allowedVersions: upgradePolicy.GetAllowedVersions(),
+		senderEnclaveId: senderEnclaveId,
+	}
+
+	// the escrow is persisted before it is acknowledged, as the sender may be gone once the upgrade is committed
+	if l.escrowStore != nil {
+		sealedEscrow, err := l.sealEscrow(request.GetChaincodeId(), e)
+		if err != nil {
+			return err
+		}
+		if err := l.escrowStore.Put(l.channelId, request.GetChaincodeId(), sealedEscrow); err != nil {
+			return errors.Wrap(err, "cannot persist escrow")
+		}
+	}
+
+	// a later escrow replaces the previous one, e.g., if the upgrade policy changed
+	l.escrows[request.GetChaincodeId()] = e
+	logger.Infof("escrowed the keys of chaincode %s from enclave %s", request.GetChaincodeId(), senderEnclaveId)
+	return nil
+}
+
+// releaseKeys returns the escrowed state key to the receiver, which must be a registered enclave of a chaincode version
+// allowed by the upgrade policy, which must still be set in ERCC. The escrow is kept, so that the release can be
+// repeated if the response is lost.
+func (l *TrustedLedger) releaseKeys(receiver *protos.AttestedData, request *protos.ReleaseKeysRequest) (*protos.ReleaseKeysResponse, error) {
+	if err := l.checkChaincode(receiver, request.GetChaincodeId()); err != nil {
+		return nil, err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	e, err := l.loadEscrow(request.GetChaincodeId())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := l.upgradePolicy(request.GetChaincodeId(), e.upgradePolicy); err != nil {
+		return nil, err
+	}
+
+	version := receiver.GetCcParams().GetVersion()
+	allowed := false
+	for _, v := range e.allowedVersions {
+		allowed = allowed || v == version
+	}
+	if !allowed {
+		return nil, fmt.Errorf("chaincode version %s is not allowed by upgrade policy", version)
+	}
+
+	receiverEnclaveId := utils.GetEnclaveId(receiver)
+	if receiverEnclaveId == e.senderEnclaveId {
+		return nil, fmt.Errorf("enclave %s escrowed the keys", receiverEnclaveId)
+	}
+	if !l.registered(request.GetChaincodeId(), receiverEnclaveId) {
+		return nil, fmt.Errorf("enclave %s is not registered", receiverEnclaveId)
+	}
+
+	logger.Infof("released the keys of chaincode %s to enclave %s", request.GetChaincodeId(), receiverEnclaveId)
+	return &protos.ReleaseKeysResponse{StateKey: e.stateKey, SenderEnclaveId: e.senderEnclaveId}, nil
+}
+
+// loadEscrow returns the escrow of the chaincode, which is loaded from the escrow store if TLCC restarted since the escrow
+func (l *TrustedLedger) loadEscrow(chaincodeId string) (*escrow, error) {
+	if e, ok := l.escrows[chaincodeId]; ok {
+		return e, nil
+	}
+
+	if l.escrowStore != nil {
+		sealedEscrow, err := l.escrowStore.Get(l.channelId, chaincodeId)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load escrow")
+		}
+		if sealedEscrow != nil {
+			e, err := l.unsealEscrow(chaincodeId, sealedEscrow)
+			if err != nil {
+				return nil, err
+			}
+			l.escrows[chaincodeId] = e
+			return e, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no keys escrowed for chaincode %s", chaincodeId)
+}
+
+// sealEscrow returns the escrow encrypted with the sealing key of TLCC
+func (l *TrustedLedger) sealEscrow(chaincodeId string, e *escrow) ([]byte, error) {
+	keyEscrowBytes, err := proto.Marshal(&protos.KeyEscrow{
+		ChannelId:       l.channelId,
+		ChaincodeId:     chaincodeId,
+		UpgradePolicy:   e.upgradePolicy,
+		StateKey:        e.stateKey,
+		SenderEnclaveId: e.senderEnclaveId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sealedEscrow, err := l.csp.EncryptMessage(l.sealingKey, keyEscrowBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot seal escrow")
+	}
+	return sealedEscrow, nil
+}
+
+// unsealEscrow returns the escrow of the chaincode sealed with sealEscrow
+func (l *TrustedLedger) unsealEscrow(chaincodeId string, sealedEscrow []byte) (*escrow, error) {
+	keyEscrowBytes, err := l.csp.DecryptMessage(l.sealingKey, sealedEscrow)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot unseal escrow")
+	}
+	keyEscrow := &protos.KeyEscrow{}
+	if err := proto.Unmarshal(keyEscrowBytes, keyEscrow); err != nil {
+		return nil, errors.Wrap(err, "invalid escrow")
+	}
+	if keyEscrow.GetChannelId() != l.channelId || keyEscrow.GetChaincodeId() != chaincodeId {
+		return nil, fmt.Errorf("escrow is for chaincode %s on channel %s", keyEscrow.GetChaincodeId(), keyEscrow.GetChannelId())
+	}
+
+	upgradePolicy, err := parseUpgradePolicy(chaincodeId, keyEscrow.GetUpgradePolicy())
+	if err != nil {
+		return nil, err
+	}
+	return &escrow{
+		stateKey:        keyEscrow.GetStateKey(),
+		upgradePolicy:   keyEscrow.GetUpgradePolicy(),
+		allowedVersions: upgradePolicy.GetAllowedVersions(),
+		senderEnclaveId: keyEscrow.GetSenderEnclaveId(),
+	}, nil
+}
+
+// checkChaincode checks that the session peer is an enclave of the given chaincode on the channel of the ledger
+func (l *TrustedLedger) checkChaincode(peer *protos.AttestedData, chaincodeId string) error {
+	if peer.GetCcParams().GetChannelId() != l.channelId {
+		return fmt.Errorf("enclave is attested for channel %s", peer.GetCcParams().GetChannelId())
+	}
+	if chaincodeId == "" || peer.GetCcParams().GetChaincodeId() != chaincodeId {
+		return fmt.Errorf("enclave is attested for chaincode %s", peer.GetCcParams().GetChaincodeId())
+	}
+	return nil
+}
+
+// registered returns whether the credentials of an enclave are registered in ERCC
+func (l *TrustedLedger) registered(chaincodeId, enclaveId string) bool {
+	entry := l.state[ErccNamespace][compositeKey(credentialsObjectType, chaincodeId, enclaveId)]
+	return entry != nil && entry.hash != nil
+}
+
+// upgradePolicy returns the given upgrade policy if it is the policy set in ERCC for the chaincode
+func (l *TrustedLedger) upgradePolicy(chaincodeId string, upgradePolicyBase64 []byte) (*protos.UpgradePolicy, error) {
+	entry := l.state[ErccNamespace][compositeKey(upgradePolicyObjectType, chaincodeId)]
+	if entry == nil || entry.hash == nil {
+		return nil, fmt.Errorf("no upgrade policy set for chaincode %s", chaincodeId)
+	}
+
+	hash, err := l.csp.Hash(crypto.DefaultHashAlgorithm, upgradePolicyBase64)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hash, entry.hash) {
+		return nil, fmt.Errorf("upgrade policy does not match the policy set for chaincode %s", chaincodeId)
+	}
+	return parseUpgradePolicy(chaincodeId, upgradePolicyBase64)
+}
+
+// parseUpgradePolicy returns the upgrade policy of the chaincode as stored by ERCC
+func parseUpgradePolicy(chaincodeId string, upgradePolicyBase64 []byte) (*protos.UpgradePolicy, error) {
+	upgradePolicyBytes, err := base64.StdEncoding.DecodeString(string(upgradePolicyBase64))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid upgrade policy bytes")
+	}
+	upgradePolicy := &protos.UpgradePolicy{}
+	if err := proto.Unmarshal(upgradePolicyBytes, upgradePolicy); err != nil {
+		return nil, errors.Wrap(err, "invalid upgrade policy message")
+	}
+	if upgradePolicy.GetChaincodeId() != chaincodeId {
+		return nil, fmt.Errorf("upgrade policy is for chaincode %s", upgradePolicy.GetChaincodeId())
+	}
+	return upgradePolicy, nil
+}
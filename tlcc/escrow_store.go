@@ -0,0 +1,80 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tlcc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// EscrowStore persists the sealed key escrows of TLCC (see WithEscrowStore), so that an enclave upgrade can be
+// completed after TLCC restarted
+type EscrowStore interface {
+	// Get returns the sealed escrow of the chaincode or nil if there is none
+	Get(channelId, chaincodeId string) ([]byte, error)
+	// Put stores the sealed escrow of the chaincode, replacing a previous escrow
+	Put(channelId, chaincodeId string, sealedEscrow []byte) error
+}
+
+// FileEscrowStore is an EscrowStore keeping the sealed escrows as files in a local directory
+type FileEscrowStore struct {
+	dir string
+}
+
+// NewFileEscrowStore returns a FileEscrowStore using the given directory, which is created if it does not exist
+func NewFileEscrowStore(dir string) (*FileEscrowStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "cannot create escrow store directory")
+	}
+	return &FileEscrowStore{dir: dir}, nil
+}
+
+func (s *FileEscrowStore) Get(channelId, chaincodeId string) ([]byte, error) {
+	sealedEscrow, err := os.ReadFile(s.path(channelId, chaincodeId))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return sealedEscrow, err
+}
+
+// Put stores the sealed escrow; the file is replaced atomically so that a crash never leaves a partial escrow behind
+func (s *FileEscrowStore) Put(channelId, chaincodeId string, sealedEscrow []byte) error {
+	tmp, err := os.CreateTemp(s.dir, "escrow-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(sealedEscrow); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(channelId, chaincodeId))
+}
+
+// path returns the file for the given chaincode; the name is derived from a hash as
+// channel ids and chaincode ids are not necessarily valid file names
+func (s *FileEscrowStore) path(channelId, chaincodeId string) string {
+	h := sha256.New()
+	for _, part := range []string{channelId, chaincodeId} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return filepath.Join(s.dir, hex.EncodeToString(h.Sum(nil))+".escrow")
+}
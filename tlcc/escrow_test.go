@@ -0,0 +1,285 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tlcc
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-private-chaincode/internal/session"
+	"github.com/hyperledger/fabric-private-chaincode/internal/utils"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+type testSigner struct {
+	csp        crypto.CSP
+	privateKey []byte
+}
+
+func (s *testSigner) Sign(msg []byte) ([]byte, error) {
+	return s.csp.SignMessage(s.privateKey, msg)
+}
+
+// testEnclave is an enclave with a session to TLCC; its credentials are accepted without attestation evidence
+type testEnclave struct {
+	attestedData *protos.AttestedData
+	client       *session.Client
+}
+
+func newTestEnclave(t *testing.T, responder *session.Responder, chaincodeId, version string) *testEnclave {
+	csp := crypto.GetDefaultCSP()
+	publicKey, privateKey, err := csp.NewECDSAKeys()
+	require.NoError(t, err)
+
+	attestedData := &protos.AttestedData{
+		CcParams:  &protos.CCParameters{ChannelId: testChannel, ChaincodeId: chaincodeId, Version: version},
+		EnclaveVk: publicKey,
+	}
+	serializedAttestedData, err := anypb.New(attestedData)
+	require.NoError(t, err)
+	identity := &session.Identity{
+		Credentials: &protos.Credentials{SerializedAttestedData: serializedAttestedData},
+		Signer:      &testSigner{csp: csp, privateKey: privateKey},
+	}
+
+	client, err := session.Dial(csp, identity, acceptAll, responder.HandleMessage, testChannel, chaincodeId, utils.GetEnclaveId(attestedData))
+	require.NoError(t, err)
+	return &testEnclave{attestedData: attestedData, client: client}
+}
+
+func (e *testEnclave) id() string {
+	return utils.GetEnclaveId(e.attestedData)
+}
+
+func (e *testEnclave) request(t *testing.T, request *protos.Request) (*protos.Response, error) {
+	requestBytes, err := proto.Marshal(request)
+	require.NoError(t, err)
+	responseBytes, err := e.client.Request(requestBytes)
+	if err != nil {
+		return nil, err
+	}
+	response := &protos.Response{}
+	require.NoError(t, proto.Unmarshal(responseBytes, response))
+	return response, nil
+}
+
+func (e *testEnclave) escrow(t *testing.T, upgradePolicy []byte, stateKey []byte) error {
+	_, err := e.request(t, &protos.Request{Request: &protos.Request_EscrowKeys{EscrowKeys: &protos.EscrowKeysRequest{
+		ChaincodeId:   e.attestedData.GetCcParams().GetChaincodeId(),
+		UpgradePolicy: upgradePolicy,
+		StateKey:      stateKey,
+	}}})
+	return err
+}
+
+func (e *testEnclave) release(t *testing.T) (*protos.ReleaseKeysResponse, error) {
+	response, err := e.request(t, &protos.Request{Request: &protos.Request_ReleaseKeys{ReleaseKeys: &protos.ReleaseKeysRequest{
+		ChaincodeId: e.attestedData.GetCcParams().GetChaincodeId(),
+	}}})
+	return response.GetReleaseKeys(), err
+}
+
+// acceptAll accepts any credentials without checking attestation evidence
+func acceptAll(credentials *protos.Credentials) (*protos.AttestedData, error) {
+	return utils.UnmarshalAttestedData(credentials.GetSerializedAttestedData())
+}
+
+func registered(enclaves ...*testEnclave) []*kvrwset.KVWrite {
+	var writes []*kvrwset.KVWrite
+	for _, e := range enclaves {
+		key := compositeKey(credentialsObjectType, e.attestedData.GetCcParams().GetChaincodeId(), e.id())
+		writes = append(writes, &kvrwset.KVWrite{Key: key, Value: []byte("credentials")})
+	}
+	return writes
+}
+
+func TestEscrow(t *testing.T) {
+	o := newTestOrderer(t)
+	genesis := o.genesis()
+	l, err := New(genesis)
+	require.NoError(t, err)
+
+	csp := crypto.GetDefaultCSP()
+	tlccPublicKey, tlccKey, err := csp.NewECDSAKeys()
+	require.NoError(t, err)
+	serializedAttestedData, err := anypb.New(&protos.AttestedData{
+		CcParams:  &protos.CCParameters{ChannelId: testChannel, ChaincodeId: "tlcc"},
+		EnclaveVk: tlccPublicKey,
+	})
+	require.NoError(t, err)
+	responder := session.NewResponder(csp, &session.Identity{
+		Credentials: &protos.Credentials{SerializedAttestedData: serializedAttestedData},
+		Signer:      &testSigner{csp: csp, privateKey: tlccKey},
+	}, acceptAll, l.Handle)
+
+	previous := newTestEnclave(t, responder, "cc", "v1")
+	upgraded := newTestEnclave(t, responder, "cc", "v2")
+	notAllowed := newTestEnclave(t, responder, "cc", "v3")
+	otherChaincode := newTestEnclave(t, responder, "othercc", "v2")
+
+	upgradePolicy := []byte(utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: "cc", AllowedVersions: []string{"v1", "v2"}}))
+	otherPolicy := []byte(utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: "cc", AllowedVersions: []string{"v3"}}))
+	stateKey := []byte("state key")
+
+	// the sender must be registered and the policy must be set
+	err = previous.escrow(t, upgradePolicy, stateKey)
+	assert.ErrorContains(t, err, "key escrow failed: enclave "+previous.id()+" is not registered")
+
+	block1 := o.block(t, genesis, [][]byte{
+		endorserTx(ErccNamespace, append(registered(previous, otherChaincode), &kvrwset.KVWrite{
+			Key:   compositeKey(upgradePolicyObjectType, "cc"),
+			Value: upgradePolicy,
+		})...),
+	}, []peer.TxValidationCode{peer.TxValidationCode_VALID})
+	require.NoError(t, l.CommitBlock(block1))
+
+	err = previous.escrow(t, otherPolicy, stateKey)
+	assert.ErrorContains(t, err, "key escrow failed: upgrade policy does not match the policy set for chaincode cc")
+
+	err = otherChaincode.escrow(t, upgradePolicy, stateKey)
+	assert.ErrorContains(t, err, "key escrow failed: no upgrade policy set for chaincode othercc")
+
+	require.NoError(t, previous.escrow(t, upgradePolicy, stateKey))
+
+	// the receiver must be registered
+	_, err = upgraded.release(t)
+	assert.ErrorContains(t, err, "key release failed: enclave "+upgraded.id()+" is not registered")
+
+	block2 := o.block(t, block1, [][]byte{
+		endorserTx(ErccNamespace, registered(upgraded, notAllowed)...),
+	}, []peer.TxValidationCode{peer.TxValidationCode_VALID})
+	require.NoError(t, l.CommitBlock(block2))
+
+	released, err := upgraded.release(t)
+	require.NoError(t, err)
+	assert.Equal(t, stateKey, released.GetStateKey())
+	assert.Equal(t, previous.id(), released.GetSenderEnclaveId())
+
+	// the release can be repeated, e.g., if the response was lost
+	_, err = upgraded.release(t)
+	assert.NoError(t, err)
+
+	// the receiver must be allowed by the policy, must not be the sender and must run the same chaincode
+	_, err = notAllowed.release(t)
+	assert.ErrorContains(t, err, "key release failed: chaincode version v3 is not allowed by upgrade policy")
+
+	_, err = previous.release(t)
+	assert.ErrorContains(t, err, "key release failed: enclave "+previous.id()+" escrowed the keys")
+
+	_, err = otherChaincode.release(t)
+	assert.ErrorContains(t, err, "key release failed: no keys escrowed for chaincode othercc")
+
+	_, err = upgraded.request(t, &protos.Request{Request: &protos.Request_ReleaseKeys{ReleaseKeys: &protos.ReleaseKeysRequest{ChaincodeId: "othercc"}}})
+	assert.ErrorContains(t, err, "key release failed: enclave is attested for chaincode cc")
+
+	// the keys are not released once the policy changed
+	block3 := o.block(t, block2, [][]byte{
+		endorserTx(ErccNamespace, &kvrwset.KVWrite{Key: compositeKey(upgradePolicyObjectType, "cc"), Value: otherPolicy}),
+	}, []peer.TxValidationCode{peer.TxValidationCode_VALID})
+	require.NoError(t, l.CommitBlock(block3))
+
+	_, err = notAllowed.release(t)
+	assert.ErrorContains(t, err, "key release failed: upgrade policy does not match the policy set for chaincode cc")
+
+	// other requests are answered by Request
+	response, err := upgraded.request(t, &protos.Request{
+		Request: &protos.Request_Metadata{Metadata: &protos.GetMetadataRequest{Namespace: ErccNamespace, Key: compositeKey(upgradePolicyObjectType, "cc")}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, valueHash(otherPolicy), response.GetMetadata().GetHash())
+}
+
+func TestEscrowStore(t *testing.T) {
+	o := newTestOrderer(t)
+	genesis := o.genesis()
+	store, err := NewFileEscrowStore(t.TempDir())
+	require.NoError(t, err)
+	sealingKey, err := crypto.GetDefaultCSP().NewSymmetricKey()
+	require.NoError(t, err)
+
+	attestedData := func(version string) *protos.AttestedData {
+		return &protos.AttestedData{
+			CcParams:  &protos.CCParameters{ChannelId: testChannel, ChaincodeId: "cc", Version: version},
+			EnclaveVk: []byte("enclave vk " + version),
+		}
+	}
+	previous, upgraded := attestedData("v1"), attestedData("v2")
+	register := func(enclaves ...*protos.AttestedData) []byte {
+		var writes []*kvrwset.KVWrite
+		for _, e := range enclaves {
+			writes = append(writes, &kvrwset.KVWrite{Key: compositeKey(credentialsObjectType, "cc", utils.GetEnclaveId(e)), Value: []byte("credentials")})
+		}
+		return endorserTx(ErccNamespace, writes...)
+	}
+
+	upgradePolicy := []byte(utils.MarshallProtoBase64(&protos.UpgradePolicy{ChaincodeId: "cc", AllowedVersions: []string{"v2"}}))
+	block1 := o.block(t, genesis, [][]byte{
+		endorserTx(ErccNamespace, &kvrwset.KVWrite{Key: compositeKey(upgradePolicyObjectType, "cc"), Value: upgradePolicy}),
+		register(previous),
+	}, []peer.TxValidationCode{peer.TxValidationCode_VALID, peer.TxValidationCode_VALID})
+	block2 := o.block(t, block1, [][]byte{register(upgraded)}, []peer.TxValidationCode{peer.TxValidationCode_VALID})
+
+	// newLedger returns a trusted ledger which committed the given blocks, as after a restart of TLCC
+	newLedger := func(blocks []*common.Block, options ...Option) *TrustedLedger {
+		l, err := New(genesis, options...)
+		require.NoError(t, err)
+		for _, block := range blocks {
+			require.NoError(t, l.CommitBlock(block))
+		}
+		return l
+	}
+
+	stored, err := store.Get(testChannel, "cc")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	l := newLedger([]*common.Block{block1}, WithEscrowStore(store, sealingKey))
+	require.NoError(t, l.escrowKeys(previous, &protos.EscrowKeysRequest{ChaincodeId: "cc", UpgradePolicy: upgradePolicy, StateKey: []byte("state key")}))
+
+	// the escrow is sealed
+	stored, err = store.Get(testChannel, "cc")
+	require.NoError(t, err)
+	assert.NotContains(t, string(stored), "state key")
+
+	// after a restart, the escrow is released from the store
+	l = newLedger([]*common.Block{block1, block2}, WithEscrowStore(store, sealingKey))
+	released, err := l.releaseKeys(upgraded, &protos.ReleaseKeysRequest{ChaincodeId: "cc"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("state key"), released.GetStateKey())
+	assert.Equal(t, utils.GetEnclaveId(previous), released.GetSenderEnclaveId())
+
+	// the escrow is bound to the sealing key, the channel and the chaincode
+	otherSealingKey, err := crypto.GetDefaultCSP().NewSymmetricKey()
+	require.NoError(t, err)
+	l = newLedger([]*common.Block{block1, block2}, WithEscrowStore(store, otherSealingKey))
+	_, err = l.releaseKeys(upgraded, &protos.ReleaseKeysRequest{ChaincodeId: "cc"})
+	assert.ErrorContains(t, err, "cannot unseal escrow")
+
+	require.NoError(t, store.Put(testChannel, "othercc", stored))
+	other := attestedData("v2")
+	other.CcParams.ChaincodeId = "othercc"
+	_, err = l.releaseKeys(other, &protos.ReleaseKeysRequest{ChaincodeId: "othercc"})
+	assert.ErrorContains(t, err, "cannot unseal escrow")
+	l = newLedger(nil, WithEscrowStore(store, sealingKey))
+	_, err = l.releaseKeys(other, &protos.ReleaseKeysRequest{ChaincodeId: "othercc"})
+	assert.EqualError(t, err, "escrow is for chaincode cc on channel "+testChannel)
+
+	// without the store, the escrow is lost
+	l = newLedger([]*common.Block{block1, block2})
+	_, err = l.releaseKeys(upgraded, &protos.ReleaseKeysRequest{ChaincodeId: "cc"})
+	assert.EqualError(t, err, "no keys escrowed for chaincode cc")
+
+	assert.Panics(t, func() { _, _ = New(genesis, WithEscrowStore(store, []byte("short key"))) })
+	assert.Panics(t, func() { _, _ = New(genesis, WithEscrowStore(nil, sealingKey)) })
+}
@@ -0,0 +1,72 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tlcc
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// credentialsObjectType is the object type of the composite keys of the enclave credentials in ERCC
+const credentialsObjectType = "namespaces/credentials"
+
+var absentHash = make([]byte, 32)
+
+// Request answers a serialized trusted ledger request (see trusted_ledger.proto) and returns the serialized
+// response. Requests with the same tx context are answered from the same ledger view; if a key changed after
+// the first request of a tx context, the request fails and the transaction should be retried.
+// Note that requests must be sent through an authenticated session as the responses are not signed.
+func (l *TrustedLedger) Request(requestBytes []byte) ([]byte, error) {
+	request := &protos.Request{}
+	if err := proto.Unmarshal(requestBytes, request); err != nil {
+		return nil, errors.Wrap(err, "invalid trusted ledger request")
+	}
+
+	// requests change the views, thus, we need the write lock
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	response := &protos.Response{}
+	switch r := request.GetRequest().(type) {
+	case *protos.Request_Metadata:
+		entry, err := l.lookup(request.GetTxContext(), r.Metadata.GetNamespace(), r.Metadata.GetKey())
+		if err != nil {
+			return nil, err
+		}
+		hash := absentHash
+		if entry != nil && entry.hash != nil {
+			hash = entry.hash
+		}
+		response.Response = &protos.Response_Metadata{Metadata: &protos.GetMetadataResponse{Hash: hash}}
+
+	case *protos.Request_CanEndorse:
+		key := compositeKey(credentialsObjectType, r.CanEndorse.GetChaincodeId(), r.CanEndorse.GetEnclaveId())
+		entry, err := l.lookup(request.GetTxContext(), ErccNamespace, key)
+		if err != nil {
+			return nil, err
+		}
+		isValid := entry != nil && entry.hash != nil
+		response.Response = &protos.Response_CanEndorse{CanEndorse: &protos.CanEndorseResponse{IsValid: isValid}}
+
+	default:
+		return nil, fmt.Errorf("unsupported trusted ledger request %T", r)
+	}
+
+	return proto.Marshal(response)
+}
+
+// compositeKey returns the ledger key of a composite key as created by the chaincode shim
+func compositeKey(objectType string, attributes ...string) string {
+	key := "\x00" + objectType + "\x00"
+	for _, attr := range attributes {
+		key += attr + "\x00"
+	}
+	return key
+}
@@ -0,0 +1,484 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tlcc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/fabric-private-chaincode/internal/crypto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/common/cauthdsl"
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/policies"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("tlcc")
+
+const (
+	// ErccNamespace is the namespace of the enclave registry, which TLCC uses to answer CanEndorse requests
+	ErccNamespace = "ercc"
+
+	defaultMaxViews = 1024
+)
+
+// BlockSource provides the committed blocks of a channel in order, e.g., from the deliver service of a peer
+type BlockSource interface {
+	// Next blocks until the next block is available
+	Next(ctx context.Context) (*common.Block, error)
+}
+
+// TrustedLedger maintains a trusted view of the ledger of a single channel. Starting from the genesis block,
+// it verifies that every block extends the hash chain and is signed by the orderers of the channel, and keeps
+// the hashes of all values written by valid transactions. Enclaves query this view through trusted ledger
+// requests (see Request) to check the integrity and freshness of the state returned by the untrusted peer.
+//
+// The validation flags of a block are set by the committing peer and not covered by the orderer signatures.
+// Hence, TLCC validates the transactions of the namespaces with an endorsement policy (see WithEndorsementPolicy)
+// itself, i.e., it checks the endorsement policy, the read versions (MVCC) and the uniqueness of the tx ID, and
+// ignores the flags of these transactions. For all other namespaces, it trusts the flags of the peer.
+type TrustedLedger struct {
+	mutex sync.RWMutex
+	csp   crypto.CSP
+
+	channelId      string
+	channelHash    []byte
+	height         uint64
+	lastHeaderHash []byte
+	config         *channelConfig
+
+	// endorsementPolicies are the policies of the validated namespaces; they are compiled against the MSPs of
+	// the channel config, thus, they are updated with the config
+	endorsementPolicies map[string]*common.SignaturePolicyEnvelope
+	policies            map[string]policies.Policy
+	// txIds are the IDs of the valid transactions of the validated namespaces
+	txIds map[string]bool
+
+	// state keeps the value hash and the version of the last write of every key, per namespace
+	state map[string]map[string]*stateEntry
+	views *viewCache
+
+	// escrows are the state keys handed over for enclave upgrades, per chaincode (see Handle); with an escrow store,
+	// they are persisted, sealed with the sealing key
+	escrows     map[string]*escrow
+	escrowStore EscrowStore
+	sealingKey  []byte
+}
+
+type stateEntry struct {
+	// hash is the hash of the value with the default FPC hash algorithm, or nil if the key was deleted
+	hash  []byte
+	block uint64
+	tx    uint64
+}
+
+type Option func(l *TrustedLedger)
+
+// WithMinSignatures sets the number of distinct orderer signatures required for a block; the default is 1,
+// which matches the BlockValidation policy of a raft ordering service
+func WithMinSignatures(n int) Option {
+	return func(l *TrustedLedger) {
+		if n < 1 {
+			panic(fmt.Sprintf("invalid number of orderer signatures %d", n))
+		}
+		l.config.orderers.minSignatures = n
+	}
+}
+
+// WithEndorsementPolicy lets TLCC validate the transactions of a namespace, e.g., of an FPC chaincode or ERCC,
+// with the given endorsement policy instead of trusting the validation flags of the peer. The policy must be the
+// endorsement policy of the chaincode definition; as it is part of the trust anchor of TLCC, like the genesis
+// block, it must be obtained from a trusted source.
+func WithEndorsementPolicy(namespace string, policy *common.SignaturePolicyEnvelope) Option {
+	return func(l *TrustedLedger) {
+		if policy == nil {
+			panic(fmt.Sprintf("invalid endorsement policy for namespace %s", namespace))
+		}
+		l.endorsementPolicies[namespace] = policy
+	}
+}
+
+// WithMaxViews sets the number of transaction contexts for which TLCC keeps a consistent ledger view
+func WithMaxViews(n int) Option {
+	return func(l *TrustedLedger) {
+		if n < 1 {
+			panic(fmt.Sprintf("invalid number of views %d", n))
+		}
+		l.views = newViewCache(n)
+	}
+}
+
+// WithEscrowStore lets TLCC persist the chaincode keys escrowed for enclave upgrades in the given store, so that an
+// upgrade can be completed after TLCC restarted. The escrows are sealed with the given AES key, which must be kept
+// secret from the peer and must be the same after a restart, e.g., derived from the sealing key of the TLCC enclave.
+func WithEscrowStore(store EscrowStore, sealingKey []byte) Option {
+	return func(l *TrustedLedger) {
+		if store == nil {
+			panic("invalid escrow store")
+		}
+		if len(sealingKey) != crypto.SymKeyLength {
+			panic(fmt.Sprintf("invalid sealing key length %d, expected %d", len(sealingKey), crypto.SymKeyLength))
+		}
+		l.escrowStore = store
+		l.sealingKey = sealingKey
+	}
+}
+
+// New returns a trusted ledger which is initialized with the genesis block of a channel. The genesis block is
+// the trust anchor of the ledger, thus, it must be obtained from a trusted source; its hash is returned by ChannelHash.
+func New(genesis *common.Block, options ...Option) (*TrustedLedger, error) {
+	if genesis.GetHeader().GetNumber() != 0 {
+		return nil, fmt.Errorf("block %d is not a genesis block", genesis.GetHeader().GetNumber())
+	}
+	if !bytes.Equal(genesis.GetHeader().GetDataHash(), protoutil.BlockDataHash(genesis.GetData())) {
+		return nil, fmt.Errorf("invalid data hash of genesis block")
+	}
+
+	channelId, config, err := parseConfigBlock(genesis)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid genesis block")
+	}
+
+	l := &TrustedLedger{
+		csp:                 crypto.GetDefaultCSP(),
+		channelId:           channelId,
+		channelHash:         protoutil.BlockHeaderHash(genesis.GetHeader()),
+		height:              1,
+		lastHeaderHash:      protoutil.BlockHeaderHash(genesis.GetHeader()),
+		config:              config,
+		endorsementPolicies: make(map[string]*common.SignaturePolicyEnvelope),
+		txIds:               make(map[string]bool),
+		state:               make(map[string]map[string]*stateEntry),
+		views:               newViewCache(defaultMaxViews),
+		escrows:             make(map[string]*escrow),
+	}
+	for _, o := range options {
+		o(l)
+	}
+	if l.policies, err = l.compilePolicies(config); err != nil {
+		return nil, err
+	}
+
+	logger.Infof("trusted ledger joined channel %s", channelId)
+	return l, nil
+}
+
+func (l *TrustedLedger) ChannelID() string {
+	return l.channelId
+}
+
+// ChannelHash returns the SHA-256 hash of the genesis block header
+func (l *TrustedLedger) ChannelHash() []byte {
+	return l.channelHash
+}
+
+// Height returns the number of blocks committed to the trusted ledger, including the genesis block
+func (l *TrustedLedger) Height() uint64 {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.height
+}
+
+// CommitBlock verifies the next block of the channel and applies the writes of its valid transactions
+func (l *TrustedLedger) CommitBlock(block *common.Block) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	header := block.GetHeader()
+	if header.GetNumber() != l.height {
+		return fmt.Errorf("unexpected block %d, expected block %d", header.GetNumber(), l.height)
+	}
+	if !bytes.Equal(header.GetPreviousHash(), l.lastHeaderHash) {
+		return fmt.Errorf("block %d does not extend the hash chain", header.GetNumber())
+	}
+	if !bytes.Equal(header.GetDataHash(), protoutil.BlockDataHash(block.GetData())) {
+		return fmt.Errorf("invalid data hash of block %d", header.GetNumber())
+	}
+
+	verify := protoutil.BlockSignatureVerifier(false, nil, l.config.orderers)
+	if err := verify(header, block.GetMetadata()); err != nil {
+		return errors.Wrapf(err, "invalid orderer signatures of block %d", header.GetNumber())
+	}
+
+	// note that the validation flags are set by the committing peer and not covered by the orderer signatures;
+	// they are only trusted for transactions which TLCC does not validate itself
+	data := block.GetData().GetData()
+	filter := block.GetMetadata().GetMetadata()
+	if len(filter) <= int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) || len(filter[common.BlockMetadataIndex_TRANSACTIONS_FILTER]) != len(data) {
+		return fmt.Errorf("block %d has no valid transaction filter", header.GetNumber())
+	}
+	flags := filter[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+
+	// validate the block before changing the state, so an invalid block is not applied partially; the writes of
+	// the valid transactions are collected in an update, against which later transactions of the block are validated
+	u := newUpdate(l.state)
+	var config *channelConfig
+	for i, envBytes := range data {
+		env, err := protoutil.UnmarshalEnvelope(envBytes)
+		if err != nil {
+			return errors.Wrapf(err, "invalid transaction %d in block %d", i, header.GetNumber())
+		}
+		payload, err := protoutil.UnmarshalPayload(env.GetPayload())
+		if err != nil {
+			return errors.Wrapf(err, "invalid transaction %d in block %d", i, header.GetNumber())
+		}
+		channelHeader, err := protoutil.UnmarshalChannelHeader(payload.GetHeader().GetChannelHeader())
+		if err != nil {
+			return errors.Wrapf(err, "invalid transaction %d in block %d", i, header.GetNumber())
+		}
+		if channelHeader.GetChannelId() != l.channelId {
+			return fmt.Errorf("transaction %d in block %d belongs to channel %s", i, header.GetNumber(), channelHeader.GetChannelId())
+		}
+
+		switch common.HeaderType(channelHeader.GetType()) {
+		case common.HeaderType_ENDORSER_TRANSACTION:
+			flaggedValid := peer.TxValidationCode(flags[i]) == peer.TxValidationCode_VALID
+			tx, err := parseTransaction(payload.GetData())
+			if err != nil {
+				if !flaggedValid {
+					continue
+				}
+				return errors.Wrapf(err, "invalid transaction %d in block %d", i, header.GetNumber())
+			}
+
+			valid := flaggedValid
+			if l.validates(tx) {
+				if err := l.validate(tx, channelHeader.GetTxId(), u); err != nil {
+					logger.Debugf("transaction %d in block %d is invalid: %s", i, header.GetNumber(), err)
+					valid = false
+				} else {
+					valid = true
+				}
+				if valid != flaggedValid {
+					logger.Warningf("validation flag of transaction %d in block %d does not match the validation of TLCC", i, header.GetNumber())
+				}
+			}
+			if !valid {
+				continue
+			}
+
+			txWrites, err := tx.writes(crypto.Hasher(l.csp, crypto.DefaultHashAlgorithm))
+			if err != nil {
+				return errors.Wrapf(err, "invalid transaction %d in block %d", i, header.GetNumber())
+			}
+			for _, nsWrites := range txWrites {
+				u.apply(nsWrites, header.GetNumber(), uint64(i))
+			}
+			if l.validates(tx) {
+				u.txIds[channelHeader.GetTxId()] = true
+			}
+		case common.HeaderType_CONFIG:
+			c, err := parseConfig(payload.GetData())
+			if err != nil {
+				return errors.Wrapf(err, "invalid config block %d", header.GetNumber())
+			}
+			c.orderers.minSignatures = l.config.orderers.minSignatures
+			config = c
+		}
+	}
+
+	var updatedPolicies map[string]policies.Policy
+	if config != nil {
+		var err error
+		if updatedPolicies, err = l.compilePolicies(config); err != nil {
+			return errors.Wrapf(err, "invalid config block %d", header.GetNumber())
+		}
+	}
+
+	u.commit(l.state)
+	for txId := range u.txIds {
+		l.txIds[txId] = true
+	}
+	if config != nil {
+		logger.Infof("config of channel %s updated by config block %d", l.channelId, header.GetNumber())
+		l.config = config
+		l.policies = updatedPolicies
+	}
+	l.height++
+	l.lastHeaderHash = protoutil.BlockHeaderHash(header)
+
+	logger.Debugf("committed block %d of channel %s", header.GetNumber(), l.channelId)
+	return nil
+}
+
+// compilePolicies returns the endorsement policies of the validated namespaces for the MSPs of a channel config
+func (l *TrustedLedger) compilePolicies(config *channelConfig) (map[string]policies.Policy, error) {
+	provider := &cauthdsl.EnvelopeBasedPolicyProvider{Deserializer: config.msps}
+	compiled := make(map[string]policies.Policy, len(l.endorsementPolicies))
+	for namespace, envelope := range l.endorsementPolicies {
+		policy, err := provider.NewPolicy(envelope)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid endorsement policy for namespace %s", namespace)
+		}
+		compiled[namespace] = policy
+	}
+	return compiled, nil
+}
+
+// validates returns true if a transaction writes to or reads from a namespace with an endorsement policy
+func (l *TrustedLedger) validates(tx *transaction) bool {
+	for _, a := range tx.actions {
+		for _, ns := range a.rwsets {
+			if _, ok := l.policies[ns.namespace]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validate checks a transaction as the committing peer does: the tx ID must be unique, the endorsements of every
+// action must satisfy the endorsement policies of its validated namespaces, and all reads must be current
+func (l *TrustedLedger) validate(tx *transaction, txId string, u *update) error {
+	if txId == "" {
+		return fmt.Errorf("transaction has no tx ID")
+	}
+	if l.txIds[txId] || u.txIds[txId] {
+		return fmt.Errorf("duplicate tx ID %s", txId)
+	}
+
+	for _, a := range tx.actions {
+		for _, ns := range a.rwsets {
+			policy, ok := l.policies[ns.namespace]
+			if !ok {
+				continue
+			}
+			if err := policy.EvaluateSignedData(a.signedData()); err != nil {
+				return errors.Wrapf(err, "endorsement policy of namespace %s not satisfied", ns.namespace)
+			}
+			// TLCC keeps no key order, thus, it cannot check for phantom reads
+			if len(ns.rwset.GetRangeQueriesInfo()) > 0 {
+				return fmt.Errorf("range queries in namespace %s are not supported", ns.namespace)
+			}
+		}
+	}
+
+	for _, a := range tx.actions {
+		for _, ns := range a.rwsets {
+			for _, r := range ns.rwset.GetReads() {
+				if !versionMatches(u.lookup(ns.namespace, r.GetKey()), r.GetVersion()) {
+					return fmt.Errorf("read of key %s in namespace %s is not current", r.GetKey(), ns.namespace)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// versionMatches returns true if a read version is the version of a state entry; absent and deleted keys are
+// read without version
+func versionMatches(entry *stateEntry, version *kvrwset.Version) bool {
+	if entry == nil || entry.hash == nil {
+		return version == nil
+	}
+	return version != nil && version.GetBlockNum() == entry.block && version.GetTxNum() == entry.tx
+}
+
+// Follow commits the blocks from the source until the context is done or a block is invalid
+func (l *TrustedLedger) Follow(ctx context.Context, source BlockSource) error {
+	for {
+		block, err := source.Next(ctx)
+		if err != nil {
+			return err
+		}
+		if err := l.CommitBlock(block); err != nil {
+			return err
+		}
+	}
+}
+
+// update collects the writes of the valid transactions of a block
+type update struct {
+	state  map[string]map[string]*stateEntry
+	writes map[string]map[string]*stateEntry
+	txIds  map[string]bool
+}
+
+func newUpdate(state map[string]map[string]*stateEntry) *update {
+	return &update{state: state, writes: make(map[string]map[string]*stateEntry), txIds: make(map[string]bool)}
+}
+
+// lookup returns the state entry of a key including the writes of the update
+func (u *update) lookup(namespace, key string) *stateEntry {
+	if entry, ok := u.writes[namespace][key]; ok {
+		return entry
+	}
+	return u.state[namespace][key]
+}
+
+func (u *update) apply(w *namespaceWrites, block, tx uint64) {
+	ns, ok := u.writes[w.namespace]
+	if !ok {
+		ns = make(map[string]*stateEntry)
+		u.writes[w.namespace] = ns
+	}
+
+	for i, kv := range w.writes {
+		ns[kv.GetKey()] = &stateEntry{hash: w.valueHashes[i], block: block, tx: tx}
+	}
+}
+
+func (u *update) commit(state map[string]map[string]*stateEntry) {
+	for namespace, writes := range u.writes {
+		ns, ok := state[namespace]
+		if !ok {
+			ns = make(map[string]*stateEntry)
+			state[namespace] = ns
+		}
+		for key, entry := range writes {
+			ns[key] = entry
+		}
+	}
+}
+
+// lookup returns the state entry of a key, and an error if the key changed after the block pinned by the view
+// of the transaction context
+func (l *TrustedLedger) lookup(txContext []byte, namespace, key string) (*stateEntry, error) {
+	entry := l.state[namespace][key]
+
+	if len(txContext) == 0 {
+		return entry, nil
+	}
+
+	lastBlock := l.views.pin(string(txContext), l.height-1)
+	if entry != nil && entry.block > lastBlock {
+		return nil, fmt.Errorf("key %s changed in block %d after the ledger view of the transaction at block %d", key, entry.block, lastBlock)
+	}
+	return entry, nil
+}
+
+// viewCache pins the last block of the ledger view of a transaction context when it is first used.
+// The oldest contexts are evicted when the cache is full.
+type viewCache struct {
+	max   int
+	views map[string]uint64
+	order []string
+}
+
+func newViewCache(max int) *viewCache {
+	return &viewCache{max: max, views: make(map[string]uint64)}
+}
+
+func (c *viewCache) pin(txContext string, lastBlock uint64) uint64 {
+	if pinned, ok := c.views[txContext]; ok {
+		return pinned
+	}
+
+	if len(c.order) == c.max {
+		delete(c.views, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.views[txContext] = lastBlock
+	c.order = append(c.order, txContext)
+	return lastBlock
+}
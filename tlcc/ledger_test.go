@@ -0,0 +1,591 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tlcc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	//lint:ignore SA1019 the fabric protos are generated with the v1 API
+	protoV1 "github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-private-chaincode/internal/protos"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/common/policydsl"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	testChannel    = "mychannel"
+	testOrdererMSP = "OrdererMSP"
+)
+
+type testIdentity struct {
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	caCert []byte
+	cert   []byte
+	key    *ecdsa.PrivateKey
+}
+
+// newTestIdentity returns an identity issued by a new CA
+func newTestIdentity(t *testing.T, name, ou string) *testIdentity {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca." + name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	ca := &testIdentity{ca: caCert, caKey: caKey, caCert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})}
+	return ca.issue(t, name, ou)
+}
+
+// issue returns another identity issued by the CA of the identity
+func (id *testIdentity) issue(t *testing.T, name, ou string) *testIdentity {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if ou != "" {
+		template.Subject.OrganizationalUnit = []string{ou}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, id.ca, &key.PublicKey, id.caKey)
+	require.NoError(t, err)
+
+	return &testIdentity{
+		ca:     id.ca,
+		caKey:  id.caKey,
+		caCert: id.caCert,
+		cert:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:    key,
+	}
+}
+
+// revoke returns a CRL of the CA of the identity which revokes the given identities
+func (id *testIdentity) revoke(t *testing.T, revoked ...*testIdentity) []byte {
+	template := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	for _, r := range revoked {
+		block, _ := pem.Decode(r.cert)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(t, err)
+		template.RevokedCertificates = append(template.RevokedCertificates, pkix.RevokedCertificate{SerialNumber: cert.SerialNumber, RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, id.ca, id.caKey)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+// sign returns a low-S ECDSA signature, as required by the fabric MSP
+func (id *testIdentity) sign(t *testing.T, msg []byte) []byte {
+	digest := sha256.Sum256(msg)
+	r, s, err := ecdsa.Sign(rand.Reader, id.key, digest[:])
+	require.NoError(t, err)
+	halfOrder := new(big.Int).Rsh(id.key.Params().N, 1)
+	if s.Cmp(halfOrder) > 0 {
+		s.Sub(id.key.Params().N, s)
+	}
+	signature, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	require.NoError(t, err)
+	return signature
+}
+
+type testOrderer struct {
+	*testIdentity
+	// crls are the CRLs of the orderer organization
+	crls [][]byte
+	// orgs are the application organizations of the channel
+	orgs map[string]*testIdentity
+}
+
+func newTestOrderer(t *testing.T, orgs ...string) *testOrderer {
+	o := &testOrderer{testIdentity: newTestIdentity(t, "orderer", "orderer"), orgs: make(map[string]*testIdentity)}
+	for _, org := range orgs {
+		o.orgs[org] = newTestIdentity(t, "peer."+org, "")
+	}
+	return o
+}
+
+func envelope(headerType common.HeaderType, data []byte) []byte {
+	return txEnvelope(headerType, "", data)
+}
+
+func txEnvelope(headerType common.HeaderType, txId string, data []byte) []byte {
+	return protoutil.MarshalOrPanic(&common.Envelope{
+		Payload: protoutil.MarshalOrPanic(&common.Payload{
+			Header: &common.Header{
+				ChannelHeader: protoutil.MarshalOrPanic(&common.ChannelHeader{Type: int32(headerType), ChannelId: testChannel, TxId: txId}),
+			},
+			Data: data,
+		}),
+	})
+}
+
+func (o *testOrderer) configTx() []byte {
+	mspValue := func(config *msp.FabricMSPConfig) map[string]*common.ConfigValue {
+		mspConfig := &msp.MSPConfig{Config: protoutil.MarshalOrPanic(config)}
+		return map[string]*common.ConfigValue{"MSP": {Value: protoutil.MarshalOrPanic(mspConfig)}}
+	}
+
+	// the orderer organization identifies its orderers by NodeOUs
+	ordererMSP := &msp.FabricMSPConfig{
+		Name:           testOrdererMSP,
+		RootCerts:      [][]byte{o.caCert},
+		RevocationList: o.crls,
+		FabricNodeOus: &msp.FabricNodeOUs{
+			Enable:              true,
+			ClientOuIdentifier:  &msp.FabricOUIdentifier{OrganizationalUnitIdentifier: "client"},
+			PeerOuIdentifier:    &msp.FabricOUIdentifier{OrganizationalUnitIdentifier: "peer"},
+			AdminOuIdentifier:   &msp.FabricOUIdentifier{OrganizationalUnitIdentifier: "admin"},
+			OrdererOuIdentifier: &msp.FabricOUIdentifier{OrganizationalUnitIdentifier: "orderer"},
+		},
+	}
+	groups := map[string]*common.ConfigGroup{
+		"Orderer": {
+			Groups: map[string]*common.ConfigGroup{
+				"OrdererOrg": {Values: mspValue(ordererMSP)},
+			},
+		},
+	}
+	if len(o.orgs) > 0 {
+		application := &common.ConfigGroup{Groups: make(map[string]*common.ConfigGroup)}
+		for name, org := range o.orgs {
+			application.Groups[name] = &common.ConfigGroup{
+				Values: mspValue(&msp.FabricMSPConfig{Name: name, RootCerts: [][]byte{org.caCert}, Admins: [][]byte{org.cert}}),
+			}
+		}
+		groups["Application"] = application
+	}
+	config := &common.ConfigEnvelope{
+		Config: &common.Config{ChannelGroup: &common.ConfigGroup{Groups: groups}},
+	}
+	return envelope(common.HeaderType_CONFIG, protoutil.MarshalOrPanic(config))
+}
+
+func (o *testOrderer) genesis() *common.Block {
+	block := protoutil.NewBlock(0, nil)
+	block.Data.Data = [][]byte{o.configTx()}
+	block.Header.DataHash = protoutil.BlockDataHash(block.Data)
+	return block
+}
+
+// block returns a signed block with the given transactions and validation flags
+func (o *testOrderer) block(t *testing.T, prev *common.Block, txs [][]byte, flags []peer.TxValidationCode) *common.Block {
+	block := protoutil.NewBlock(prev.Header.Number+1, protoutil.BlockHeaderHash(prev.Header))
+	block.Data.Data = txs
+	block.Header.DataHash = protoutil.BlockDataHash(block.Data)
+
+	filter := make([]byte, len(flags))
+	for i, f := range flags {
+		filter[i] = byte(f)
+	}
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = filter
+
+	signBlock(t, block, o.testIdentity)
+	return block
+}
+
+// signBlock replaces the signatures of a block by signatures of the given identities of the orderer organization
+func signBlock(t *testing.T, block *common.Block, signers ...*testIdentity) {
+	metadata := &common.Metadata{}
+	for _, signer := range signers {
+		sigHeader := protoutil.MarshalOrPanic(&common.SignatureHeader{
+			Creator: protoutil.MarshalOrPanic(&msp.SerializedIdentity{Mspid: testOrdererMSP, IdBytes: signer.cert}),
+		})
+		signature := signer.sign(t, append(sigHeader, protoutil.BlockHeaderBytes(block.Header)...))
+		metadata.Signatures = append(metadata.Signatures, &common.MetadataSignature{SignatureHeader: sigHeader, Signature: signature})
+	}
+	block.Metadata.Metadata[common.BlockMetadataIndex_SIGNATURES] = protoutil.MarshalOrPanic(metadata)
+}
+
+func endorserTx(namespace string, writes ...*kvrwset.KVWrite) []byte {
+	txRWSet := &rwset.TxReadWriteSet{
+		NsRwset: []*rwset.NsReadWriteSet{{
+			Namespace: namespace,
+			Rwset:     protoutil.MarshalOrPanic(&kvrwset.KVRWSet{Writes: writes}),
+		}},
+	}
+	prp := &peer.ProposalResponsePayload{
+		Extension: protoutil.MarshalOrPanic(&peer.ChaincodeAction{Results: protoutil.MarshalOrPanic(txRWSet)}),
+	}
+	tx := &peer.Transaction{
+		Actions: []*peer.TransactionAction{{
+			Payload: protoutil.MarshalOrPanic(&peer.ChaincodeActionPayload{
+				Action: &peer.ChaincodeEndorsedAction{ProposalResponsePayload: protoutil.MarshalOrPanic(prp)},
+			}),
+		}},
+	}
+	return envelope(common.HeaderType_ENDORSER_TRANSACTION, protoutil.MarshalOrPanic(tx))
+}
+
+// endorsedTx returns a transaction with the given rwset for a namespace which is endorsed by the peers of the given orgs
+func (o *testOrderer) endorsedTx(t *testing.T, txId, namespace string, kvRWSet *kvrwset.KVRWSet, orgs ...string) []byte {
+	txRWSet := &rwset.TxReadWriteSet{
+		NsRwset: []*rwset.NsReadWriteSet{{Namespace: namespace, Rwset: protoutil.MarshalOrPanic(kvRWSet)}},
+	}
+	prpBytes := protoutil.MarshalOrPanic(&peer.ProposalResponsePayload{
+		Extension: protoutil.MarshalOrPanic(&peer.ChaincodeAction{Results: protoutil.MarshalOrPanic(txRWSet)}),
+	})
+
+	var endorsements []*peer.Endorsement
+	for _, name := range orgs {
+		endorser := protoutil.MarshalOrPanic(&msp.SerializedIdentity{Mspid: name, IdBytes: o.orgs[name].cert})
+		endorsements = append(endorsements, &peer.Endorsement{
+			Endorser:  endorser,
+			Signature: o.orgs[name].sign(t, append(append([]byte{}, prpBytes...), endorser...)),
+		})
+	}
+
+	tx := &peer.Transaction{
+		Actions: []*peer.TransactionAction{{
+			Payload: protoutil.MarshalOrPanic(&peer.ChaincodeActionPayload{
+				Action: &peer.ChaincodeEndorsedAction{ProposalResponsePayload: prpBytes, Endorsements: endorsements},
+			}),
+		}},
+	}
+	return txEnvelope(common.HeaderType_ENDORSER_TRANSACTION, txId, protoutil.MarshalOrPanic(tx))
+}
+
+func getMetadata(t *testing.T, l *TrustedLedger, txContext []byte, namespace, key string) ([]byte, error) {
+	requestBytes, err := proto.Marshal(&protos.Request{
+		TxContext: txContext,
+		Request:   &protos.Request_Metadata{Metadata: &protos.GetMetadataRequest{Namespace: namespace, Key: key}},
+	})
+	require.NoError(t, err)
+
+	responseBytes, err := l.Request(requestBytes)
+	if err != nil {
+		return nil, err
+	}
+	response := &protos.Response{}
+	require.NoError(t, proto.Unmarshal(responseBytes, response))
+	return response.GetMetadata().GetHash(), nil
+}
+
+func valueHash(value []byte) []byte {
+	h := sha256.Sum256(value)
+	return h[:]
+}
+
+func TestNew(t *testing.T) {
+	o := newTestOrderer(t)
+	genesis := o.genesis()
+
+	l, err := New(genesis)
+	require.NoError(t, err)
+	assert.Equal(t, testChannel, l.ChannelID())
+	assert.Equal(t, protoutil.BlockHeaderHash(genesis.Header), l.ChannelHash())
+	assert.EqualValues(t, 1, l.Height())
+
+	// not a genesis block
+	_, err = New(o.block(t, genesis, nil, nil))
+	assert.Error(t, err)
+
+	// not a config block
+	block := protoutil.NewBlock(0, nil)
+	block.Data.Data = [][]byte{endorserTx("cc")}
+	block.Header.DataHash = protoutil.BlockDataHash(block.Data)
+	_, err = New(block)
+	assert.Error(t, err)
+
+	// tampered data
+	genesis.Data.Data[0] = endorserTx("cc")
+	_, err = New(genesis)
+	assert.Error(t, err)
+}
+
+func TestCommitBlock(t *testing.T) {
+	o := newTestOrderer(t)
+	genesis := o.genesis()
+	l, err := New(genesis)
+	require.NoError(t, err)
+
+	// valid and invalid transactions
+	block1 := o.block(t, genesis, [][]byte{
+		endorserTx("cc", &kvrwset.KVWrite{Key: "a", Value: []byte("value a")}),
+		endorserTx("cc", &kvrwset.KVWrite{Key: "b", Value: []byte("value b")}),
+	}, []peer.TxValidationCode{peer.TxValidationCode_VALID, peer.TxValidationCode_MVCC_READ_CONFLICT})
+	require.NoError(t, l.CommitBlock(block1))
+	assert.EqualValues(t, 2, l.Height())
+
+	h, err := getMetadata(t, l, nil, "cc", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, valueHash([]byte("value a")), h)
+
+	h, err = getMetadata(t, l, nil, "cc", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, absentHash, h)
+
+	// the block is committed already
+	assert.Error(t, l.CommitBlock(block1))
+
+	// delete
+	block2 := o.block(t, block1, [][]byte{
+		endorserTx("cc", &kvrwset.KVWrite{Key: "a", IsDelete: true}),
+	}, []peer.TxValidationCode{peer.TxValidationCode_VALID})
+
+	// tampered data
+	tampered := protoV1.Clone(block2).(*common.Block)
+	tampered.Data.Data[0] = endorserTx("cc", &kvrwset.KVWrite{Key: "a", Value: []byte("forged")})
+	assert.Error(t, l.CommitBlock(tampered))
+
+	// missing transaction filter
+	tampered = protoV1.Clone(block2).(*common.Block)
+	tampered.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = nil
+	assert.Error(t, l.CommitBlock(tampered))
+
+	// missing signature
+	tampered = protoV1.Clone(block2).(*common.Block)
+	tampered.Metadata.Metadata[common.BlockMetadataIndex_SIGNATURES] = nil
+	assert.Error(t, l.CommitBlock(tampered))
+
+	// signed by an orderer of another organization
+	other := newTestOrderer(t)
+	assert.Error(t, l.CommitBlock(other.block(t, block1, block2.Data.Data, []peer.TxValidationCode{peer.TxValidationCode_VALID})))
+
+	// not extending the hash chain
+	assert.Error(t, l.CommitBlock(o.block(t, genesis, block2.Data.Data, []peer.TxValidationCode{peer.TxValidationCode_VALID})))
+
+	require.NoError(t, l.CommitBlock(block2))
+	h, err = getMetadata(t, l, nil, "cc", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, absentHash, h)
+
+	// more signatures required
+	l, err = New(genesis, WithMinSignatures(2))
+	require.NoError(t, err)
+	assert.Error(t, l.CommitBlock(block1))
+}
+
+func TestOrdererSignatures(t *testing.T) {
+	o := newTestOrderer(t)
+	orderer2 := o.issue(t, "orderer2", "orderer")
+	revoked := o.issue(t, "orderer3", "orderer")
+	o.crls = [][]byte{o.revoke(t, revoked)}
+	genesis := o.genesis()
+
+	l, err := New(genesis, WithMinSignatures(2))
+	require.NoError(t, err)
+	block1 := o.block(t, genesis, nil, nil)
+
+	// each orderer is counted once, even if it signs with several certificates
+	signBlock(t, block1, o.testIdentity, o.testIdentity)
+	assert.Error(t, l.CommitBlock(block1))
+	signBlock(t, block1, o.testIdentity, o.issue(t, "orderer", "orderer"))
+	assert.Error(t, l.CommitBlock(block1))
+
+	// only orderers of the orderer organization count
+	signBlock(t, block1, o.testIdentity, o.issue(t, "peer0", "peer"))
+	assert.Error(t, l.CommitBlock(block1))
+	signBlock(t, block1, o.testIdentity, o.issue(t, "admin", "admin"))
+	assert.Error(t, l.CommitBlock(block1))
+
+	// revoked orderers do not count
+	signBlock(t, block1, o.testIdentity, revoked)
+	assert.Error(t, l.CommitBlock(block1))
+
+	signBlock(t, block1, o.testIdentity, orderer2)
+	require.NoError(t, l.CommitBlock(block1))
+
+	// the orderer organization must identify its orderers by NodeOUs
+	mspConfig := &msp.MSPConfig{Config: protoutil.MarshalOrPanic(&msp.FabricMSPConfig{Name: testOrdererMSP, RootCerts: [][]byte{o.caCert}, Admins: [][]byte{o.cert}})}
+	_, err = parseOrderers(map[string]*common.ConfigGroup{
+		"Orderer": {
+			Groups: map[string]*common.ConfigGroup{
+				"OrdererOrg": {Values: map[string]*common.ConfigValue{"MSP": {Value: protoutil.MarshalOrPanic(mspConfig)}}},
+			},
+		},
+	})
+	assert.EqualError(t, err, "msp of orderer organization OrdererOrg does not define an orderer OU")
+}
+
+func TestRequest(t *testing.T) {
+	o := newTestOrderer(t)
+	genesis := o.genesis()
+	l, err := New(genesis, WithMaxViews(1))
+	require.NoError(t, err)
+
+	credentialsKey := compositeKey(credentialsObjectType, "cc", "enclave1")
+	block1 := o.block(t, genesis, [][]byte{
+		endorserTx("cc", &kvrwset.KVWrite{Key: "a", Value: []byte("value a")}),
+		endorserTx(ErccNamespace, &kvrwset.KVWrite{Key: credentialsKey, Value: []byte("credentials")}),
+	}, []peer.TxValidationCode{peer.TxValidationCode_VALID, peer.TxValidationCode_VALID})
+	require.NoError(t, l.CommitBlock(block1))
+
+	// can endorse
+	canEndorse := func(enclaveId string) bool {
+		requestBytes, err := proto.Marshal(&protos.Request{
+			Request: &protos.Request_CanEndorse{CanEndorse: &protos.CanEndorseRequest{ChaincodeId: "cc", EnclaveId: enclaveId}},
+		})
+		require.NoError(t, err)
+		responseBytes, err := l.Request(requestBytes)
+		require.NoError(t, err)
+		response := &protos.Response{}
+		require.NoError(t, proto.Unmarshal(responseBytes, response))
+		return response.GetCanEndorse().GetIsValid()
+	}
+	assert.True(t, canEndorse("enclave1"))
+	assert.False(t, canEndorse("enclave2"))
+
+	// the view of a tx context is pinned at its first request
+	txContext := []byte("tx1")
+	h, err := getMetadata(t, l, txContext, "cc", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, valueHash([]byte("value a")), h)
+
+	block2 := o.block(t, block1, [][]byte{
+		endorserTx("cc", &kvrwset.KVWrite{Key: "a", Value: []byte("new value a")}),
+	}, []peer.TxValidationCode{peer.TxValidationCode_VALID})
+	require.NoError(t, l.CommitBlock(block2))
+
+	_, err = getMetadata(t, l, txContext, "cc", "a")
+	assert.Error(t, err)
+
+	// unchanged keys can still be read
+	_, err = getMetadata(t, l, txContext, "cc", "b")
+	assert.NoError(t, err)
+
+	// a new tx context evicts the old view
+	h, err = getMetadata(t, l, []byte("tx2"), "cc", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, valueHash([]byte("new value a")), h)
+
+	h, err = getMetadata(t, l, txContext, "cc", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, valueHash([]byte("new value a")), h)
+
+	// unsupported and invalid requests
+	requestBytes, err := proto.Marshal(&protos.Request{
+		Request: &protos.Request_ValidateIdentity{ValidateIdentity: &protos.ValidateIdentityRequest{}},
+	})
+	require.NoError(t, err)
+	_, err = l.Request(requestBytes)
+	assert.Error(t, err)
+
+	_, err = l.Request([]byte("invalid"))
+	assert.Error(t, err)
+}
+
+func TestValidation(t *testing.T) {
+	o := newTestOrderer(t, "Org1MSP", "Org2MSP")
+	genesis := o.genesis()
+	l, err := New(genesis, WithEndorsementPolicy("fpc", policydsl.SignedByMspMember("Org1MSP")))
+	require.NoError(t, err)
+
+	valid := peer.TxValidationCode_VALID
+	invalid := peer.TxValidationCode_ENDORSEMENT_POLICY_FAILURE
+	write := func(key, value string) *kvrwset.KVRWSet {
+		return &kvrwset.KVRWSet{Writes: []*kvrwset.KVWrite{{Key: key, Value: []byte(value)}}}
+	}
+	hash := func(namespace, key string) []byte {
+		h, err := getMetadata(t, l, nil, namespace, key)
+		require.NoError(t, err)
+		return h
+	}
+
+	// the flags of the peer are ignored for validated namespaces, but not for others
+	block1 := o.block(t, genesis, [][]byte{
+		o.endorsedTx(t, "tx1", "fpc", write("a", "value a"), "Org1MSP"),
+		o.endorsedTx(t, "tx2", "fpc", write("b", "value b"), "Org2MSP"),
+		o.endorsedTx(t, "tx3", "fpc", write("c", "value c")),
+		o.endorsedTx(t, "tx4", "cc", write("a", "value a")),
+	}, []peer.TxValidationCode{invalid, valid, valid, invalid})
+	require.NoError(t, l.CommitBlock(block1))
+	assert.Equal(t, valueHash([]byte("value a")), hash("fpc", "a"))
+	assert.Equal(t, absentHash, hash("fpc", "b"))
+	assert.Equal(t, absentHash, hash("fpc", "c"))
+	assert.Equal(t, absentHash, hash("cc", "a"))
+
+	// endorsed by an identity which is not issued by the CA of the org
+	org1 := o.orgs["Org1MSP"]
+	o.orgs["Org1MSP"] = newTestIdentity(t, "peer.Org1MSP", "")
+	forged := o.endorsedTx(t, "tx5", "fpc", write("b", "forged"), "Org1MSP")
+	o.orgs["Org1MSP"] = org1
+
+	block2 := o.block(t, block1, [][]byte{forged}, []peer.TxValidationCode{valid})
+	require.NoError(t, l.CommitBlock(block2))
+	assert.Equal(t, absentHash, hash("fpc", "b"))
+
+	// reads must be current, also within a block
+	readA := func(version *kvrwset.Version, key, value string) *kvrwset.KVRWSet {
+		rwset := write(key, value)
+		rwset.Reads = []*kvrwset.KVRead{{Key: "a", Version: version}}
+		return rwset
+	}
+	current := &kvrwset.Version{BlockNum: 1, TxNum: 0}
+	block3 := o.block(t, block2, [][]byte{
+		o.endorsedTx(t, "tx6", "fpc", readA(nil, "b", "stale"), "Org1MSP"),
+		o.endorsedTx(t, "tx7", "fpc", readA(current, "a", "new value a"), "Org1MSP"),
+		o.endorsedTx(t, "tx8", "fpc", readA(current, "c", "conflict"), "Org1MSP"),
+		o.endorsedTx(t, "tx9", "fpc", readA(&kvrwset.Version{BlockNum: 3, TxNum: 1}, "d", "value d"), "Org1MSP"),
+	}, []peer.TxValidationCode{valid, valid, valid, valid})
+	require.NoError(t, l.CommitBlock(block3))
+	assert.Equal(t, valueHash([]byte("new value a")), hash("fpc", "a"))
+	assert.Equal(t, absentHash, hash("fpc", "b"))
+	assert.Equal(t, absentHash, hash("fpc", "c"))
+	assert.Equal(t, valueHash([]byte("value d")), hash("fpc", "d"))
+
+	// duplicate tx IDs, within a block and across blocks
+	block4 := o.block(t, block3, [][]byte{
+		o.endorsedTx(t, "tx1", "fpc", write("e", "value e"), "Org1MSP"),
+		o.endorsedTx(t, "tx10", "fpc", write("f", "value f"), "Org1MSP"),
+		o.endorsedTx(t, "tx10", "fpc", write("g", "value g"), "Org1MSP"),
+	}, []peer.TxValidationCode{valid, valid, valid})
+	require.NoError(t, l.CommitBlock(block4))
+	assert.Equal(t, absentHash, hash("fpc", "e"))
+	assert.Equal(t, valueHash([]byte("value f")), hash("fpc", "f"))
+	assert.Equal(t, absentHash, hash("fpc", "g"))
+
+	// range queries are not supported
+	rangeQuery := write("h", "value h")
+	rangeQuery.RangeQueriesInfo = []*kvrwset.RangeQueryInfo{{StartKey: "a", EndKey: "z"}}
+	block5 := o.block(t, block4, [][]byte{
+		o.endorsedTx(t, "tx11", "fpc", rangeQuery, "Org1MSP"),
+	}, []peer.TxValidationCode{valid})
+	require.NoError(t, l.CommitBlock(block5))
+	assert.Equal(t, absentHash, hash("fpc", "h"))
+
+	// an invalid endorsement policy
+	_, err = New(genesis, WithEndorsementPolicy("fpc", &common.SignaturePolicyEnvelope{}))
+	assert.Error(t, err)
+	assert.Panics(t, func() { _, _ = New(genesis, WithEndorsementPolicy("fpc", nil)) })
+}